@@ -1,7 +1,6 @@
 package client
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"net"
@@ -9,104 +8,1558 @@ import (
 	"simpleKV/resp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type IClient interface {
 	Close() error
 	Set(k string, v any) error
+	SetArgs(k string, v any, args SetArgs) (any, error)
 	Get(k string) (any, error)
 	Del(k string) error
+	MGet(keys ...string) ([]any, error)
+	MSet(values map[string]any) error
+	MSetNX(values map[string]any) (bool, error)
+	Incr(k string) (int64, error)
+	Decr(k string) (int64, error)
+	IncrBy(k string, incr int64) (int64, error)
+	DecrBy(k string, decr int64) (int64, error)
+	IncrByFloat(k string, incr float64) (float64, error)
+	Append(k, v string) (int64, error)
+	StrLen(k string) (int64, error)
+	GetRange(k string, start, end int) (string, error)
+	SetRange(k string, offset int, v string) (int64, error)
+	GetDel(k string) (any, error)
+	GetEx(k string, ttl time.Duration) (any, error)
+	LCS(k1, k2 string) (string, error)
+	SetBit(k string, offset int64, value int) (int64, error)
+	GetBit(k string, offset int64) (int64, error)
+	BitCount(k string) (int64, error)
+	BitPos(k string, bit int) (int64, error)
+	BitOp(op, dst string, keys ...string) (int64, error)
+	BitField(k string, args ...any) ([]*int64, error)
+	Expire(k string, ttl time.Duration) (bool, error)
+	TTL(k string) (time.Duration, error)
+	Persist(k string) (bool, error)
+	Exists(keys ...string) (int64, error)
+	Touch(keys ...string) (int64, error)
+	Type(k string) (string, error)
+	Rename(src, dst string) error
+	RenameNX(src, dst string) (bool, error)
+	Copy(src, dst string, replace bool) (bool, error)
+	RandomKey() (any, error)
+	DBSize() (int64, error)
+	HSet(k string, fields map[string]any) (int64, error)
+	HGet(k, field string) (any, error)
+	HMGet(k string, fields ...string) ([]any, error)
+	HDel(k string, fields ...string) (int64, error)
+	HGetAll(k string) (map[string]string, error)
+	HIncrBy(k, field string, incr int64) (int64, error)
+	HLen(k string) (int64, error)
+	HScan(k string, cursor int, matchPattern string, count int) (map[string]string, int, error)
+	LPush(k string, values ...any) (int64, error)
+	RPush(k string, values ...any) (int64, error)
+	LPop(k string) (any, error)
+	RPop(k string) (any, error)
+	LLen(k string) (int64, error)
+	LRange(k string, start, stop int) ([]string, error)
+	LMove(src, dst, from, to string) (any, error)
+	BLPop(timeout time.Duration, keys ...string) ([]string, error)
+	BRPop(timeout time.Duration, keys ...string) ([]string, error)
+	SAdd(k string, members ...any) (int64, error)
+	SRem(k string, members ...any) (int64, error)
+	SIsMember(k string, member any) (bool, error)
+	SMembers(k string) ([]string, error)
+	SCard(k string) (int64, error)
+	SInter(keys ...string) ([]string, error)
+	SUnion(keys ...string) ([]string, error)
+	SDiff(keys ...string) ([]string, error)
+	ZAdd(k string, members ...ScoredMember) (int64, error)
+	ZIncrBy(k string, incr float64, member string) (float64, error)
+	ZScore(k, member string) (float64, bool, error)
+	ZRank(k, member string) (int64, bool, error)
+	ZRange(k string, start, stop int) ([]string, error)
+	ZRangeWithScores(k string, start, stop int) ([]ScoredMember, error)
+	ZRangeByScore(k, min, max string) ([]string, error)
+	ZPopMin(k string, count int) ([]ScoredMember, error)
+	XAdd(k, id string, fieldValues ...any) (string, error)
+	XLen(k string) (int64, error)
+	XRange(k, start, end string) ([]StreamEntry, error)
+	XTrimMaxLen(k string, maxLen int) (int64, error)
+	XRead(args XReadArgs) (map[string][]StreamEntry, error)
+	XGroupCreate(k, group, id string, mkStream bool) error
+	XReadGroup(group, consumer string, args XReadArgs) (map[string][]StreamEntry, error)
+	XAck(k, group string, ids ...string) (int64, error)
+	XPending(k, group string) (int64, error)
+	XClaim(k, group, consumer string, minIdle time.Duration, ids ...string) ([]StreamEntry, error)
+	PFAdd(k string, elements ...any) (bool, error)
+	PFCount(keys ...string) (int64, error)
+	PFMerge(dst string, keys ...string) error
+	BFReserve(k string, errorRate float64, capacity int) error
+	BFAdd(k string, item any) (bool, error)
+	BFMAdd(k string, items ...any) ([]bool, error)
+	BFExists(k string, item any) (bool, error)
+	BFMExists(k string, items ...any) ([]bool, error)
+	BFInfo(k string) (map[string]int64, error)
+	CFReserve(k string, capacity int) error
+	CFAdd(k string, item any) (bool, error)
+	CFAddNX(k string, item any) (bool, error)
+	CFExists(k string, item any) (bool, error)
+	CFCount(k string, item any) (int64, error)
+	CFDel(k string, item any) (bool, error)
+	CFInfo(k string) (map[string]int64, error)
+	CMSInitByDim(k string, width, depth int) error
+	CMSIncrBy(k string, item any, increment int64) (int64, error)
+	CMSQuery(k string, items ...any) ([]int64, error)
+	CMSMerge(dst string, keys []string, weights []int64) error
+	TopKReserve(k string, topk int) error
+	TopKAdd(k string, items ...any) ([]any, error)
+	TopKList(k string) ([]string, error)
+	TDigestCreate(k string, compression int) error
+	TDigestAdd(k string, values ...float64) error
+	TDigestQuantile(k string, quantiles ...float64) ([]float64, error)
+	TDigestCDF(k string, values ...float64) ([]float64, error)
+	TDigestMerge(dst string, keys ...string) error
+	GeoAdd(k string, locations ...GeoLocation) (int64, error)
+	GeoPos(k string, members ...string) ([]*GeoLocation, error)
+	GeoDist(k, member1, member2, unit string) (float64, bool, error)
+	GeoHash(k string, members ...string) ([]string, error)
+	GeoSearch(k string, q GeoSearchQuery) ([]GeoLocation, error)
+	GeoSearchStore(dst, src string, q GeoSearchQuery, storeDist bool) (int64, error)
+	Publish(channel string, message any) (int64, error)
+	SPublish(channel string, message any) (int64, error)
+	Subscribe(channels ...string) error
+	PSubscribe(patterns ...string) error
+	SSubscribe(channels ...string) error
+	Unsubscribe(channels ...string) error
+	PUnsubscribe(patterns ...string) error
+	SUnsubscribe(channels ...string) error
+	ReceiveMessage(timeout time.Duration) (Message, error)
+	PubSubChannels(pattern string) ([]string, error)
+	PubSubNumSub(channels ...string) (map[string]int64, error)
+	Watch(keys ...string) error
+	Unwatch() error
+	Multi() error
+	Queue(args ...any) error
+	Discard() error
+	Exec() ([]any, bool, error)
+	Hello(protocol int) (map[string]string, error)
+	ConfigGet(pattern string) (map[string]string, error)
+	ConfigSet(param, value string) error
+	MemoryUsage(k string) (int64, bool, error)
+	MemoryStats() (map[string]int64, error)
 	Command(arg string) error
+	CommandCount() (int64, error)
+	CommandInfo(names ...string) ([]*CommandInfo, error)
+	CommandDocs(names ...string) (map[string]CommandDoc, error)
+	CommandGetKeys(args ...any) ([]string, error)
 	Info() error
+	Keys(pattern string) ([]string, error)
 	Scan(cursor int, matchPattern *regexp.Regexp, count int) ([]string, int, error)
+	ScanType(cursor int, matchPattern, keyType string, count int) ([]string, int, error)
+}
+
+// SetArgs are the optional parts of a SET command.
+type SetArgs struct {
+	Mode     string        // "NX", "XX" or empty
+	TTL      time.Duration // relative deadline, rounded down to milliseconds
+	ExpireAt time.Time     // absolute deadline, used when TTL is zero
+	KeepTTL  bool
+	Get      bool
+}
+
+// ScoredMember is one element of a sorted set.
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// StreamEntry is one stream item. Values is nil for an entry that was
+// deleted while still pending in a consumer group.
+type StreamEntry struct {
+	ID     string
+	Values map[string]string
+}
+
+// XReadArgs are the options shared by XREAD and XREADGROUP.
+type XReadArgs struct {
+	Streams []string      // keys followed by one ID per key
+	Count   int           // zero for no limit
+	Block   time.Duration // how long to wait for entries, zero to not wait
+}
+
+// GeoLocation is a member of a geo set. Dist is only filled in by GeoSearch,
+// in the unit of the query.
+type GeoLocation struct {
+	Name                string
+	Longitude, Latitude float64
+	Dist                float64
+}
+
+// GeoSearchQuery describes a GEOSEARCH. The search starts at Member when it
+// is set and at Longitude, Latitude otherwise, and covers a circle when
+// Radius is set and a Width by Height box otherwise.
+type GeoSearchQuery struct {
+	Member              string
+	Longitude, Latitude float64
+	Radius              float64
+	Width, Height       float64
+	Unit                string // "m", "km", "ft" or "mi"; meters when empty
+	Sort                string // "ASC", "DESC" or empty
+	Count               int    // zero for no limit
+	Any                 bool
+}
+
+func (q GeoSearchQuery) args() []string {
+	var args []string
+	if q.Member != "" {
+		args = append(args, "FROMMEMBER", q.Member)
+	} else {
+		args = append(args, "FROMLONLAT", formatFloat(q.Longitude), formatFloat(q.Latitude))
+	}
+
+	unit := q.Unit
+	if unit == "" {
+		unit = "m"
+	}
+	if q.Radius > 0 {
+		args = append(args, "BYRADIUS", formatFloat(q.Radius), unit)
+	} else {
+		args = append(args, "BYBOX", formatFloat(q.Width), formatFloat(q.Height), unit)
+	}
+
+	if q.Sort != "" {
+		args = append(args, q.Sort)
+	}
+	if q.Count > 0 {
+		args = append(args, "COUNT", strconv.Itoa(q.Count))
+		if q.Any {
+			args = append(args, "ANY")
+		}
+	}
+	return args
+}
+
+// Message is a pub/sub message. Pattern is only set for messages received
+// through PSubscribe.
+type Message struct {
+	Kind    string // "message", "pmessage" or "smessage"
+	Pattern string
+	Channel string
+	Payload string
+}
+
+// CommandInfo is what COMMAND INFO reports about a command. Key positions
+// count the command name as 0, and a negative LastKey counts from the end.
+type CommandInfo struct {
+	Name          string
+	Arity         int64 // negative for a minimum
+	Flags         []string
+	FirstKey      int64
+	LastKey       int64
+	Step          int64
+	ACLCategories []string
+}
+
+// CommandDoc is what COMMAND DOCS reports about a command.
+type CommandDoc struct {
+	Summary string
+	Since   string
+	Group   string
 }
 
 type client struct {
-	conn net.Conn
+	conn   net.Conn
+	reader resp.IReader
+
+	// subscribed holds the channels, patterns and shard channels the client
+	// listens to, keyed by the command that joined them. pending queues
+	// messages that arrived while a (un)subscription was being confirmed.
+	subscribed map[string]map[string]bool
+	pending    []Message
+}
+
+func NewClient(address string) (IClient, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to server: %v", err)
+	}
+
+	return &client{
+		conn:   conn,
+		reader: resp.NewReader(conn),
+		subscribed: map[string]map[string]bool{
+			"subscribe": {}, "psubscribe": {}, "ssubscribe": {},
+		},
+	}, nil
+}
+
+func (c *client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *client) Set(k string, v any) error {
+	response, err := c.do("SET", k, fmt.Sprintf("%v", v))
+	if err != nil {
+		return err
+	}
+
+	if response.Type == resp.SIMPLE_STRING && response.String == "OK" {
+		return nil
+	}
+
+	return errors.New("SET command failed")
+}
+
+// SetArgs sends a SET with options. Without args.Get it returns "OK" when the
+// value was written and nil when the NX/XX condition failed; with args.Get it
+// returns the previous value, or nil if there was none.
+func (c *client) SetArgs(k string, v any, args SetArgs) (any, error) {
+	command := []string{"SET", k, fmt.Sprintf("%v", v)}
+	if args.Mode != "" {
+		command = append(command, args.Mode)
+	}
+	if args.Get {
+		command = append(command, "GET")
+	}
+	if args.TTL > 0 {
+		command = append(command, "PX", strconv.FormatInt(args.TTL.Milliseconds(), 10))
+	} else if !args.ExpireAt.IsZero() {
+		command = append(command, "PXAT", strconv.FormatInt(args.ExpireAt.UnixMilli(), 10))
+	}
+	if args.KeepTTL {
+		command = append(command, "KEEPTTL")
+	}
+
+	response, err := c.do(command...)
+	if err != nil {
+		return nil, err
+	}
+
+	switch response.Type {
+	case resp.SIMPLE_STRING:
+		return response.String, nil
+	case resp.BULK_STRING:
+		return response.BulkString, nil
+	case resp.NULL:
+		return nil, nil
+	}
+
+	return nil, errors.New("SET command failed or returned unexpected type")
+}
+
+func (c *client) Get(k string) (any, error) {
+	response, err := c.do("GET", k)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Type == resp.BULK_STRING {
+		return response.BulkString, nil
+	} else if response.Type == resp.NULL {
+		return nil, nil
+	}
+
+	return nil, errors.New("GET command failed or returned unexpected type")
+}
+
+// MGet returns the value of each key, nil for missing ones, in one round
+// trip.
+func (c *client) MGet(keys ...string) ([]any, error) {
+	response, err := c.do(append([]string{"MGET"}, keys...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Type != resp.ARRAY {
+		return nil, errors.New("MGET command failed or returned unexpected type")
+	}
+
+	values := make([]any, len(response.Array))
+	for i, v := range response.Array {
+		if v.Type == resp.BULK_STRING {
+			values[i] = v.BulkString
+		}
+	}
+
+	return values, nil
+}
+
+// MSet sets every key in values at once.
+func (c *client) MSet(values map[string]any) error {
+	_, err := c.do(msetArgs("MSET", values)...)
+	return err
+}
+
+// MSetNX sets every key in values only if none of them exist, and reports
+// whether it did.
+func (c *client) MSetNX(values map[string]any) (bool, error) {
+	n, err := c.doInt(msetArgs("MSETNX", values)...)
+	return n == 1, err
+}
+
+func msetArgs(cmd string, values map[string]any) []string {
+	args := []string{cmd}
+	for k, v := range values {
+		args = append(args, k, fmt.Sprintf("%v", v))
+	}
+	return args
+}
+
+func (c *client) Del(k string) error {
+	response, err := c.do("DEL", k)
+	if err != nil {
+		return err
+	}
+
+	if response.Type == resp.INTEGER && response.Integer > 0 {
+		return nil
+	}
+
+	return errors.New("DEL command failed or key not found")
+}
+
+func (c *client) Incr(k string) (int64, error) {
+	return c.doInt("INCR", k)
+}
+
+func (c *client) Decr(k string) (int64, error) {
+	return c.doInt("DECR", k)
+}
+
+func (c *client) IncrBy(k string, incr int64) (int64, error) {
+	return c.doInt("INCRBY", k, strconv.FormatInt(incr, 10))
+}
+
+func (c *client) DecrBy(k string, decr int64) (int64, error) {
+	return c.doInt("DECRBY", k, strconv.FormatInt(decr, 10))
+}
+
+func (c *client) IncrByFloat(k string, incr float64) (float64, error) {
+	response, err := c.do("INCRBYFLOAT", k, strconv.FormatFloat(incr, 'f', -1, 64))
+	if err != nil {
+		return 0, err
+	}
+	return toFloat(response)
+}
+
+func (c *client) Append(k, v string) (int64, error) {
+	return c.doInt("APPEND", k, v)
+}
+
+func (c *client) StrLen(k string) (int64, error) {
+	return c.doInt("STRLEN", k)
+}
+
+func (c *client) GetRange(k string, start, end int) (string, error) {
+	response, err := c.doBulk("GETRANGE", k, strconv.Itoa(start), strconv.Itoa(end))
+	if err != nil || response == nil {
+		return "", err
+	}
+	return response.(string), nil
+}
+
+func (c *client) SetRange(k string, offset int, v string) (int64, error) {
+	return c.doInt("SETRANGE", k, strconv.Itoa(offset), v)
+}
+
+func (c *client) GetDel(k string) (any, error) {
+	return c.doBulk("GETDEL", k)
+}
+
+// GetEx returns the value of k and gives it a new time to live, rounded down
+// to milliseconds. A zero ttl removes the key's deadline instead.
+func (c *client) GetEx(k string, ttl time.Duration) (any, error) {
+	if ttl == 0 {
+		return c.doBulk("GETEX", k, "PERSIST")
+	}
+	return c.doBulk("GETEX", k, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+}
+
+// LCS returns the longest common subsequence of the values at k1 and k2.
+func (c *client) LCS(k1, k2 string) (string, error) {
+	response, err := c.doBulk("LCS", k1, k2)
+	if err != nil || response == nil {
+		return "", err
+	}
+	return response.(string), nil
+}
+
+// SetBit sets the bit at offset to value and returns its previous value.
+func (c *client) SetBit(k string, offset int64, value int) (int64, error) {
+	return c.doInt("SETBIT", k, strconv.FormatInt(offset, 10), strconv.Itoa(value))
+}
+
+func (c *client) GetBit(k string, offset int64) (int64, error) {
+	return c.doInt("GETBIT", k, strconv.FormatInt(offset, 10))
+}
+
+func (c *client) BitCount(k string) (int64, error) {
+	return c.doInt("BITCOUNT", k)
+}
+
+func (c *client) BitPos(k string, bit int) (int64, error) {
+	return c.doInt("BITPOS", k, strconv.Itoa(bit))
+}
+
+// BitOp stores the result of op ("AND", "OR", "XOR" or "NOT") across keys at
+// dst and returns its length.
+func (c *client) BitOp(op, dst string, keys ...string) (int64, error) {
+	return c.doInt(append([]string{"BITOP", op, dst}, keys...)...)
+}
+
+// BitField sends args as BITFIELD sub-commands, e.g. "INCRBY", "u8", 0, 1.
+// A nil result marks a sub-command skipped by OVERFLOW FAIL.
+func (c *client) BitField(k string, args ...any) ([]*int64, error) {
+	response, err := c.do(append([]string{"BITFIELD", k}, stringify(args)...)...)
+	if err != nil {
+		return nil, err
+	}
+	if response.Type != resp.ARRAY {
+		return nil, errors.New("BITFIELD command returned unexpected type")
+	}
+
+	results := make([]*int64, len(response.Array))
+	for i, v := range response.Array {
+		if v.Type == resp.INTEGER {
+			results[i] = &v.Integer
+		}
+	}
+	return results, nil
+}
+
+// Expire gives k a time to live, rounded down to whole milliseconds. It
+// reports whether the key existed.
+func (c *client) Expire(k string, ttl time.Duration) (bool, error) {
+	response, err := c.do("PEXPIRE", k, strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+
+	if response.Type == resp.INTEGER {
+		return response.Integer == 1, nil
+	}
+
+	return false, errors.New("PEXPIRE command failed or returned unexpected type")
+}
+
+// TTL returns the remaining time to live of k. Like the server it answers -2
+// for a missing key and -1 for a key without a deadline.
+func (c *client) TTL(k string) (time.Duration, error) {
+	response, err := c.do("PTTL", k)
+	if err != nil {
+		return 0, err
+	}
+
+	if response.Type != resp.INTEGER {
+		return 0, errors.New("PTTL command failed or returned unexpected type")
+	}
+
+	if response.Integer < 0 {
+		return time.Duration(response.Integer), nil
+	}
+	return time.Duration(response.Integer) * time.Millisecond, nil
+}
+
+func (c *client) Persist(k string) (bool, error) {
+	response, err := c.do("PERSIST", k)
+	if err != nil {
+		return false, err
+	}
+
+	if response.Type == resp.INTEGER {
+		return response.Integer == 1, nil
+	}
+
+	return false, errors.New("PERSIST command failed or returned unexpected type")
+}
+
+// Exists counts how many of keys exist, counting repeated keys each time.
+func (c *client) Exists(keys ...string) (int64, error) {
+	return c.doInt(append([]string{"EXISTS"}, keys...)...)
+}
+
+func (c *client) Touch(keys ...string) (int64, error) {
+	return c.doInt(append([]string{"TOUCH"}, keys...)...)
+}
+
+// Type returns the type of the value at k, "none" when it is missing.
+func (c *client) Type(k string) (string, error) {
+	response, err := c.do("TYPE", k)
+	if err != nil {
+		return "", err
+	}
+
+	if response.Type == resp.SIMPLE_STRING {
+		return response.String, nil
+	}
+
+	return "", errors.New("TYPE command failed or returned unexpected type")
+}
+
+func (c *client) Rename(src, dst string) error {
+	_, err := c.do("RENAME", src, dst)
+	return err
+}
+
+// RenameNX renames src to dst unless dst exists, and reports whether it did.
+func (c *client) RenameNX(src, dst string) (bool, error) {
+	n, err := c.doInt("RENAMENX", src, dst)
+	return n == 1, err
+}
+
+// Copy copies the value at src to dst, overwriting dst only with replace,
+// and reports whether it did.
+func (c *client) Copy(src, dst string, replace bool) (bool, error) {
+	args := []string{"COPY", src, dst}
+	if replace {
+		args = append(args, "REPLACE")
+	}
+	n, err := c.doInt(args...)
+	return n == 1, err
+}
+
+// RandomKey returns a random key, or nil when the store is empty.
+func (c *client) RandomKey() (any, error) {
+	return c.doBulk("RANDOMKEY")
+}
+
+func (c *client) DBSize() (int64, error) {
+	return c.doInt("DBSIZE")
+}
+
+// HSet sets the given hash fields and returns how many of them are new.
+func (c *client) HSet(k string, fields map[string]any) (int64, error) {
+	args := []string{"HSET", k}
+	for field, v := range fields {
+		args = append(args, field, fmt.Sprintf("%v", v))
+	}
+
+	return c.doInt(args...)
+}
+
+func (c *client) HGet(k, field string) (any, error) {
+	return c.doBulk("HGET", k, field)
+}
+
+// HMGet returns one value per field, nil for the ones that are missing.
+func (c *client) HMGet(k string, fields ...string) ([]any, error) {
+	response, err := c.do(append([]string{"HMGET", k}, fields...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Type != resp.ARRAY {
+		return nil, errors.New("HMGET command failed or returned unexpected type")
+	}
+
+	values := make([]any, len(response.Array))
+	for i, v := range response.Array {
+		if v.Type == resp.BULK_STRING {
+			values[i] = v.BulkString
+		}
+	}
+
+	return values, nil
+}
+
+func (c *client) HDel(k string, fields ...string) (int64, error) {
+	return c.doInt(append([]string{"HDEL", k}, fields...)...)
+}
+
+func (c *client) HGetAll(k string) (map[string]string, error) {
+	response, err := c.do("HGETALL", k)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Type != resp.ARRAY && response.Type != resp.MAP {
+		return nil, errors.New("HGETALL command failed or returned unexpected type")
+	}
+
+	return pairsToMap(response.Array), nil
+}
+
+func (c *client) HIncrBy(k, field string, incr int64) (int64, error) {
+	return c.doInt("HINCRBY", k, field, strconv.FormatInt(incr, 10))
+}
+
+func (c *client) HLen(k string) (int64, error) {
+	return c.doInt("HLEN", k)
+}
+
+// HScan returns one page of the hash fields matching the glob-style
+// matchPattern, along with the cursor of the next page.
+func (c *client) HScan(k string, cursor int, matchPattern string, count int) (map[string]string, int, error) {
+	args := []string{"HSCAN", k, strconv.Itoa(cursor)}
+	if matchPattern != "" {
+		args = append(args, "MATCH", matchPattern)
+	}
+	args = append(args, "COUNT", strconv.Itoa(count))
+
+	response, err := c.do(args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if response.Type != resp.ARRAY || len(response.Array) != 2 {
+		return nil, 0, errors.New("HSCAN command failed or returned unexpected type")
+	}
+
+	nextCursor, err := strconv.Atoi(response.Array[0].BulkString)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid cursor in HSCAN response: %v", err)
+	}
+
+	return pairsToMap(response.Array[1].Array), nextCursor, nil
+}
+
+func (c *client) LPush(k string, values ...any) (int64, error) {
+	return c.doInt(append([]string{"LPUSH", k}, stringify(values)...)...)
+}
+
+func (c *client) RPush(k string, values ...any) (int64, error) {
+	return c.doInt(append([]string{"RPUSH", k}, stringify(values)...)...)
+}
+
+func (c *client) LPop(k string) (any, error) {
+	return c.doBulk("LPOP", k)
+}
+
+func (c *client) RPop(k string) (any, error) {
+	return c.doBulk("RPOP", k)
+}
+
+func (c *client) LLen(k string) (int64, error) {
+	return c.doInt("LLEN", k)
+}
+
+func (c *client) LRange(k string, start, stop int) ([]string, error) {
+	return c.doStrings("LRANGE", k, strconv.Itoa(start), strconv.Itoa(stop))
+}
+
+// LMove moves one element between lists; from and to are "LEFT" or "RIGHT".
+// It returns nil when src is empty.
+func (c *client) LMove(src, dst, from, to string) (any, error) {
+	return c.doBulk("LMOVE", src, dst, from, to)
+}
+
+// BLPop blocks until one of keys has an element or timeout passes, zero
+// meaning forever. It returns the key and the popped element, or nil on
+// timeout.
+func (c *client) BLPop(timeout time.Duration, keys ...string) ([]string, error) {
+	return c.doBlockingPop("BLPOP", timeout, keys)
+}
+
+func (c *client) BRPop(timeout time.Duration, keys ...string) ([]string, error) {
+	return c.doBlockingPop("BRPOP", timeout, keys)
+}
+
+func (c *client) doBlockingPop(cmd string, timeout time.Duration, keys []string) ([]string, error) {
+	args := append([]string{cmd}, keys...)
+	args = append(args, strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64))
+
+	response, err := c.do(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Type == resp.NULL {
+		return nil, nil
+	}
+	return toStrings(response)
+}
+
+func (c *client) SAdd(k string, members ...any) (int64, error) {
+	return c.doInt(append([]string{"SADD", k}, stringify(members)...)...)
+}
+
+func (c *client) SRem(k string, members ...any) (int64, error) {
+	return c.doInt(append([]string{"SREM", k}, stringify(members)...)...)
+}
+
+func (c *client) SIsMember(k string, member any) (bool, error) {
+	n, err := c.doInt("SISMEMBER", k, fmt.Sprintf("%v", member))
+	return n == 1, err
+}
+
+func (c *client) SMembers(k string) ([]string, error) {
+	return c.doStrings("SMEMBERS", k)
+}
+
+func (c *client) SCard(k string) (int64, error) {
+	return c.doInt("SCARD", k)
+}
+
+func (c *client) SInter(keys ...string) ([]string, error) {
+	return c.doStrings(append([]string{"SINTER"}, keys...)...)
+}
+
+func (c *client) SUnion(keys ...string) ([]string, error) {
+	return c.doStrings(append([]string{"SUNION"}, keys...)...)
+}
+
+func (c *client) SDiff(keys ...string) ([]string, error) {
+	return c.doStrings(append([]string{"SDIFF"}, keys...)...)
+}
+
+func (c *client) ZAdd(k string, members ...ScoredMember) (int64, error) {
+	args := []string{"ZADD", k}
+	for _, m := range members {
+		args = append(args, strconv.FormatFloat(m.Score, 'g', -1, 64), m.Member)
+	}
+	return c.doInt(args...)
+}
+
+func (c *client) ZIncrBy(k string, incr float64, member string) (float64, error) {
+	response, err := c.do("ZINCRBY", k, strconv.FormatFloat(incr, 'g', -1, 64), member)
+	if err != nil {
+		return 0, err
+	}
+	return toFloat(response)
+}
+
+// ZScore returns the score of member and whether it is in the set.
+func (c *client) ZScore(k, member string) (float64, bool, error) {
+	response, err := c.do("ZSCORE", k, member)
+	if err != nil || response.Type == resp.NULL {
+		return 0, false, err
+	}
+	score, err := toFloat(response)
+	return score, err == nil, err
+}
+
+// ZRank returns the 0-based rank of member and whether it is in the set.
+func (c *client) ZRank(k, member string) (int64, bool, error) {
+	response, err := c.do("ZRANK", k, member)
+	if err != nil || response.Type == resp.NULL {
+		return 0, false, err
+	}
+	if response.Type != resp.INTEGER {
+		return 0, false, errors.New("ZRANK command returned unexpected type")
+	}
+	return response.Integer, true, nil
+}
+
+func (c *client) ZRange(k string, start, stop int) ([]string, error) {
+	return c.doStrings("ZRANGE", k, strconv.Itoa(start), strconv.Itoa(stop))
+}
+
+func (c *client) ZRangeWithScores(k string, start, stop int) ([]ScoredMember, error) {
+	response, err := c.do("ZRANGE", k, strconv.Itoa(start), strconv.Itoa(stop), "WITHSCORES")
+	if err != nil {
+		return nil, err
+	}
+	return toScoredMembers(response)
+}
+
+// ZRangeByScore takes the bounds as ZRANGEBYSCORE does, so "(1" or "+inf"
+// work as expected.
+func (c *client) ZRangeByScore(k, min, max string) ([]string, error) {
+	return c.doStrings("ZRANGEBYSCORE", k, min, max)
+}
+
+func (c *client) ZPopMin(k string, count int) ([]ScoredMember, error) {
+	response, err := c.do("ZPOPMIN", k, strconv.Itoa(count))
+	if err != nil {
+		return nil, err
+	}
+	return toScoredMembers(response)
+}
+
+// XAdd appends an entry with the given field/value pairs and returns its ID.
+// Pass "*" as id to let the server pick one.
+func (c *client) XAdd(k, id string, fieldValues ...any) (string, error) {
+	response, err := c.doBulk(append([]string{"XADD", k, id}, stringify(fieldValues)...)...)
+	if err != nil || response == nil {
+		return "", err
+	}
+	return response.(string), nil
+}
+
+func (c *client) XLen(k string) (int64, error) {
+	return c.doInt("XLEN", k)
+}
+
+func (c *client) XRange(k, start, end string) ([]StreamEntry, error) {
+	response, err := c.do("XRANGE", k, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return toStreamEntries(response)
+}
+
+func (c *client) XTrimMaxLen(k string, maxLen int) (int64, error) {
+	return c.doInt("XTRIM", k, "MAXLEN", strconv.Itoa(maxLen))
+}
+
+// XRead returns the entries of each stream added after its ID, keyed by
+// stream. It returns nil when there are none, after waiting up to
+// args.Block.
+func (c *client) XRead(args XReadArgs) (map[string][]StreamEntry, error) {
+	return c.doStreamRead([]string{"XREAD"}, args)
+}
+
+func (c *client) XGroupCreate(k, group, id string, mkStream bool) error {
+	args := []string{"XGROUP", "CREATE", k, group, id}
+	if mkStream {
+		args = append(args, "MKSTREAM")
+	}
+	_, err := c.do(args...)
+	return err
+}
+
+// XReadGroup reads on behalf of consumer; use ">" as the ID to get entries
+// never delivered to the group.
+func (c *client) XReadGroup(group, consumer string, args XReadArgs) (map[string][]StreamEntry, error) {
+	return c.doStreamRead([]string{"XREADGROUP", "GROUP", group, consumer}, args)
+}
+
+func (c *client) XAck(k, group string, ids ...string) (int64, error) {
+	return c.doInt(append([]string{"XACK", k, group}, ids...)...)
+}
+
+// XPending returns how many entries of the group are waiting to be
+// acknowledged.
+func (c *client) XPending(k, group string) (int64, error) {
+	response, err := c.do("XPENDING", k, group)
+	if err != nil {
+		return 0, err
+	}
+	if response.Type != resp.ARRAY || len(response.Array) == 0 || response.Array[0].Type != resp.INTEGER {
+		return 0, errors.New("XPENDING command returned unexpected type")
+	}
+	return response.Array[0].Integer, nil
+}
+
+func (c *client) XClaim(k, group, consumer string, minIdle time.Duration, ids ...string) ([]StreamEntry, error) {
+	args := []string{"XCLAIM", k, group, consumer, strconv.FormatInt(minIdle.Milliseconds(), 10)}
+	response, err := c.do(append(args, ids...)...)
+	if err != nil {
+		return nil, err
+	}
+	return toStreamEntries(response)
+}
+
+func (c *client) doStreamRead(cmd []string, args XReadArgs) (map[string][]StreamEntry, error) {
+	if args.Count > 0 {
+		cmd = append(cmd, "COUNT", strconv.Itoa(args.Count))
+	}
+	if args.Block > 0 {
+		cmd = append(cmd, "BLOCK", strconv.FormatInt(args.Block.Milliseconds(), 10))
+	}
+	cmd = append(cmd, "STREAMS")
+
+	response, err := c.do(append(cmd, args.Streams...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var pairs []resp.Value
+	switch response.Type {
+	case resp.NULL:
+		return nil, nil
+	case resp.MAP:
+		pairs = response.Array
+	case resp.ARRAY:
+		for _, stream := range response.Array {
+			pairs = append(pairs, stream.Array...)
+		}
+	default:
+		return nil, fmt.Errorf("%s command returned unexpected type", cmd[0])
+	}
+
+	streams := make(map[string][]StreamEntry, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		entries, err := toStreamEntries(pairs[i+1])
+		if err != nil {
+			return nil, err
+		}
+		streams[pairs[i].BulkString] = entries
+	}
+	return streams, nil
+}
+
+// PFAdd reports whether adding elements changed the HyperLogLog at k.
+func (c *client) PFAdd(k string, elements ...any) (bool, error) {
+	n, err := c.doInt(append([]string{"PFADD", k}, stringify(elements)...)...)
+	return n == 1, err
+}
+
+// PFCount estimates the number of distinct elements across the
+// HyperLogLogs at keys.
+func (c *client) PFCount(keys ...string) (int64, error) {
+	return c.doInt(append([]string{"PFCOUNT"}, keys...)...)
+}
+
+func (c *client) PFMerge(dst string, keys ...string) error {
+	_, err := c.do(append([]string{"PFMERGE", dst}, keys...)...)
+	return err
+}
+
+func (c *client) BFReserve(k string, errorRate float64, capacity int) error {
+	_, err := c.do("BF.RESERVE", k, formatFloat(errorRate), strconv.Itoa(capacity))
+	return err
+}
+
+// BFAdd reports whether item was new to the bloom filter at k.
+func (c *client) BFAdd(k string, item any) (bool, error) {
+	n, err := c.doInt("BF.ADD", k, fmt.Sprintf("%v", item))
+	return n == 1, err
+}
+
+func (c *client) BFMAdd(k string, items ...any) ([]bool, error) {
+	return c.doBools(append([]string{"BF.MADD", k}, stringify(items)...)...)
+}
+
+// BFExists reports whether item may have been added to the bloom filter
+// at k.
+func (c *client) BFExists(k string, item any) (bool, error) {
+	n, err := c.doInt("BF.EXISTS", k, fmt.Sprintf("%v", item))
+	return n == 1, err
+}
+
+func (c *client) BFMExists(k string, items ...any) ([]bool, error) {
+	return c.doBools(append([]string{"BF.MEXISTS", k}, stringify(items)...)...)
+}
+
+// BFInfo returns the fields of BF.INFO by name. A non-scaling filter has
+// no "Expansion rate".
+func (c *client) BFInfo(k string) (map[string]int64, error) {
+	return c.doIntMap("BF.INFO", k)
+}
+
+func (c *client) CFReserve(k string, capacity int) error {
+	_, err := c.do("CF.RESERVE", k, strconv.Itoa(capacity))
+	return err
+}
+
+func (c *client) CFAdd(k string, item any) (bool, error) {
+	n, err := c.doInt("CF.ADD", k, fmt.Sprintf("%v", item))
+	return n == 1, err
+}
+
+// CFAddNX adds item to the cuckoo filter at k unless it may already be
+// there, and reports whether it did.
+func (c *client) CFAddNX(k string, item any) (bool, error) {
+	n, err := c.doInt("CF.ADDNX", k, fmt.Sprintf("%v", item))
+	return n == 1, err
+}
+
+func (c *client) CFExists(k string, item any) (bool, error) {
+	n, err := c.doInt("CF.EXISTS", k, fmt.Sprintf("%v", item))
+	return n == 1, err
+}
+
+func (c *client) CFCount(k string, item any) (int64, error) {
+	return c.doInt("CF.COUNT", k, fmt.Sprintf("%v", item))
+}
+
+func (c *client) CFDel(k string, item any) (bool, error) {
+	n, err := c.doInt("CF.DEL", k, fmt.Sprintf("%v", item))
+	return n == 1, err
+}
+
+func (c *client) CFInfo(k string) (map[string]int64, error) {
+	return c.doIntMap("CF.INFO", k)
+}
+
+func (c *client) CMSInitByDim(k string, width, depth int) error {
+	_, err := c.do("CMS.INITBYDIM", k, strconv.Itoa(width), strconv.Itoa(depth))
+	return err
+}
+
+// CMSIncrBy adds increment to the count of item in the sketch at k and
+// returns its new estimated count.
+func (c *client) CMSIncrBy(k string, item any, increment int64) (int64, error) {
+	response, err := c.do("CMS.INCRBY", k, fmt.Sprintf("%v", item), strconv.FormatInt(increment, 10))
+	if err != nil {
+		return 0, err
+	}
+	if response.Type != resp.ARRAY || len(response.Array) != 1 {
+		return 0, errors.New("CMS.INCRBY command returned unexpected type")
+	}
+	return response.Array[0].Integer, nil
+}
+
+func (c *client) CMSQuery(k string, items ...any) ([]int64, error) {
+	response, err := c.do(append([]string{"CMS.QUERY", k}, stringify(items)...)...)
+	if err != nil {
+		return nil, err
+	}
+	if response.Type != resp.ARRAY {
+		return nil, errors.New("CMS.QUERY command returned unexpected type")
+	}
+
+	counts := make([]int64, len(response.Array))
+	for i, v := range response.Array {
+		counts[i] = v.Integer
+	}
+	return counts, nil
+}
+
+// CMSMerge replaces the sketch at dst with the sum of the sketches at keys,
+// each multiplied by its weight. With no weights every one counts once.
+func (c *client) CMSMerge(dst string, keys []string, weights []int64) error {
+	args := append([]string{"CMS.MERGE", dst, strconv.Itoa(len(keys))}, keys...)
+	if len(weights) > 0 {
+		args = append(args, "WEIGHTS")
+		for _, w := range weights {
+			args = append(args, strconv.FormatInt(w, 10))
+		}
+	}
+	_, err := c.do(args...)
+	return err
+}
+
+func (c *client) TopKReserve(k string, topk int) error {
+	_, err := c.do("TOPK.RESERVE", k, strconv.Itoa(topk))
+	return err
+}
+
+// TopKAdd returns, for every item, the item it pushed out of the list at
+// k, or nil.
+func (c *client) TopKAdd(k string, items ...any) ([]any, error) {
+	response, err := c.do(append([]string{"TOPK.ADD", k}, stringify(items)...)...)
+	if err != nil {
+		return nil, err
+	}
+	if response.Type != resp.ARRAY {
+		return nil, errors.New("TOPK.ADD command returned unexpected type")
+	}
+
+	expelled := make([]any, len(response.Array))
+	for i, v := range response.Array {
+		expelled[i] = toAny(v)
+	}
+	return expelled, nil
+}
+
+// TopKList returns the items of the list at k, the most frequent first.
+func (c *client) TopKList(k string) ([]string, error) {
+	return c.doStrings("TOPK.LIST", k)
+}
+
+// TDigestCreate creates a t-digest at k, with the default compression
+// when compression is 0.
+func (c *client) TDigestCreate(k string, compression int) error {
+	args := []string{"TDIGEST.CREATE", k}
+	if compression > 0 {
+		args = append(args, "COMPRESSION", strconv.Itoa(compression))
+	}
+	_, err := c.do(args...)
+	return err
+}
+
+func (c *client) TDigestAdd(k string, values ...float64) error {
+	args := []string{"TDIGEST.ADD", k}
+	for _, v := range values {
+		args = append(args, formatFloat(v))
+	}
+	_, err := c.do(args...)
+	return err
+}
+
+func (c *client) TDigestQuantile(k string, quantiles ...float64) ([]float64, error) {
+	return c.doFloats("TDIGEST.QUANTILE", k, quantiles)
+}
+
+func (c *client) TDigestCDF(k string, values ...float64) ([]float64, error) {
+	return c.doFloats("TDIGEST.CDF", k, values)
+}
+
+// TDigestMerge merges the t-digests at keys into the one at dst, which
+// keeps its own values.
+func (c *client) TDigestMerge(dst string, keys ...string) error {
+	_, err := c.do(append([]string{"TDIGEST.MERGE", dst, strconv.Itoa(len(keys))}, keys...)...)
+	return err
+}
+
+// GeoAdd adds or moves members of the geo set at k and returns how many
+// were new.
+func (c *client) GeoAdd(k string, locations ...GeoLocation) (int64, error) {
+	args := []string{"GEOADD", k}
+	for _, l := range locations {
+		args = append(args, formatFloat(l.Longitude), formatFloat(l.Latitude), l.Name)
+	}
+	return c.doInt(args...)
+}
+
+// GeoPos returns the position of each member, nil for missing ones.
+func (c *client) GeoPos(k string, members ...string) ([]*GeoLocation, error) {
+	response, err := c.do(append([]string{"GEOPOS", k}, members...)...)
+	if err != nil {
+		return nil, err
+	}
+	if response.Type != resp.ARRAY {
+		return nil, errors.New("GEOPOS command returned unexpected type")
+	}
+
+	locations := make([]*GeoLocation, len(response.Array))
+	for i, v := range response.Array {
+		if v.Type != resp.ARRAY {
+			continue
+		}
+		l := GeoLocation{Name: members[i]}
+		if l.Longitude, l.Latitude, err = toCoordinates(v); err != nil {
+			return nil, err
+		}
+		locations[i] = &l
+	}
+	return locations, nil
 }
 
-func NewClient(address string) (IClient, error) {
-	conn, err := net.Dial("tcp", address)
-	if err != nil {
-		return nil, fmt.Errorf("could not connect to server: %v", err)
+// GeoDist returns the distance between two members in unit, or false when
+// either of them is missing.
+func (c *client) GeoDist(k, member1, member2, unit string) (float64, bool, error) {
+	args := []string{"GEODIST", k, member1, member2}
+	if unit != "" {
+		args = append(args, unit)
 	}
 
-	return &client{conn: conn}, nil
+	response, err := c.do(args...)
+	if err != nil || response.Type == resp.NULL {
+		return 0, false, err
+	}
+	dist, err := toFloat(response)
+	return dist, err == nil, err
 }
 
-func (c *client) Close() error {
-	return c.conn.Close()
+// GeoHash returns the geohash string of each member, empty for missing ones.
+func (c *client) GeoHash(k string, members ...string) ([]string, error) {
+	return c.doStrings(append([]string{"GEOHASH", k}, members...)...)
 }
 
-func (c *client) Set(k string, v any) error {
-	value := fmt.Sprintf("%v", v)
-	command := fmt.Sprintf("*3\r\n$3\r\nSET\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n",
-		len(k), k, len(value), value)
-	_, err := c.conn.Write([]byte(command))
+// GeoSearch returns the members inside the area of q along with their
+// positions and distances from its center.
+func (c *client) GeoSearch(k string, q GeoSearchQuery) ([]GeoLocation, error) {
+	args := append([]string{"GEOSEARCH", k}, q.args()...)
+	response, err := c.do(append(args, "WITHDIST", "WITHCOORD")...)
 	if err != nil {
-		return fmt.Errorf("could not send SET command: %v", err)
+		return nil, err
+	}
+	if response.Type != resp.ARRAY {
+		return nil, errors.New("GEOSEARCH command returned unexpected type")
+	}
+
+	locations := make([]GeoLocation, len(response.Array))
+	for i, item := range response.Array {
+		if item.Type != resp.ARRAY || len(item.Array) != 3 {
+			return nil, errors.New("GEOSEARCH command returned unexpected type")
+		}
+		l := &locations[i]
+		l.Name = item.Array[0].BulkString
+		if l.Dist, err = toFloat(item.Array[1]); err != nil {
+			return nil, err
+		}
+		if l.Longitude, l.Latitude, err = toCoordinates(item.Array[2]); err != nil {
+			return nil, err
+		}
 	}
+	return locations, nil
+}
+
+// GeoSearchStore stores the members of src inside the area of q at dst, as
+// a geo set or, with storeDist, as a sorted set scored by distance.
+func (c *client) GeoSearchStore(dst, src string, q GeoSearchQuery, storeDist bool) (int64, error) {
+	args := append([]string{"GEOSEARCHSTORE", dst, src}, q.args()...)
+	if storeDist {
+		args = append(args, "STOREDIST")
+	}
+	return c.doInt(args...)
+}
 
-	response, err := c.readResponse()
+// ConfigGet returns the server settings whose names match pattern.
+func (c *client) ConfigGet(pattern string) (map[string]string, error) {
+	response, err := c.do("CONFIG", "GET", pattern)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if response.Type == resp.SIMPLE_STRING && response.String == "OK" {
-		return nil
+	if response.Type != resp.ARRAY && response.Type != resp.MAP {
+		return nil, errors.New("CONFIG GET command failed or returned unexpected type")
 	}
+	return pairsToMap(response.Array), nil
+}
 
-	return errors.New("SET command failed")
+func (c *client) ConfigSet(param, value string) error {
+	_, err := c.do("CONFIG", "SET", param, value)
+	return err
 }
 
-func (c *client) Get(k string) (any, error) {
-	command := fmt.Sprintf("*2\r\n$3\r\nGET\r\n$%d\r\n%s\r\n", len(k), k)
-	_, err := c.conn.Write([]byte(command))
-	if err != nil {
-		return nil, fmt.Errorf("could not send GET command: %v", err)
+// MemoryUsage returns the bytes held by k and its value, or false when k
+// does not exist.
+func (c *client) MemoryUsage(k string) (int64, bool, error) {
+	response, err := c.do("MEMORY", "USAGE", k)
+	if err != nil || response.Type == resp.NULL {
+		return 0, false, err
 	}
+	if response.Type != resp.INTEGER {
+		return 0, false, errors.New("MEMORY USAGE command returned unexpected type")
+	}
+	return response.Integer, true, nil
+}
 
-	response, err := c.readResponse()
+// MemoryStats returns the MEMORY STATS counters. Per-shard counters are
+// flattened into names such as "shard.0.keys".
+func (c *client) MemoryStats() (map[string]int64, error) {
+	response, err := c.do("MEMORY", "STATS")
 	if err != nil {
 		return nil, err
 	}
 
-	if response.Type == resp.BULK_STRING {
-		return response.BulkString, nil
-	} else if response.Type == resp.NULL {
-		return nil, nil
+	stats := make(map[string]int64)
+	var flatten func(prefix string, pairs []resp.Value)
+	flatten = func(prefix string, pairs []resp.Value) {
+		for i := 0; i+1 < len(pairs); i += 2 {
+			name, v := prefix+pairs[i].BulkString, pairs[i+1]
+			switch v.Type {
+			case resp.INTEGER:
+				stats[name] = v.Integer
+			case resp.ARRAY, resp.MAP:
+				flatten(name+".", v.Array)
+			}
+		}
 	}
+	flatten("", response.Array)
+	return stats, nil
+}
 
-	return nil, errors.New("GET command failed or returned unexpected type")
+// Publish sends message to channel and returns how many subscribers got it.
+func (c *client) Publish(channel string, message any) (int64, error) {
+	return c.doInt("PUBLISH", channel, fmt.Sprintf("%v", message))
 }
 
-func (c *client) Del(k string) error {
-	command := fmt.Sprintf("*2\r\n$3\r\nDEL\r\n$%d\r\n%s\r\n", len(k), k)
-	_, err := c.conn.Write([]byte(command))
+func (c *client) SPublish(channel string, message any) (int64, error) {
+	return c.doInt("SPUBLISH", channel, fmt.Sprintf("%v", message))
+}
+
+// Subscribe switches the connection to subscriber mode and listens to
+// channels. Messages are then read with ReceiveMessage; until every
+// subscription is dropped, only pub/sub methods may be used.
+func (c *client) Subscribe(channels ...string) error {
+	return c.subscription("SUBSCRIBE", "subscribe", channels)
+}
+
+// PSubscribe listens to every channel matching one of patterns.
+func (c *client) PSubscribe(patterns ...string) error {
+	return c.subscription("PSUBSCRIBE", "psubscribe", patterns)
+}
+
+func (c *client) SSubscribe(channels ...string) error {
+	return c.subscription("SSUBSCRIBE", "ssubscribe", channels)
+}
+
+// Unsubscribe stops listening to channels, or to every channel when none
+// are given.
+func (c *client) Unsubscribe(channels ...string) error {
+	return c.subscription("UNSUBSCRIBE", "subscribe", channels)
+}
+
+func (c *client) PUnsubscribe(patterns ...string) error {
+	return c.subscription("PUNSUBSCRIBE", "psubscribe", patterns)
+}
+
+func (c *client) SUnsubscribe(channels ...string) error {
+	return c.subscription("SUNSUBSCRIBE", "ssubscribe", channels)
+}
+
+// subscription sends a (un)subscribe command and reads the confirmation the
+// server sends for each name, queueing any message that comes in between.
+// group is the subscribing command of the namespace cmd works on.
+func (c *client) subscription(cmd, group string, names []string) error {
+	kind := strings.ToLower(cmd)
+	leaving := kind != group
+	joined := c.subscribed[group]
+
+	expected := max(len(names), 1)
+	if len(names) == 0 && leaving {
+		expected = max(len(joined), 1)
+	}
+
+	if err := c.send(append([]string{cmd}, names...)...); err != nil {
+		return err
+	}
+
+	for confirmed := 0; confirmed < expected; {
+		frame, err := c.reader.Read()
+		if err != nil {
+			return fmt.Errorf("could not read response: %v", err)
+		}
+		if frame.Type == resp.SIMPLE_ERROR {
+			return errors.New(frame.String)
+		}
+		if msg, ok := toMessage(frame); ok {
+			c.pending = append(c.pending, msg)
+			continue
+		}
+		if len(frame.Array) != 3 || frame.Array[0].BulkString != kind {
+			return fmt.Errorf("%s command returned unexpected reply", cmd)
+		}
+
+		if name := frame.Array[1]; name.Type == resp.BULK_STRING {
+			if leaving {
+				delete(joined, name.BulkString)
+			} else {
+				joined[name.BulkString] = true
+			}
+		}
+		confirmed++
+	}
+	return nil
+}
+
+// ReceiveMessage waits for the next pub/sub message, giving up after
+// timeout when it is positive.
+func (c *client) ReceiveMessage(timeout time.Duration) (Message, error) {
+	if len(c.pending) > 0 {
+		msg := c.pending[0]
+		c.pending = c.pending[1:]
+		return msg, nil
+	}
+
+	if timeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(timeout))
+		defer c.conn.SetReadDeadline(time.Time{})
+	}
+
+	for {
+		frame, err := c.reader.Read()
+		if err != nil {
+			return Message{}, fmt.Errorf("could not read message: %v", err)
+		}
+		if msg, ok := toMessage(frame); ok {
+			return msg, nil
+		}
+	}
+}
+
+// toMessage reads a message, pmessage or smessage frame.
+func toMessage(frame resp.Value) (Message, bool) {
+	if frame.Type != resp.ARRAY && frame.Type != resp.PUSH || len(frame.Array) == 0 {
+		return Message{}, false
+	}
+
+	parts := make([]string, len(frame.Array))
+	for i, v := range frame.Array {
+		parts[i] = v.BulkString
+	}
+	switch {
+	case (parts[0] == "message" || parts[0] == "smessage") && len(parts) == 3:
+		return Message{Kind: parts[0], Channel: parts[1], Payload: parts[2]}, true
+	case parts[0] == "pmessage" && len(parts) == 4:
+		return Message{Kind: parts[0], Pattern: parts[1], Channel: parts[2], Payload: parts[3]}, true
+	}
+	return Message{}, false
+}
+
+// PubSubChannels lists the channels with subscribers that match pattern, or
+// all of them when pattern is empty.
+func (c *client) PubSubChannels(pattern string) ([]string, error) {
+	args := []string{"PUBSUB", "CHANNELS"}
+	if pattern != "" {
+		args = append(args, pattern)
+	}
+	return c.doStrings(args...)
+}
+
+// PubSubNumSub returns the number of subscribers of each channel.
+func (c *client) PubSubNumSub(channels ...string) (map[string]int64, error) {
+	response, err := c.do(append([]string{"PUBSUB", "NUMSUB"}, channels...)...)
 	if err != nil {
-		return fmt.Errorf("could not send DEL command: %v", err)
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(channels))
+	for i := 0; i+1 < len(response.Array); i += 2 {
+		counts[response.Array[i].BulkString] = response.Array[i+1].Integer
 	}
+	return counts, nil
+}
+
+// Watch makes the next EXEC fail if any of keys changes before it runs.
+func (c *client) Watch(keys ...string) error {
+	_, err := c.do(append([]string{"WATCH"}, keys...)...)
+	return err
+}
+
+func (c *client) Unwatch() error {
+	_, err := c.do("UNWATCH")
+	return err
+}
+
+// Multi starts a transaction. Commands are then sent with Queue and run
+// together by Exec.
+func (c *client) Multi() error {
+	_, err := c.do("MULTI")
+	return err
+}
 
-	response, err := c.readResponse()
+// Queue sends a command inside a transaction. An error means the server
+// refused it, and Exec will fail.
+func (c *client) Queue(args ...any) error {
+	response, err := c.do(stringify(args)...)
 	if err != nil {
 		return err
 	}
+	if response.Type != resp.SIMPLE_STRING || response.String != "QUEUED" {
+		return fmt.Errorf("%v command was not queued", args[0])
+	}
+	return nil
+}
 
-	if response.Type == resp.INTEGER && response.Integer > 0 {
-		return nil
+func (c *client) Discard() error {
+	_, err := c.do("DISCARD")
+	return err
+}
+
+// Exec runs the queued commands and returns their replies, with error
+// replies as error values. It returns false, running nothing, when a watched
+// key changed.
+func (c *client) Exec() ([]any, bool, error) {
+	response, err := c.do("EXEC")
+	if err != nil || response.Type == resp.NULL {
+		return nil, false, err
+	}
+	if response.Type != resp.ARRAY {
+		return nil, false, errors.New("EXEC command returned unexpected type")
 	}
 
-	return errors.New("DEL command failed or key not found")
+	replies := make([]any, len(response.Array))
+	for i, v := range response.Array {
+		replies[i] = toAny(v)
+	}
+	return replies, true, nil
 }
 
-func (c *client) Command(arg string) error {
-	command := fmt.Sprintf("*2\r\n$7\r\nCOMMAND\r\n$%d\r\n%s\r\n", len(arg), arg)
-	_, err := c.conn.Write([]byte(command))
+// Hello switches the connection to the given RESP version and returns the
+// server's description of itself, with every value rendered as a string.
+func (c *client) Hello(protocol int) (map[string]string, error) {
+	response, err := c.do("HELLO", strconv.Itoa(protocol))
 	if err != nil {
-		return fmt.Errorf("could not send COMMAND command: %v", err)
+		return nil, err
+	}
+
+	if response.Type != resp.ARRAY && response.Type != resp.MAP {
+		return nil, errors.New("HELLO command failed or returned unexpected type")
 	}
 
-	response, err := c.readResponse()
+	info := make(map[string]string, len(response.Array)/2)
+	for i := 0; i+1 < len(response.Array); i += 2 {
+		v := response.Array[i+1]
+		switch v.Type {
+		case resp.INTEGER:
+			info[response.Array[i].BulkString] = strconv.FormatInt(v.Integer, 10)
+		default:
+			info[response.Array[i].BulkString] = v.BulkString
+		}
+	}
+
+	return info, nil
+}
+
+func (c *client) Command(arg string) error {
+	response, err := c.do("COMMAND", arg)
 	if err != nil {
 		return err
 	}
@@ -119,38 +1572,110 @@ func (c *client) Command(arg string) error {
 	return errors.New("COMMAND failed or returned unexpected type")
 }
 
-func (c *client) Info() error {
-	command := "*1\r\n$4\r\nINFO\r\n"
-	_, err := c.conn.Write([]byte(command))
+func (c *client) CommandCount() (int64, error) {
+	return c.doInt("COMMAND", "COUNT")
+}
+
+// CommandInfo describes the named commands, or all of them when no name is
+// given. Unknown names get a nil entry.
+func (c *client) CommandInfo(names ...string) ([]*CommandInfo, error) {
+	response, err := c.do(append([]string{"COMMAND", "INFO"}, names...)...)
+	if err != nil {
+		return nil, err
+	}
+	if response.Type != resp.ARRAY {
+		return nil, errors.New("COMMAND INFO command returned unexpected type")
+	}
+
+	infos := make([]*CommandInfo, len(response.Array))
+	for i, v := range response.Array {
+		if v.Type != resp.ARRAY || len(v.Array) < 6 {
+			continue
+		}
+		info := &CommandInfo{
+			Name:     v.Array[0].BulkString,
+			Arity:    v.Array[1].Integer,
+			FirstKey: v.Array[3].Integer,
+			LastKey:  v.Array[4].Integer,
+			Step:     v.Array[5].Integer,
+		}
+		info.Flags, _ = toStrings(v.Array[2])
+		if len(v.Array) > 6 {
+			info.ACLCategories, _ = toStrings(v.Array[6])
+		}
+		infos[i] = info
+	}
+	return infos, nil
+}
+
+// CommandDocs returns the documentation of the named commands, or of all of
+// them when no name is given, keyed by lowercase name.
+func (c *client) CommandDocs(names ...string) (map[string]CommandDoc, error) {
+	response, err := c.do(append([]string{"COMMAND", "DOCS"}, names...)...)
 	if err != nil {
-		return fmt.Errorf("could not send INFO command: %v", err)
+		return nil, err
+	}
+	if response.Type != resp.ARRAY && response.Type != resp.MAP {
+		return nil, errors.New("COMMAND DOCS command returned unexpected type")
+	}
+
+	docs := make(map[string]CommandDoc, len(response.Array)/2)
+	for i := 0; i+1 < len(response.Array); i += 2 {
+		fields := pairsToMap(response.Array[i+1].Array)
+		docs[response.Array[i].BulkString] = CommandDoc{
+			Summary: fields["summary"],
+			Since:   fields["since"],
+			Group:   fields["group"],
+		}
 	}
+	return docs, nil
+}
+
+// CommandGetKeys returns the keys the command given by args would touch.
+func (c *client) CommandGetKeys(args ...any) ([]string, error) {
+	return c.doStrings(append([]string{"COMMAND", "GETKEYS"}, stringify(args)...)...)
+}
 
-	response, err := c.readResponse()
+func (c *client) Info() error {
+	response, err := c.do("INFO")
 	if err != nil {
 		return err
 	}
 
-	if response.Type == resp.SIMPLE_STRING {
-		fmt.Println("INFO response:\n", response.String)
+	if response.Type == resp.BULK_STRING {
+		fmt.Println("INFO response:\n", response.BulkString)
 		return nil
 	}
 
 	return errors.New("INFO command failed or returned unexpected type")
 }
 
+func (c *client) Keys(pattern string) ([]string, error) {
+	return c.doStrings("KEYS", pattern)
+}
+
 func (c *client) Scan(cursor int, matchPattern *regexp.Regexp, count int) ([]string, int, error) {
-	command := fmt.Sprintf("*4\r\n$4\r\nSCAN\r\n$%d\r\n%d\r\n$5\r\nMATCH\r\n$%d\r\n%s\r\n$5\r\nCOUNT\r\n$%d\r\n%d\r\n",
-		len(strconv.Itoa(cursor)), cursor,
-		len(matchPattern.String()), matchPattern.String(),
-		len(strconv.Itoa(count)), count)
+	args := []string{"SCAN", strconv.Itoa(cursor)}
+	if matchPattern != nil {
+		args = append(args, "MATCH", matchPattern.String())
+	}
+	args = append(args, "COUNT", strconv.Itoa(count))
+	return c.scan(args)
+}
 
-	_, err := c.conn.Write([]byte(command))
-	if err != nil {
-		return nil, 0, fmt.Errorf("could not send SCAN command: %v", err)
+// ScanType is Scan limited to keys holding keyType, such as "hash". An
+// empty matchPattern matches every key.
+func (c *client) ScanType(cursor int, matchPattern, keyType string, count int) ([]string, int, error) {
+	args := []string{"SCAN", strconv.Itoa(cursor)}
+	if matchPattern != "" {
+		args = append(args, "MATCH", matchPattern)
 	}
+	args = append(args, "COUNT", strconv.Itoa(count), "TYPE", keyType)
+	return c.scan(args)
+}
 
-	response, err := c.readResponse()
+func (c *client) scan(args []string) ([]string, int, error) {
+	response, err := c.do(args...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -176,52 +1701,265 @@ func (c *client) Scan(cursor int, matchPattern *regexp.Regexp, count int) ([]str
 	return nil, 0, errors.New("SCAN command failed or returned unexpected type")
 }
 
-func (c *client) readResponse() (resp.Value, error) {
-	reader := bufio.NewReader(c.conn)
-	line, err := reader.ReadString('\n')
+// doInt is do for commands that reply with an integer.
+func (c *client) doInt(args ...string) (int64, error) {
+	response, err := c.do(args...)
+	if err != nil {
+		return 0, err
+	}
+
+	if response.Type != resp.INTEGER {
+		return 0, fmt.Errorf("%s command returned unexpected type", args[0])
+	}
+
+	return response.Integer, nil
+}
+
+// doBools is do for commands that reply with an array of 0 or 1 integers.
+func (c *client) doBools(args ...string) ([]bool, error) {
+	response, err := c.do(args...)
+	if err != nil {
+		return nil, err
+	}
+	if response.Type != resp.ARRAY {
+		return nil, fmt.Errorf("%s command returned unexpected type", args[0])
+	}
+
+	bools := make([]bool, len(response.Array))
+	for i, v := range response.Array {
+		if v.Type == resp.SIMPLE_ERROR {
+			return bools[:i], errors.New(v.String)
+		}
+		bools[i] = v.Integer == 1
+	}
+	return bools, nil
+}
+
+// doIntMap is do for commands that reply with field names paired with
+// integers. Fields with other values are left out.
+func (c *client) doIntMap(args ...string) (map[string]int64, error) {
+	response, err := c.do(args...)
+	if err != nil {
+		return nil, err
+	}
+	if response.Type != resp.ARRAY && response.Type != resp.MAP {
+		return nil, fmt.Errorf("%s command returned unexpected type", args[0])
+	}
+
+	fields := make(map[string]int64)
+	for i := 0; i+1 < len(response.Array); i += 2 {
+		if v := response.Array[i+1]; v.Type == resp.INTEGER {
+			fields[response.Array[i].BulkString] = v.Integer
+		}
+	}
+	return fields, nil
+}
+
+// doFloats sends cmd with k and args and reads back one float per arg.
+func (c *client) doFloats(cmd, k string, args []float64) ([]float64, error) {
+	strs := []string{cmd, k}
+	for _, a := range args {
+		strs = append(strs, formatFloat(a))
+	}
+	response, err := c.do(strs...)
+	if err != nil {
+		return nil, err
+	}
+	if response.Type != resp.ARRAY {
+		return nil, fmt.Errorf("%s command returned unexpected type", cmd)
+	}
+
+	floats := make([]float64, len(response.Array))
+	for i, v := range response.Array {
+		if floats[i], err = toFloat(v); err != nil {
+			return nil, err
+		}
+	}
+	return floats, nil
+}
+
+// doBulk is do for commands that reply with a bulk string or a null, which
+// is returned as nil.
+func (c *client) doBulk(args ...string) (any, error) {
+	response, err := c.do(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Type == resp.BULK_STRING {
+		return response.BulkString, nil
+	} else if response.Type == resp.NULL {
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("%s command returned unexpected type", args[0])
+}
+
+// doStrings is do for commands that reply with an array of bulk strings.
+func (c *client) doStrings(args ...string) ([]string, error) {
+	response, err := c.do(args...)
+	if err != nil {
+		return nil, err
+	}
+	return toStrings(response)
+}
+
+// do sends args as a command and waits for its reply. Error replies are
+// turned into Go errors.
+func (c *client) do(args ...string) (resp.Value, error) {
+	if err := c.send(args...); err != nil {
+		return resp.Value{}, err
+	}
+
+	response, err := c.reader.Read()
 	if err != nil {
 		return resp.Value{}, fmt.Errorf("could not read response: %v", err)
 	}
 
-	line = strings.TrimSpace(line)
+	if response.Type == resp.SIMPLE_ERROR {
+		return response, errors.New(response.String)
+	}
+
+	return response, nil
+}
 
-	switch line[0] {
-	case '+':
-		return resp.Value{Type: resp.SIMPLE_STRING, String: line[1:]}, nil
-	case '-':
-		return resp.NewErrorValue(line[1:]), nil
-	case ':':
-		val, err := strconv.ParseInt(line[1:], 10, 64)
-		if err != nil {
-			return resp.Value{}, fmt.Errorf("invalid integer response: %v", err)
+func (c *client) send(args ...string) error {
+	command := make([]resp.Value, len(args))
+	for i, arg := range args {
+		command[i] = resp.Value{Type: resp.BULK_STRING, BulkString: arg}
+	}
+
+	_, err := c.conn.Write(resp.Value{Type: resp.ARRAY, Array: command}.Marshal())
+	if err != nil {
+		return fmt.Errorf("could not send %s command: %v", args[0], err)
+	}
+	return nil
+}
+
+func pairsToMap(pairs []resp.Value) map[string]string {
+	m := make(map[string]string, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		m[pairs[i].BulkString] = pairs[i+1].BulkString
+	}
+	return m
+}
+
+func toStrings(response resp.Value) ([]string, error) {
+	if response.Type != resp.ARRAY && response.Type != resp.SET {
+		return nil, errors.New("expected an array reply")
+	}
+
+	strs := make([]string, len(response.Array))
+	for i, v := range response.Array {
+		strs[i] = v.BulkString
+	}
+	return strs, nil
+}
+
+// toAny turns a reply into the Go value closest to it: strings, int64,
+// float64, bool, nil, errors, or []any for aggregates.
+func toAny(v resp.Value) any {
+	switch v.Type {
+	case resp.BULK_STRING:
+		return v.BulkString
+	case resp.SIMPLE_STRING:
+		return v.String
+	case resp.INTEGER:
+		return v.Integer
+	case resp.DOUBLE:
+		return v.Double
+	case resp.BOOLEAN:
+		return v.Boolean
+	case resp.SIMPLE_ERROR:
+		return errors.New(v.String)
+	case resp.ARRAY, resp.SET, resp.MAP, resp.PUSH:
+		values := make([]any, len(v.Array))
+		for i, item := range v.Array {
+			values[i] = toAny(item)
 		}
-		return resp.Value{Type: resp.INTEGER, Integer: val}, nil
-	case '$':
-		length, err := strconv.Atoi(line[1:])
-		if err != nil || length < 0 {
-			return resp.Value{Type: resp.NULL}, nil
+		return values
+	default:
+		return nil
+	}
+}
+
+func stringify(values []any) []string {
+	strs := make([]string, len(values))
+	for i, v := range values {
+		strs[i] = fmt.Sprintf("%v", v)
+	}
+	return strs
+}
+
+// toFloat reads a score, sent as a double under RESP3 and as a bulk string
+// under RESP2.
+func toFloat(v resp.Value) (float64, error) {
+	switch v.Type {
+	case resp.DOUBLE:
+		return v.Double, nil
+	case resp.BULK_STRING:
+		return strconv.ParseFloat(v.BulkString, 64)
+	}
+	return 0, errors.New("expected a score reply")
+}
+
+// toCoordinates reads a [longitude, latitude] pair.
+func toCoordinates(v resp.Value) (float64, float64, error) {
+	if v.Type != resp.ARRAY || len(v.Array) != 2 {
+		return 0, 0, errors.New("expected a coordinate pair")
+	}
+	lon, err := strconv.ParseFloat(v.Array[0].BulkString, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	lat, err := strconv.ParseFloat(v.Array[1].BulkString, 64)
+	return lon, lat, err
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// toScoredMembers reads a WITHSCORES reply in either its flat RESP2 or nested
+// RESP3 shape.
+func toScoredMembers(response resp.Value) ([]ScoredMember, error) {
+	if response.Type != resp.ARRAY {
+		return nil, errors.New("expected an array reply")
+	}
+
+	flat := response.Array
+	if len(flat) > 0 && flat[0].Type == resp.ARRAY {
+		flat = nil
+		for _, pair := range response.Array {
+			flat = append(flat, pair.Array...)
 		}
-		data := make([]byte, length+2)
-		_, err = reader.Read(data)
+	}
+
+	members := make([]ScoredMember, 0, len(flat)/2)
+	for i := 0; i+1 < len(flat); i += 2 {
+		score, err := toFloat(flat[i+1])
 		if err != nil {
-			return resp.Value{}, fmt.Errorf("could not read bulk string: %v", err)
-		}
-		return resp.Value{Type: resp.BULK_STRING, BulkString: string(data[:length])}, nil
-	case '*':
-		count, err := strconv.Atoi(line[1:])
-		if err != nil || count < 0 {
-			return resp.Value{}, fmt.Errorf("invalid array response: %v", err)
-		}
-		array := []resp.Value{}
-		for i := 0; i < count; i++ {
-			elem, err := c.readResponse()
-			if err != nil {
-				return resp.Value{}, err
-			}
-			array = append(array, elem)
+			return nil, err
+		}
+		members = append(members, ScoredMember{Member: flat[i].BulkString, Score: score})
+	}
+	return members, nil
+}
+
+func toStreamEntries(response resp.Value) ([]StreamEntry, error) {
+	if response.Type != resp.ARRAY {
+		return nil, errors.New("expected an array reply")
+	}
+
+	entries := make([]StreamEntry, len(response.Array))
+	for i, e := range response.Array {
+		if e.Type != resp.ARRAY || len(e.Array) != 2 {
+			return nil, errors.New("expected a stream entry")
+		}
+		entries[i].ID = e.Array[0].BulkString
+		if e.Array[1].Type == resp.ARRAY {
+			entries[i].Values = pairsToMap(e.Array[1].Array)
 		}
-		return resp.Value{Type: resp.ARRAY, Array: array}, nil
-	default:
-		return resp.Value{}, fmt.Errorf("unknown response type: %v", line)
 	}
+	return entries, nil
 }
@@ -3,10 +3,196 @@ package resp
 type RESPCommand string
 
 const (
-	CMD_SET     = "SET"
-	CMD_GET     = "GET"
-	CMD_DEL     = "DEL"
-	CMD_COMMAND = "COMMAND"
-	CMD_INFO    = "INFO"
-	CMD_SCAN    = "SCAN"
+	CMD_SET       = "SET"
+	CMD_GET       = "GET"
+	CMD_DEL       = "DEL"
+	CMD_COMMAND   = "COMMAND"
+	CMD_INFO      = "INFO"
+	CMD_SCAN      = "SCAN"
+	CMD_KEYS      = "KEYS"
+	CMD_EXPIRE    = "EXPIRE"
+	CMD_PEXPIRE   = "PEXPIRE"
+	CMD_EXPIREAT  = "EXPIREAT"
+	CMD_PEXPIREAT = "PEXPIREAT"
+	CMD_TTL       = "TTL"
+	CMD_PTTL      = "PTTL"
+	CMD_PERSIST   = "PERSIST"
+	CMD_EXISTS    = "EXISTS"
+	CMD_TYPE      = "TYPE"
+	CMD_RENAME    = "RENAME"
+	CMD_RENAMENX  = "RENAMENX"
+	CMD_COPY      = "COPY"
+	CMD_TOUCH     = "TOUCH"
+	CMD_RANDOMKEY = "RANDOMKEY"
+	CMD_DBSIZE    = "DBSIZE"
+
+	CMD_INCR        = "INCR"
+	CMD_DECR        = "DECR"
+	CMD_INCRBY      = "INCRBY"
+	CMD_DECRBY      = "DECRBY"
+	CMD_INCRBYFLOAT = "INCRBYFLOAT"
+	CMD_APPEND      = "APPEND"
+	CMD_STRLEN      = "STRLEN"
+	CMD_GETRANGE    = "GETRANGE"
+	CMD_SETRANGE    = "SETRANGE"
+	CMD_GETDEL      = "GETDEL"
+	CMD_GETEX       = "GETEX"
+	CMD_LCS         = "LCS"
+	CMD_MGET        = "MGET"
+	CMD_MSET        = "MSET"
+	CMD_MSETNX      = "MSETNX"
+
+	CMD_SETBIT      = "SETBIT"
+	CMD_GETBIT      = "GETBIT"
+	CMD_BITCOUNT    = "BITCOUNT"
+	CMD_BITPOS      = "BITPOS"
+	CMD_BITOP       = "BITOP"
+	CMD_BITFIELD    = "BITFIELD"
+	CMD_BITFIELD_RO = "BITFIELD_RO"
+
+	CMD_HSET         = "HSET"
+	CMD_HSETNX       = "HSETNX"
+	CMD_HGET         = "HGET"
+	CMD_HMGET        = "HMGET"
+	CMD_HDEL         = "HDEL"
+	CMD_HGETALL      = "HGETALL"
+	CMD_HKEYS        = "HKEYS"
+	CMD_HVALS        = "HVALS"
+	CMD_HEXISTS      = "HEXISTS"
+	CMD_HLEN         = "HLEN"
+	CMD_HINCRBY      = "HINCRBY"
+	CMD_HINCRBYFLOAT = "HINCRBYFLOAT"
+	CMD_HSCAN        = "HSCAN"
+
+	CMD_LPUSH     = "LPUSH"
+	CMD_RPUSH     = "RPUSH"
+	CMD_LPUSHX    = "LPUSHX"
+	CMD_RPUSHX    = "RPUSHX"
+	CMD_LPOP      = "LPOP"
+	CMD_RPOP      = "RPOP"
+	CMD_LLEN      = "LLEN"
+	CMD_LRANGE    = "LRANGE"
+	CMD_LINDEX    = "LINDEX"
+	CMD_LSET      = "LSET"
+	CMD_LREM      = "LREM"
+	CMD_LTRIM     = "LTRIM"
+	CMD_LINSERT   = "LINSERT"
+	CMD_LMOVE     = "LMOVE"
+	CMD_RPOPLPUSH = "RPOPLPUSH"
+	CMD_BLPOP     = "BLPOP"
+	CMD_BRPOP     = "BRPOP"
+	CMD_BLMOVE    = "BLMOVE"
+
+	CMD_SADD        = "SADD"
+	CMD_SREM        = "SREM"
+	CMD_SISMEMBER   = "SISMEMBER"
+	CMD_SMISMEMBER  = "SMISMEMBER"
+	CMD_SMEMBERS    = "SMEMBERS"
+	CMD_SCARD       = "SCARD"
+	CMD_SPOP        = "SPOP"
+	CMD_SRANDMEMBER = "SRANDMEMBER"
+	CMD_SMOVE       = "SMOVE"
+	CMD_SINTER      = "SINTER"
+	CMD_SUNION      = "SUNION"
+	CMD_SDIFF       = "SDIFF"
+	CMD_SINTERSTORE = "SINTERSTORE"
+	CMD_SUNIONSTORE = "SUNIONSTORE"
+	CMD_SDIFFSTORE  = "SDIFFSTORE"
+	CMD_SINTERCARD  = "SINTERCARD"
+
+	CMD_ZADD             = "ZADD"
+	CMD_ZINCRBY          = "ZINCRBY"
+	CMD_ZREM             = "ZREM"
+	CMD_ZSCORE           = "ZSCORE"
+	CMD_ZMSCORE          = "ZMSCORE"
+	CMD_ZCARD            = "ZCARD"
+	CMD_ZRANK            = "ZRANK"
+	CMD_ZREVRANK         = "ZREVRANK"
+	CMD_ZCOUNT           = "ZCOUNT"
+	CMD_ZLEXCOUNT        = "ZLEXCOUNT"
+	CMD_ZRANGE           = "ZRANGE"
+	CMD_ZREVRANGE        = "ZREVRANGE"
+	CMD_ZRANGEBYSCORE    = "ZRANGEBYSCORE"
+	CMD_ZREVRANGEBYSCORE = "ZREVRANGEBYSCORE"
+	CMD_ZRANGEBYLEX      = "ZRANGEBYLEX"
+	CMD_ZREVRANGEBYLEX   = "ZREVRANGEBYLEX"
+	CMD_ZPOPMIN          = "ZPOPMIN"
+	CMD_ZPOPMAX          = "ZPOPMAX"
+
+	CMD_XADD       = "XADD"
+	CMD_XLEN       = "XLEN"
+	CMD_XRANGE     = "XRANGE"
+	CMD_XREVRANGE  = "XREVRANGE"
+	CMD_XDEL       = "XDEL"
+	CMD_XTRIM      = "XTRIM"
+	CMD_XREAD      = "XREAD"
+	CMD_XGROUP     = "XGROUP"
+	CMD_XREADGROUP = "XREADGROUP"
+	CMD_XACK       = "XACK"
+	CMD_XPENDING   = "XPENDING"
+	CMD_XCLAIM     = "XCLAIM"
+
+	CMD_PFADD   = "PFADD"
+	CMD_PFCOUNT = "PFCOUNT"
+	CMD_PFMERGE = "PFMERGE"
+
+	CMD_BF_RESERVE = "BF.RESERVE"
+	CMD_BF_ADD     = "BF.ADD"
+	CMD_BF_MADD    = "BF.MADD"
+	CMD_BF_EXISTS  = "BF.EXISTS"
+	CMD_BF_MEXISTS = "BF.MEXISTS"
+	CMD_BF_INFO    = "BF.INFO"
+
+	CMD_CF_RESERVE = "CF.RESERVE"
+	CMD_CF_ADD     = "CF.ADD"
+	CMD_CF_ADDNX   = "CF.ADDNX"
+	CMD_CF_EXISTS  = "CF.EXISTS"
+	CMD_CF_MEXISTS = "CF.MEXISTS"
+	CMD_CF_COUNT   = "CF.COUNT"
+	CMD_CF_DEL     = "CF.DEL"
+	CMD_CF_INFO    = "CF.INFO"
+
+	CMD_CMS_INITBYDIM = "CMS.INITBYDIM"
+	CMD_CMS_INCRBY    = "CMS.INCRBY"
+	CMD_CMS_QUERY     = "CMS.QUERY"
+	CMD_CMS_MERGE     = "CMS.MERGE"
+
+	CMD_TOPK_RESERVE = "TOPK.RESERVE"
+	CMD_TOPK_ADD     = "TOPK.ADD"
+	CMD_TOPK_LIST    = "TOPK.LIST"
+
+	CMD_TDIGEST_CREATE   = "TDIGEST.CREATE"
+	CMD_TDIGEST_ADD      = "TDIGEST.ADD"
+	CMD_TDIGEST_QUANTILE = "TDIGEST.QUANTILE"
+	CMD_TDIGEST_CDF      = "TDIGEST.CDF"
+	CMD_TDIGEST_MERGE    = "TDIGEST.MERGE"
+
+	CMD_GEOADD         = "GEOADD"
+	CMD_GEOPOS         = "GEOPOS"
+	CMD_GEODIST        = "GEODIST"
+	CMD_GEOHASH        = "GEOHASH"
+	CMD_GEOSEARCH      = "GEOSEARCH"
+	CMD_GEOSEARCHSTORE = "GEOSEARCHSTORE"
+
+	CMD_HELLO  = "HELLO"
+	CMD_CONFIG = "CONFIG"
+	CMD_MEMORY = "MEMORY"
+	CMD_PING   = "PING"
+	CMD_RESET  = "RESET"
+
+	CMD_PUBLISH      = "PUBLISH"
+	CMD_SPUBLISH     = "SPUBLISH"
+	CMD_SUBSCRIBE    = "SUBSCRIBE"
+	CMD_PSUBSCRIBE   = "PSUBSCRIBE"
+	CMD_SSUBSCRIBE   = "SSUBSCRIBE"
+	CMD_UNSUBSCRIBE  = "UNSUBSCRIBE"
+	CMD_PUNSUBSCRIBE = "PUNSUBSCRIBE"
+	CMD_SUNSUBSCRIBE = "SUNSUBSCRIBE"
+	CMD_PUBSUB       = "PUBSUB"
+
+	CMD_MULTI   = "MULTI"
+	CMD_EXEC    = "EXEC"
+	CMD_DISCARD = "DISCARD"
+	CMD_WATCH   = "WATCH"
+	CMD_UNWATCH = "UNWATCH"
 )
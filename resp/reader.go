@@ -72,6 +72,9 @@ func (r *reader) readArray() (Value, error) {
 		return v, err
 	}
 
+	if length == -1 {
+		return Value{Type: NULL}, nil
+	}
 	if length < 0 {
 		return v, fmt.Errorf("Array length cant be negative")
 	}
@@ -97,6 +100,9 @@ func (r *reader) readBulkString() (Value, error) {
 		return v, err
 	}
 
+	if length == -1 {
+		return Value{Type: NULL}, nil
+	}
 	if length < 0 {
 		return v, fmt.Errorf("Bulk length cant be negative")
 	}
@@ -2,6 +2,7 @@ package resp
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 )
 
@@ -123,7 +124,16 @@ func (v Value) marshalBoolean() []byte {
 func (v Value) marshalDouble() []byte {
 	var bytes []byte
 	bytes = append(bytes, DOUBLE)
-	bytes = append(bytes, strconv.FormatFloat(v.Double, 'f', -1, 64)...)
+	switch {
+	case math.IsInf(v.Double, 1):
+		bytes = append(bytes, "inf"...)
+	case math.IsInf(v.Double, -1):
+		bytes = append(bytes, "-inf"...)
+	case math.IsNaN(v.Double):
+		bytes = append(bytes, "nan"...)
+	default:
+		bytes = append(bytes, strconv.FormatFloat(v.Double, 'f', -1, 64)...)
+	}
 	bytes = append(bytes, '\r', '\n')
 	return bytes
 }
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"simpleKV/resp"
+	"simpleKV/server/store"
+	"strconv"
+	"strings"
+)
+
+func (s *server) setbit(args []resp.Value) resp.Value {
+	offset, err := strconv.ParseInt(args[1].BulkString, 10, 64)
+	if err != nil {
+		return resp.NewErrorValue(store.ErrBitOffset.Error())
+	}
+	bit := args[2].BulkString
+	if bit != "0" && bit != "1" {
+		return resp.NewErrorValue("ERR bit is not an integer or out of range")
+	}
+
+	prev, err := s.store.SetBit(args[0].BulkString, offset, int(bit[0]-'0'))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(prev))
+}
+
+func (s *server) getbit(args []resp.Value) resp.Value {
+	offset, err := strconv.ParseInt(args[1].BulkString, 10, 64)
+	if err != nil {
+		return resp.NewErrorValue(store.ErrBitOffset.Error())
+	}
+
+	bit, err := s.store.GetBit(args[0].BulkString, offset)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(bit))
+}
+
+// parseBitRange reads the optional [start [end [BYTE | BIT]]] tail of BITCOUNT
+// and BITPOS.
+func parseBitRange(args []resp.Value) (store.BitRange, resp.Value, bool) {
+	var r store.BitRange
+	if len(args) == 0 {
+		return r, resp.Value{}, true
+	}
+
+	start, err := strconv.Atoi(args[0].BulkString)
+	if err != nil {
+		return r, resp.NewErrorValue("ERR value is not an integer or out of range"), false
+	}
+	r.Start = start
+
+	if len(args) > 1 {
+		end, err := strconv.Atoi(args[1].BulkString)
+		if err != nil {
+			return r, resp.NewErrorValue("ERR value is not an integer or out of range"), false
+		}
+		r.End, r.HasEnd = end, true
+	}
+
+	if len(args) > 2 {
+		switch strings.ToUpper(args[2].BulkString) {
+		case "BYTE":
+		case "BIT":
+			r.Bits = true
+		default:
+			return r, resp.NewErrorValue("ERR syntax error"), false
+		}
+	}
+	return r, resp.Value{}, true
+}
+
+// bitcount handles
+//
+//	BITCOUNT key [start end [BYTE | BIT]]
+func (s *server) bitcount(args []resp.Value) resp.Value {
+	if len(args) == 2 || len(args) > 4 {
+		return resp.NewErrorValue("ERR syntax error")
+	}
+
+	r, errReply, ok := parseBitRange(args[1:])
+	if !ok {
+		return errReply
+	}
+
+	n, err := s.store.BitCount(args[0].BulkString, r)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+// bitpos handles
+//
+//	BITPOS key bit [start [end [BYTE | BIT]]]
+func (s *server) bitpos(args []resp.Value) resp.Value {
+	if len(args) > 5 {
+		return resp.NewErrorValue("ERR syntax error")
+	}
+	bit := args[1].BulkString
+	if bit != "0" && bit != "1" {
+		return resp.NewErrorValue("ERR The bit argument must be 1 or 0.")
+	}
+
+	r, errReply, ok := parseBitRange(args[2:])
+	if !ok {
+		return errReply
+	}
+
+	pos, err := s.store.BitPos(args[0].BulkString, int(bit[0]-'0'), r)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(pos))
+}
+
+// bitop handles
+//
+//	BITOP AND | OR | XOR | NOT destkey key [key ...]
+func (s *server) bitop(args []resp.Value) resp.Value {
+	var op store.BitOp
+	switch strings.ToUpper(args[0].BulkString) {
+	case "AND":
+		op = store.BitAnd
+	case "OR":
+		op = store.BitOr
+	case "XOR":
+		op = store.BitXor
+	case "NOT":
+		op = store.BitNot
+	default:
+		return resp.NewErrorValue("ERR syntax error")
+	}
+
+	n, err := s.store.BitOp(op, args[1].BulkString, bulkStrings(args[2:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+// bitfield serves BITFIELD and BITFIELD_RO:
+//
+//	BITFIELD key [GET encoding offset | [OVERFLOW WRAP | SAT | FAIL]
+//	  SET encoding offset value | INCRBY encoding offset increment [...]]
+//
+// BITFIELD_RO only accepts GET.
+func (s *server) bitfield(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	var ops []store.BitFieldOp
+	overflow := store.OverflowWrap
+	for i := 1; i < len(args); i++ {
+		sub := strings.ToUpper(args[i].BulkString)
+		if sub == "OVERFLOW" && i+1 < len(args) {
+			switch strings.ToUpper(args[i+1].BulkString) {
+			case "WRAP":
+				overflow = store.OverflowWrap
+			case "SAT":
+				overflow = store.OverflowSat
+			case "FAIL":
+				overflow = store.OverflowFail
+			default:
+				return resp.NewErrorValue("ERR Invalid OVERFLOW type specified")
+			}
+			i++
+			continue
+		}
+
+		op := store.BitFieldOp{Overflow: overflow}
+		need := 3
+		switch sub {
+		case "GET":
+			op.Kind, need = store.BitFieldGet, 2
+		case "SET":
+			op.Kind = store.BitFieldSet
+		case "INCRBY":
+			op.Kind = store.BitFieldIncrBy
+		default:
+			return resp.NewErrorValue("ERR syntax error")
+		}
+		if i+need >= len(args) {
+			return resp.NewErrorValue("ERR syntax error")
+		}
+		if cmd == resp.CMD_BITFIELD_RO && op.Kind != store.BitFieldGet {
+			return resp.NewErrorValue("ERR BITFIELD_RO only supports the GET subcommand")
+		}
+
+		signed, width, ok := parseBitFieldType(args[i+1].BulkString)
+		if !ok {
+			return resp.NewErrorValue("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+		}
+		op.Signed, op.Bits = signed, width
+
+		offset, ok := parseBitFieldOffset(args[i+2].BulkString, width)
+		if !ok {
+			return resp.NewErrorValue(store.ErrBitOffset.Error())
+		}
+		op.Offset = offset
+
+		if need == 3 {
+			value, err := strconv.ParseInt(args[i+3].BulkString, 10, 64)
+			if err != nil {
+				return resp.NewErrorValue("ERR value is not an integer or out of range")
+			}
+			op.Value = value
+		}
+
+		ops = append(ops, op)
+		i += need
+	}
+
+	results, err := s.store.BitField(args[0].BulkString, ops)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	reply := make([]resp.Value, len(results))
+	for i, v := range results {
+		if v == nil {
+			reply[i] = resp.Value{Type: resp.NULL}
+		} else {
+			reply[i] = resp.NewIntegerValue(*v)
+		}
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
+
+// parseBitFieldType reads an encoding such as i16 or u8. Unsigned integers
+// are limited to 63 bits so that every value fits an int64 reply.
+func parseBitFieldType(str string) (bool, int, bool) {
+	if len(str) < 2 {
+		return false, 0, false
+	}
+	signed := str[0] == 'i' || str[0] == 'I'
+	if !signed && str[0] != 'u' && str[0] != 'U' {
+		return false, 0, false
+	}
+
+	width, err := strconv.Atoi(str[1:])
+	if err != nil || width < 1 || signed && width > 64 || !signed && width > 63 {
+		return false, 0, false
+	}
+	return signed, width, true
+}
+
+// parseBitFieldOffset reads a bit offset, or with a "#" prefix an index
+// into consecutive integers of the given width.
+func parseBitFieldOffset(str string, width int) (int64, bool) {
+	multiply := strings.HasPrefix(str, "#")
+	n, err := strconv.ParseInt(strings.TrimPrefix(str, "#"), 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	if multiply {
+		if n > (1<<62)/int64(width) {
+			return 0, false
+		}
+		n *= int64(width)
+	}
+	return n, true
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"simpleKV/resp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// servePop tries to serve a blocked client from key. It returns the reply for
+// the client, the key it pushed to (for BLMOVE) so clients blocked there can
+// be served in turn, and whether it succeeded. An error, such as the key
+// holding another type, is a reply too and ends the wait.
+type servePop func(key string) (reply resp.Value, pushed string, ok bool)
+
+type waiter struct {
+	keys   []string
+	serve  servePop
+	result chan resp.Value
+	done   bool
+}
+
+// blockingQueues parks clients blocked on list and stream keys. Every key
+// keeps its waiters in arrival order, and a push serves them first come,
+// first served.
+type blockingQueues struct {
+	mu      sync.Mutex
+	waiters map[string][]*waiter
+
+	// txMu is the server's transaction lock, which a parked client gives up
+	// while it waits.
+	txMu *sync.RWMutex
+}
+
+// noWait is the timeout of blocking commands run by EXEC. As in Redis they
+// don't block there and act as if they timed out straight away.
+const noWait time.Duration = -1
+
+func newBlockingQueues(txMu *sync.RWMutex) *blockingQueues {
+	return &blockingQueues{
+		waiters: make(map[string][]*waiter),
+		txMu:    txMu,
+	}
+}
+
+// wait serves the client right away from the first of keys that has data and
+// no one queued ahead of it. Otherwise it parks the calling connection until a
+// push serves it, timeout passes or gone is closed; a zero timeout waits
+// forever.
+func (b *blockingQueues) wait(keys []string, timeout time.Duration, gone <-chan struct{}, serve servePop) (resp.Value, bool) {
+	b.mu.Lock()
+	for _, key := range keys {
+		if len(b.waiters[key]) > 0 {
+			continue
+		}
+		if reply, pushed, ok := serve(key); ok {
+			b.mu.Unlock()
+			if pushed != "" {
+				b.signal(pushed)
+			}
+			return reply, true
+		}
+	}
+
+	return b.park(keys, timeout, gone, serve)
+}
+
+// waitStream parks a stream reader until an XADD to one of keys serves it.
+// The caller has already tried to read; unlike wait it does not defer to
+// clients queued ahead, since reading a stream leaves the entries in place.
+func (b *blockingQueues) waitStream(keys []string, timeout time.Duration, gone <-chan struct{}, serve servePop) (resp.Value, bool) {
+	b.mu.Lock()
+	if reply, _, ok := serve(""); ok {
+		b.mu.Unlock()
+		return reply, true
+	}
+	return b.park(keys, timeout, gone, serve)
+}
+
+// park queues a waiter on keys and blocks until it is served, timeout passes
+// or gone is closed because the client disconnected. It expects b.mu to be
+// held and releases it.
+func (b *blockingQueues) park(keys []string, timeout time.Duration, gone <-chan struct{}, serve servePop) (resp.Value, bool) {
+	if timeout == noWait {
+		b.mu.Unlock()
+		return resp.Value{}, false
+	}
+
+	w := &waiter{
+		keys:   keys,
+		serve:  serve,
+		result: make(chan resp.Value, 1),
+	}
+	for _, key := range keys {
+		b.waiters[key] = append(b.waiters[key], w)
+	}
+	b.mu.Unlock()
+
+	// The command runs under txMu for reading; a client that may wait for
+	// a long time must not hold up transactions on other connections.
+	b.txMu.RUnlock()
+	defer b.txMu.RLock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	// A waiter left queued by a client that is gone would take the next
+	// push with it, so one that gives up leaves the queues first.
+	select {
+	case reply := <-w.result:
+		return reply, true
+	case <-expired:
+	case <-gone:
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if w.done {
+		return <-w.result, true
+	}
+	b.unregister(w)
+	return resp.Value{}, false
+}
+
+// signal serves the clients blocked on keys, oldest first. A client that
+// cannot be served yet keeps its place without holding up the ones behind it.
+// Commands call it after pushing to a list or adding to a stream.
+func (b *blockingQueues) signal(keys ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for len(keys) > 0 {
+		key := keys[0]
+		keys = keys[1:]
+
+		for _, w := range slices.Clone(b.waiters[key]) {
+			reply, pushed, ok := w.serve(key)
+			if !ok {
+				continue
+			}
+
+			b.unregister(w)
+			w.done = true
+			w.result <- reply
+
+			if pushed != "" {
+				keys = append(keys, pushed)
+			}
+		}
+	}
+}
+
+// unregister expects b.mu to be held.
+func (b *blockingQueues) unregister(w *waiter) {
+	for _, key := range w.keys {
+		queue := slices.DeleteFunc(b.waiters[key], func(other *waiter) bool { return other == w })
+		if len(queue) == 0 {
+			delete(b.waiters, key)
+		} else {
+			b.waiters[key] = queue
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"math"
+	"simpleKV/resp"
+	"simpleKV/server/store"
+	"strconv"
+	"strings"
+)
+
+// bfreserve handles
+//
+//	BF.RESERVE key error_rate capacity [EXPANSION expansion] [NONSCALING]
+func (s *server) bfreserve(args []resp.Value) resp.Value {
+	opts := store.BloomOptions{Expansion: 2}
+
+	errorRate, err := strconv.ParseFloat(args[1].BulkString, 64)
+	if err != nil || math.IsNaN(errorRate) {
+		return resp.NewErrorValue("ERR bad error rate")
+	}
+	if errorRate <= 0 || errorRate >= 1 {
+		return resp.NewErrorValue("ERR (0 < error rate range < 1)")
+	}
+	opts.ErrorRate = errorRate
+
+	capacity, err := strconv.Atoi(args[2].BulkString)
+	if err != nil {
+		return resp.NewErrorValue("ERR bad capacity")
+	}
+	if capacity <= 0 {
+		return resp.NewErrorValue("ERR (capacity should be larger than 0)")
+	}
+	opts.Capacity = capacity
+
+	hasExpansion, nonScaling := false, false
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].BulkString); {
+		case opt == "EXPANSION" && i+1 < len(args):
+			expansion, err := strconv.Atoi(args[i+1].BulkString)
+			if err != nil {
+				return resp.NewErrorValue("ERR bad expansion")
+			}
+			if expansion < 1 {
+				return resp.NewErrorValue("ERR expansion should be greater or equal to 1")
+			}
+			opts.Expansion, hasExpansion = expansion, true
+			i++
+		case opt == "NONSCALING":
+			nonScaling = true
+		default:
+			return resp.NewErrorValue("ERR syntax error")
+		}
+	}
+	if nonScaling {
+		if hasExpansion {
+			return resp.NewErrorValue("ERR Nonscaling filters cannot expand")
+		}
+		opts.Expansion = 0
+	}
+
+	if err := s.store.BFReserve(args[0].BulkString, opts); err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+}
+
+// bfadd serves BF.ADD, which answers with a single integer, and BF.MADD,
+// which answers with one per item. Items past the point where a
+// non-scaling filter filled up get an error each.
+func (s *server) bfadd(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	added, err := s.store.BFAdd(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil && (cmd == resp.CMD_BF_ADD || err != store.ErrBloomFull) {
+		return resp.NewErrorValue(err.Error())
+	}
+	if cmd == resp.CMD_BF_ADD {
+		return boolInteger(added[0])
+	}
+
+	reply := make([]resp.Value, len(args)-1)
+	for i := range reply {
+		if i < len(added) {
+			reply[i] = boolInteger(added[i])
+		} else {
+			reply[i] = resp.NewErrorValue(err.Error())
+		}
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
+
+// bfexists serves BF.EXISTS and BF.MEXISTS the way bfadd serves BF.ADD and
+// BF.MADD.
+func (s *server) bfexists(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	found, err := s.store.BFExists(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if cmd == resp.CMD_BF_EXISTS {
+		return boolInteger(found[0])
+	}
+
+	reply := make([]resp.Value, len(found))
+	for i, ok := range found {
+		reply[i] = boolInteger(ok)
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
+
+// bfinfo handles
+//
+//	BF.INFO key [CAPACITY | SIZE | FILTERS | ITEMS | EXPANSION]
+//
+// A non-scaling filter reports a null expansion rate.
+func (s *server) bfinfo(sess *session, args []resp.Value) resp.Value {
+	if len(args) > 2 {
+		return wrongNumberOfArgs(resp.CMD_BF_INFO)
+	}
+
+	info, err := s.store.BFInfo(args[0].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	expansion := resp.Value{Type: resp.NULL}
+	if info.Expansion > 0 {
+		expansion = resp.NewIntegerValue(int64(info.Expansion))
+	}
+	fields := []struct {
+		option, name string
+		value        resp.Value
+	}{
+		{"CAPACITY", "Capacity", resp.NewIntegerValue(int64(info.Capacity))},
+		{"SIZE", "Size", resp.NewIntegerValue(int64(info.Size))},
+		{"FILTERS", "Number of filters", resp.NewIntegerValue(int64(info.Filters))},
+		{"ITEMS", "Number of items inserted", resp.NewIntegerValue(int64(info.Items))},
+		{"EXPANSION", "Expansion rate", expansion},
+	}
+
+	if len(args) == 2 {
+		opt := strings.ToUpper(args[1].BulkString)
+		for _, f := range fields {
+			if f.option == opt {
+				return resp.Value{Type: resp.ARRAY, Array: []resp.Value{f.value}}
+			}
+		}
+		return resp.NewErrorValue("ERR Invalid information value")
+	}
+
+	var pairs []resp.Value
+	for _, f := range fields {
+		pairs = append(pairs, resp.Value{Type: resp.BULK_STRING, BulkString: f.name}, f.value)
+	}
+	return sess.mapReply(pairs)
+}
+
+// cfreserve handles
+//
+//	CF.RESERVE key capacity [BUCKETSIZE bucketsize] [MAXITERATIONS maxiterations] [EXPANSION expansion]
+func (s *server) cfreserve(args []resp.Value) resp.Value {
+	opts := store.CuckooOptions{BucketSize: 2, MaxIterations: 20, Expansion: 1}
+
+	capacity, err := strconv.Atoi(args[1].BulkString)
+	if err != nil || capacity <= 0 {
+		return resp.NewErrorValue("ERR Bad capacity")
+	}
+	opts.Capacity = capacity
+
+	for i := 2; i < len(args); i++ {
+		opt := strings.ToUpper(args[i].BulkString)
+		if i+1 == len(args) {
+			return resp.NewErrorValue("ERR syntax error")
+		}
+		n, err := strconv.Atoi(args[i+1].BulkString)
+		switch {
+		case opt == "BUCKETSIZE":
+			if err != nil || n < 1 || n > 255 {
+				return resp.NewErrorValue("ERR Bucket size must be between 1 and 255")
+			}
+			opts.BucketSize = n
+		case opt == "MAXITERATIONS":
+			if err != nil || n < 1 || n > 65535 {
+				return resp.NewErrorValue("ERR Max iterations must be between 1 and 65535")
+			}
+			opts.MaxIterations = n
+		case opt == "EXPANSION":
+			if err != nil || n < 0 || n > 32768 {
+				return resp.NewErrorValue("ERR Expansion must be in range of [0, 32768]")
+			}
+			opts.Expansion = n
+		default:
+			return resp.NewErrorValue("ERR syntax error")
+		}
+		i++
+	}
+	if opts.Capacity < 2*opts.BucketSize {
+		return resp.NewErrorValue("ERR Capacity must be at least (BucketSize * 2)")
+	}
+
+	if err := s.store.CFReserve(args[0].BulkString, opts); err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+}
+
+// cfadd serves CF.ADD and CF.ADDNX, which leaves out items that may already
+// be in the filter.
+func (s *server) cfadd(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	added, err := s.store.CFAdd(args[0].BulkString, args[1].BulkString, cmd == resp.CMD_CF_ADDNX)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return boolInteger(added)
+}
+
+// cfexists serves CF.EXISTS and CF.MEXISTS the way bfexists serves their
+// bloom filter counterparts.
+func (s *server) cfexists(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	found, err := s.store.CFExists(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if cmd == resp.CMD_CF_EXISTS {
+		return boolInteger(found[0])
+	}
+
+	reply := make([]resp.Value, len(found))
+	for i, ok := range found {
+		reply[i] = boolInteger(ok)
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
+
+func (s *server) cfcount(args []resp.Value) resp.Value {
+	n, err := s.store.CFCount(args[0].BulkString, args[1].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+func (s *server) cfdel(args []resp.Value) resp.Value {
+	deleted, err := s.store.CFDel(args[0].BulkString, args[1].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return boolInteger(deleted)
+}
+
+func (s *server) cfinfo(sess *session, args []resp.Value) resp.Value {
+	info, err := s.store.CFInfo(args[0].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	field := func(name string, value int) []resp.Value {
+		return []resp.Value{{Type: resp.BULK_STRING, BulkString: name}, resp.NewIntegerValue(int64(value))}
+	}
+	var pairs []resp.Value
+	pairs = append(pairs, field("Size", info.Size)...)
+	pairs = append(pairs, field("Number of buckets", info.Buckets)...)
+	pairs = append(pairs, field("Number of filters", info.Filters)...)
+	pairs = append(pairs, field("Number of items inserted", info.Items)...)
+	pairs = append(pairs, field("Number of items deleted", info.Deleted)...)
+	pairs = append(pairs, field("Bucket size", info.BucketSize)...)
+	pairs = append(pairs, field("Expansion rate", info.Expansion)...)
+	pairs = append(pairs, field("Max iterations", info.MaxIterations)...)
+	return sess.mapReply(pairs)
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"maps"
+	"simpleKV/resp"
+	"slices"
+	"strings"
+)
+
+// command handles
+//
+//	COMMAND
+//	COMMAND COUNT
+//	COMMAND INFO [command-name ...]
+//	COMMAND DOCS [command-name ...]
+//	COMMAND GETKEYS command [arg ...]
+func (s *server) command(sess *session, args []resp.Value) resp.Value {
+	if len(args) == 0 {
+		return commandInfos(sess, nil)
+	}
+
+	switch sub := strings.ToUpper(args[0].BulkString); {
+	case sub == "COUNT" && len(args) == 1:
+		return resp.NewIntegerValue(int64(len(commands)))
+
+	case sub == "INFO":
+		return commandInfos(sess, args[1:])
+
+	case sub == "DOCS":
+		var pairs []resp.Value
+		for _, spec := range lookupCommands(args[1:]) {
+			if spec != nil {
+				pairs = append(pairs,
+					resp.Value{Type: resp.BULK_STRING, BulkString: strings.ToLower(string(spec.name))},
+					spec.docs(sess))
+			}
+		}
+		return sess.mapReply(pairs)
+
+	case sub == "GETKEYS" && len(args) >= 2:
+		argv := args[1:]
+		spec, ok := commands[resp.RESPCommand(strings.ToUpper(argv[0].BulkString))]
+		if !ok {
+			return resp.NewErrorValue("ERR Invalid command specified")
+		}
+		if !spec.checkArity(len(argv)) {
+			return resp.NewErrorValue("ERR Invalid number of arguments specified for command")
+		}
+		positions := spec.keyPositions(argv)
+		if len(positions) == 0 {
+			return resp.NewErrorValue("ERR The command has no key arguments")
+		}
+		keys := make([]string, len(positions))
+		for i, pos := range positions {
+			keys[i] = argv[pos].BulkString
+		}
+		return resp.Value{Type: resp.ARRAY, Array: createBulkStringArray(keys)}
+	}
+
+	return resp.NewErrorValue("ERR unknown subcommand or wrong number of arguments for '" + args[0].BulkString + "'. Try COMMAND HELP.")
+}
+
+// lookupCommands returns the spec of each name, nil for the unknown ones,
+// or every spec sorted by name when names is empty.
+func lookupCommands(names []resp.Value) []*commandSpec {
+	if len(names) == 0 {
+		specs := make([]*commandSpec, 0, len(commands))
+		for _, name := range slices.Sorted(maps.Keys(commands)) {
+			specs = append(specs, commands[name])
+		}
+		return specs
+	}
+
+	specs := make([]*commandSpec, len(names))
+	for i, name := range names {
+		specs[i] = commands[resp.RESPCommand(strings.ToUpper(name.BulkString))]
+	}
+	return specs
+}
+
+// commandInfos answers COMMAND and COMMAND INFO, with NULL in place of an
+// unknown name.
+func commandInfos(sess *session, names []resp.Value) resp.Value {
+	specs := lookupCommands(names)
+	infos := make([]resp.Value, len(specs))
+	for i, spec := range specs {
+		if spec == nil {
+			infos[i] = resp.Value{Type: resp.NULL}
+			continue
+		}
+		infos[i] = spec.info(sess)
+	}
+	return resp.Value{Type: resp.ARRAY, Array: infos}
+}
+
+// info describes the command in the ten-element layout of Redis 7: name,
+// arity, flags, first key, last key, key step, ACL categories, tips, key
+// specifications and subcommands. The last three are left empty.
+func (spec *commandSpec) info(sess *session) resp.Value {
+	var flags []string
+	for _, fl := range commandFlagNames {
+		if spec.flags&fl.flag != 0 {
+			flags = append(flags, fl.name)
+		}
+	}
+	keys := spec.keys
+	if spec.movableKeys != nil {
+		flags = append(flags, "movablekeys")
+		keys = keyRange{}
+	}
+
+	return resp.Value{Type: resp.ARRAY, Array: []resp.Value{
+		{Type: resp.BULK_STRING, BulkString: strings.ToLower(string(spec.name))},
+		resp.NewIntegerValue(int64(spec.arity)),
+		sess.setReply(flags),
+		resp.NewIntegerValue(int64(keys.first)),
+		resp.NewIntegerValue(int64(keys.last)),
+		resp.NewIntegerValue(int64(keys.step)),
+		sess.setReply(spec.aclCategories()),
+		{Type: resp.ARRAY, Array: []resp.Value{}},
+		{Type: resp.ARRAY, Array: []resp.Value{}},
+		{Type: resp.ARRAY, Array: []resp.Value{}},
+	}}
+}
+
+// groupCategories maps the group a command is documented under to its ACL
+// category.
+var groupCategories = map[string]string{
+	"generic": "@keyspace", "string": "@string", "bitmap": "@bitmap", "hash": "@hash",
+	"list": "@list", "set": "@set", "sorted-set": "@sortedset", "stream": "@stream",
+	"hyperloglog": "@hyperloglog", "geo": "@geo", "connection": "@connection",
+	"pubsub": "@pubsub", "transactions": "@transaction", "bf": "@bloom", "cf": "@cuckoo",
+	"cms": "@cms", "topk": "@topk", "tdigest": "@tdigest",
+}
+
+func (spec *commandSpec) aclCategories() []string {
+	var cats []string
+	switch {
+	case spec.flags&flagWrite != 0:
+		cats = append(cats, "@write")
+	case spec.flags&flagReadonly != 0:
+		cats = append(cats, "@read")
+	}
+	if cat, ok := groupCategories[spec.group]; ok {
+		cats = append(cats, cat)
+	}
+	if spec.flags&flagAdmin != 0 {
+		cats = append(cats, "@admin", "@dangerous")
+	}
+	if spec.flags&flagFast != 0 {
+		cats = append(cats, "@fast")
+	} else {
+		cats = append(cats, "@slow")
+	}
+	if spec.flags&flagBlocking != 0 {
+		cats = append(cats, "@blocking")
+	}
+	return cats
+}
+
+// docs is the COMMAND DOCS entry of the command.
+func (spec *commandSpec) docs(sess *session) resp.Value {
+	return sess.mapReply([]resp.Value{
+		{Type: resp.BULK_STRING, BulkString: "summary"},
+		{Type: resp.BULK_STRING, BulkString: spec.summary},
+		{Type: resp.BULK_STRING, BulkString: "since"},
+		{Type: resp.BULK_STRING, BulkString: spec.since},
+		{Type: resp.BULK_STRING, BulkString: "group"},
+		{Type: resp.BULK_STRING, BulkString: spec.group},
+	})
+}
@@ -7,93 +7,687 @@ import (
 	"strings"
 )
 
-func (s *server) handleRequest(req resp.Value) resp.Value {
+func (s *server) handleRequest(sess *session, req resp.Value) resp.Value {
 	if req.Type != resp.ARRAY || len(req.Array) < 1 {
 		return resp.NewErrorValue("ERR invalid request format")
 	}
 
 	cmd := resp.RESPCommand(strings.ToUpper(req.Array[0].BulkString))
 
-	switch cmd {
-	case resp.CMD_SET:
-		if len(req.Array) != 3 {
-			return resp.NewErrorValue("ERR wrong number of arguments for 'SET' command")
+	if sess.subscribed() && sess.protocol == 2 && !allowedWhileSubscribed[cmd] {
+		return resp.NewErrorValue(fmt.Sprintf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context",
+			strings.ToLower(string(cmd))))
+	}
+
+	spec, ok := commands[cmd]
+	switch {
+	case !ok:
+		return sess.tx.refuse(resp.NewErrorValue(fmt.Sprintf("ERR unknown command '%s'", cmd)))
+	case !spec.checkArity(len(req.Array)):
+		return sess.tx.refuse(wrongNumberOfArgs(cmd))
+	case transactionControl[cmd]:
+		return spec.handler(s, sess, cmd, req.Array[1:])
+	case sess.tx.active && cmd != resp.CMD_RESET:
+		return s.queue(sess, spec, req.Array[1:])
+	}
+
+	// Transactions take txMu for writing to run their commands without
+	// anything else interleaving.
+	s.txMu.RLock()
+	defer s.txMu.RUnlock()
+
+	return s.execute(sess, spec, req.Array[1:])
+}
+
+// execute runs a single command whose arity was already checked. It expects
+// the caller to hold txMu.
+func (s *server) execute(sess *session, spec *commandSpec, args []resp.Value) resp.Value {
+	if spec.flags&flagDenyOOM != 0 {
+		if err := s.store.FreeMemory(); err != nil {
+			return resp.NewErrorValue(err.Error())
 		}
-		key := req.Array[1].BulkString
-		value := req.Array[2]
-		s.store.Set(key, value)
-		return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
-
-	case resp.CMD_GET:
-		if len(req.Array) != 2 {
-			return resp.NewErrorValue("ERR wrong number of arguments for 'GET' command")
+	}
+	return spec.handler(s, sess, spec.name, args)
+}
+
+// commandHandler serves one command. args leaves out the command name.
+type commandHandler func(s *server, sess *session, cmd resp.RESPCommand, args []resp.Value) resp.Value
+
+// The adapters below let the table point at handlers that need less than
+// the full commandHandler signature.
+
+func withArgs(fn func(*server, []resp.Value) resp.Value) commandHandler {
+	return func(s *server, _ *session, _ resp.RESPCommand, args []resp.Value) resp.Value {
+		return fn(s, args)
+	}
+}
+
+func withCmd(fn func(*server, resp.RESPCommand, []resp.Value) resp.Value) commandHandler {
+	return func(s *server, _ *session, cmd resp.RESPCommand, args []resp.Value) resp.Value {
+		return fn(s, cmd, args)
+	}
+}
+
+func withSession(fn func(*server, *session, []resp.Value) resp.Value) commandHandler {
+	return func(s *server, sess *session, _ resp.RESPCommand, args []resp.Value) resp.Value {
+		return fn(s, sess, args)
+	}
+}
+
+// commandFlags describe a command the way COMMAND INFO reports it. Some of
+// them also change how the command is run.
+type commandFlags uint32
+
+const (
+	flagWrite    commandFlags = 1 << iota
+	flagReadonly              // never modifies the dataset
+	flagDenyOOM               // may grow the dataset, so refused while over maxmemory
+	flagAdmin
+	flagPubSub
+	flagNoScript
+	flagBlocking // may wait for data
+	flagFast     // O(1) or O(log N)
+	flagNoMulti  // may not be queued between MULTI and EXEC
+)
+
+var commandFlagNames = []struct {
+	flag commandFlags
+	name string
+}{
+	{flagWrite, "write"}, {flagReadonly, "readonly"}, {flagDenyOOM, "denyoom"},
+	{flagAdmin, "admin"}, {flagPubSub, "pubsub"}, {flagNoScript, "noscript"},
+	{flagBlocking, "blocking"}, {flagFast, "fast"}, {flagNoMulti, "no_multi"},
+}
+
+// keyRange locates the keys of a command as Redis' legacy key positions
+// do: the first and last argument holding a key, counting the command name
+// as 0, and the distance between keys. A negative last counts from the end.
+type keyRange struct {
+	first, last, step int
+}
+
+var (
+	oneKey   = keyRange{1, 1, 1}
+	twoKeys  = keyRange{1, 2, 1}
+	allKeys  = keyRange{1, -1, 1}
+	pairKeys = keyRange{1, -1, 2}
+)
+
+// commandSpec is everything known about a command: what dispatch needs to
+// validate and run it, and what COMMAND INFO and COMMAND DOCS report.
+type commandSpec struct {
+	name  resp.RESPCommand
+	arity int // counts the command name; negative means at least -arity
+	flags commandFlags
+	keys  keyRange
+	// movableKeys finds the keys of commands whose key positions depend on
+	// their arguments. It gets the whole request, name included.
+	movableKeys func(argv []resp.Value) []int
+	group       string
+	since       string
+	summary     string
+	handler     commandHandler
+}
+
+func (spec *commandSpec) checkArity(argc int) bool {
+	return spec.arity > 0 && argc == spec.arity || spec.arity < 0 && argc >= -spec.arity
+}
+
+// keyPositions returns the indexes of the keys in argv, which holds the
+// whole request.
+func (spec *commandSpec) keyPositions(argv []resp.Value) []int {
+	if spec.movableKeys != nil {
+		return spec.movableKeys(argv)
+	}
+	if spec.keys.first == 0 {
+		return nil
+	}
+	last := spec.keys.last
+	if last < 0 {
+		last += len(argv)
+	}
+	var positions []int
+	for i := spec.keys.first; i <= last && i < len(argv); i += spec.keys.step {
+		positions = append(positions, i)
+	}
+	return positions
+}
+
+// numKeysPositions finds the keys of commands shaped like
+//
+//	SINTERCARD numkeys key [key ...] ...
+func numKeysPositions(argv []resp.Value) []int {
+	n, err := strconv.Atoi(argv[1].BulkString)
+	if err != nil || n < 1 || n > len(argv)-2 {
+		return nil
+	}
+	positions := make([]int, n)
+	for i := range positions {
+		positions[i] = 2 + i
+	}
+	return positions
+}
+
+// destNumKeysPositions finds the keys of commands shaped like
+//
+//	CMS.MERGE destination numkeys key [key ...] ...
+func destNumKeysPositions(argv []resp.Value) []int {
+	if len(argv) < 3 {
+		return nil
+	}
+	sources := numKeysPositions(argv[1:])
+	if sources == nil {
+		return nil
+	}
+	positions := []int{1}
+	for _, i := range sources {
+		positions = append(positions, 1+i)
+	}
+	return positions
+}
+
+// streamKeyPositions finds the keys of XREAD and XREADGROUP: the first half
+// of what follows STREAMS.
+func streamKeyPositions(argv []resp.Value) []int {
+	for i, arg := range argv {
+		if strings.ToUpper(arg.BulkString) != "STREAMS" {
+			continue
 		}
-		key := req.Array[1].BulkString
-		value, found := s.store.Get(key)
-		if !found {
-			return resp.Value{Type: resp.NULL}
+		n := (len(argv) - i - 1) / 2
+		positions := make([]int, n)
+		for j := range positions {
+			positions[j] = i + 1 + j
 		}
-		return value
+		return positions
+	}
+	return nil
+}
 
-	case resp.CMD_DEL:
-		if len(req.Array) < 2 {
-			return resp.NewErrorValue("ERR wrong number of arguments for 'DEL' command")
+// subcommandKey finds the key of container commands such as MEMORY and
+// XGROUP, where only some subcommands take one, right after their name.
+func subcommandKey(subs ...string) func(argv []resp.Value) []int {
+	return func(argv []resp.Value) []int {
+		if len(argv) < 3 {
+			return nil
 		}
-		deletedCount := 0
-		for _, keyValue := range req.Array[1:] {
-			if s.store.Del(keyValue.BulkString) {
-				deletedCount++
+		sub := strings.ToUpper(argv[1].BulkString)
+		for _, name := range subs {
+			if sub == name {
+				return []int{2}
 			}
 		}
-		return resp.NewIntegerValue(int64(deletedCount))
+		return nil
+	}
+}
 
-	case resp.CMD_COMMAND:
-		if len(req.Array) == 2 && strings.ToUpper(req.Array[1].BulkString) == "DOCS" {
-			return resp.Value{
-				Type:  resp.ARRAY,
-				Array: []resp.Value{},
-			}
-		}
-		return resp.NewErrorValue("ERR unknown subcommand for 'COMMAND'")
-
-	case resp.CMD_INFO:
-		info := "redis_version: 0.0.1\n"
-		info += "connected_clients: 1\n"
-		info += "used_memory: 1024\n"
-		info += "uptime_in_seconds: 3600\n"
-		info += "keys_count: 1000\n"
-
-		return resp.Value{
-			Type:   resp.SIMPLE_STRING,
-			String: info,
-		}
+// commands is filled in by init rather than by its declaration, since the
+// handlers of COMMAND and EXEC look commands up themselves.
+var commands map[resp.RESPCommand]*commandSpec
 
-	case resp.CMD_SCAN:
-		cursor := 0
-		matchPattern := ""
-		count := 100
+func init() {
+	const (
+		w  = flagWrite
+		r  = flagReadonly
+		wm = flagWrite | flagDenyOOM
+	)
 
-		if len(req.Array) > 1 {
-			parsedCursor, err := strconv.Atoi(req.Array[1].BulkString)
-			if err == nil {
-				cursor = parsedCursor 
-			}
-		}
-		if len(req.Array) > 2 {
-			matchPattern = req.Array[2].BulkString
-		}
-		if len(req.Array) > 3 {
-			parsedCount, err := strconv.Atoi(req.Array[3].BulkString)
-			if err == nil {
-				count = parsedCount
-			}
-		}
+	table := []commandSpec{
+		// generic
+		{name: resp.CMD_DEL, arity: -2, flags: w, keys: allKeys, group: "generic", since: "1.0.0",
+			summary: "Deletes one or more keys.", handler: withArgs((*server).del)},
+		{name: resp.CMD_EXISTS, arity: -2, flags: r | flagFast, keys: allKeys, group: "generic", since: "1.0.0",
+			summary: "Determines whether one or more keys exist.", handler: withArgs((*server).exists)},
+		{name: resp.CMD_TOUCH, arity: -2, flags: r | flagFast, keys: allKeys, group: "generic", since: "3.2.1",
+			summary: "Returns the number of existing keys out of those specified after updating the time they were last accessed.",
+			handler: withArgs((*server).touch)},
+		{name: resp.CMD_TYPE, arity: 2, flags: r | flagFast, keys: oneKey, group: "generic", since: "1.0.0",
+			summary: "Determines the type of value stored at a key.", handler: withArgs((*server).keyType)},
+		{name: resp.CMD_EXPIRE, arity: -3, flags: w | flagFast, keys: oneKey, group: "generic", since: "1.0.0",
+			summary: "Sets the expiration time of a key in seconds.", handler: withCmd((*server).expire)},
+		{name: resp.CMD_PEXPIRE, arity: -3, flags: w | flagFast, keys: oneKey, group: "generic", since: "2.6.0",
+			summary: "Sets the expiration time of a key in milliseconds.", handler: withCmd((*server).expire)},
+		{name: resp.CMD_EXPIREAT, arity: -3, flags: w | flagFast, keys: oneKey, group: "generic", since: "1.2.0",
+			summary: "Sets the expiration time of a key to a Unix timestamp.", handler: withCmd((*server).expire)},
+		{name: resp.CMD_PEXPIREAT, arity: -3, flags: w | flagFast, keys: oneKey, group: "generic", since: "2.6.0",
+			summary: "Sets the expiration time of a key to a Unix milliseconds timestamp.", handler: withCmd((*server).expire)},
+		{name: resp.CMD_TTL, arity: 2, flags: r | flagFast, keys: oneKey, group: "generic", since: "1.0.0",
+			summary: "Returns the expiration time in seconds of a key.", handler: withCmd((*server).ttl)},
+		{name: resp.CMD_PTTL, arity: 2, flags: r | flagFast, keys: oneKey, group: "generic", since: "2.6.0",
+			summary: "Returns the expiration time in milliseconds of a key.", handler: withCmd((*server).ttl)},
+		{name: resp.CMD_PERSIST, arity: 2, flags: w | flagFast, keys: oneKey, group: "generic", since: "2.2.0",
+			summary: "Removes the expiration time of a key.", handler: withArgs((*server).persist)},
+		{name: resp.CMD_RENAME, arity: 3, flags: w, keys: twoKeys, group: "generic", since: "1.0.0",
+			summary: "Renames a key and overwrites the destination.", handler: withCmd((*server).rename)},
+		{name: resp.CMD_RENAMENX, arity: 3, flags: w | flagFast, keys: twoKeys, group: "generic", since: "1.0.0",
+			summary: "Renames a key only when the target key name doesn't exist.", handler: withCmd((*server).rename)},
+		{name: resp.CMD_COPY, arity: -3, flags: wm, keys: twoKeys, group: "generic", since: "6.2.0",
+			summary: "Copies the value of a key to a new key.", handler: withArgs((*server).copyKey)},
+		{name: resp.CMD_RANDOMKEY, arity: 1, flags: r, group: "generic", since: "1.0.0",
+			summary: "Returns a random key name from the database.",
+			handler: withArgs(func(s *server, _ []resp.Value) resp.Value { return s.randomKey() })},
+		{name: resp.CMD_DBSIZE, arity: 1, flags: r | flagFast, group: "server", since: "1.0.0",
+			summary: "Returns the number of keys in the database.", handler: withArgs((*server).dbsize)},
+		{name: resp.CMD_KEYS, arity: 2, flags: r, group: "generic", since: "1.0.0",
+			summary: "Returns all key names that match a pattern.", handler: withArgs((*server).keys)},
+		{name: resp.CMD_SCAN, arity: -2, flags: r, group: "generic", since: "2.8.0",
+			summary: "Iterates over the key names in the database.", handler: withArgs((*server).scan)},
+
+		// string
+		{name: resp.CMD_SET, arity: -3, flags: wm, keys: oneKey, group: "string", since: "1.0.0",
+			summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.",
+			handler: withArgs((*server).set)},
+		{name: resp.CMD_GET, arity: 2, flags: r | flagFast, keys: oneKey, group: "string", since: "1.0.0",
+			summary: "Returns the string value of a key.", handler: withArgs((*server).get)},
+		{name: resp.CMD_INCR, arity: 2, flags: wm | flagFast, keys: oneKey, group: "string", since: "1.0.0",
+			summary: "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.",
+			handler: withCmd((*server).incr)},
+		{name: resp.CMD_DECR, arity: 2, flags: wm | flagFast, keys: oneKey, group: "string", since: "1.0.0",
+			summary: "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.",
+			handler: withCmd((*server).incr)},
+		{name: resp.CMD_INCRBY, arity: 3, flags: wm | flagFast, keys: oneKey, group: "string", since: "1.0.0",
+			summary: "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist.",
+			handler: withCmd((*server).incr)},
+		{name: resp.CMD_DECRBY, arity: 3, flags: wm | flagFast, keys: oneKey, group: "string", since: "1.0.0",
+			summary: "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist.",
+			handler: withCmd((*server).incr)},
+		{name: resp.CMD_INCRBYFLOAT, arity: 3, flags: wm | flagFast, keys: oneKey, group: "string", since: "2.6.0",
+			summary: "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist.",
+			handler: withArgs((*server).incrbyfloat)},
+		{name: resp.CMD_APPEND, arity: 3, flags: wm | flagFast, keys: oneKey, group: "string", since: "2.0.0",
+			summary: "Appends a string to the value of a key. Creates the key if it doesn't exist.",
+			handler: withArgs((*server).appendString)},
+		{name: resp.CMD_STRLEN, arity: 2, flags: r | flagFast, keys: oneKey, group: "string", since: "2.2.0",
+			summary: "Returns the length of a string value.", handler: withArgs((*server).strlen)},
+		{name: resp.CMD_GETRANGE, arity: 4, flags: r, keys: oneKey, group: "string", since: "2.4.0",
+			summary: "Returns a substring of the string stored at a key.", handler: withArgs((*server).getrange)},
+		{name: resp.CMD_SETRANGE, arity: 4, flags: wm, keys: oneKey, group: "string", since: "2.2.0",
+			summary: "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist.",
+			handler: withArgs((*server).setrange)},
+		{name: resp.CMD_GETDEL, arity: 2, flags: w | flagFast, keys: oneKey, group: "string", since: "6.2.0",
+			summary: "Returns the string value of a key after deleting the key.", handler: withArgs((*server).getdel)},
+		{name: resp.CMD_GETEX, arity: -2, flags: w | flagFast, keys: oneKey, group: "string", since: "6.2.0",
+			summary: "Returns the string value of a key after setting its expiration time.", handler: withArgs((*server).getex)},
+		{name: resp.CMD_LCS, arity: -3, flags: r, keys: twoKeys, group: "string", since: "7.0.0",
+			summary: "Finds the longest common substring.", handler: withSession((*server).lcs)},
+		{name: resp.CMD_MGET, arity: -2, flags: r | flagFast, keys: allKeys, group: "string", since: "1.0.0",
+			summary: "Atomically returns the string values of one or more keys.", handler: withArgs((*server).mget)},
+		{name: resp.CMD_MSET, arity: -3, flags: wm, keys: pairKeys, group: "string", since: "1.0.1",
+			summary: "Atomically creates or modifies the string values of one or more keys.", handler: withCmd((*server).mset)},
+		{name: resp.CMD_MSETNX, arity: -3, flags: wm, keys: pairKeys, group: "string", since: "1.0.1",
+			summary: "Atomically modifies the string values of one or more keys only when all keys don't exist.",
+			handler: withCmd((*server).mset)},
+
+		// bitmap
+		{name: resp.CMD_SETBIT, arity: 4, flags: wm, keys: oneKey, group: "bitmap", since: "2.2.0",
+			summary: "Sets or clears the bit at offset of the string value. Creates the key if it doesn't exist.",
+			handler: withArgs((*server).setbit)},
+		{name: resp.CMD_GETBIT, arity: 3, flags: r | flagFast, keys: oneKey, group: "bitmap", since: "2.2.0",
+			summary: "Returns a bit value by offset.", handler: withArgs((*server).getbit)},
+		{name: resp.CMD_BITCOUNT, arity: -2, flags: r, keys: oneKey, group: "bitmap", since: "2.6.0",
+			summary: "Counts the number of set bits (population counting) in a string.", handler: withArgs((*server).bitcount)},
+		{name: resp.CMD_BITPOS, arity: -3, flags: r, keys: oneKey, group: "bitmap", since: "2.8.7",
+			summary: "Finds the first set (1) or clear (0) bit in a string.", handler: withArgs((*server).bitpos)},
+		{name: resp.CMD_BITOP, arity: -4, flags: wm, keys: keyRange{2, -1, 1}, group: "bitmap", since: "2.6.0",
+			summary: "Performs bitwise operations on multiple strings, and stores the result.", handler: withArgs((*server).bitop)},
+		{name: resp.CMD_BITFIELD, arity: -2, flags: wm, keys: oneKey, group: "bitmap", since: "3.2.0",
+			summary: "Performs arbitrary bitfield integer operations on strings.", handler: withCmd((*server).bitfield)},
+		{name: resp.CMD_BITFIELD_RO, arity: -2, flags: r | flagFast, keys: oneKey, group: "bitmap", since: "6.0.0",
+			summary: "Performs arbitrary read-only bitfield integer operations on strings.", handler: withCmd((*server).bitfield)},
+
+		// hash
+		{name: resp.CMD_HSET, arity: -4, flags: wm | flagFast, keys: oneKey, group: "hash", since: "2.0.0",
+			summary: "Creates or modifies the value of a field in a hash.", handler: withArgs((*server).hset)},
+		{name: resp.CMD_HSETNX, arity: 4, flags: wm | flagFast, keys: oneKey, group: "hash", since: "2.0.0",
+			summary: "Sets the value of a field in a hash only when the field doesn't exist.", handler: withArgs((*server).hsetnx)},
+		{name: resp.CMD_HGET, arity: 3, flags: r | flagFast, keys: oneKey, group: "hash", since: "2.0.0",
+			summary: "Returns the value of a field in a hash.", handler: withArgs((*server).hget)},
+		{name: resp.CMD_HMGET, arity: -3, flags: r | flagFast, keys: oneKey, group: "hash", since: "2.0.0",
+			summary: "Returns the values of all fields in a hash.", handler: withArgs((*server).hmget)},
+		{name: resp.CMD_HDEL, arity: -3, flags: w | flagFast, keys: oneKey, group: "hash", since: "2.0.0",
+			summary: "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain.",
+			handler: withArgs((*server).hdel)},
+		{name: resp.CMD_HGETALL, arity: 2, flags: r, keys: oneKey, group: "hash", since: "2.0.0",
+			summary: "Returns all fields and values in a hash.", handler: (*server).hgetall},
+		{name: resp.CMD_HKEYS, arity: 2, flags: r, keys: oneKey, group: "hash", since: "2.0.0",
+			summary: "Returns all fields in a hash.", handler: (*server).hgetall},
+		{name: resp.CMD_HVALS, arity: 2, flags: r, keys: oneKey, group: "hash", since: "2.0.0",
+			summary: "Returns all values in a hash.", handler: (*server).hgetall},
+		{name: resp.CMD_HEXISTS, arity: 3, flags: r | flagFast, keys: oneKey, group: "hash", since: "2.0.0",
+			summary: "Determines whether a field exists in a hash.", handler: withArgs((*server).hexists)},
+		{name: resp.CMD_HLEN, arity: 2, flags: r | flagFast, keys: oneKey, group: "hash", since: "2.0.0",
+			summary: "Returns the number of fields in a hash.", handler: withArgs((*server).hlen)},
+		{name: resp.CMD_HINCRBY, arity: 4, flags: wm | flagFast, keys: oneKey, group: "hash", since: "2.0.0",
+			summary: "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist.",
+			handler: withArgs((*server).hincrby)},
+		{name: resp.CMD_HINCRBYFLOAT, arity: 4, flags: wm | flagFast, keys: oneKey, group: "hash", since: "2.6.0",
+			summary: "Increments the floating point value of a field by a number. Uses 0 as initial value if the field doesn't exist.",
+			handler: withArgs((*server).hincrbyfloat)},
+		{name: resp.CMD_HSCAN, arity: -3, flags: r, keys: oneKey, group: "hash", since: "2.8.0",
+			summary: "Iterates over fields and values of a hash.", handler: withArgs((*server).hscan)},
 
-		result := s.store.Scan(cursor, matchPattern, count)
+		// list
+		{name: resp.CMD_LPUSH, arity: -3, flags: wm | flagFast, keys: oneKey, group: "list", since: "1.0.0",
+			summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist.", handler: withCmd((*server).push)},
+		{name: resp.CMD_RPUSH, arity: -3, flags: wm | flagFast, keys: oneKey, group: "list", since: "1.0.0",
+			summary: "Appends one or more elements to a list. Creates the key if it doesn't exist.", handler: withCmd((*server).push)},
+		{name: resp.CMD_LPUSHX, arity: -3, flags: wm | flagFast, keys: oneKey, group: "list", since: "2.2.0",
+			summary: "Prepends one or more elements to a list only when the list exists.", handler: withCmd((*server).push)},
+		{name: resp.CMD_RPUSHX, arity: -3, flags: wm | flagFast, keys: oneKey, group: "list", since: "2.2.0",
+			summary: "Appends an element to a list only when the list exists.", handler: withCmd((*server).push)},
+		{name: resp.CMD_LPOP, arity: -2, flags: w | flagFast, keys: oneKey, group: "list", since: "1.0.0",
+			summary: "Returns the first elements in a list after removing it. Deletes the list if the last element was popped.",
+			handler: withCmd((*server).pop)},
+		{name: resp.CMD_RPOP, arity: -2, flags: w | flagFast, keys: oneKey, group: "list", since: "1.0.0",
+			summary: "Returns and removes the last elements of a list. Deletes the list if the last element was popped.",
+			handler: withCmd((*server).pop)},
+		{name: resp.CMD_LLEN, arity: 2, flags: r | flagFast, keys: oneKey, group: "list", since: "1.0.0",
+			summary: "Returns the length of a list.", handler: withArgs((*server).llen)},
+		{name: resp.CMD_LRANGE, arity: 4, flags: r, keys: oneKey, group: "list", since: "1.0.0",
+			summary: "Returns a range of elements from a list.", handler: withArgs((*server).lrange)},
+		{name: resp.CMD_LINDEX, arity: 3, flags: r, keys: oneKey, group: "list", since: "1.0.0",
+			summary: "Returns an element from a list by its index.", handler: withArgs((*server).lindex)},
+		{name: resp.CMD_LSET, arity: 4, flags: wm, keys: oneKey, group: "list", since: "1.0.0",
+			summary: "Sets the value of an element in a list by its index.", handler: withArgs((*server).lset)},
+		{name: resp.CMD_LREM, arity: 4, flags: w, keys: oneKey, group: "list", since: "1.0.0",
+			summary: "Removes elements from a list. Deletes the list if the last element was removed.", handler: withArgs((*server).lrem)},
+		{name: resp.CMD_LTRIM, arity: 4, flags: w, keys: oneKey, group: "list", since: "1.0.0",
+			summary: "Removes elements from both ends a list. Deletes the list if all elements were trimmed.",
+			handler: withArgs((*server).ltrim)},
+		{name: resp.CMD_LINSERT, arity: 5, flags: wm, keys: oneKey, group: "list", since: "2.2.0",
+			summary: "Inserts an element before or after another element in a list.", handler: withArgs((*server).linsert)},
+		{name: resp.CMD_LMOVE, arity: 5, flags: wm, keys: twoKeys, group: "list", since: "6.2.0",
+			summary: "Returns an element after popping it from one list and pushing it to another. Deletes the list if the last element was moved.",
+			handler: withCmd((*server).lmove)},
+		{name: resp.CMD_RPOPLPUSH, arity: 3, flags: wm, keys: twoKeys, group: "list", since: "1.2.0",
+			summary: "Returns the last element of a list after removing and pushing it to another list. Deletes the list if the last element was popped.",
+			handler: withCmd((*server).lmove)},
+		{name: resp.CMD_BLPOP, arity: -3, flags: w | flagBlocking, keys: keyRange{1, -2, 1}, group: "list", since: "2.0.0",
+			summary: "Removes and returns the first element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.",
+			handler: (*server).bpop},
+		{name: resp.CMD_BRPOP, arity: -3, flags: w | flagBlocking, keys: keyRange{1, -2, 1}, group: "list", since: "2.0.0",
+			summary: "Removes and returns the last element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.",
+			handler: (*server).bpop},
+		{name: resp.CMD_BLMOVE, arity: 6, flags: wm | flagBlocking, keys: twoKeys, group: "list", since: "6.2.0",
+			summary: "Pops an element from a list, pushes it to another list and returns it. Blocks until an element is available otherwise. Deletes the list if the last element was moved.",
+			handler: withSession((*server).blmove)},
 
-		return result
+		// set
+		{name: resp.CMD_SADD, arity: -3, flags: wm | flagFast, keys: oneKey, group: "set", since: "1.0.0",
+			summary: "Adds one or more members to a set. Creates the key if it doesn't exist.", handler: withArgs((*server).sadd)},
+		{name: resp.CMD_SREM, arity: -3, flags: w | flagFast, keys: oneKey, group: "set", since: "1.0.0",
+			summary: "Removes one or more members from a set. Deletes the set if the last member was removed.",
+			handler: withArgs((*server).srem)},
+		{name: resp.CMD_SISMEMBER, arity: 3, flags: r | flagFast, keys: oneKey, group: "set", since: "1.0.0",
+			summary: "Determines whether a member belongs to a set.", handler: withCmd((*server).smismember)},
+		{name: resp.CMD_SMISMEMBER, arity: -3, flags: r | flagFast, keys: oneKey, group: "set", since: "6.2.0",
+			summary: "Determines whether multiple members belong to a set.", handler: withCmd((*server).smismember)},
+		{name: resp.CMD_SMEMBERS, arity: 2, flags: r, keys: oneKey, group: "set", since: "1.0.0",
+			summary: "Returns all members of a set.", handler: withSession((*server).smembers)},
+		{name: resp.CMD_SCARD, arity: 2, flags: r | flagFast, keys: oneKey, group: "set", since: "1.0.0",
+			summary: "Returns the number of members in a set.", handler: withArgs((*server).scard)},
+		{name: resp.CMD_SPOP, arity: -2, flags: w | flagFast, keys: oneKey, group: "set", since: "1.0.0",
+			summary: "Returns one or more random members from a set after removing them. Deletes the set if the last member was popped.",
+			handler: (*server).spop},
+		{name: resp.CMD_SRANDMEMBER, arity: -2, flags: r, keys: oneKey, group: "set", since: "1.0.0",
+			summary: "Get one or multiple random members from a set.", handler: (*server).spop},
+		{name: resp.CMD_SMOVE, arity: 4, flags: wm | flagFast, keys: twoKeys, group: "set", since: "1.0.0",
+			summary: "Moves a member from one set to another.", handler: withArgs((*server).smove)},
+		{name: resp.CMD_SINTER, arity: -2, flags: r, keys: allKeys, group: "set", since: "1.0.0",
+			summary: "Returns the intersect of multiple sets.", handler: (*server).scombine},
+		{name: resp.CMD_SUNION, arity: -2, flags: r, keys: allKeys, group: "set", since: "1.0.0",
+			summary: "Returns the union of multiple sets.", handler: (*server).scombine},
+		{name: resp.CMD_SDIFF, arity: -2, flags: r, keys: allKeys, group: "set", since: "1.0.0",
+			summary: "Returns the difference of multiple sets.", handler: (*server).scombine},
+		{name: resp.CMD_SINTERSTORE, arity: -3, flags: wm, keys: allKeys, group: "set", since: "1.0.0",
+			summary: "Stores the intersect of multiple sets in a key.", handler: withCmd((*server).scombinestore)},
+		{name: resp.CMD_SUNIONSTORE, arity: -3, flags: wm, keys: allKeys, group: "set", since: "1.0.0",
+			summary: "Stores the union of multiple sets in a key.", handler: withCmd((*server).scombinestore)},
+		{name: resp.CMD_SDIFFSTORE, arity: -3, flags: wm, keys: allKeys, group: "set", since: "1.0.0",
+			summary: "Stores the difference of multiple sets in a key.", handler: withCmd((*server).scombinestore)},
+		{name: resp.CMD_SINTERCARD, arity: -3, flags: r, movableKeys: numKeysPositions, group: "set", since: "7.0.0",
+			summary: "Returns the number of members of the intersect of multiple sets.", handler: withArgs((*server).sintercard)},
+
+		// sorted-set
+		{name: resp.CMD_ZADD, arity: -4, flags: wm | flagFast, keys: oneKey, group: "sorted-set", since: "1.2.0",
+			summary: "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist.",
+			handler: withSession((*server).zadd)},
+		{name: resp.CMD_ZINCRBY, arity: 4, flags: wm | flagFast, keys: oneKey, group: "sorted-set", since: "1.2.0",
+			summary: "Increments the score of a member in a sorted set.", handler: withSession((*server).zincrby)},
+		{name: resp.CMD_ZREM, arity: -3, flags: w | flagFast, keys: oneKey, group: "sorted-set", since: "1.2.0",
+			summary: "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed.",
+			handler: withArgs((*server).zrem)},
+		{name: resp.CMD_ZSCORE, arity: 3, flags: r | flagFast, keys: oneKey, group: "sorted-set", since: "1.2.0",
+			summary: "Returns the score of a member in a sorted set.", handler: (*server).zmscore},
+		{name: resp.CMD_ZMSCORE, arity: -3, flags: r | flagFast, keys: oneKey, group: "sorted-set", since: "6.2.0",
+			summary: "Returns the score of one or more members in a sorted set.", handler: (*server).zmscore},
+		{name: resp.CMD_ZCARD, arity: 2, flags: r | flagFast, keys: oneKey, group: "sorted-set", since: "1.2.0",
+			summary: "Returns the number of members in a sorted set.", handler: withArgs((*server).zcard)},
+		{name: resp.CMD_ZRANK, arity: 3, flags: r | flagFast, keys: oneKey, group: "sorted-set", since: "2.0.0",
+			summary: "Returns the index of a member in a sorted set ordered by ascending scores.", handler: withCmd((*server).zrank)},
+		{name: resp.CMD_ZREVRANK, arity: 3, flags: r | flagFast, keys: oneKey, group: "sorted-set", since: "2.0.0",
+			summary: "Returns the index of a member in a sorted set ordered by descending scores.", handler: withCmd((*server).zrank)},
+		{name: resp.CMD_ZCOUNT, arity: 4, flags: r | flagFast, keys: oneKey, group: "sorted-set", since: "2.0.0",
+			summary: "Returns the count of members in a sorted set that have scores within a range.", handler: withArgs((*server).zcount)},
+		{name: resp.CMD_ZLEXCOUNT, arity: 4, flags: r | flagFast, keys: oneKey, group: "sorted-set", since: "2.8.9",
+			summary: "Returns the number of members in a sorted set within a lexicographical range.", handler: withArgs((*server).zlexcount)},
+		{name: resp.CMD_ZRANGE, arity: -4, flags: r, keys: oneKey, group: "sorted-set", since: "1.2.0",
+			summary: "Returns members in a sorted set within a range of indexes.", handler: (*server).zrange},
+		{name: resp.CMD_ZREVRANGE, arity: -4, flags: r, keys: oneKey, group: "sorted-set", since: "1.2.0",
+			summary: "Returns members in a sorted set within a range of indexes in reverse order.", handler: (*server).zrange},
+		{name: resp.CMD_ZRANGEBYSCORE, arity: -4, flags: r, keys: oneKey, group: "sorted-set", since: "1.0.5",
+			summary: "Returns members in a sorted set within a range of scores.", handler: (*server).zrange},
+		{name: resp.CMD_ZREVRANGEBYSCORE, arity: -4, flags: r, keys: oneKey, group: "sorted-set", since: "2.2.0",
+			summary: "Returns members in a sorted set within a range of scores in reverse order.", handler: (*server).zrange},
+		{name: resp.CMD_ZRANGEBYLEX, arity: -4, flags: r, keys: oneKey, group: "sorted-set", since: "2.8.9",
+			summary: "Returns members in a sorted set within a lexicographical range.", handler: (*server).zrange},
+		{name: resp.CMD_ZREVRANGEBYLEX, arity: -4, flags: r, keys: oneKey, group: "sorted-set", since: "2.8.9",
+			summary: "Returns members in a sorted set within a lexicographical range in reverse order.", handler: (*server).zrange},
+		{name: resp.CMD_ZPOPMIN, arity: -2, flags: w | flagFast, keys: oneKey, group: "sorted-set", since: "5.0.0",
+			summary: "Returns the lowest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.",
+			handler: (*server).zpop},
+		{name: resp.CMD_ZPOPMAX, arity: -2, flags: w | flagFast, keys: oneKey, group: "sorted-set", since: "5.0.0",
+			summary: "Returns the highest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.",
+			handler: (*server).zpop},
+
+		// stream
+		{name: resp.CMD_XADD, arity: -5, flags: wm | flagFast, keys: oneKey, group: "stream", since: "5.0.0",
+			summary: "Appends a new message to a stream. Creates the key if it doesn't exist.", handler: withArgs((*server).xadd)},
+		{name: resp.CMD_XLEN, arity: 2, flags: r | flagFast, keys: oneKey, group: "stream", since: "5.0.0",
+			summary: "Return the number of messages in a stream.", handler: withArgs((*server).xlen)},
+		{name: resp.CMD_XRANGE, arity: -4, flags: r, keys: oneKey, group: "stream", since: "5.0.0",
+			summary: "Returns the messages from a stream within a range of IDs.", handler: withCmd((*server).xrange)},
+		{name: resp.CMD_XREVRANGE, arity: -4, flags: r, keys: oneKey, group: "stream", since: "5.0.0",
+			summary: "Returns the messages from a stream within a range of IDs in reverse order.", handler: withCmd((*server).xrange)},
+		{name: resp.CMD_XDEL, arity: -3, flags: w | flagFast, keys: oneKey, group: "stream", since: "5.0.0",
+			summary: "Returns the number of messages after removing them from a stream.", handler: withArgs((*server).xdel)},
+		{name: resp.CMD_XTRIM, arity: -4, flags: w, keys: oneKey, group: "stream", since: "5.0.0",
+			summary: "Deletes messages from the beginning of a stream.", handler: withArgs((*server).xtrim)},
+		{name: resp.CMD_XREAD, arity: -4, flags: r | flagBlocking, movableKeys: streamKeyPositions, group: "stream", since: "5.0.0",
+			summary: "Returns messages from multiple streams with IDs greater than the ones requested. Blocks until a message is available otherwise.",
+			handler: withSession((*server).xread)},
+		{name: resp.CMD_XGROUP, arity: -2, flags: wm, movableKeys: subcommandKey("CREATE", "SETID", "DESTROY", "CREATECONSUMER", "DELCONSUMER"),
+			group: "stream", since: "5.0.0", summary: "A container for consumer groups commands.", handler: withArgs((*server).xgroup)},
+		{name: resp.CMD_XREADGROUP, arity: -7, flags: wm | flagBlocking, movableKeys: streamKeyPositions, group: "stream", since: "5.0.0",
+			summary: "Returns new or historical messages from a stream for a consumer in a group. Blocks until a message is available otherwise.",
+			handler: withSession((*server).xreadgroup)},
+		{name: resp.CMD_XACK, arity: -4, flags: w | flagFast, keys: oneKey, group: "stream", since: "5.0.0",
+			summary: "Returns the number of messages that were successfully acknowledged by the consumer group member of a stream.",
+			handler: withArgs((*server).xack)},
+		{name: resp.CMD_XPENDING, arity: -3, flags: r, keys: oneKey, group: "stream", since: "5.0.0",
+			summary: "Returns the information and entries from a stream consumer group's pending entries list.",
+			handler: withArgs((*server).xpending)},
+		{name: resp.CMD_XCLAIM, arity: -6, flags: wm | flagFast, keys: oneKey, group: "stream", since: "5.0.0",
+			summary: "Changes, or acquires, ownership of a message in a consumer group, as if the message was delivered a consumer group member.",
+			handler: withArgs((*server).xclaim)},
+
+		// hyperloglog
+		{name: resp.CMD_PFADD, arity: -2, flags: wm | flagFast, keys: oneKey, group: "hyperloglog", since: "2.8.9",
+			summary: "Adds elements to a HyperLogLog key. Creates the key if it doesn't exist.", handler: withArgs((*server).pfadd)},
+		{name: resp.CMD_PFCOUNT, arity: -2, flags: r, keys: allKeys, group: "hyperloglog", since: "2.8.9",
+			summary: "Returns the approximated cardinality of the set(s) observed by the HyperLogLog key(s).",
+			handler: withArgs((*server).pfcount)},
+		{name: resp.CMD_PFMERGE, arity: -2, flags: wm, keys: allKeys, group: "hyperloglog", since: "2.8.9",
+			summary: "Merges one or more HyperLogLog values into a single key.", handler: withArgs((*server).pfmerge)},
+
+		// bloom and cuckoo filters
+		{name: resp.CMD_BF_RESERVE, arity: -4, flags: wm, keys: oneKey, group: "bf", since: "1.0.0",
+			summary: "Creates a new Bloom Filter.", handler: withArgs((*server).bfreserve)},
+		{name: resp.CMD_BF_ADD, arity: 3, flags: wm | flagFast, keys: oneKey, group: "bf", since: "1.0.0",
+			summary: "Adds an item to a Bloom Filter.", handler: withCmd((*server).bfadd)},
+		{name: resp.CMD_BF_MADD, arity: -3, flags: wm | flagFast, keys: oneKey, group: "bf", since: "1.0.0",
+			summary: "Adds one or more items to a Bloom Filter. A filter will be created if it does not exist.",
+			handler: withCmd((*server).bfadd)},
+		{name: resp.CMD_BF_EXISTS, arity: 3, flags: r | flagFast, keys: oneKey, group: "bf", since: "1.0.0",
+			summary: "Checks whether an item exists in a Bloom Filter.", handler: withCmd((*server).bfexists)},
+		{name: resp.CMD_BF_MEXISTS, arity: -3, flags: r | flagFast, keys: oneKey, group: "bf", since: "1.0.0",
+			summary: "Checks whether one or more items exist in a Bloom Filter.", handler: withCmd((*server).bfexists)},
+		{name: resp.CMD_BF_INFO, arity: -2, flags: r | flagFast, keys: oneKey, group: "bf", since: "1.0.0",
+			summary: "Returns information about a Bloom Filter.", handler: withSession((*server).bfinfo)},
+		{name: resp.CMD_CF_RESERVE, arity: -3, flags: wm, keys: oneKey, group: "cf", since: "1.0.0",
+			summary: "Creates a new Cuckoo Filter.", handler: withArgs((*server).cfreserve)},
+		{name: resp.CMD_CF_ADD, arity: 3, flags: wm | flagFast, keys: oneKey, group: "cf", since: "1.0.0",
+			summary: "Adds an item to a Cuckoo Filter.", handler: withCmd((*server).cfadd)},
+		{name: resp.CMD_CF_ADDNX, arity: 3, flags: wm | flagFast, keys: oneKey, group: "cf", since: "1.0.0",
+			summary: "Adds an item to a Cuckoo Filter if the item did not exist previously.", handler: withCmd((*server).cfadd)},
+		{name: resp.CMD_CF_EXISTS, arity: 3, flags: r | flagFast, keys: oneKey, group: "cf", since: "1.0.0",
+			summary: "Checks whether one or more items exist in a Cuckoo Filter.", handler: withCmd((*server).cfexists)},
+		{name: resp.CMD_CF_MEXISTS, arity: -3, flags: r | flagFast, keys: oneKey, group: "cf", since: "1.0.0",
+			summary: "Checks whether one or more items exist in a Cuckoo Filter.", handler: withCmd((*server).cfexists)},
+		{name: resp.CMD_CF_COUNT, arity: 3, flags: r | flagFast, keys: oneKey, group: "cf", since: "1.0.0",
+			summary: "Return the number of times an item might be in a Cuckoo Filter.", handler: withArgs((*server).cfcount)},
+		{name: resp.CMD_CF_DEL, arity: 3, flags: w | flagFast, keys: oneKey, group: "cf", since: "1.0.0",
+			summary: "Deletes an item from a Cuckoo Filter.", handler: withArgs((*server).cfdel)},
+		{name: resp.CMD_CF_INFO, arity: 2, flags: r | flagFast, keys: oneKey, group: "cf", since: "1.0.0",
+			summary: "Returns information about a Cuckoo Filter.", handler: withSession((*server).cfinfo)},
+
+		// sketches
+		{name: resp.CMD_CMS_INITBYDIM, arity: 4, flags: wm, keys: oneKey, group: "cms", since: "2.0.0",
+			summary: "Initializes a Count-Min Sketch to dimensions specified by user.", handler: withArgs((*server).cmsinitbydim)},
+		{name: resp.CMD_CMS_INCRBY, arity: -4, flags: wm, keys: oneKey, group: "cms", since: "2.0.0",
+			summary: "Increases the count of one or more items by increment.", handler: withArgs((*server).cmsincrby)},
+		{name: resp.CMD_CMS_QUERY, arity: -3, flags: r, keys: oneKey, group: "cms", since: "2.0.0",
+			summary: "Returns the count for one or more items in a sketch.", handler: withArgs((*server).cmsquery)},
+		{name: resp.CMD_CMS_MERGE, arity: -4, flags: wm, movableKeys: destNumKeysPositions, group: "cms", since: "2.0.0",
+			summary: "Merges several sketches into one sketch.", handler: withArgs((*server).cmsmerge)},
+		{name: resp.CMD_TOPK_RESERVE, arity: -3, flags: wm, keys: oneKey, group: "topk", since: "2.0.0",
+			summary: "Initializes a TopK with specified parameters.", handler: withArgs((*server).topkreserve)},
+		{name: resp.CMD_TOPK_ADD, arity: -3, flags: wm, keys: oneKey, group: "topk", since: "2.0.0",
+			summary: "Increases the count of one or more items by increment.", handler: withArgs((*server).topkadd)},
+		{name: resp.CMD_TOPK_LIST, arity: -2, flags: r, keys: oneKey, group: "topk", since: "2.0.0",
+			summary: "Return full list of items in Top K list.", handler: withArgs((*server).topklist)},
+		{name: resp.CMD_TDIGEST_CREATE, arity: -2, flags: wm, keys: oneKey, group: "tdigest", since: "2.4.0",
+			summary: "Allocates memory and initializes a new t-digest sketch.", handler: withArgs((*server).tdigestcreate)},
+		{name: resp.CMD_TDIGEST_ADD, arity: -3, flags: wm, keys: oneKey, group: "tdigest", since: "2.4.0",
+			summary: "Adds one or more observations to a t-digest sketch.", handler: withArgs((*server).tdigestadd)},
+		{name: resp.CMD_TDIGEST_QUANTILE, arity: -3, flags: r, keys: oneKey, group: "tdigest", since: "2.4.0",
+			summary: "Returns, for each input fraction, an estimation of the value (floating point) that is smaller than the given fraction of observations.",
+			handler: withSession((*server).tdigestquantile)},
+		{name: resp.CMD_TDIGEST_CDF, arity: -3, flags: r, keys: oneKey, group: "tdigest", since: "2.4.0",
+			summary: "Returns, for each input value, an estimation of the fraction (floating-point) of (observations smaller than the given value + half the observations equal to the given value).",
+			handler: withSession((*server).tdigestcdf)},
+		{name: resp.CMD_TDIGEST_MERGE, arity: -4, flags: wm, movableKeys: destNumKeysPositions, group: "tdigest", since: "2.4.0",
+			summary: "Merges multiple t-digest sketches into a single sketch.", handler: withArgs((*server).tdigestmerge)},
+
+		// geo
+		{name: resp.CMD_GEOADD, arity: -5, flags: wm, keys: oneKey, group: "geo", since: "3.2.0",
+			summary: "Adds one or more members to a geospatial index. The key is created if it doesn't exist.",
+			handler: withArgs((*server).geoadd)},
+		{name: resp.CMD_GEOPOS, arity: -2, flags: r, keys: oneKey, group: "geo", since: "3.2.0",
+			summary: "Returns the longitude and latitude of members from a geospatial index.", handler: withCmd((*server).geopos)},
+		{name: resp.CMD_GEOHASH, arity: -2, flags: r, keys: oneKey, group: "geo", since: "3.2.0",
+			summary: "Returns members from a geospatial index as geohash strings.", handler: withCmd((*server).geopos)},
+		{name: resp.CMD_GEODIST, arity: -4, flags: r, keys: oneKey, group: "geo", since: "3.2.0",
+			summary: "Returns the distance between two members of a geospatial index.", handler: withArgs((*server).geodist)},
+		{name: resp.CMD_GEOSEARCH, arity: -7, flags: r, keys: oneKey, group: "geo", since: "6.2.0",
+			summary: "Queries a geospatial index for members inside an area of a box or a circle.", handler: withArgs((*server).geosearch)},
+		{name: resp.CMD_GEOSEARCHSTORE, arity: -8, flags: wm, keys: twoKeys, group: "geo", since: "6.2.0",
+			summary: "Queries a geospatial index for members inside an area of a box or a circle, optionally stores the result.",
+			handler: withArgs((*server).geosearchstore)},
+
+		// connection and server
+		{name: resp.CMD_HELLO, arity: -1, flags: flagNoScript | flagFast, group: "connection", since: "6.0.0",
+			summary: "Handshakes with the Redis server.", handler: withSession((*server).hello)},
+		{name: resp.CMD_PING, arity: -1, flags: flagFast, group: "connection", since: "1.0.0",
+			summary: "Returns the server's liveliness response.", handler: withSession((*server).ping)},
+		{name: resp.CMD_RESET, arity: 1, flags: flagNoScript | flagFast, group: "connection", since: "6.2.0",
+			summary: "Resets the connection.",
+			handler: withSession(func(s *server, sess *session, _ []resp.Value) resp.Value { return s.reset(sess) })},
+		{name: resp.CMD_CONFIG, arity: -2, flags: flagAdmin | flagNoScript, group: "server", since: "2.0.0",
+			summary: "A container for server configuration commands.", handler: withSession((*server).config)},
+		{name: resp.CMD_COMMAND, arity: -1, group: "server", since: "2.8.13",
+			summary: "Returns detailed information about all commands.", handler: withSession((*server).command)},
+		{name: resp.CMD_INFO, arity: -1, group: "server", since: "1.0.0",
+			summary: "Returns information and statistics about the server.",
+			handler: withArgs(func(s *server, _ []resp.Value) resp.Value { return s.info() })},
+		{name: resp.CMD_MEMORY, arity: -2, flags: r, movableKeys: subcommandKey("USAGE"), group: "server", since: "4.0.0",
+			summary: "A container for memory diagnostics commands.", handler: withSession((*server).memory)},
+
+		// pubsub
+		{name: resp.CMD_PUBLISH, arity: 3, flags: flagPubSub | flagFast, group: "pubsub", since: "2.0.0",
+			summary: "Posts a message to a channel.", handler: withCmd((*server).publish)},
+		{name: resp.CMD_SPUBLISH, arity: 3, flags: flagPubSub | flagFast, group: "pubsub", since: "7.0.0",
+			summary: "Post a message to a shard channel.", handler: withCmd((*server).publish)},
+		{name: resp.CMD_SUBSCRIBE, arity: -2, flags: flagPubSub | flagNoScript | flagNoMulti, group: "pubsub", since: "2.0.0",
+			summary: "Listens for messages published to channels.", handler: (*server).subscribe},
+		{name: resp.CMD_PSUBSCRIBE, arity: -2, flags: flagPubSub | flagNoScript | flagNoMulti, group: "pubsub", since: "2.0.0",
+			summary: "Listens for messages published to channels that match one or more patterns.", handler: (*server).subscribe},
+		{name: resp.CMD_SSUBSCRIBE, arity: -2, flags: flagPubSub | flagNoScript | flagNoMulti, group: "pubsub", since: "7.0.0",
+			summary: "Listens for messages published to shard channels.", handler: (*server).subscribe},
+		{name: resp.CMD_UNSUBSCRIBE, arity: -1, flags: flagPubSub | flagNoScript | flagNoMulti, group: "pubsub", since: "2.0.0",
+			summary: "Stops listening to messages posted to channels.", handler: (*server).unsubscribe},
+		{name: resp.CMD_PUNSUBSCRIBE, arity: -1, flags: flagPubSub | flagNoScript | flagNoMulti, group: "pubsub", since: "2.0.0",
+			summary: "Stops listening to messages published to channels that match one or more patterns.", handler: (*server).unsubscribe},
+		{name: resp.CMD_SUNSUBSCRIBE, arity: -1, flags: flagPubSub | flagNoScript | flagNoMulti, group: "pubsub", since: "7.0.0",
+			summary: "Stops listening to messages posted to shard channels.", handler: (*server).unsubscribe},
+		{name: resp.CMD_PUBSUB, arity: -2, flags: flagPubSub, group: "pubsub", since: "2.8.0",
+			summary: "A container for Pub/Sub commands.", handler: withSession((*server).pubsubCommand)},
+
+		// transactions
+		{name: resp.CMD_MULTI, arity: 1, flags: flagNoScript | flagFast, group: "transactions", since: "1.2.0",
+			summary: "Starts a transaction.", handler: (*server).transaction},
+		{name: resp.CMD_EXEC, arity: 1, flags: flagNoScript, group: "transactions", since: "1.2.0",
+			summary: "Executes all commands in a transaction.", handler: (*server).transaction},
+		{name: resp.CMD_DISCARD, arity: 1, flags: flagNoScript | flagFast, group: "transactions", since: "2.0.0",
+			summary: "Discards a transaction.", handler: (*server).transaction},
+		{name: resp.CMD_WATCH, arity: -2, flags: flagNoScript | flagFast, keys: allKeys, group: "transactions", since: "2.2.0",
+			summary: "Monitors changes to keys to determine the execution of a transaction.", handler: (*server).transaction},
+		{name: resp.CMD_UNWATCH, arity: 1, flags: flagNoScript | flagFast, group: "transactions", since: "2.2.0",
+			summary: "Forgets about watched keys of a transaction.",
+			handler: withSession(func(s *server, sess *session, _ []resp.Value) resp.Value {
+				s.unwatch(sess)
+				return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+			})},
+	}
+
+	commands = make(map[resp.RESPCommand]*commandSpec, len(table))
+	for i := range table {
+		commands[table[i].name] = &table[i]
+	}
+}
+
+func wrongNumberOfArgs(cmd resp.RESPCommand) resp.Value {
+	return resp.NewErrorValue(fmt.Sprintf("ERR wrong number of arguments for '%s' command", cmd))
+}
+
+func boolInteger(b bool) resp.Value {
+	if b {
+		return resp.NewIntegerValue(1)
+	}
+	return resp.NewIntegerValue(0)
+}
+
+func bulkStrings(args []resp.Value) []string {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = arg.BulkString
+	}
+	return strs
+}
 
-	default:
-		return resp.NewErrorValue(fmt.Sprintf("ERR unknown command '%s'", cmd))
+func createBulkStringArray(strs []string) []resp.Value {
+	arr := make([]resp.Value, len(strs))
+	for i, str := range strs {
+		arr[i] = resp.Value{Type: resp.BULK_STRING, BulkString: str}
 	}
+	return arr
 }
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"maps"
+	"simpleKV/resp"
+	"simpleKV/server/store"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func commandSet(cmds ...resp.RESPCommand) map[resp.RESPCommand]bool {
+	set := make(map[resp.RESPCommand]bool, len(cmds))
+	for _, cmd := range cmds {
+		set[cmd] = true
+	}
+	return set
+}
+
+// configParam is one setting reachable through CONFIG GET and CONFIG SET.
+type configParam struct {
+	get func(s *server) string
+	set func(s *server, value string) bool
+}
+
+var configParams = map[string]configParam{
+	"maxmemory": {
+		get: func(s *server) string {
+			return strconv.FormatInt(s.store.EvictionConfig().MaxMemory, 10)
+		},
+		set: func(s *server, value string) bool {
+			n, ok := parseMemory(value)
+			if ok {
+				cfg := s.store.EvictionConfig()
+				cfg.MaxMemory = n
+				s.store.SetEvictionConfig(cfg)
+			}
+			return ok
+		},
+	},
+	"maxmemory-policy": {
+		get: func(s *server) string {
+			return s.store.EvictionConfig().Policy.String()
+		},
+		set: func(s *server, value string) bool {
+			policy, ok := store.ParseEvictionPolicy(strings.ToLower(value))
+			if ok {
+				cfg := s.store.EvictionConfig()
+				cfg.Policy = policy
+				s.store.SetEvictionConfig(cfg)
+			}
+			return ok
+		},
+	},
+	"notify-keyspace-events": {
+		get: func(s *server) string {
+			return s.store.NotifyFlags().String()
+		},
+		set: func(s *server, value string) bool {
+			flags, ok := store.ParseNotifyFlags(value)
+			if ok {
+				s.store.SetNotifyFlags(flags)
+			}
+			return ok
+		},
+	},
+	"maxmemory-samples": {
+		get: func(s *server) string {
+			return strconv.Itoa(s.store.EvictionConfig().Samples)
+		},
+		set: func(s *server, value string) bool {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 64 {
+				return false
+			}
+			cfg := s.store.EvictionConfig()
+			cfg.Samples = n
+			s.store.SetEvictionConfig(cfg)
+			return true
+		},
+	},
+}
+
+// parseMemory reads a byte count such as "1048576", "100kb" or "1gb". As
+// in Redis, k, m and g are powers of 1000 and kb, mb and gb powers of 1024.
+func parseMemory(value string) (int64, bool) {
+	units := []struct {
+		suffix string
+		scale  int64
+	}{
+		{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+		{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+		{"b", 1},
+	}
+
+	value = strings.ToLower(value)
+	scale := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(value, u.suffix) {
+			value, scale = strings.TrimSuffix(value, u.suffix), u.scale
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 || n > (1<<63-1)/scale {
+		return 0, false
+	}
+	return n * scale, true
+}
+
+// config handles
+//
+//	CONFIG GET parameter [parameter ...]
+//	CONFIG SET parameter value [parameter value ...]
+func (s *server) config(sess *session, args []resp.Value) resp.Value {
+	sub := strings.ToUpper(args[0].BulkString)
+	switch {
+	case sub == "GET" && len(args) >= 2:
+		var pairs []resp.Value
+		for _, name := range slices.Sorted(maps.Keys(configParams)) {
+			param := configParams[name]
+			for _, pattern := range args[1:] {
+				if store.MatchGlob(strings.ToLower(pattern.BulkString), name) {
+					pairs = append(pairs,
+						resp.Value{Type: resp.BULK_STRING, BulkString: name},
+						resp.Value{Type: resp.BULK_STRING, BulkString: param.get(s)})
+					break
+				}
+			}
+		}
+		return sess.mapReply(pairs)
+
+	case sub == "SET" && len(args) >= 3 && len(args)%2 == 1:
+		for i := 1; i < len(args); i += 2 {
+			name := strings.ToLower(args[i].BulkString)
+			param, ok := configParams[name]
+			if !ok {
+				return resp.NewErrorValue("ERR Unknown option or number of arguments for CONFIG SET - '" + name + "'")
+			}
+			if !param.set(s, args[i+1].BulkString) {
+				return resp.NewErrorValue("ERR CONFIG SET failed (possibly related to argument '" + name + "') - argument couldn't be parsed into an integer or is not valid")
+			}
+		}
+		return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+	}
+
+	return resp.NewErrorValue("ERR unknown subcommand or wrong number of arguments for '" + args[0].BulkString + "'. Try CONFIG HELP.")
+}
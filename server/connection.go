@@ -0,0 +1,78 @@
+package server
+
+import (
+	"fmt"
+	"simpleKV/resp"
+	"strconv"
+	"strings"
+)
+
+// hello handles
+//
+//	HELLO [protover [AUTH username password] [SETNAME clientname]]
+//
+// It switches the connection between RESP2 and RESP3 and describes the
+// server. There are no users, so AUTH is accepted as is.
+func (s *server) hello(sess *session, args []resp.Value) resp.Value {
+	protocol := sess.protocol
+	if len(args) > 0 {
+		version, err := strconv.Atoi(args[0].BulkString)
+		if err != nil {
+			return resp.NewErrorValue("ERR Protocol version is not an integer or out of range")
+		}
+		if version != 2 && version != 3 {
+			return resp.NewErrorValue("NOPROTO unsupported protocol version")
+		}
+		protocol = version
+	}
+
+	name := sess.name
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(args[i].BulkString) {
+		case "AUTH":
+			if i+2 >= len(args) {
+				return resp.NewErrorValue(fmt.Sprintf("ERR Syntax error in HELLO option '%s'", args[i].BulkString))
+			}
+			i += 2
+		case "SETNAME":
+			if i+1 >= len(args) {
+				return resp.NewErrorValue(fmt.Sprintf("ERR Syntax error in HELLO option '%s'", args[i].BulkString))
+			}
+			i++
+			name = args[i].BulkString
+		default:
+			return resp.NewErrorValue(fmt.Sprintf("ERR Syntax error in HELLO option '%s'", args[i].BulkString))
+		}
+	}
+
+	sess.protocol = protocol
+	sess.name = name
+
+	return sess.mapReply([]resp.Value{
+		{Type: resp.BULK_STRING, BulkString: "server"},
+		{Type: resp.BULK_STRING, BulkString: "simpleKV"},
+		{Type: resp.BULK_STRING, BulkString: "version"},
+		{Type: resp.BULK_STRING, BulkString: "0.0.1"},
+		{Type: resp.BULK_STRING, BulkString: "proto"},
+		resp.NewIntegerValue(int64(protocol)),
+		{Type: resp.BULK_STRING, BulkString: "id"},
+		resp.NewIntegerValue(sess.id),
+		{Type: resp.BULK_STRING, BulkString: "mode"},
+		{Type: resp.BULK_STRING, BulkString: "standalone"},
+		{Type: resp.BULK_STRING, BulkString: "role"},
+		{Type: resp.BULK_STRING, BulkString: "master"},
+		{Type: resp.BULK_STRING, BulkString: "modules"},
+		{Type: resp.ARRAY, Array: []resp.Value{}},
+	})
+}
+
+// reset returns the connection to its initial state: RESP2, no name, no
+// subscriptions and no transaction or watched keys.
+func (s *server) reset(sess *session) resp.Value {
+	s.pubsub.leave(sess)
+	s.discard(sess)
+	sess.protocol = 2
+	sess.name = ""
+
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "RESET"}
+}
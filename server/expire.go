@@ -0,0 +1,88 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"simpleKV/resp"
+	"simpleKV/server/store"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidExpire = errors.New("invalid expire time")
+
+// expireDuration converts n units into a duration, refusing values that
+// would overflow it.
+func expireDuration(n int64, unit time.Duration) (time.Duration, error) {
+	if n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+		return 0, errInvalidExpire
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// expire handles EXPIRE, PEXPIRE, EXPIREAT and PEXPIREAT:
+//
+//	EXPIRE key seconds [NX | XX | GT | LT]
+func (s *server) expire(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	key := args[0].BulkString
+	n, err := strconv.ParseInt(args[1].BulkString, 10, 64)
+	if err != nil {
+		return resp.NewErrorValue("ERR value is not an integer or out of range")
+	}
+
+	cond := store.ExpireAlways
+	for _, arg := range args[2:] {
+		switch strings.ToUpper(arg.BulkString) {
+		case "NX":
+			cond |= store.ExpireNX
+		case "XX":
+			cond |= store.ExpireXX
+		case "GT":
+			cond |= store.ExpireGT
+		case "LT":
+			cond |= store.ExpireLT
+		default:
+			return resp.NewErrorValue(fmt.Sprintf("ERR Unsupported option %s", arg.BulkString))
+		}
+	}
+	if cond&store.ExpireNX != 0 && cond != store.ExpireNX {
+		return resp.NewErrorValue("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	if cond&store.ExpireGT != 0 && cond&store.ExpireLT != 0 {
+		return resp.NewErrorValue("ERR GT and LT options at the same time are not compatible")
+	}
+
+	unit := time.Second
+	if cmd == resp.CMD_PEXPIRE || cmd == resp.CMD_PEXPIREAT {
+		unit = time.Millisecond
+	}
+	d, err := expireDuration(n, unit)
+	if err != nil {
+		return resp.NewErrorValue(fmt.Sprintf("ERR invalid expire time in '%s' command", cmd))
+	}
+
+	var deadline time.Time
+	if cmd == resp.CMD_EXPIREAT || cmd == resp.CMD_PEXPIREAT {
+		deadline = time.UnixMilli(d.Milliseconds())
+	} else {
+		deadline = time.Now().Add(d)
+	}
+
+	return boolInteger(s.store.Expire(key, deadline, cond))
+}
+
+// ttl serves TTL, in seconds, and PTTL, in milliseconds. -2 means the key
+// does not exist and -1 that it has no deadline.
+func (s *server) ttl(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	ttl := s.store.PTTL(args[0].BulkString)
+	if ttl > 0 && cmd == resp.CMD_TTL {
+		ttl = (ttl + 500) / 1000
+	}
+	return resp.NewIntegerValue(ttl)
+}
+
+func (s *server) persist(args []resp.Value) resp.Value {
+	return boolInteger(s.store.Persist(args[0].BulkString))
+}
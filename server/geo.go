@@ -0,0 +1,331 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"simpleKV/resp"
+	"simpleKV/server/store"
+	"strconv"
+	"strings"
+)
+
+// geoUnit returns how many meters one of unit is.
+func geoUnit(unit string) (float64, bool) {
+	switch strings.ToLower(unit) {
+	case "m":
+		return 1, true
+	case "km":
+		return 1000, true
+	case "ft":
+		return 0.3048, true
+	case "mi":
+		return 1609.34, true
+	default:
+		return 0, false
+	}
+}
+
+func parseGeoPoint(lon, lat resp.Value) (store.GeoPoint, resp.Value, bool) {
+	x, err1 := strconv.ParseFloat(lon.BulkString, 64)
+	y, err2 := strconv.ParseFloat(lat.BulkString, 64)
+	if err1 != nil || err2 != nil {
+		return store.GeoPoint{}, resp.NewErrorValue("ERR value is not a valid float"), false
+	}
+
+	p := store.GeoPoint{Lon: x, Lat: y}
+	if !store.ValidGeoPoint(p) {
+		return p, resp.NewErrorValue(fmt.Sprintf("ERR invalid longitude,latitude pair %f,%f", x, y)), false
+	}
+	return p, resp.Value{}, true
+}
+
+func formatDistance(meters, unit float64) resp.Value {
+	return resp.Value{Type: resp.BULK_STRING, BulkString: strconv.FormatFloat(meters/unit, 'f', 4, 64)}
+}
+
+func coordinatesReply(p store.GeoPoint) resp.Value {
+	return resp.Value{Type: resp.ARRAY, Array: createBulkStringArray([]string{
+		strconv.FormatFloat(p.Lon, 'f', -1, 64),
+		strconv.FormatFloat(p.Lat, 'f', -1, 64),
+	})}
+}
+
+// geoadd handles
+//
+//	GEOADD key [NX | XX] [CH] longitude latitude member [longitude latitude member ...]
+func (s *server) geoadd(args []resp.Value) resp.Value {
+	var opts store.ZAddOptions
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i].BulkString) {
+		case "NX":
+			opts.Condition = store.SetNX
+		case "XX":
+			opts.Condition = store.SetXX
+		case "CH":
+			opts.CH = true
+		default:
+			break options
+		}
+	}
+
+	triples := args[i:]
+	if len(triples) == 0 || len(triples)%3 != 0 {
+		return resp.NewErrorValue("ERR syntax error")
+	}
+
+	members := make([]store.ScoredMember, 0, len(triples)/3)
+	for j := 0; j < len(triples); j += 3 {
+		p, errReply, ok := parseGeoPoint(triples[j], triples[j+1])
+		if !ok {
+			return errReply
+		}
+		members = append(members, store.ScoredMember{Member: triples[j+2].BulkString, Score: float64(store.GeoEncode(p))})
+	}
+
+	n, err := s.store.ZAdd(args[0].BulkString, members, opts)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+// geoPoints looks up the positions of members, nil for missing ones.
+func (s *server) geoPoints(key string, members []string) ([]*store.GeoPoint, error) {
+	scores, err := s.store.ZMScore(key, members)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]*store.GeoPoint, len(scores))
+	for i, score := range scores {
+		if score != nil {
+			p := store.GeoDecode(uint64(*score))
+			points[i] = &p
+		}
+	}
+	return points, nil
+}
+
+// geopos serves GEOPOS, which answers with the coordinates of each member,
+// and GEOHASH, which answers with their geohash strings.
+func (s *server) geopos(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	points, err := s.geoPoints(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	reply := make([]resp.Value, len(points))
+	for i, p := range points {
+		switch {
+		case p == nil:
+			reply[i] = resp.Value{Type: resp.NULL}
+		case cmd == resp.CMD_GEOHASH:
+			reply[i] = resp.Value{Type: resp.BULK_STRING, BulkString: store.GeohashString(*p)}
+		default:
+			reply[i] = coordinatesReply(*p)
+		}
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
+
+// geodist handles
+//
+//	GEODIST key member1 member2 [M | KM | FT | MI]
+func (s *server) geodist(args []resp.Value) resp.Value {
+	if len(args) > 4 {
+		return wrongNumberOfArgs(resp.CMD_GEODIST)
+	}
+	unit := 1.0
+	if len(args) == 4 {
+		var ok bool
+		if unit, ok = geoUnit(args[3].BulkString); !ok {
+			return resp.NewErrorValue("ERR unsupported unit provided. please use M, KM, FT, MI")
+		}
+	}
+
+	points, err := s.geoPoints(args[0].BulkString, []string{args[1].BulkString, args[2].BulkString})
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if points[0] == nil || points[1] == nil {
+		return resp.Value{Type: resp.NULL}
+	}
+	return formatDistance(store.GeoDistance(*points[0], *points[1]), unit)
+}
+
+// geoSearchArgs is a parsed GEOSEARCH or GEOSEARCHSTORE.
+type geoSearchArgs struct {
+	query                         store.GeoQuery
+	unit                          float64
+	withCoord, withDist, withHash bool
+	storeDist                     bool
+}
+
+// parseGeoSearch reads the options shared by GEOSEARCH and GEOSEARCHSTORE:
+//
+//	FROMMEMBER member | FROMLONLAT longitude latitude
+//	BYRADIUS radius M | KM | FT | MI | BYBOX width height M | KM | FT | MI
+//	[ASC | DESC] [COUNT count [ANY]]
+//
+// followed by WITHCOORD, WITHDIST and WITHHASH for GEOSEARCH or STOREDIST
+// for GEOSEARCHSTORE.
+func parseGeoSearch(cmd resp.RESPCommand, args []resp.Value) (geoSearchArgs, resp.Value, bool) {
+	var g geoSearchArgs
+	q := &g.query
+	syntaxError := resp.NewErrorValue("ERR syntax error")
+	hasFrom, hasBy, hasCount := false, false, false
+
+	for i := 0; i < len(args); i++ {
+		opt := strings.ToUpper(args[i].BulkString)
+		switch {
+		case opt == "FROMMEMBER" && i+1 < len(args) && !hasFrom:
+			q.FromMember, q.Member = true, args[i+1].BulkString
+			hasFrom = true
+			i++
+
+		case opt == "FROMLONLAT" && i+2 < len(args) && !hasFrom:
+			p, errReply, ok := parseGeoPoint(args[i+1], args[i+2])
+			if !ok {
+				return g, errReply, false
+			}
+			q.Center = p
+			hasFrom = true
+			i += 2
+
+		case opt == "BYRADIUS" && i+2 < len(args) && !hasBy:
+			radius, err := strconv.ParseFloat(args[i+1].BulkString, 64)
+			if err != nil || radius < 0 || math.IsNaN(radius) {
+				return g, resp.NewErrorValue("ERR radius cannot be negative"), false
+			}
+			unit, ok := geoUnit(args[i+2].BulkString)
+			if !ok {
+				return g, resp.NewErrorValue("ERR unsupported unit provided. please use M, KM, FT, MI"), false
+			}
+			q.Shape, q.Radius, g.unit = store.GeoByRadius, radius*unit, unit
+			hasBy = true
+			i += 2
+
+		case opt == "BYBOX" && i+3 < len(args) && !hasBy:
+			width, err1 := strconv.ParseFloat(args[i+1].BulkString, 64)
+			height, err2 := strconv.ParseFloat(args[i+2].BulkString, 64)
+			if err1 != nil || err2 != nil || width < 0 || height < 0 || math.IsNaN(width) || math.IsNaN(height) {
+				return g, resp.NewErrorValue("ERR height or width cannot be negative"), false
+			}
+			unit, ok := geoUnit(args[i+3].BulkString)
+			if !ok {
+				return g, resp.NewErrorValue("ERR unsupported unit provided. please use M, KM, FT, MI"), false
+			}
+			q.Shape, q.Width, q.Height, g.unit = store.GeoByBox, width*unit, height*unit, unit
+			hasBy = true
+			i += 3
+
+		case opt == "ASC":
+			q.Sort = store.GeoAsc
+		case opt == "DESC":
+			q.Sort = store.GeoDesc
+
+		case opt == "COUNT" && i+1 < len(args):
+			count, err := strconv.Atoi(args[i+1].BulkString)
+			if err != nil {
+				return g, resp.NewErrorValue("ERR value is not an integer or out of range"), false
+			}
+			if count <= 0 {
+				return g, resp.NewErrorValue("ERR COUNT must be > 0"), false
+			}
+			q.Count, hasCount = count, true
+			i++
+			if i+1 < len(args) && strings.ToUpper(args[i+1].BulkString) == "ANY" {
+				q.Any = true
+				i++
+			}
+
+		case opt == "WITHCOORD" && cmd == resp.CMD_GEOSEARCH:
+			g.withCoord = true
+		case opt == "WITHDIST" && cmd == resp.CMD_GEOSEARCH:
+			g.withDist = true
+		case opt == "WITHHASH" && cmd == resp.CMD_GEOSEARCH:
+			g.withHash = true
+		case opt == "STOREDIST" && cmd == resp.CMD_GEOSEARCHSTORE:
+			g.storeDist = true
+
+		default:
+			return g, syntaxError, false
+		}
+	}
+
+	if !hasFrom {
+		return g, resp.NewErrorValue("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for " + string(cmd)), false
+	}
+	if !hasBy {
+		return g, resp.NewErrorValue("ERR exactly one of BYRADIUS and BYBOX can be specified for " + string(cmd)), false
+	}
+
+	// A limited search without ANY returns the nearest matches.
+	if hasCount && !q.Any && q.Sort == store.GeoUnsorted {
+		q.Sort = store.GeoAsc
+	}
+	return g, resp.Value{}, true
+}
+
+// geosearch handles
+//
+//	GEOSEARCH key FROMMEMBER member | FROMLONLAT longitude latitude
+//	  BYRADIUS radius M | KM | FT | MI | BYBOX width height M | KM | FT | MI
+//	  [ASC | DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH]
+//
+// Plain members are returned unless a WITH option is given, in which case
+// each result is an array of the member followed by the distance, hash and
+// coordinates that were asked for, in that order.
+func (s *server) geosearch(args []resp.Value) resp.Value {
+	g, errReply, ok := parseGeoSearch(resp.CMD_GEOSEARCH, args[1:])
+	if !ok {
+		return errReply
+	}
+
+	results, err := s.store.GeoSearch(args[0].BulkString, g.query)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	reply := make([]resp.Value, len(results))
+	for i, r := range results {
+		member := resp.Value{Type: resp.BULK_STRING, BulkString: r.Member}
+		if !g.withCoord && !g.withDist && !g.withHash {
+			reply[i] = member
+			continue
+		}
+
+		item := []resp.Value{member}
+		if g.withDist {
+			item = append(item, formatDistance(r.Dist, g.unit))
+		}
+		if g.withHash {
+			item = append(item, resp.NewIntegerValue(int64(r.Hash)))
+		}
+		if g.withCoord {
+			item = append(item, coordinatesReply(r.Point))
+		}
+		reply[i] = resp.Value{Type: resp.ARRAY, Array: item}
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
+
+// geosearchstore handles
+//
+//	GEOSEARCHSTORE destination source FROMMEMBER member | FROMLONLAT longitude latitude
+//	  BYRADIUS radius M | KM | FT | MI | BYBOX width height M | KM | FT | MI
+//	  [ASC | DESC] [COUNT count [ANY]] [STOREDIST]
+func (s *server) geosearchstore(args []resp.Value) resp.Value {
+	g, errReply, ok := parseGeoSearch(resp.CMD_GEOSEARCHSTORE, args[2:])
+	if !ok {
+		return errReply
+	}
+
+	n, err := s.store.GeoSearchStore(args[0].BulkString, args[1].BulkString, g.query, g.storeDist, g.unit)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"math"
+	"simpleKV/resp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func (s *server) hset(args []resp.Value) resp.Value {
+	if len(args)%2 == 0 {
+		return wrongNumberOfArgs(resp.CMD_HSET)
+	}
+	added, err := s.store.HSet(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(added))
+}
+
+func (s *server) hsetnx(args []resp.Value) resp.Value {
+	ok, err := s.store.HSetNX(args[0].BulkString, args[1].BulkString, args[2].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return boolInteger(ok)
+}
+
+func (s *server) hget(args []resp.Value) resp.Value {
+	value, ok, err := s.store.HGet(args[0].BulkString, args[1].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if !ok {
+		return resp.Value{Type: resp.NULL}
+	}
+	return resp.Value{Type: resp.BULK_STRING, BulkString: value}
+}
+
+func (s *server) hmget(args []resp.Value) resp.Value {
+	values, err := s.store.HMGet(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.ARRAY, Array: values}
+}
+
+func (s *server) hdel(args []resp.Value) resp.Value {
+	removed, err := s.store.HDel(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(removed))
+}
+
+// hgetall serves HGETALL, HKEYS and HVALS. Fields are sorted so replies are
+// stable between calls.
+func (s *server) hgetall(sess *session, cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	h, err := s.store.HGetAll(args[0].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	fields := make([]string, 0, len(h))
+	for field := range h {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	reply := []resp.Value{}
+	for _, field := range fields {
+		if cmd != resp.CMD_HVALS {
+			reply = append(reply, resp.Value{Type: resp.BULK_STRING, BulkString: field})
+		}
+		if cmd != resp.CMD_HKEYS {
+			reply = append(reply, resp.Value{Type: resp.BULK_STRING, BulkString: h[field]})
+		}
+	}
+	if cmd == resp.CMD_HGETALL {
+		return sess.mapReply(reply)
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
+
+func (s *server) hexists(args []resp.Value) resp.Value {
+	ok, err := s.store.HExists(args[0].BulkString, args[1].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return boolInteger(ok)
+}
+
+func (s *server) hlen(args []resp.Value) resp.Value {
+	n, err := s.store.HLen(args[0].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+func (s *server) hincrby(args []resp.Value) resp.Value {
+	delta, err := strconv.ParseInt(args[2].BulkString, 10, 64)
+	if err != nil {
+		return resp.NewErrorValue("ERR value is not an integer or out of range")
+	}
+
+	n, err := s.store.HIncrBy(args[0].BulkString, args[1].BulkString, delta)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(n)
+}
+
+func (s *server) hincrbyfloat(args []resp.Value) resp.Value {
+	delta, err := strconv.ParseFloat(args[2].BulkString, 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return resp.NewErrorValue("ERR value is not a valid float")
+	}
+
+	n, err := s.store.HIncrByFloat(args[0].BulkString, args[1].BulkString, delta)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.BULK_STRING, BulkString: strconv.FormatFloat(n, 'f', -1, 64)}
+}
+
+// hscan handles
+//
+//	HSCAN key cursor [MATCH pattern] [COUNT count] [NOVALUES]
+func (s *server) hscan(args []resp.Value) resp.Value {
+	cursor, err := strconv.Atoi(args[1].BulkString)
+	if err != nil || cursor < 0 {
+		return resp.NewErrorValue("ERR invalid cursor")
+	}
+
+	matchPattern := ""
+	count := 10
+	noValues := false
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i].BulkString) {
+		case "MATCH":
+			if i+1 >= len(args) {
+				return resp.NewErrorValue("ERR syntax error")
+			}
+			i++
+			matchPattern = args[i].BulkString
+		case "COUNT":
+			if i+1 >= len(args) {
+				return resp.NewErrorValue("ERR syntax error")
+			}
+			i++
+			count, err = strconv.Atoi(args[i].BulkString)
+			if err != nil || count < 1 {
+				return resp.NewErrorValue("ERR syntax error")
+			}
+		case "NOVALUES":
+			noValues = true
+		default:
+			return resp.NewErrorValue("ERR syntax error")
+		}
+	}
+
+	next, fieldValues, err := s.store.HScan(args[0].BulkString, cursor, matchPattern, count)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	items := []resp.Value{}
+	for i := 0; i < len(fieldValues); i += 2 {
+		items = append(items, resp.Value{Type: resp.BULK_STRING, BulkString: fieldValues[i]})
+		if !noValues {
+			items = append(items, resp.Value{Type: resp.BULK_STRING, BulkString: fieldValues[i+1]})
+		}
+	}
+
+	return resp.Value{
+		Type: resp.ARRAY,
+		Array: []resp.Value{
+			{Type: resp.BULK_STRING, BulkString: strconv.Itoa(next)},
+			{Type: resp.ARRAY, Array: items},
+		},
+	}
+}
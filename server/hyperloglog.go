@@ -0,0 +1,29 @@
+package server
+
+import (
+	"simpleKV/resp"
+)
+
+func (s *server) pfadd(args []resp.Value) resp.Value {
+	changed, err := s.store.PFAdd(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return boolInteger(changed)
+}
+
+func (s *server) pfcount(args []resp.Value) resp.Value {
+	n, err := s.store.PFCount(bulkStrings(args))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+func (s *server) pfmerge(args []resp.Value) resp.Value {
+	err := s.store.PFMerge(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+}
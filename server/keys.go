@@ -0,0 +1,144 @@
+package server
+
+import (
+	"simpleKV/resp"
+	"strconv"
+	"strings"
+)
+
+func (s *server) del(args []resp.Value) resp.Value {
+	deleted := 0
+	for _, arg := range args {
+		if s.store.Del(arg.BulkString) {
+			deleted++
+		}
+	}
+	return resp.NewIntegerValue(int64(deleted))
+}
+
+func (s *server) exists(args []resp.Value) resp.Value {
+	return resp.NewIntegerValue(int64(s.store.Exists(bulkStrings(args))))
+}
+
+func (s *server) touch(args []resp.Value) resp.Value {
+	return resp.NewIntegerValue(int64(s.store.Touch(bulkStrings(args))))
+}
+
+func (s *server) keyType(args []resp.Value) resp.Value {
+	return resp.Value{Type: resp.SIMPLE_STRING, String: s.store.Type(args[0].BulkString)}
+}
+
+func (s *server) dbsize(args []resp.Value) resp.Value {
+	return resp.NewIntegerValue(int64(s.store.DBSize()))
+}
+
+// rename serves RENAME, which answers OK, and RENAMENX, which answers
+// whether the key was moved. Clients blocked on the new name are woken.
+func (s *server) rename(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	src, dst := args[0].BulkString, args[1].BulkString
+	moved, err := s.store.Rename(src, dst, cmd == resp.CMD_RENAMENX)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if moved && src != dst {
+		s.blocking.signal(dst)
+	}
+
+	if cmd == resp.CMD_RENAMENX {
+		return boolInteger(moved)
+	}
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+}
+
+// copyKey handles
+//
+//	COPY source destination [REPLACE]
+func (s *server) copyKey(args []resp.Value) resp.Value {
+	replace := false
+	for _, arg := range args[2:] {
+		if strings.ToUpper(arg.BulkString) != "REPLACE" {
+			return resp.NewErrorValue("ERR syntax error")
+		}
+		replace = true
+	}
+
+	dst := args[1].BulkString
+	copied, err := s.store.Copy(args[0].BulkString, dst, replace)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if copied {
+		s.blocking.signal(dst)
+	}
+	return boolInteger(copied)
+}
+
+func (s *server) randomKey() resp.Value {
+	key, ok := s.store.RandomKey()
+	if !ok {
+		return resp.Value{Type: resp.NULL}
+	}
+	return resp.Value{Type: resp.BULK_STRING, BulkString: key}
+}
+
+func (s *server) keys(args []resp.Value) resp.Value {
+	return resp.Value{Type: resp.ARRAY, Array: createBulkStringArray(s.store.Keys(args[0].BulkString))}
+}
+
+// scanTypes maps the names SCAN's TYPE option accepts, in lower case, to
+// the type names TYPE reports.
+var scanTypes = map[string]string{
+	"string": "string", "list": "list", "set": "set", "zset": "zset", "hash": "hash", "stream": "stream",
+	"mbbloom--": "MBbloom--", "mbbloomcf": "MBbloomCF", "cmsk-type": "CMSk-TYPE", "topk-type": "TopK-TYPE",
+	"tdis-type": "TDIS-TYPE",
+}
+
+// scan handles
+//
+//	SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+func (s *server) scan(args []resp.Value) resp.Value {
+	cursor, err := strconv.ParseUint(args[0].BulkString, 10, 64)
+	if err != nil {
+		return resp.NewErrorValue("ERR invalid cursor")
+	}
+
+	matchPattern, keyType := "", ""
+	count := 10
+	for i := 1; i < len(args); i++ {
+		if i+1 >= len(args) {
+			return resp.NewErrorValue("ERR syntax error")
+		}
+		switch strings.ToUpper(args[i].BulkString) {
+		case "MATCH":
+			i++
+			matchPattern = args[i].BulkString
+		case "COUNT":
+			i++
+			count, err = strconv.Atoi(args[i].BulkString)
+			if err != nil {
+				return resp.NewErrorValue("ERR value is not an integer or out of range")
+			}
+			if count < 1 {
+				return resp.NewErrorValue("ERR syntax error")
+			}
+		case "TYPE":
+			i++
+			var ok bool
+			keyType, ok = scanTypes[strings.ToLower(args[i].BulkString)]
+			if !ok {
+				return resp.NewErrorValue("ERR unknown type name '" + args[i].BulkString + "'")
+			}
+		default:
+			return resp.NewErrorValue("ERR syntax error")
+		}
+	}
+
+	next, keys := s.store.Scan(cursor, matchPattern, keyType, count)
+	return resp.Value{
+		Type: resp.ARRAY,
+		Array: []resp.Value{
+			{Type: resp.BULK_STRING, BulkString: strconv.FormatUint(next, 10)},
+			{Type: resp.ARRAY, Array: createBulkStringArray(keys)},
+		},
+	}
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"math"
+	"simpleKV/resp"
+	"simpleKV/server/store"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// push handles LPUSH, RPUSH, LPUSHX and RPUSHX.
+func (s *server) push(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	key := args[0].BulkString
+	side := store.ListLeft
+	if cmd == resp.CMD_RPUSH || cmd == resp.CMD_RPUSHX {
+		side = store.ListRight
+	}
+	onlyIfExists := cmd == resp.CMD_LPUSHX || cmd == resp.CMD_RPUSHX
+
+	n, err := s.store.ListPush(key, side, bulkStrings(args[1:]), onlyIfExists)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if n > 0 {
+		s.blocking.signal(key)
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+// pop handles
+//
+//	LPOP key [count]
+func (s *server) pop(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	if len(args) > 2 {
+		return wrongNumberOfArgs(cmd)
+	}
+	side := store.ListLeft
+	if cmd == resp.CMD_RPOP {
+		side = store.ListRight
+	}
+
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1].BulkString)
+		if err != nil || n < 0 {
+			return resp.NewErrorValue("ERR value is out of range, must be positive")
+		}
+		count = n
+	}
+
+	values, err := s.store.ListPop(args[0].BulkString, side, count)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if values == nil {
+		return resp.Value{Type: resp.NULL}
+	}
+	if len(args) == 1 {
+		return resp.Value{Type: resp.BULK_STRING, BulkString: values[0]}
+	}
+	return resp.Value{Type: resp.ARRAY, Array: createBulkStringArray(values)}
+}
+
+func (s *server) llen(args []resp.Value) resp.Value {
+	n, err := s.store.LLen(args[0].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+func (s *server) lrange(args []resp.Value) resp.Value {
+	start, err1 := strconv.Atoi(args[1].BulkString)
+	stop, err2 := strconv.Atoi(args[2].BulkString)
+	if err1 != nil || err2 != nil {
+		return resp.NewErrorValue("ERR value is not an integer or out of range")
+	}
+
+	values, err := s.store.LRange(args[0].BulkString, start, stop)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.ARRAY, Array: createBulkStringArray(values)}
+}
+
+func (s *server) lindex(args []resp.Value) resp.Value {
+	index, err := strconv.Atoi(args[1].BulkString)
+	if err != nil {
+		return resp.NewErrorValue("ERR value is not an integer or out of range")
+	}
+
+	value, ok, err := s.store.LIndex(args[0].BulkString, index)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if !ok {
+		return resp.Value{Type: resp.NULL}
+	}
+	return resp.Value{Type: resp.BULK_STRING, BulkString: value}
+}
+
+func (s *server) lset(args []resp.Value) resp.Value {
+	index, err := strconv.Atoi(args[1].BulkString)
+	if err != nil {
+		return resp.NewErrorValue("ERR value is not an integer or out of range")
+	}
+
+	err = s.store.LSet(args[0].BulkString, index, args[2].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+}
+
+func (s *server) lrem(args []resp.Value) resp.Value {
+	count, err := strconv.Atoi(args[1].BulkString)
+	if err != nil {
+		return resp.NewErrorValue("ERR value is not an integer or out of range")
+	}
+
+	n, err := s.store.LRem(args[0].BulkString, count, args[2].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+func (s *server) ltrim(args []resp.Value) resp.Value {
+	start, err1 := strconv.Atoi(args[1].BulkString)
+	stop, err2 := strconv.Atoi(args[2].BulkString)
+	if err1 != nil || err2 != nil {
+		return resp.NewErrorValue("ERR value is not an integer or out of range")
+	}
+
+	err := s.store.LTrim(args[0].BulkString, start, stop)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+}
+
+// linsert handles
+//
+//	LINSERT key BEFORE | AFTER pivot element
+func (s *server) linsert(args []resp.Value) resp.Value {
+	var before bool
+	switch strings.ToUpper(args[1].BulkString) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+		before = false
+	default:
+		return resp.NewErrorValue("ERR syntax error")
+	}
+
+	key := args[0].BulkString
+	n, err := s.store.LInsert(key, before, args[2].BulkString, args[3].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if n > 0 {
+		s.blocking.signal(key)
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+// lmove handles LMOVE and RPOPLPUSH, which is LMOVE src dst RIGHT LEFT.
+//
+//	LMOVE source destination LEFT | RIGHT LEFT | RIGHT
+func (s *server) lmove(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	from, to := store.ListRight, store.ListLeft
+	if cmd == resp.CMD_LMOVE {
+		var ok1, ok2 bool
+		from, ok1 = parseListSide(args[2])
+		to, ok2 = parseListSide(args[3])
+		if !ok1 || !ok2 {
+			return resp.NewErrorValue("ERR syntax error")
+		}
+	}
+
+	reply, pushed, _ := s.moveOne(args[0].BulkString, args[1].BulkString, from, to)
+	if pushed != "" {
+		s.blocking.signal(pushed)
+	}
+	return reply
+}
+
+// moveOne is the shared core of LMOVE and BLMOVE, shaped as a servePop so a
+// blocked BLMOVE can be served with it.
+func (s *server) moveOne(src, dst string, from, to store.ListSide) (resp.Value, string, bool) {
+	value, ok, err := s.store.LMove(src, dst, from, to)
+	if err != nil {
+		return resp.NewErrorValue(err.Error()), "", true
+	}
+	if !ok {
+		return resp.Value{Type: resp.NULL}, "", false
+	}
+	return resp.Value{Type: resp.BULK_STRING, BulkString: value}, dst, true
+}
+
+// bpop handles
+//
+//	BLPOP key [key ...] timeout
+func (s *server) bpop(sess *session, cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	timeout, errReply, ok := parseBlockingTimeout(args[len(args)-1])
+	if !ok {
+		return errReply
+	}
+
+	side := store.ListLeft
+	if cmd == resp.CMD_BRPOP {
+		side = store.ListRight
+	}
+
+	keys := bulkStrings(args[:len(args)-1])
+	reply, ok := s.blocking.wait(keys, sess.blockTimeout(timeout), sess.done, func(key string) (resp.Value, string, bool) {
+		values, err := s.store.ListPop(key, side, 1)
+		if err != nil {
+			return resp.NewErrorValue(err.Error()), "", true
+		}
+		if len(values) == 0 {
+			return resp.Value{}, "", false
+		}
+		return resp.Value{Type: resp.ARRAY, Array: createBulkStringArray([]string{key, values[0]})}, "", true
+	})
+	if !ok {
+		return resp.Value{Type: resp.NULL}
+	}
+	return reply
+}
+
+// blmove handles
+//
+//	BLMOVE source destination LEFT | RIGHT LEFT | RIGHT timeout
+func (s *server) blmove(sess *session, args []resp.Value) resp.Value {
+	from, ok1 := parseListSide(args[2])
+	to, ok2 := parseListSide(args[3])
+	if !ok1 || !ok2 {
+		return resp.NewErrorValue("ERR syntax error")
+	}
+
+	timeout, errReply, ok := parseBlockingTimeout(args[4])
+	if !ok {
+		return errReply
+	}
+
+	src, dst := args[0].BulkString, args[1].BulkString
+	reply, ok := s.blocking.wait([]string{src}, sess.blockTimeout(timeout), sess.done, func(key string) (resp.Value, string, bool) {
+		return s.moveOne(src, dst, from, to)
+	})
+	if !ok {
+		return resp.Value{Type: resp.NULL}
+	}
+	return reply
+}
+
+func parseListSide(arg resp.Value) (store.ListSide, bool) {
+	switch strings.ToUpper(arg.BulkString) {
+	case "LEFT":
+		return store.ListLeft, true
+	case "RIGHT":
+		return store.ListRight, true
+	default:
+		return 0, false
+	}
+}
+
+// parseBlockingTimeout reads the timeout of a blocking command, given in
+// seconds with an optional fraction.
+func parseBlockingTimeout(arg resp.Value) (time.Duration, resp.Value, bool) {
+	seconds, err := strconv.ParseFloat(arg.BulkString, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) || seconds > math.MaxInt64/float64(time.Second) {
+		return 0, resp.NewErrorValue("ERR timeout is not a float or out of range"), false
+	}
+	if seconds < 0 {
+		return 0, resp.NewErrorValue("ERR timeout is negative"), false
+	}
+	return time.Duration(seconds * float64(time.Second)), resp.Value{}, true
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"fmt"
+	"runtime"
+	"simpleKV/resp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// memory handles
+//
+//	MEMORY USAGE key [SAMPLES count]
+//	MEMORY STATS
+//	MEMORY DOCTOR
+func (s *server) memory(sess *session, args []resp.Value) resp.Value {
+	switch sub := strings.ToUpper(args[0].BulkString); {
+	case sub == "USAGE" && (len(args) == 2 || len(args) == 4):
+		samples := 5
+		if len(args) == 4 {
+			if strings.ToUpper(args[2].BulkString) != "SAMPLES" {
+				return resp.NewErrorValue("ERR syntax error")
+			}
+			n, err := strconv.Atoi(args[3].BulkString)
+			if err != nil || n < 0 {
+				return resp.NewErrorValue("ERR value is not an integer or out of range")
+			}
+			samples = n
+		}
+
+		size, ok := s.store.MemoryUsage(args[1].BulkString, samples)
+		if !ok {
+			return resp.Value{Type: resp.NULL}
+		}
+		return resp.NewIntegerValue(size)
+
+	case sub == "STATS" && len(args) == 1:
+		return s.memoryStats(sess)
+
+	case sub == "DOCTOR" && len(args) == 1:
+		return resp.Value{Type: resp.BULK_STRING, BulkString: s.memoryDoctor()}
+	}
+
+	return resp.NewErrorValue("ERR unknown subcommand or wrong number of arguments for '" + args[0].BulkString + "'. Try MEMORY HELP.")
+}
+
+func (s *server) memoryStats(sess *session) resp.Value {
+	stats := s.store.MemoryStats()
+	var heap runtime.MemStats
+	runtime.ReadMemStats(&heap)
+
+	field := func(name string, value int64) []resp.Value {
+		return []resp.Value{{Type: resp.BULK_STRING, BulkString: name}, resp.NewIntegerValue(value)}
+	}
+
+	var pairs []resp.Value
+	pairs = append(pairs, field("total.allocated", stats.Total())...)
+	pairs = append(pairs, field("dataset.bytes", stats.Dataset())...)
+	pairs = append(pairs, field("bloomfilter.bytes", stats.BloomFilterBytes())...)
+	pairs = append(pairs, field("keys.count", int64(stats.Keys()))...)
+	if n := stats.Keys(); n > 0 {
+		pairs = append(pairs, field("keys.bytes-per-key", stats.Dataset()/int64(n))...)
+	}
+	pairs = append(pairs, field("maxmemory", s.store.EvictionConfig().MaxMemory)...)
+	pairs = append(pairs, field("runtime.heap.allocated", int64(heap.HeapAlloc))...)
+	pairs = append(pairs, field("runtime.sys", int64(heap.Sys))...)
+
+	for i, sh := range stats.Shards {
+		var shard []resp.Value
+		shard = append(shard, field("keys", int64(sh.Keys))...)
+		shard = append(shard, field("keys.bytes", sh.KeyBytes)...)
+		shard = append(shard, field("values.bytes", sh.ValueBytes)...)
+		shard = append(shard, field("bloomfilter.bytes", sh.BloomBytes)...)
+		pairs = append(pairs, resp.Value{Type: resp.BULK_STRING, BulkString: fmt.Sprintf("shard.%d", i)}, sess.mapReply(shard))
+	}
+
+	return sess.mapReply(pairs)
+}
+
+// memoryDoctor looks for the few memory problems the store can recognize:
+// a nearly full maxmemory, unevenly loaded shards and a bloom filter that
+// costs more than the data it guards. Like Redis it keeps quiet below 5MB,
+// where none of these mean much.
+func (s *server) memoryDoctor() string {
+	stats := s.store.MemoryStats()
+	if stats.Total() < 5<<20 {
+		return "Hi Sam, this instance is empty or is using very little memory, my issues detector can't be used in these conditions."
+	}
+
+	var issues []string
+	if limit := s.store.EvictionConfig().MaxMemory; limit > 0 && stats.Total() > limit*9/10 {
+		issues = append(issues, fmt.Sprintf("Used memory is %s of the %s maxmemory limit; keys are being evicted or writes refused.",
+			humanBytes(stats.Total()), humanBytes(limit)))
+	}
+
+	mean := stats.Dataset() / int64(len(stats.Shards))
+	for i, sh := range stats.Shards {
+		if used := sh.KeyBytes + sh.ValueBytes; mean > 0 && used > 2*mean {
+			issues = append(issues, fmt.Sprintf("Shard %d holds %s, more than twice the %s average; a few big keys may live there.",
+				i, humanBytes(used), humanBytes(mean)))
+		}
+	}
+
+	if stats.BloomFilterBytes() > stats.Dataset() {
+		issues = append(issues, fmt.Sprintf("The bloom filters take %s, more than the %s dataset they guard.",
+			humanBytes(stats.BloomFilterBytes()), humanBytes(stats.Dataset())))
+	}
+
+	if len(issues) == 0 {
+		return "Hi Sam, I can't find any memory issue in your instance. I can only account for what occurs on this base."
+	}
+	return "Sam, I detected a few issues in this instance memory implementation:\n\n * " + strings.Join(issues, "\n * ") + "\n"
+}
+
+// info reports the server state as "name: value" lines.
+func (s *server) info() resp.Value {
+	stats := s.store.MemoryStats()
+	cfg := s.store.EvictionConfig()
+
+	info := "redis_version: 0.0.1\n"
+	info += fmt.Sprintf("connected_clients: %d\n", s.connected.Load())
+	info += fmt.Sprintf("used_memory: %d\n", stats.Total())
+	info += fmt.Sprintf("used_memory_human: %s\n", humanBytes(stats.Total()))
+	info += fmt.Sprintf("used_memory_dataset: %d\n", stats.Dataset())
+	info += fmt.Sprintf("maxmemory: %d\n", cfg.MaxMemory)
+	info += fmt.Sprintf("maxmemory_policy: %s\n", cfg.Policy)
+	info += fmt.Sprintf("uptime_in_seconds: %d\n", int64(time.Since(s.started).Seconds()))
+	info += fmt.Sprintf("keys_count: %d\n", stats.Keys())
+	info += fmt.Sprintf("bloom_filter_bytes: %d\n", stats.BloomFilterBytes())
+	info += fmt.Sprintf("bloom_filter_fill_ratio: %.4f\n", stats.BloomFillRatio())
+	info += fmt.Sprintf("bloom_filter_estimated_fpr: %.6f\n", stats.BloomFPR())
+	info += fmt.Sprintf("bloom_filter_rebuilds: %d\n", stats.BloomRebuilds())
+	info += fmt.Sprintf("keyspace_events_dropped: %d\n", s.droppedKeyspaceEvents.Load())
+
+	// The text spans lines, which only a bulk string can carry.
+	return resp.Value{
+		Type:       resp.BULK_STRING,
+		BulkString: info,
+	}
+}
+
+// humanBytes formats n the way INFO's *_human fields do, e.g. "1.50M".
+func humanBytes(n int64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	f, i := float64(n)/1024, 0
+	for f >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.2f%c", f, units[i])
+}
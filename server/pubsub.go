@@ -0,0 +1,330 @@
+package server
+
+import (
+	"simpleKV/resp"
+	"simpleKV/server/store"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// subscriptionKind tells the three pub/sub namespaces apart: plain channels,
+// channel patterns and shard channels.
+type subscriptionKind int
+
+const (
+	channelSubscription subscriptionKind = iota
+	patternSubscription
+	shardSubscription
+)
+
+// pubsubHub tracks which sessions listen to which channels. Each session
+// also keeps its own subscriptions, so a closing connection can leave
+// everything it joined.
+type pubsubHub struct {
+	mu   sync.RWMutex
+	subs [3]map[string]map[*session]struct{} // indexed by subscriptionKind
+}
+
+func newPubsubHub() *pubsubHub {
+	h := &pubsubHub{}
+	for i := range h.subs {
+		h.subs[i] = make(map[string]map[*session]struct{})
+	}
+	return h
+}
+
+// subscriptions is the per-session side of the hub.
+type subscriptions [3]map[string]struct{}
+
+func (subs *subscriptions) count(kinds ...subscriptionKind) int {
+	n := 0
+	for _, kind := range kinds {
+		n += len(subs[kind])
+	}
+	return n
+}
+
+// subscribed reports whether the session is in subscriber mode.
+func (sess *session) subscribed() bool {
+	return sess.subs.count(channelSubscription, patternSubscription, shardSubscription) > 0
+}
+
+// replyCount is the number Redis sends back with each (un)subscription:
+// shard channels are counted apart from channels and patterns.
+func (sess *session) replyCount(kind subscriptionKind) int64 {
+	if kind == shardSubscription {
+		return int64(sess.subs.count(shardSubscription))
+	}
+	return int64(sess.subs.count(channelSubscription, patternSubscription))
+}
+
+func (h *pubsubHub) subscribe(sess *session, kind subscriptionKind, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if sess.subs[kind] == nil {
+		sess.subs[kind] = make(map[string]struct{})
+	}
+	sess.subs[kind][name] = struct{}{}
+
+	if h.subs[kind][name] == nil {
+		h.subs[kind][name] = make(map[*session]struct{})
+	}
+	h.subs[kind][name][sess] = struct{}{}
+}
+
+func (h *pubsubHub) unsubscribe(sess *session, kind subscriptionKind, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(sess.subs[kind], name)
+	delete(h.subs[kind][name], sess)
+	if len(h.subs[kind][name]) == 0 {
+		delete(h.subs[kind], name)
+	}
+}
+
+// leave drops every subscription of a session whose connection closed.
+func (h *pubsubHub) leave(sess *session) {
+	for kind := range sess.subs {
+		for name := range sess.subs[kind] {
+			h.unsubscribe(sess, subscriptionKind(kind), name)
+		}
+	}
+}
+
+// publish delivers message to the subscribers of channel and, unless it is
+// a shard channel, to the sessions whose patterns match it. It returns how
+// many deliveries were made.
+func (h *pubsubHub) publish(channel, message string, shard bool) int {
+	type delivery struct {
+		sess    *session
+		pattern string
+	}
+
+	h.mu.RLock()
+	var targets []delivery
+	if shard {
+		for sess := range h.subs[shardSubscription][channel] {
+			targets = append(targets, delivery{sess: sess})
+		}
+	} else {
+		for sess := range h.subs[channelSubscription][channel] {
+			targets = append(targets, delivery{sess: sess})
+		}
+		for pattern, sessions := range h.subs[patternSubscription] {
+			if store.MatchGlob(pattern, channel) {
+				for sess := range sessions {
+					targets = append(targets, delivery{sess: sess, pattern: pattern})
+				}
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	// Messages are queued outside the hub lock; a subscriber too slow to
+	// keep up is disconnected instead of holding up the publisher.
+	for _, t := range targets {
+		var frame []string
+		switch {
+		case shard:
+			frame = []string{"smessage", channel, message}
+		case t.pattern != "":
+			frame = []string{"pmessage", t.pattern, channel, message}
+		default:
+			frame = []string{"message", channel, message}
+		}
+		t.sess.push(t.sess.pushReply(createBulkStringArray(frame)))
+	}
+	return len(targets)
+}
+
+// channels lists the channels of kind that have subscribers and match
+// pattern, all of them when pattern is empty.
+func (h *pubsubHub) channels(kind subscriptionKind, pattern string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var names []string
+	for name := range h.subs[kind] {
+		if pattern == "" || store.MatchGlob(pattern, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (h *pubsubHub) numSub(kind subscriptionKind, name string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.subs[kind][name])
+}
+
+// allowedWhileSubscribed are the only commands a RESP2 connection may send
+// once it has subscribed to something.
+var allowedWhileSubscribed = commandSet(
+	resp.CMD_SUBSCRIBE, resp.CMD_PSUBSCRIBE, resp.CMD_SSUBSCRIBE,
+	resp.CMD_UNSUBSCRIBE, resp.CMD_PUNSUBSCRIBE, resp.CMD_SUNSUBSCRIBE,
+	resp.CMD_PING, resp.CMD_RESET,
+)
+
+var subscribeKinds = map[resp.RESPCommand]subscriptionKind{
+	resp.CMD_SUBSCRIBE:    channelSubscription,
+	resp.CMD_PSUBSCRIBE:   patternSubscription,
+	resp.CMD_SSUBSCRIBE:   shardSubscription,
+	resp.CMD_UNSUBSCRIBE:  channelSubscription,
+	resp.CMD_PUNSUBSCRIBE: patternSubscription,
+	resp.CMD_SUNSUBSCRIBE: shardSubscription,
+}
+
+// publish serves PUBLISH and SPUBLISH, answering how many subscribers got
+// the message.
+func (s *server) publish(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	n := s.pubsub.publish(args[0].BulkString, args[1].BulkString, cmd == resp.CMD_SPUBLISH)
+	return resp.NewIntegerValue(int64(n))
+}
+
+// subscribe serves SUBSCRIBE, PSUBSCRIBE and SSUBSCRIBE. Each channel is
+// confirmed with its own frame, so the frames are sent here and nothing is
+// left for the caller to reply.
+func (s *server) subscribe(sess *session, cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	kind := subscribeKinds[cmd]
+	for _, arg := range args {
+		s.pubsub.subscribe(sess, kind, arg.BulkString)
+		sess.send(sess.pushReply([]resp.Value{
+			{Type: resp.BULK_STRING, BulkString: strings.ToLower(string(cmd))},
+			{Type: resp.BULK_STRING, BulkString: arg.BulkString},
+			resp.NewIntegerValue(sess.replyCount(kind)),
+		}))
+	}
+	return resp.Value{}
+}
+
+// unsubscribe serves UNSUBSCRIBE, PUNSUBSCRIBE and SUNSUBSCRIBE. Without
+// arguments it leaves every channel of its kind.
+func (s *server) unsubscribe(sess *session, cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	kind := subscribeKinds[cmd]
+	names := bulkStrings(args)
+	if len(names) == 0 {
+		for name := range sess.subs[kind] {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+	}
+
+	kindName := resp.Value{Type: resp.BULK_STRING, BulkString: strings.ToLower(string(cmd))}
+	if len(names) == 0 {
+		sess.send(sess.pushReply([]resp.Value{kindName, {Type: resp.NULL}, resp.NewIntegerValue(sess.replyCount(kind))}))
+		return resp.Value{}
+	}
+
+	for _, name := range names {
+		s.pubsub.unsubscribe(sess, kind, name)
+		sess.send(sess.pushReply([]resp.Value{
+			kindName,
+			{Type: resp.BULK_STRING, BulkString: name},
+			resp.NewIntegerValue(sess.replyCount(kind)),
+		}))
+	}
+	return resp.Value{}
+}
+
+// pubsubCommand handles
+//
+//	PUBSUB CHANNELS [pattern]
+//	PUBSUB NUMSUB [channel ...]
+//	PUBSUB NUMPAT
+//	PUBSUB SHARDCHANNELS [pattern]
+//	PUBSUB SHARDNUMSUB [channel ...]
+func (s *server) pubsubCommand(sess *session, args []resp.Value) resp.Value {
+	sub := strings.ToUpper(args[0].BulkString)
+	switch {
+	case (sub == "CHANNELS" || sub == "SHARDCHANNELS") && len(args) <= 2:
+		kind := channelSubscription
+		if sub == "SHARDCHANNELS" {
+			kind = shardSubscription
+		}
+		pattern := ""
+		if len(args) == 2 {
+			pattern = args[1].BulkString
+		}
+		return resp.Value{Type: resp.ARRAY, Array: createBulkStringArray(s.pubsub.channels(kind, pattern))}
+
+	case sub == "NUMSUB" || sub == "SHARDNUMSUB":
+		kind := channelSubscription
+		if sub == "SHARDNUMSUB" {
+			kind = shardSubscription
+		}
+		var pairs []resp.Value
+		for _, arg := range args[1:] {
+			pairs = append(pairs,
+				resp.Value{Type: resp.BULK_STRING, BulkString: arg.BulkString},
+				resp.NewIntegerValue(int64(s.pubsub.numSub(kind, arg.BulkString))))
+		}
+		return sess.mapReply(pairs)
+
+	case sub == "NUMPAT" && len(args) == 1:
+		return resp.NewIntegerValue(int64(len(s.pubsub.channels(patternSubscription, ""))))
+	}
+
+	return resp.NewErrorValue("ERR unknown subcommand or wrong number of arguments for '" + args[0].BulkString + "'. Try PUBSUB HELP.")
+}
+
+// ping answers PONG, or echoes its argument. A RESP2 connection in
+// subscriber mode gets the reply as a pong frame instead.
+func (s *server) ping(sess *session, args []resp.Value) resp.Value {
+	if len(args) > 1 {
+		return wrongNumberOfArgs(resp.CMD_PING)
+	}
+	message := ""
+	if len(args) == 1 {
+		message = args[0].BulkString
+	}
+
+	if sess.subscribed() && sess.protocol == 2 {
+		return resp.Value{Type: resp.ARRAY, Array: createBulkStringArray([]string{"pong", message})}
+	}
+	if len(args) == 1 {
+		return resp.Value{Type: resp.BULK_STRING, BulkString: message}
+	}
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "PONG"}
+}
+
+// Keyspace notifications are published on these channels, followed by the
+// key or the event name. There is a single database, numbered 0.
+const (
+	keyspaceChannelPrefix = "__keyspace@0__:"
+	keyeventChannelPrefix = "__keyevent@0__:"
+)
+
+// keyspaceEvent is a change reported by the store, waiting to be published.
+type keyspaceEvent struct {
+	event, key string
+}
+
+// notifyKeyspaceEvent is the store's Notifier. The store calls it with
+// shard locks held, so events are queued here and written to subscribers
+// by deliverKeyspaceEvents. Waiting on a slow subscriber would stall every
+// writer, so when the queue is full the event is dropped and counted.
+func (s *server) notifyKeyspaceEvent(event, key string) {
+	select {
+	case s.keyspaceEvents <- keyspaceEvent{event: event, key: key}:
+	default:
+		s.droppedKeyspaceEvents.Add(1)
+	}
+}
+
+func (s *server) deliverKeyspaceEvents() {
+	for ev := range s.keyspaceEvents {
+		flags := s.store.NotifyFlags()
+		if flags&store.NotifyKeyspace != 0 {
+			s.pubsub.publish(keyspaceChannelPrefix+ev.key, ev.event, false)
+		}
+		if flags&store.NotifyKeyevent != 0 {
+			s.pubsub.publish(keyeventChannelPrefix+ev.event, ev.key, false)
+		}
+	}
+}
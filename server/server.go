@@ -1,26 +1,76 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"simpleKV/resp"
 	"simpleKV/server/store"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type IServer interface {
 	Run() error
+	Close() error
 }
 
 type server struct {
-	store store.IStore
-	addr  string
+	store    store.IStore
+	addr     string
+	blocking *blockingQueues
+	pubsub   *pubsubHub
+	clientID atomic.Int64
+
+	// txMu is held for reading by every command and for writing by EXEC.
+	txMu sync.RWMutex
+
+	keyspaceEvents        chan keyspaceEvent
+	droppedKeyspaceEvents atomic.Int64
+
+	started   time.Time
+	connected atomic.Int64
+
+	// connMu guards the listener and the open connections, which Close
+	// shuts.
+	connMu   sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// session is the state the server keeps for each open connection.
+type session struct {
+	id       int64
+	conn     net.Conn
+	protocol int // RESP version spoken on the connection, 2 until HELLO 3
+	name     string
+	subs     subscriptions
+	tx       txState
+
+	// out queues the frames a single goroutine writes to conn, replies and
+	// pub/sub messages from other connections alike.
+	out chan []byte
+
+	// done is closed once the client disconnects or the server closes the
+	// connection, which ends blocking commands still waiting for it.
+	done chan struct{}
 }
 
 func NewServer(addr string, store store.IStore) IServer {
-	return &server{
-		store: store,
-		addr:  addr,
+	s := &server{
+		store:          store,
+		addr:           addr,
+		pubsub:         newPubsubHub(),
+		keyspaceEvents: make(chan keyspaceEvent, 1024),
+		started:        time.Now(),
+		conns:          make(map[net.Conn]struct{}),
 	}
+	s.blocking = newBlockingQueues(&s.txMu)
+	store.SetNotifier(s.notifyKeyspaceEvent)
+	go s.deliverKeyspaceEvents()
+	return s
 }
 
 func (s *server) Run() error {
@@ -30,34 +80,213 @@ func (s *server) Run() error {
 	}
 	defer ln.Close()
 
+	s.connMu.Lock()
+	if s.closed {
+		s.connMu.Unlock()
+		return nil
+	}
+	s.listener = ln
+	s.connMu.Unlock()
+
 	fmt.Printf("Server started on %s\n", s.addr)
 
 	for {
 		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
 		if err != nil {
 			fmt.Println("Error accepting connection:", err)
 			continue
 		}
+
+		s.connMu.Lock()
+		if s.closed {
+			s.connMu.Unlock()
+			conn.Close()
+			return nil
+		}
+		s.conns[conn] = struct{}{}
+		s.connMu.Unlock()
 		go s.handleConnection(conn)
 	}
 }
 
+// Close stops accepting connections and closes the open ones, which makes
+// Run return. The store is left to its owner.
+func (s *server) Close() error {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+	return nil
+}
+
 func (s *server) handleConnection(conn net.Conn) {
-	defer conn.Close()
+	defer func() {
+		s.connMu.Lock()
+		delete(s.conns, conn)
+		s.connMu.Unlock()
+		conn.Close()
+	}()
+	s.connected.Add(1)
+	defer s.connected.Add(-1)
 	reader := resp.NewReader(conn)
+	sess := &session{
+		id:       s.clientID.Add(1),
+		conn:     conn,
+		protocol: 2,
+		out:      make(chan []byte, outboundQueueLen),
+		done:     make(chan struct{}),
+	}
+	defer s.pubsub.leave(sess)
+	defer s.unwatch(sess)
+
+	// Requests are read on a goroutine of their own, so that a client
+	// hanging up is noticed while one of its commands is blocked.
+	requests := make(chan resp.Value)
+	stopped := make(chan struct{})
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		sess.writeLoop(stopped)
+	}()
+	defer func() {
+		close(stopped)
+		<-flushed
+	}()
+	go func() {
+		defer close(sess.done)
+		for {
+			req, err := reader.Read()
+			if err != nil {
+				fmt.Println("Error reading request:", err)
+				return
+			}
+			select {
+			case requests <- req:
+			case <-stopped:
+				return
+			}
+		}
+	}()
 
 	for {
-		req, err := reader.Read()
-		if err != nil {
-			fmt.Println("Error reading request:", err)
+		var req resp.Value
+		select {
+		case req = <-requests:
+		case <-sess.done:
 			return
 		}
 
-		res := s.handleRequest(req)
-		_, err = conn.Write(res.Marshal())
-		if err != nil {
-			fmt.Println("Error writing response:", err)
+		res := s.handleRequest(sess, req)
+		if err := sess.send(res); err != nil {
 			return
 		}
 	}
 }
+
+// outboundQueueLen is how many frames may wait to be written to a client.
+// A subscriber that falls this far behind is disconnected.
+const outboundQueueLen = 1024
+
+var errConnClosed = errors.New("connection closed")
+
+// send queues v for the connection, waiting for room if the client is slow
+// to read. The zero Value, which handlers return when they already sent
+// their own frames, writes nothing.
+func (sess *session) send(v resp.Value) error {
+	if v.Type == 0 {
+		return nil
+	}
+
+	select {
+	case sess.out <- v.Marshal():
+		return nil
+	case <-sess.done:
+		return errConnClosed
+	}
+}
+
+// push queues a message from another connection without waiting. A client
+// whose queue is full is disconnected rather than allowed to hold up the
+// sender.
+func (sess *session) push(v resp.Value) {
+	select {
+	case sess.out <- v.Marshal():
+	default:
+		fmt.Printf("Closing client %d for falling behind on pub/sub messages\n", sess.id)
+		sess.conn.Close()
+	}
+}
+
+// flushTimeout bounds how long a closing connection keeps writing out
+// what is still queued for it.
+const flushTimeout = 5 * time.Second
+
+// writeLoop writes queued frames to the connection until stopped is closed,
+// then flushes what is left. A failed write closes the connection.
+func (sess *session) writeLoop(stopped <-chan struct{}) {
+	write := func(frame []byte) bool {
+		if _, err := sess.conn.Write(frame); err != nil {
+			fmt.Println("Error writing response:", err)
+			sess.conn.Close()
+			return false
+		}
+		return true
+	}
+
+	for {
+		select {
+		case frame := <-sess.out:
+			if !write(frame) {
+				return
+			}
+		case <-stopped:
+			sess.conn.SetWriteDeadline(time.Now().Add(flushTimeout))
+			for {
+				select {
+				case frame := <-sess.out:
+					if !write(frame) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// pushReply wraps an out-of-band message: a RESP3 push frame, or a plain
+// array for RESP2 clients.
+func (sess *session) pushReply(items []resp.Value) resp.Value {
+	if sess.protocol == 3 {
+		return resp.Value{Type: resp.PUSH, Array: items}
+	}
+	return resp.Value{Type: resp.ARRAY, Array: items}
+}
+
+// setReply answers with a RESP3 set when the client speaks RESP3 and with a
+// plain array otherwise.
+func (sess *session) setReply(members []string) resp.Value {
+	if sess.protocol == 3 {
+		return resp.Value{Type: resp.SET, Array: createBulkStringArray(members)}
+	}
+	return resp.Value{Type: resp.ARRAY, Array: createBulkStringArray(members)}
+}
+
+// mapReply answers with a RESP3 map when the client speaks RESP3 and with a
+// flat key/value array otherwise.
+func (sess *session) mapReply(pairs []resp.Value) resp.Value {
+	if sess.protocol == 3 {
+		return resp.Value{Type: resp.MAP, Array: pairs}
+	}
+	return resp.Value{Type: resp.ARRAY, Array: pairs}
+}
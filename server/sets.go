@@ -0,0 +1,181 @@
+package server
+
+import (
+	"simpleKV/resp"
+	"simpleKV/server/store"
+	"strconv"
+	"strings"
+)
+
+func (s *server) sadd(args []resp.Value) resp.Value {
+	n, err := s.store.SAdd(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+func (s *server) srem(args []resp.Value) resp.Value {
+	n, err := s.store.SRem(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+// smismember serves SISMEMBER, which answers with a single integer, and
+// SMISMEMBER, which answers with one per member.
+func (s *server) smismember(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	found, err := s.store.SMIsMember(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if cmd == resp.CMD_SISMEMBER {
+		return boolInteger(found[0])
+	}
+
+	reply := make([]resp.Value, len(found))
+	for i, ok := range found {
+		reply[i] = boolInteger(ok)
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
+
+func (s *server) smembers(sess *session, args []resp.Value) resp.Value {
+	members, err := s.store.SMembers(args[0].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return sess.setReply(members)
+}
+
+func (s *server) scard(args []resp.Value) resp.Value {
+	n, err := s.store.SCard(args[0].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+// maxRandMembers bounds the members a negative SRANDMEMBER count may ask
+// for, since they are all gathered before the reply is written.
+const maxRandMembers = 1 << 24
+
+// spop serves SPOP and SRANDMEMBER. Without a count both answer with a single
+// member or a null.
+//
+//	SPOP key [count]
+//	SRANDMEMBER key [count]
+func (s *server) spop(sess *session, cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	if len(args) > 2 {
+		return wrongNumberOfArgs(cmd)
+	}
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1].BulkString)
+		if err != nil || cmd == resp.CMD_SPOP && n < 0 {
+			return resp.NewErrorValue("ERR value is out of range, must be positive")
+		}
+		if n < -maxRandMembers {
+			return resp.NewErrorValue("ERR value is out of range")
+		}
+		count = n
+	}
+
+	var members []string
+	var err error
+	if cmd == resp.CMD_SPOP {
+		members, err = s.store.SPop(args[0].BulkString, count)
+	} else {
+		members, err = s.store.SRandMember(args[0].BulkString, count)
+	}
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	if len(args) == 1 {
+		if len(members) == 0 {
+			return resp.Value{Type: resp.NULL}
+		}
+		return resp.Value{Type: resp.BULK_STRING, BulkString: members[0]}
+	}
+	if cmd == resp.CMD_SPOP {
+		return sess.setReply(members)
+	}
+	// SRANDMEMBER with a negative count may repeat members, so it can't be a set.
+	return resp.Value{Type: resp.ARRAY, Array: createBulkStringArray(members)}
+}
+
+func (s *server) smove(args []resp.Value) resp.Value {
+	ok, err := s.store.SMove(args[0].BulkString, args[1].BulkString, args[2].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return boolInteger(ok)
+}
+
+func setOpOf(cmd resp.RESPCommand) store.SetOp {
+	switch cmd {
+	case resp.CMD_SUNION, resp.CMD_SUNIONSTORE:
+		return store.SetUnion
+	case resp.CMD_SDIFF, resp.CMD_SDIFFSTORE:
+		return store.SetDiff
+	default:
+		return store.SetInter
+	}
+}
+
+// scombine serves SINTER, SUNION and SDIFF.
+func (s *server) scombine(sess *session, cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	members, err := s.store.SCombine(setOpOf(cmd), bulkStrings(args))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return sess.setReply(members)
+}
+
+// scombinestore serves SINTERSTORE, SUNIONSTORE and SDIFFSTORE.
+//
+//	SINTERSTORE destination key [key ...]
+func (s *server) scombinestore(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	n, err := s.store.SCombineStore(setOpOf(cmd), args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+// sintercard handles
+//
+//	SINTERCARD numkeys key [key ...] [LIMIT limit]
+func (s *server) sintercard(args []resp.Value) resp.Value {
+	numKeys, err := strconv.Atoi(args[0].BulkString)
+	if err != nil || numKeys < 1 {
+		return resp.NewErrorValue("ERR numkeys should be greater than 0")
+	}
+	if numKeys > len(args)-1 {
+		return resp.NewErrorValue("ERR Number of keys can't be greater than number of args")
+	}
+
+	limit := 0
+	rest := args[1+numKeys:]
+	if len(rest) > 0 {
+		if len(rest) != 2 || strings.ToUpper(rest[0].BulkString) != "LIMIT" {
+			return resp.NewErrorValue("ERR syntax error")
+		}
+		limit, err = strconv.Atoi(rest[1].BulkString)
+		if err != nil || limit < 0 {
+			return resp.NewErrorValue("ERR LIMIT can't be negative")
+		}
+	}
+
+	members, err := s.store.SCombine(store.SetInter, bulkStrings(args[1:1+numKeys]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	n := len(members)
+	if limit > 0 {
+		n = min(n, limit)
+	}
+	return resp.NewIntegerValue(int64(n))
+}
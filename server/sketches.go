@@ -0,0 +1,325 @@
+package server
+
+import (
+	"math"
+	"simpleKV/resp"
+	"simpleKV/server/store"
+	"strconv"
+	"strings"
+)
+
+// cmsinitbydim handles
+//
+//	CMS.INITBYDIM key width depth
+func (s *server) cmsinitbydim(args []resp.Value) resp.Value {
+	width, err := strconv.Atoi(args[1].BulkString)
+	if err != nil || width < 1 {
+		return resp.NewErrorValue("ERR CMS: invalid width")
+	}
+	depth, err := strconv.Atoi(args[2].BulkString)
+	if err != nil || depth < 1 {
+		return resp.NewErrorValue("ERR CMS: invalid depth")
+	}
+
+	if err := s.store.CMSInitByDim(args[0].BulkString, width, depth); err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+}
+
+// cmsincrby handles
+//
+//	CMS.INCRBY key item increment [item increment ...]
+func (s *server) cmsincrby(args []resp.Value) resp.Value {
+	if len(args)%2 != 1 {
+		return wrongNumberOfArgs(resp.CMD_CMS_INCRBY)
+	}
+
+	items := make([]string, 0, len(args)/2)
+	increments := make([]int64, 0, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		n, err := strconv.ParseInt(args[i+1].BulkString, 10, 64)
+		if err != nil || n < 0 {
+			return resp.NewErrorValue("ERR CMS: Cannot parse number")
+		}
+		items = append(items, args[i].BulkString)
+		increments = append(increments, n)
+	}
+
+	counts, err := s.store.CMSIncrBy(args[0].BulkString, items, increments)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return integerArray(counts)
+}
+
+func (s *server) cmsquery(args []resp.Value) resp.Value {
+	counts, err := s.store.CMSQuery(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return integerArray(counts)
+}
+
+// cmsmerge handles
+//
+//	CMS.MERGE destination numKeys source [source ...] [WEIGHTS weight [weight ...]]
+func (s *server) cmsmerge(args []resp.Value) resp.Value {
+	n, err := strconv.Atoi(args[1].BulkString)
+	if err != nil || n < 1 {
+		return resp.NewErrorValue("ERR CMS: invalid numkeys")
+	}
+	if n > len(args)-2 {
+		return resp.NewErrorValue("ERR syntax error")
+	}
+	keys := bulkStrings(args[2 : 2+n])
+
+	weights := make([]int64, n)
+	for i := range weights {
+		weights[i] = 1
+	}
+	if rest := args[2+n:]; len(rest) > 0 {
+		if strings.ToUpper(rest[0].BulkString) != "WEIGHTS" || len(rest) != 1+n {
+			return resp.NewErrorValue("ERR syntax error")
+		}
+		for i, arg := range rest[1:] {
+			weights[i], err = strconv.ParseInt(arg.BulkString, 10, 64)
+			if err != nil {
+				return resp.NewErrorValue("ERR CMS: invalid weight value")
+			}
+		}
+	}
+
+	if err := s.store.CMSMerge(args[0].BulkString, keys, weights); err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+}
+
+// topkreserve handles
+//
+//	TOPK.RESERVE key topk [width depth decay]
+func (s *server) topkreserve(args []resp.Value) resp.Value {
+	if len(args) != 2 && len(args) != 5 {
+		return wrongNumberOfArgs(resp.CMD_TOPK_RESERVE)
+	}
+	opts := store.TopKOptions{Width: 8, Depth: 7, Decay: 0.9}
+
+	k, err := strconv.Atoi(args[1].BulkString)
+	if err != nil || k < 1 {
+		return resp.NewErrorValue("ERR TopK: invalid k")
+	}
+	opts.K = k
+
+	if len(args) == 5 {
+		width, err := strconv.Atoi(args[2].BulkString)
+		if err != nil || width < 1 {
+			return resp.NewErrorValue("ERR TopK: invalid width")
+		}
+		depth, err := strconv.Atoi(args[3].BulkString)
+		if err != nil || depth < 1 {
+			return resp.NewErrorValue("ERR TopK: invalid depth")
+		}
+		decay, err := strconv.ParseFloat(args[4].BulkString, 64)
+		if err != nil || !(decay > 0 && decay <= 1) {
+			return resp.NewErrorValue("ERR TopK: invalid decay value. must be '<= 1' and '> 0'")
+		}
+		opts.Width, opts.Depth, opts.Decay = width, depth, decay
+	}
+
+	if err := s.store.TopKReserve(args[0].BulkString, opts); err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+}
+
+// topkadd answers with the item each added item pushed out of the list,
+// or a null.
+func (s *server) topkadd(args []resp.Value) resp.Value {
+	expelled, err := s.store.TopKAdd(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	reply := make([]resp.Value, len(expelled))
+	for i, item := range expelled {
+		if item == nil {
+			reply[i] = resp.Value{Type: resp.NULL}
+		} else {
+			reply[i] = resp.Value{Type: resp.BULK_STRING, BulkString: *item}
+		}
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
+
+// topklist handles
+//
+//	TOPK.LIST key [WITHCOUNT]
+func (s *server) topklist(args []resp.Value) resp.Value {
+	if len(args) > 2 {
+		return wrongNumberOfArgs(resp.CMD_TOPK_LIST)
+	}
+	withCount := len(args) == 2
+	if withCount && strings.ToUpper(args[1].BulkString) != "WITHCOUNT" {
+		return resp.NewErrorValue("ERR syntax error")
+	}
+
+	items, err := s.store.TopKList(args[0].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	reply := []resp.Value{}
+	for _, it := range items {
+		reply = append(reply, resp.Value{Type: resp.BULK_STRING, BulkString: it.Item})
+		if withCount {
+			reply = append(reply, resp.NewIntegerValue(it.Count))
+		}
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
+
+// parseCompression reads the value of a COMPRESSION option.
+func parseCompression(arg resp.Value) (float64, resp.Value, bool) {
+	n, err := strconv.Atoi(arg.BulkString)
+	if err != nil || n < 1 {
+		return 0, resp.NewErrorValue("ERR T-Digest: compression parameter needs to be a positive integer"), false
+	}
+	return float64(n), resp.Value{}, true
+}
+
+// tdigestcreate handles
+//
+//	TDIGEST.CREATE key [COMPRESSION compression]
+func (s *server) tdigestcreate(args []resp.Value) resp.Value {
+	var compression float64
+	switch {
+	case len(args) == 3 && strings.ToUpper(args[1].BulkString) == "COMPRESSION":
+		var errReply resp.Value
+		var ok bool
+		if compression, errReply, ok = parseCompression(args[2]); !ok {
+			return errReply
+		}
+	case len(args) != 1:
+		return resp.NewErrorValue("ERR syntax error")
+	}
+
+	if err := s.store.TDigestCreate(args[0].BulkString, compression); err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+}
+
+// parseFloats reads args as finite floats, answering with errMsg if one
+// isn't.
+func parseFloats(args []resp.Value, errMsg string) ([]float64, resp.Value, bool) {
+	floats := make([]float64, len(args))
+	for i, arg := range args {
+		f, err := strconv.ParseFloat(arg.BulkString, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, resp.NewErrorValue(errMsg), false
+		}
+		floats[i] = f
+	}
+	return floats, resp.Value{}, true
+}
+
+func (s *server) tdigestadd(args []resp.Value) resp.Value {
+	values, errReply, ok := parseFloats(args[1:], "ERR T-Digest: error parsing val parameter")
+	if !ok {
+		return errReply
+	}
+	if err := s.store.TDigestAdd(args[0].BulkString, values); err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+}
+
+// tdigestquantile answers with the estimated value at every quantile, nan
+// for an empty digest.
+func (s *server) tdigestquantile(sess *session, args []resp.Value) resp.Value {
+	qs, errReply, ok := parseFloats(args[1:], "ERR T-Digest: error parsing quantile")
+	if !ok {
+		return errReply
+	}
+	for _, q := range qs {
+		if q < 0 || q > 1 {
+			return resp.NewErrorValue("ERR T-Digest: quantile should be in [0,1]")
+		}
+	}
+
+	values, err := s.store.TDigestQuantile(args[0].BulkString, qs)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return sess.scoresReply(values)
+}
+
+// tdigestcdf answers with the estimated share of values below every value,
+// nan for an empty digest.
+func (s *server) tdigestcdf(sess *session, args []resp.Value) resp.Value {
+	values, errReply, ok := parseFloats(args[1:], "ERR T-Digest: error parsing cdf")
+	if !ok {
+		return errReply
+	}
+
+	shares, err := s.store.TDigestCDF(args[0].BulkString, values)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return sess.scoresReply(shares)
+}
+
+// tdigestmerge handles
+//
+//	TDIGEST.MERGE destination numkeys source [source ...] [COMPRESSION compression] [OVERRIDE]
+func (s *server) tdigestmerge(args []resp.Value) resp.Value {
+	n, err := strconv.Atoi(args[1].BulkString)
+	if err != nil || n < 1 {
+		return resp.NewErrorValue("ERR T-Digest: numkeys needs to be a positive integer")
+	}
+	if n > len(args)-2 {
+		return resp.NewErrorValue("ERR syntax error")
+	}
+	keys := bulkStrings(args[2 : 2+n])
+
+	var compression float64
+	override := false
+	for i := 2 + n; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].BulkString); {
+		case opt == "COMPRESSION" && i+1 < len(args):
+			var errReply resp.Value
+			var ok bool
+			if compression, errReply, ok = parseCompression(args[i+1]); !ok {
+				return errReply
+			}
+			i++
+		case opt == "OVERRIDE":
+			override = true
+		default:
+			return resp.NewErrorValue("ERR syntax error")
+		}
+	}
+
+	if err := s.store.TDigestMerge(args[0].BulkString, keys, compression, override); err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+}
+
+func integerArray(ns []int64) resp.Value {
+	reply := make([]resp.Value, len(ns))
+	for i, n := range ns {
+		reply[i] = resp.NewIntegerValue(n)
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
+
+// scoresReply answers with an array of scoreReply values.
+func (sess *session) scoresReply(scores []float64) resp.Value {
+	reply := make([]resp.Value, len(scores))
+	for i, score := range scores {
+		reply[i] = sess.scoreReply(score)
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
@@ -0,0 +1,448 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"simpleKV/resp"
+)
+
+var (
+	ErrBitOffset = errors.New("ERR bit offset is not an integer or out of range")
+	ErrBitNotOne = errors.New("ERR BITOP NOT must be called with a single source key.")
+)
+
+// maxBitOffset is the last bit a value of maxStringSize bytes can hold.
+const maxBitOffset = maxStringSize*8 - 1
+
+// BitRange selects part of a string for BitCount and BitPos. Negative
+// offsets count from the end; End only applies when HasEnd is set.
+type BitRange struct {
+	Start, End int
+	HasEnd     bool
+	Bits       bool // offsets count bits rather than bytes
+}
+
+// BitOp is the operation BitOp applies across its source keys.
+type BitOp int
+
+const (
+	BitAnd BitOp = iota
+	BitOr
+	BitXor
+	BitNot
+)
+
+// BitFieldKind is what a BITFIELD sub-command does to its integer.
+type BitFieldKind int
+
+const (
+	BitFieldGet BitFieldKind = iota
+	BitFieldSet
+	BitFieldIncrBy
+)
+
+// BitFieldOverflow decides what SET and INCRBY do with results that do not
+// fit the integer type.
+type BitFieldOverflow int
+
+const (
+	OverflowWrap BitFieldOverflow = iota
+	OverflowSat
+	OverflowFail
+)
+
+// BitFieldOp is one BITFIELD sub-command on the Bits-wide integer starting
+// at bit Offset.
+type BitFieldOp struct {
+	Kind     BitFieldKind
+	Signed   bool
+	Bits     int
+	Offset   int64
+	Value    int64 // the value to SET or the increment to add
+	Overflow BitFieldOverflow
+}
+
+func (s *store) lookupBytes(sh *shard, key string) (*entry, []byte, error) {
+	e, err := lookupString(sh, key)
+	if err != nil || e == nil {
+		return nil, nil, err
+	}
+	return e, []byte(asBulk(e.Value).BulkString), nil
+}
+
+// grow zero-pads buf so that it holds bit offset lastBit.
+func grow(buf []byte, lastBit int64) []byte {
+	if need := int(lastBit/8) + 1; need > len(buf) {
+		buf = append(buf, make([]byte, need-len(buf))...)
+	}
+	return buf
+}
+
+func getBit(buf []byte, offset int64) int {
+	if offset/8 >= int64(len(buf)) {
+		return 0
+	}
+	return int(buf[offset/8]>>(7-offset%8)) & 1
+}
+
+func setBit(buf []byte, offset int64, bit int) {
+	mask := byte(1) << (7 - offset%8)
+	if bit == 1 {
+		buf[offset/8] |= mask
+	} else {
+		buf[offset/8] &^= mask
+	}
+}
+
+// SetBit sets the bit at offset, growing the string as needed, and returns
+// the bit's previous value.
+func (s *store) SetBit(key string, offset int64, bit int) (int, error) {
+	if offset < 0 || offset > maxBitOffset {
+		return 0, ErrBitOffset
+	}
+
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e, buf, err := s.lookupBytes(shard, key)
+	if err != nil {
+		return 0, err
+	}
+
+	buf = grow(buf, offset)
+	prev := getBit(buf, offset)
+	setBit(buf, offset, bit)
+	s.putString(shard, key, e, string(buf))
+	s.notify(NotifyString, "setbit", key)
+
+	return prev, nil
+}
+
+func (s *store) GetBit(key string, offset int64) (int, error) {
+	if offset < 0 || offset > maxBitOffset {
+		return 0, ErrBitOffset
+	}
+
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	_, buf, err := s.lookupBytes(shard, key)
+	if err != nil {
+		return 0, err
+	}
+	return getBit(buf, offset), nil
+}
+
+// bitSpan resolves r against a string of n bytes into an inclusive range of
+// bit offsets. It reports false when the range is empty.
+func (r BitRange) bitSpan(n int) (int, int, bool) {
+	total := n
+	if r.Bits {
+		total = n * 8
+	}
+
+	start, end := r.Start, total-1
+	if r.HasEnd {
+		end = r.End
+	}
+	if start < 0 {
+		start = max(total+start, 0)
+	}
+	if end < 0 {
+		end = max(total+end, 0)
+	}
+	end = min(end, total-1)
+	if start > end || total == 0 {
+		return 0, 0, false
+	}
+
+	if !r.Bits {
+		return start * 8, end*8 + 7, true
+	}
+	return start, end, true
+}
+
+// BitCount counts the set bits of the string at key within r.
+func (s *store) BitCount(key string, r BitRange) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	_, buf, err := s.lookupBytes(shard, key)
+	if err != nil {
+		return 0, err
+	}
+
+	first, last, ok := r.bitSpan(len(buf))
+	if !ok {
+		return 0, nil
+	}
+
+	count := 0
+	for i := first; i <= last; {
+		if i%8 == 0 && i+7 <= last {
+			count += bits.OnesCount8(buf[i/8])
+			i += 8
+			continue
+		}
+		count += getBit(buf, int64(i))
+		i++
+	}
+	return count, nil
+}
+
+// BitPos returns the offset of the first bit equal to bit within r, or -1.
+// When looking for a clear bit without an explicit end, the string counts as
+// padded with zeros, so the bit right after it is the answer.
+func (s *store) BitPos(key string, bit int, r BitRange) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e, buf, err := s.lookupBytes(shard, key)
+	if err != nil {
+		return 0, err
+	}
+	if e == nil {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+
+	first, last, ok := r.bitSpan(len(buf))
+	if !ok {
+		return -1, nil
+	}
+
+	skip := byte(0)
+	if bit == 0 {
+		skip = 0xff
+	}
+	for i := first; i <= last; {
+		if i%8 == 0 && i+7 <= last && buf[i/8] == skip {
+			i += 8
+			continue
+		}
+		if getBit(buf, int64(i)) == bit {
+			return i, nil
+		}
+		i++
+	}
+
+	if bit == 0 && !r.HasEnd {
+		return last + 1, nil
+	}
+	return -1, nil
+}
+
+// BitOp stores at dst the result of op across the strings at keys, treating
+// missing keys as empty and shorter strings as zero-padded. It returns the
+// length of the result; an empty result deletes dst.
+func (s *store) BitOp(op BitOp, dst string, keys []string) (int, error) {
+	if op == BitNot && len(keys) != 1 {
+		return 0, ErrBitNotOne
+	}
+
+	unlock := s.lockKeys(append([]string{dst}, keys...)...)
+	defer unlock()
+
+	sources := make([][]byte, len(keys))
+	size := 0
+	for i, key := range keys {
+		_, buf, err := s.lookupBytes(s.getShard(key), key)
+		if err != nil {
+			return 0, err
+		}
+		sources[i] = buf
+		size = max(size, len(buf))
+	}
+
+	result := make([]byte, size)
+	for i := range result {
+		at := func(src []byte) byte {
+			if i < len(src) {
+				return src[i]
+			}
+			return 0
+		}
+
+		b := at(sources[0])
+		for _, src := range sources[1:] {
+			switch op {
+			case BitAnd:
+				b &= at(src)
+			case BitOr:
+				b |= at(src)
+			case BitXor:
+				b ^= at(src)
+			}
+		}
+		if op == BitNot {
+			b = ^b
+		}
+		result[i] = b
+	}
+
+	shard := s.getShard(dst)
+	if size == 0 {
+		if _, ok := shard.lookup(dst); ok {
+			shard.remove(dst)
+			s.notify(NotifyGeneric, "del", dst)
+		}
+		return 0, nil
+	}
+	shard.put(dst, &entry{Value: resp.Value{Type: resp.BULK_STRING, BulkString: string(result)}})
+	s.notify(NotifyString, "set", dst)
+
+	return size, nil
+}
+
+func getField(buf []byte, offset int64, width int) uint64 {
+	var v uint64
+	for i := range int64(width) {
+		v = v<<1 | uint64(getBit(buf, offset+i))
+	}
+	return v
+}
+
+func setField(buf []byte, offset int64, width int, v uint64) {
+	for i := range int64(width) {
+		setBit(buf, offset+i, int(v>>(int64(width)-1-i))&1)
+	}
+}
+
+// signExtend reads the low width bits of v as a two's complement integer.
+func signExtend(v uint64, width int) int64 {
+	shift := 64 - width
+	return int64(v<<shift) >> shift
+}
+
+// fit applies op.Overflow to value+incr for the integer type of op. It
+// reports false when the result overflowed and the policy is FAIL.
+func (op BitFieldOp) fit(value, incr int64) (int64, bool) {
+	if op.Signed {
+		maxV := int64(math.MaxInt64)
+		if op.Bits < 64 {
+			maxV = int64(1)<<(op.Bits-1) - 1
+		}
+		minV := -maxV - 1
+
+		overflow := incr > 0 && value > maxV-incr
+		underflow := incr < 0 && value < minV-incr
+		if value > maxV {
+			overflow = true
+		} else if value < minV {
+			underflow = true
+		}
+		if !overflow && !underflow {
+			return value + incr, true
+		}
+
+		switch op.Overflow {
+		case OverflowSat:
+			if overflow {
+				return maxV, true
+			}
+			return minV, true
+		case OverflowWrap:
+			return signExtend(uint64(value)+uint64(incr), op.Bits), true
+		}
+		return 0, false
+	}
+
+	// A negative value to SET reads as a huge unsigned one, as in Redis.
+	maxV := uint64(1)<<op.Bits - 1
+	u := uint64(value)
+	overflow := u > maxV || incr > 0 && uint64(incr) > maxV-u
+	underflow := !overflow && incr < 0 && uint64(-incr) > u
+	if !overflow && !underflow {
+		return int64(u + uint64(incr)), true
+	}
+
+	switch op.Overflow {
+	case OverflowSat:
+		if overflow {
+			return int64(maxV), true
+		}
+		return 0, true
+	case OverflowWrap:
+		return int64((u + uint64(incr)) & maxV), true
+	}
+	return 0, false
+}
+
+// BitField runs ops against the string at key in order and returns one
+// result per op: the value for GET, the previous value for SET and the new
+// one for INCRBY. A nil result marks an op skipped by OVERFLOW FAIL. The
+// string only grows when an op writes.
+func (s *store) BitField(key string, ops []BitFieldOp) ([]*int64, error) {
+	for _, op := range ops {
+		if op.Offset < 0 || op.Offset > maxBitOffset-int64(op.Bits)+1 {
+			return nil, ErrBitOffset
+		}
+	}
+
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e, buf, err := s.lookupBytes(shard, key)
+	if err != nil {
+		return nil, err
+	}
+
+	read := func(op BitFieldOp) int64 {
+		v := getField(buf, op.Offset, op.Bits)
+		if op.Signed {
+			return signExtend(v, op.Bits)
+		}
+		return int64(v)
+	}
+
+	results := make([]*int64, len(ops))
+	written := false
+	for i, op := range ops {
+		if op.Kind == BitFieldGet {
+			v := read(op)
+			results[i] = &v
+			continue
+		}
+
+		buf = grow(buf, op.Offset+int64(op.Bits)-1)
+		written = true
+
+		old := read(op)
+		var next int64
+		var ok bool
+		if op.Kind == BitFieldSet {
+			next, ok = op.fit(op.Value, 0)
+		} else {
+			next, ok = op.fit(old, op.Value)
+		}
+		if !ok {
+			continue
+		}
+
+		setField(buf, op.Offset, op.Bits, uint64(next))
+		if op.Kind == BitFieldSet {
+			results[i] = &old
+		} else {
+			results[i] = &next
+		}
+	}
+
+	if written {
+		s.putString(shard, key, e, string(buf))
+		s.notify(NotifyString, "setbit", key)
+	}
+	return results, nil
+}
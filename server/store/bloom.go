@@ -0,0 +1,268 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"slices"
+)
+
+// Defaults for filters that BF.ADD and BF.MADD create on their own, the
+// same as RedisBloom's.
+const (
+	bloomDefaultErrorRate = 0.01
+	bloomDefaultCapacity  = 100
+	bloomDefaultExpansion = 2
+
+	// bloomTightening scales the error rate of every layer added to a
+	// scalable filter, so the compound rate stays below the one asked for.
+	bloomTightening = 0.5
+
+	// bloomMaxBits bounds a single layer to 512MB.
+	bloomMaxBits = 1 << 32
+)
+
+var (
+	ErrItemExists     = errors.New("ERR item exists")
+	ErrFilterNotFound = errors.New("ERR not found")
+	ErrBloomFull      = errors.New("ERR non scaling filter is full")
+	ErrBloomTooLarge  = errors.New("ERR filter would be too large")
+)
+
+// BloomOptions describe a filter created by BF.RESERVE. An Expansion of 0
+// makes the filter non-scaling: it refuses items once it holds Capacity.
+type BloomOptions struct {
+	ErrorRate float64
+	Capacity  int
+	Expansion int
+}
+
+// BloomInfo is what BF.INFO reports about a filter.
+type BloomInfo struct {
+	Capacity  int // items the filter takes before it has to grow
+	Size      int // bytes held
+	Filters   int // layers stacked so far
+	Items     int
+	Expansion int // 0 for a non-scaling filter
+}
+
+// bloomLayer is one plain bloom filter of a scalable filter, sized for
+// Capacity items at the error rate it was created with.
+type bloomLayer struct {
+	Bits     []uint64
+	Hashes   uint32
+	Capacity int
+	Items    int
+}
+
+// bloomBits is the size of a layer for capacity items at errorRate. It is a
+// float so that sizes past any int still compare against bloomMaxBits.
+func bloomBits(capacity int, errorRate float64) float64 {
+	return math.Ceil(float64(capacity) * bloomBitsPerItem(errorRate))
+}
+
+func bloomBitsPerItem(errorRate float64) float64 {
+	return -math.Log(errorRate) / (math.Ln2 * math.Ln2)
+}
+
+// newBloomLayer expects bloomBits(capacity, errorRate) to be within
+// bloomMaxBits.
+func newBloomLayer(capacity int, errorRate float64) bloomLayer {
+	bitsPerItem := bloomBitsPerItem(errorRate)
+	bits := max(64, int(bloomBits(capacity, errorRate)))
+	return bloomLayer{
+		Bits:     make([]uint64, (bits+63)/64),
+		Hashes:   uint32(math.Ceil(bitsPerItem * math.Ln2)),
+		Capacity: capacity,
+	}
+}
+
+// positions calls fn with the bit indexes of the item hashed to h, using
+// the same double hashing as the shards' key filters.
+func (l *bloomLayer) positions(h uint64, fn func(i uint64)) {
+	h1, h2 := h&math.MaxUint32, h>>32|1
+	m := uint64(len(l.Bits)) * 64
+	for i := uint64(0); i < uint64(l.Hashes); i++ {
+		fn((h1 + i*h2) % m)
+	}
+}
+
+func (l *bloomLayer) test(h uint64) bool {
+	found := true
+	l.positions(h, func(i uint64) {
+		if l.Bits[i/64]&(1<<(i%64)) == 0 {
+			found = false
+		}
+	})
+	return found
+}
+
+func (l *bloomLayer) set(h uint64) {
+	l.positions(h, func(i uint64) {
+		l.Bits[i/64] |= 1 << (i % 64)
+	})
+	l.Items++
+}
+
+// bloomObject is a scalable bloom filter: a stack of layers, each Expansion
+// times larger and with half the error rate of the one before. Items go
+// into the newest layer and are looked for in all of them.
+type bloomObject struct {
+	Layers    []bloomLayer
+	ErrorRate float64 // of the first layer
+	Expansion int
+}
+
+// Like RedisBloom's, the type name is that of the module type.
+func (b *bloomObject) typeName() string { return "MBbloom--" }
+
+func (b *bloomObject) memoryUsage(int) int {
+	size := bloomObjectSize
+	for _, l := range b.Layers {
+		size += bloomLayerSize + 8*len(l.Bits)
+	}
+	return size
+}
+
+func newBloom(opts BloomOptions) *bloomObject {
+	return &bloomObject{
+		Layers:    []bloomLayer{newBloomLayer(opts.Capacity, opts.ErrorRate)},
+		ErrorRate: opts.ErrorRate,
+		Expansion: opts.Expansion,
+	}
+}
+
+func (b *bloomObject) exists(h uint64) bool {
+	for i := range b.Layers {
+		if b.Layers[i].test(h) {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds the item hashed to h and reports whether it was new, which a
+// false positive can make it look not to be.
+func (b *bloomObject) add(h uint64) (bool, error) {
+	if b.exists(h) {
+		return false, nil
+	}
+
+	top := &b.Layers[len(b.Layers)-1]
+	if top.Items >= top.Capacity {
+		if b.Expansion == 0 {
+			return false, ErrBloomFull
+		}
+		errorRate := b.ErrorRate * math.Pow(bloomTightening, float64(len(b.Layers)))
+		if top.Capacity > math.MaxInt/b.Expansion || bloomBits(top.Capacity*b.Expansion, errorRate) > bloomMaxBits {
+			return false, ErrBloomTooLarge
+		}
+		b.Layers = append(b.Layers, newBloomLayer(top.Capacity*b.Expansion, errorRate))
+		top = &b.Layers[len(b.Layers)-1]
+	}
+	top.set(h)
+	return true, nil
+}
+
+func (b *bloomObject) info() BloomInfo {
+	info := BloomInfo{Size: b.memoryUsage(0), Filters: len(b.Layers), Expansion: b.Expansion}
+	for _, l := range b.Layers {
+		info.Capacity += l.Capacity
+		info.Items += l.Items
+	}
+	return info
+}
+
+// BFReserve creates an empty bloom filter at key. It fails with
+// ErrBloomTooLarge if the first layer would exceed bloomMaxBits and with
+// ErrItemExists if the key is taken.
+func (s *store) BFReserve(key string, opts BloomOptions) error {
+	if bloomBits(opts.Capacity, opts.ErrorRate) > bloomMaxBits {
+		return ErrBloomTooLarge
+	}
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, ok := shard.lookup(key); ok {
+		return ErrItemExists
+	}
+	shard.put(key, &entry{Object: newBloom(opts)})
+	s.notify(NotifyGeneric, "bf.reserve", key)
+	return nil
+}
+
+// BFAdd adds items to the bloom filter at key, creating it with the default
+// options if needed, and reports which of them were new. A non-scaling
+// filter that fills up, or one that cannot grow any larger, stops the adds:
+// the items before it are reported along with ErrBloomFull or
+// ErrBloomTooLarge.
+func (s *store) BFAdd(key string, items []string) ([]bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	b, err := lookupOrCreate(s, shard, key, func() *bloomObject {
+		return newBloom(BloomOptions{
+			ErrorRate: bloomDefaultErrorRate,
+			Capacity:  bloomDefaultCapacity,
+			Expansion: bloomDefaultExpansion,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	added := make([]bool, 0, len(items))
+	for _, item := range items {
+		var ok bool
+		ok, err = b.add(stableHash(item))
+		if err != nil {
+			break
+		}
+		added = append(added, ok)
+	}
+	if slices.Contains(added, true) {
+		s.notify(NotifyGeneric, "bf.add", key)
+	}
+	return added, err
+}
+
+// BFExists reports which items may have been added to the bloom filter at
+// key. A missing key holds none of them.
+func (s *store) BFExists(key string, items []string) ([]bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	b, ok, err := lookupAs[*bloomObject](shard, key)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]bool, len(items))
+	if ok {
+		for i, item := range items {
+			found[i] = b.exists(stableHash(item))
+		}
+	}
+	return found, nil
+}
+
+func (s *store) BFInfo(key string) (BloomInfo, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	b, ok, err := lookupAs[*bloomObject](shard, key)
+	if err != nil {
+		return BloomInfo{}, err
+	}
+	if !ok {
+		return BloomInfo{}, ErrFilterNotFound
+	}
+	return b.info(), nil
+}
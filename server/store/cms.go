@@ -0,0 +1,208 @@
+package store
+
+import (
+	"errors"
+	"math"
+)
+
+// cmsMaxCounters bounds the counters a sketch may have, 512MB worth.
+const cmsMaxCounters = 1 << 27
+
+var (
+	ErrCMSTooLarge     = errors.New("ERR CMS: width * depth is too large")
+	ErrCMSExists       = errors.New("ERR CMS: key already exists")
+	ErrCMSNotFound     = errors.New("ERR CMS: key does not exist")
+	ErrCMSSizeMismatch = errors.New("ERR CMS: width/depth is not equal")
+)
+
+// cmsObject is a Count-Min Sketch: Depth rows of Width counters, each row
+// indexed by its own hash of the item. An item's count is the smallest of
+// its counters, which other items can only have pushed up.
+type cmsObject struct {
+	Width, Depth int
+	Counters     []uint32 // row after row
+	Count        int64    // total of all increments
+}
+
+// Like RedisBloom's, the type name is that of the module type.
+func (c *cmsObject) typeName() string { return "CMSk-TYPE" }
+
+func (c *cmsObject) memoryUsage(int) int {
+	return cmsObjectSize + 4*len(c.Counters)
+}
+
+func newCMS(width, depth int) *cmsObject {
+	return &cmsObject{Width: width, Depth: depth, Counters: make([]uint32, width*depth)}
+}
+
+// indexes calls fn with the index in Counters of item's counter in every
+// row, derived from one hash by double hashing.
+func (c *cmsObject) indexes(item string, fn func(i int)) {
+	h := stableHash(item)
+	h1, h2 := h&math.MaxUint32, h>>32|1
+	for row := 0; row < c.Depth; row++ {
+		fn(row*c.Width + int((h1+uint64(row)*h2)%uint64(c.Width)))
+	}
+}
+
+// incrBy adds increment, which must not be negative, to item's counters and
+// returns its new count. Counters and the total saturate rather than wrap
+// around.
+func (c *cmsObject) incrBy(item string, increment int64) int64 {
+	step := min(increment, math.MaxUint32)
+	c.indexes(item, func(i int) {
+		c.Counters[i] = uint32(min(int64(c.Counters[i])+step, math.MaxUint32))
+	})
+	c.Count = addSat(c.Count, increment)
+	return c.query(item)
+}
+
+// addSat and mulSat are int64 arithmetic that sticks at the limits instead
+// of wrapping around.
+func addSat(a, b int64) int64 {
+	sum := a + b
+	switch {
+	case a > 0 && b > 0 && sum < 0:
+		return math.MaxInt64
+	case a < 0 && b < 0 && sum >= 0:
+		return math.MinInt64
+	}
+	return sum
+}
+
+func mulSat(a, b int64) int64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	product := a * b
+	if product/b != a || a == -1 && b == math.MinInt64 || b == -1 && a == math.MinInt64 {
+		if a < 0 != (b < 0) {
+			return math.MinInt64
+		}
+		return math.MaxInt64
+	}
+	return product
+}
+
+func (c *cmsObject) query(item string) int64 {
+	count := int64(math.MaxUint32)
+	c.indexes(item, func(i int) {
+		count = min(count, int64(c.Counters[i]))
+	})
+	return count
+}
+
+// CMSInitByDim creates an empty sketch of depth rows of width counters at
+// key. It fails with ErrCMSTooLarge past cmsMaxCounters counters and with
+// ErrCMSExists if the key is taken.
+func (s *store) CMSInitByDim(key string, width, depth int) error {
+	if width < 1 || depth < 1 || width > cmsMaxCounters/depth {
+		return ErrCMSTooLarge
+	}
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, ok := shard.lookup(key); ok {
+		return ErrCMSExists
+	}
+	shard.put(key, &entry{Object: newCMS(width, depth)})
+	s.notify(NotifyGeneric, "cms.initbydim", key)
+	return nil
+}
+
+// CMSIncrBy adds increments[i] to the count of items[i] in the sketch at
+// key and returns the new counts.
+func (s *store) CMSIncrBy(key string, items []string, increments []int64) ([]int64, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	c, ok, err := lookupAs[*cmsObject](shard, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrCMSNotFound
+	}
+
+	counts := make([]int64, len(items))
+	for i, item := range items {
+		counts[i] = c.incrBy(item, increments[i])
+	}
+	s.notify(NotifyGeneric, "cms.incrby", key)
+	return counts, nil
+}
+
+// CMSQuery returns the estimated count of every item in the sketch at key.
+func (s *store) CMSQuery(key string, items []string) ([]int64, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	c, ok, err := lookupAs[*cmsObject](shard, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrCMSNotFound
+	}
+
+	counts := make([]int64, len(items))
+	for i, item := range items {
+		counts[i] = c.query(item)
+	}
+	return counts, nil
+}
+
+// CMSMerge replaces the counters of the sketch at dst with the sum of those
+// of the sketches at keys, scaled by weights. Every sketch, dst included,
+// must exist and have the same dimensions. Sketches built from the same
+// hashes add up exactly, so the result is the sketch that would have seen
+// every source's increments.
+func (s *store) CMSMerge(dst string, keys []string, weights []int64) error {
+	unlock := s.lockKeys(append([]string{dst}, keys...)...)
+	defer unlock()
+
+	target, ok, err := lookupAs[*cmsObject](s.getShard(dst), dst)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCMSNotFound
+	}
+
+	sources := make([]*cmsObject, len(keys))
+	for i, key := range keys {
+		c, ok, err := lookupAs[*cmsObject](s.getShard(key), key)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrCMSNotFound
+		}
+		if c.Width != target.Width || c.Depth != target.Depth {
+			return ErrCMSSizeMismatch
+		}
+		sources[i] = c
+	}
+
+	// dst may be one of the sources, so sum up before writing to it.
+	sums := make([]int64, len(target.Counters))
+	var count int64
+	for i, c := range sources {
+		for j, v := range c.Counters {
+			sums[j] = addSat(sums[j], mulSat(int64(v), weights[i]))
+		}
+		count = addSat(count, mulSat(c.Count, weights[i]))
+	}
+	for j, sum := range sums {
+		target.Counters[j] = uint32(min(max(sum, 0), math.MaxUint32))
+	}
+	target.Count = count
+	s.notify(NotifyGeneric, "cms.merge", dst)
+	return nil
+}
@@ -1,70 +1,118 @@
 package store
 
 import (
-	"hash/fnv"
-	"sync"
+	"hash/maphash"
+	"math"
+	"unsafe"
 )
 
+// bloomTargetFPR is the false positive rate key filters are sized for. A
+// filter is rebuilt once its fill ratio implies twice that.
+const bloomTargetFPR = 0.01
+
+// bloomCountersPerKey and bloomHashes are the optimal counter count and
+// hash count for bloomTargetFPR: m/n = -ln p / ln² 2 and k = m/n · ln 2.
+var (
+	bloomCountersPerKey = -math.Log(bloomTargetFPR) / (math.Ln2 * math.Ln2)
+	bloomHashes         = uint32(math.Round(bloomCountersPerKey * math.Ln2))
+	bloomMaxFill        = math.Pow(2*bloomTargetFPR, 1/float64(bloomHashes))
+)
+
+var bloomSeed = maphash.MakeSeed()
+
+// CountingBloomFilter tells a shard which keys it certainly doesn't hold.
+// Counters rather than bits let removals be undone exactly; a counter that
+// saturates stays put, which only costs accuracy until the next rebuild.
+// It has no lock of its own: the shard's lock covers it.
 type CountingBloomFilter struct {
-	M      uint32   // Size of the filter (number of counters)
-	K      uint32   // Number of hash functions
-	Filter []uint32 // The array of counters
-	mu     sync.RWMutex
+	counters []uint8
+	capacity int // keys the filter was sized for
+	count    int // keys inserted and not removed
+	nonZero  int // counters above zero
 }
 
-func NewCountingBloomFilter(m uint32, k uint32) *CountingBloomFilter {
+func newCountingBloomFilter(capacity int) *CountingBloomFilter {
+	capacity = max(capacity, 1)
 	return &CountingBloomFilter{
-		M:      m,
-		K:      k,
-		Filter: make([]uint32, m),
+		counters: make([]uint8, int(math.Ceil(float64(capacity)*bloomCountersPerKey))),
+		capacity: capacity,
 	}
 }
 
-func (b *CountingBloomFilter) hash(key string) []uint32 {
-	hashValues := make([]uint32, b.K)
-	h := fnv.New32a()
-	h.Write([]byte(key))
-	baseHash := h.Sum32()
-
-	for i := uint32(0); i < b.K; i++ {
-		hashValues[i] = (baseHash + i*i) % b.M
+// positions calls fn with the k counter indexes of key, derived from one
+// 64-bit hash by double hashing: h1 + i·h2 for i < k.
+func (b *CountingBloomFilter) positions(key string, fn func(i uint64)) {
+	h := maphash.String(bloomSeed, key)
+	h1, h2 := h&math.MaxUint32, h>>32|1
+	m := uint64(len(b.counters))
+	for i := uint64(0); i < uint64(bloomHashes); i++ {
+		fn((h1 + i*h2) % m)
 	}
-
-	return hashValues
 }
 
 func (b *CountingBloomFilter) Insert(key string) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	hashes := b.hash(key)
-	for _, hashVal := range hashes {
-		b.Filter[hashVal]++
-	}
+	b.count++
+	b.positions(key, func(i uint64) {
+		switch b.counters[i] {
+		case 0:
+			b.nonZero++
+		case math.MaxUint8:
+			return
+		}
+		b.counters[i]++
+	})
 }
 
 func (b *CountingBloomFilter) Remove(key string) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	hashes := b.hash(key)
-	for _, hashVal := range hashes {
-		if b.Filter[hashVal] > 0 {
-			b.Filter[hashVal]--
+	b.count--
+	b.positions(key, func(i uint64) {
+		switch b.counters[i] {
+		case 0, math.MaxUint8:
+			return
+		case 1:
+			b.nonZero--
 		}
-	}
+		b.counters[i]--
+	})
 }
 
 func (b *CountingBloomFilter) MightContain(key string) bool {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
-
-	hashes := b.hash(key)
-	for _, hashVal := range hashes {
-		if b.Filter[hashVal] == 0 {
-			return false
+	found := true
+	b.positions(key, func(i uint64) {
+		if b.counters[i] == 0 {
+			found = false
 		}
+	})
+	return found
+}
+
+// fillRatio is the share of counters above zero.
+func (b *CountingBloomFilter) fillRatio() float64 {
+	return float64(b.nonZero) / float64(len(b.counters))
+}
+
+// estimatedFPR is the chance that a key never inserted finds all its
+// counters set.
+func (b *CountingBloomFilter) estimatedFPR() float64 {
+	return math.Pow(b.fillRatio(), float64(bloomHashes))
+}
+
+// resizeTo returns the capacity the filter should be rebuilt with, or 0
+// while it is fine as it is. It grows when it holds more keys than it was
+// sized for, shrinks when it holds far fewer, and is rebuilt at the same
+// size when saturated counters have pushed its fill past bloomMaxFill.
+func (b *CountingBloomFilter) resizeTo(minCapacity int) int {
+	switch {
+	case b.count > b.capacity:
+		return 2 * b.count
+	case b.capacity > minCapacity && b.count < b.capacity/8:
+		return max(2*b.count, minCapacity)
+	case b.fillRatio() > bloomMaxFill:
+		return b.capacity
 	}
+	return 0
+}
 
-	return true
+func (b *CountingBloomFilter) memoryUsage() int64 {
+	return int64(unsafe.Sizeof(*b)) + int64(len(b.counters))
 }
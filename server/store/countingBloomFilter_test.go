@@ -0,0 +1,71 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestShardBloomFilter grows a shard well past its filter's initial size and
+// empties it again, checking that the filter never turns down a key the
+// shard holds and that removals bring its fill back down.
+func TestShardBloomFilter(t *testing.T) {
+	var events notifier
+	sh := newShard(&events, 16)
+
+	const n = 5000
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		sh.put(key, &entry{})
+	}
+	if sh.bloomRebuilds == 0 {
+		t.Fatalf("filter was not grown past its initial capacity")
+	}
+	for i := 0; i < n; i++ {
+		if key := fmt.Sprintf("key:%d", i); !sh.bloom.MightContain(key) {
+			t.Fatalf("%s is in the shard but not in the filter", key)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < n; i++ {
+		if sh.bloom.MightContain(fmt.Sprintf("missing:%d", i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / n; rate > 3*bloomTargetFPR {
+		t.Errorf("false positive rate %.4f, want about %.2f", rate, bloomTargetFPR)
+	}
+	if fpr := sh.bloom.estimatedFPR(); fpr > 2*bloomTargetFPR {
+		t.Errorf("estimated false positive rate %.4f, want at most %.2f", fpr, 2*bloomTargetFPR)
+	}
+
+	// Overwriting a key must not count it twice.
+	count := sh.bloom.count
+	sh.put("key:0", &entry{})
+	if sh.bloom.count != count {
+		t.Errorf("overwrite changed the key count from %d to %d", count, sh.bloom.count)
+	}
+
+	fill := sh.bloom.fillRatio()
+	for i := 0; i < n-10; i++ {
+		sh.remove(fmt.Sprintf("key:%d", i))
+	}
+	if sh.bloom.fillRatio() >= fill {
+		t.Errorf("fill ratio %.4f did not drop below %.4f after removals", sh.bloom.fillRatio(), fill)
+	}
+	if sh.bloom.capacity > 8*len(sh.Data) {
+		t.Errorf("filter kept capacity %d for %d keys", sh.bloom.capacity, len(sh.Data))
+	}
+	for i := n - 10; i < n; i++ {
+		if key := fmt.Sprintf("key:%d", i); !sh.bloom.MightContain(key) {
+			t.Fatalf("%s is in the shard but not in the filter", key)
+		}
+	}
+
+	for i := n - 10; i < n; i++ {
+		sh.remove(fmt.Sprintf("key:%d", i))
+	}
+	if sh.bloom.nonZero != 0 || sh.bloom.count != 0 {
+		t.Errorf("empty shard left %d keys and %d counters set", sh.bloom.count, sh.bloom.nonZero)
+	}
+}
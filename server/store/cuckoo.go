@@ -0,0 +1,394 @@
+package store
+
+import (
+	"errors"
+	"math/bits"
+	"math/rand/v2"
+)
+
+// Defaults for filters that CF.ADD and CF.ADDNX create on their own, the
+// same as RedisBloom's.
+const (
+	cuckooDefaultCapacity      = 1024
+	cuckooDefaultBucketSize    = 2
+	cuckooDefaultMaxIterations = 20
+	cuckooDefaultExpansion     = 1
+
+	// cuckooMaxSlots bounds a single table to 512MB.
+	cuckooMaxSlots = 1 << 29
+)
+
+var (
+	ErrCuckooFull     = errors.New("ERR Filter is full")
+	ErrCuckooTooLarge = errors.New("ERR Capacity is too large")
+)
+
+// CuckooOptions describe a filter created by CF.RESERVE. An Expansion of 0
+// keeps the filter at its first table, which refuses items once full.
+type CuckooOptions struct {
+	Capacity      int
+	BucketSize    int // fingerprints per bucket
+	MaxIterations int // evictions tried before a table counts as full
+	Expansion     int
+}
+
+// CuckooInfo is what CF.INFO reports about a filter.
+type CuckooInfo struct {
+	Size          int // bytes held
+	Buckets       int
+	Filters       int
+	Items         int
+	Deleted       int
+	BucketSize    int
+	Expansion     int
+	MaxIterations int
+}
+
+// cuckooTable is one cuckoo hash table of a filter: a power-of-two number
+// of buckets laid out back to back in Slots, holding 8-bit fingerprints.
+// Zero marks an empty slot.
+type cuckooTable struct {
+	Slots []uint8
+}
+
+// cuckooObject is a cuckoo filter. Unlike a bloom filter it can delete
+// items, by removing one copy of their fingerprint. When the newest table
+// fills up a table Expansion times larger is added, as RedisBloom does.
+type cuckooObject struct {
+	Tables        []cuckooTable
+	Capacity      int // of the first table
+	BucketSize    int
+	MaxIterations int
+	Expansion     int
+	Items         int
+	Deleted       int
+}
+
+// Like RedisBloom's, the type name is that of the module type.
+func (c *cuckooObject) typeName() string { return "MBbloomCF" }
+
+func (c *cuckooObject) memoryUsage(int) int {
+	size := cuckooObjectSize
+	for _, t := range c.Tables {
+		size += sliceHeader + len(t.Slots)
+	}
+	return size
+}
+
+// newCuckoo returns nil if the first table would exceed cuckooMaxSlots.
+func newCuckoo(opts CuckooOptions) *cuckooObject {
+	c := &cuckooObject{
+		Capacity:      opts.Capacity,
+		BucketSize:    opts.BucketSize,
+		MaxIterations: opts.MaxIterations,
+		Expansion:     opts.Expansion,
+	}
+	if !c.grow() {
+		return nil
+	}
+	return c
+}
+
+// grow adds a table sized for the next Capacity·Expansion^n items. It adds
+// nothing and returns false if the table would exceed cuckooMaxSlots.
+func (c *cuckooObject) grow() bool {
+	capacity := c.Capacity
+	for range c.Tables {
+		if c.Expansion > 0 && capacity > cuckooMaxSlots/c.Expansion {
+			return false
+		}
+		capacity *= c.Expansion
+	}
+	if capacity > cuckooMaxSlots {
+		return false
+	}
+	buckets := max(1, (capacity+c.BucketSize-1)/c.BucketSize)
+	buckets = 1 << bits.Len(uint(buckets-1))
+	if buckets > cuckooMaxSlots/c.BucketSize {
+		return false
+	}
+	c.Tables = append(c.Tables, cuckooTable{Slots: make([]uint8, buckets*c.BucketSize)})
+	return true
+}
+
+func (c *cuckooObject) bucket(t *cuckooTable, i uint64) []uint8 {
+	b := uint64(c.BucketSize)
+	return t.Slots[i*b : (i+1)*b]
+}
+
+func (c *cuckooObject) mask(t *cuckooTable) uint64 {
+	return uint64(len(t.Slots)/c.BucketSize) - 1
+}
+
+// cuckooFingerprint splits the hash of an item into its nonzero fingerprint
+// and the hash its first bucket is taken from.
+func cuckooFingerprint(h uint64) (uint8, uint64) {
+	return uint8(h>>56%255) + 1, h
+}
+
+// altIndex is the other bucket a fingerprint may live in. The number of
+// buckets is a power of two, so taking it twice gives back i.
+func altIndex(i uint64, fp uint8, mask uint64) uint64 {
+	return (i ^ uint64(fp)*0x5bd1e995) & mask
+}
+
+// place puts fp in a free slot of bucket i of t and reports whether there
+// was one.
+func (c *cuckooObject) place(t *cuckooTable, i uint64, fp uint8) bool {
+	b := c.bucket(t, i)
+	for j := range b {
+		if b[j] == 0 {
+			b[j] = fp
+			return true
+		}
+	}
+	return false
+}
+
+// insert adds fp to the first table with room in one of its buckets, or
+// makes room in the newest table by moving fingerprints to their other
+// bucket. It fails only when the filter may not grow.
+func (c *cuckooObject) insert(fp uint8, h uint64) error {
+	for n := len(c.Tables) - 1; n >= 0; n-- {
+		t := &c.Tables[n]
+		i1 := h & c.mask(t)
+		if c.place(t, i1, fp) || c.place(t, altIndex(i1, fp, c.mask(t)), fp) {
+			c.Items++
+			return nil
+		}
+	}
+
+	if c.kick(&c.Tables[len(c.Tables)-1], fp, h) {
+		c.Items++
+		return nil
+	}
+	if c.Expansion == 0 || !c.grow() {
+		return ErrCuckooFull
+	}
+	t := &c.Tables[len(c.Tables)-1]
+	c.place(t, h&c.mask(t), fp)
+	c.Items++
+	return nil
+}
+
+// kick evicts fingerprints from t to their other bucket to make room for
+// fp, up to MaxIterations times. If no free slot turns up, every move is
+// undone so no fingerprint is lost.
+func (c *cuckooObject) kick(t *cuckooTable, fp uint8, h uint64) bool {
+	type move struct {
+		i    uint64
+		slot int
+	}
+	mask := c.mask(t)
+	i := h & mask
+	if rand.IntN(2) == 0 {
+		i = altIndex(i, fp, mask)
+	}
+
+	path := make([]move, 0, c.MaxIterations)
+	for range c.MaxIterations {
+		slot := rand.IntN(c.BucketSize)
+		b := c.bucket(t, i)
+		b[slot], fp = fp, b[slot]
+		path = append(path, move{i, slot})
+
+		i = altIndex(i, fp, mask)
+		if c.place(t, i, fp) {
+			return true
+		}
+	}
+
+	for k := len(path) - 1; k >= 0; k-- {
+		b := c.bucket(t, path[k].i)
+		b[path[k].slot], fp = fp, b[path[k].slot]
+	}
+	return false
+}
+
+// count returns how many copies of fp the filter holds in the buckets of
+// the item hashed to h.
+func (c *cuckooObject) count(fp uint8, h uint64) int {
+	n := 0
+	for k := range c.Tables {
+		t := &c.Tables[k]
+		i1 := h & c.mask(t)
+		i2 := altIndex(i1, fp, c.mask(t))
+		for _, i := range []uint64{i1, i2} {
+			for _, v := range c.bucket(t, i) {
+				if v == fp {
+					n++
+				}
+			}
+			if i1 == i2 {
+				break
+			}
+		}
+	}
+	return n
+}
+
+// remove deletes one copy of fp, from the newest table that has one.
+func (c *cuckooObject) remove(fp uint8, h uint64) bool {
+	for k := len(c.Tables) - 1; k >= 0; k-- {
+		t := &c.Tables[k]
+		i1 := h & c.mask(t)
+		for _, i := range []uint64{i1, altIndex(i1, fp, c.mask(t))} {
+			b := c.bucket(t, i)
+			for j := range b {
+				if b[j] == fp {
+					b[j] = 0
+					c.Items--
+					c.Deleted++
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (c *cuckooObject) info() CuckooInfo {
+	info := CuckooInfo{
+		Filters:       len(c.Tables),
+		Items:         c.Items,
+		Deleted:       c.Deleted,
+		BucketSize:    c.BucketSize,
+		Expansion:     c.Expansion,
+		MaxIterations: c.MaxIterations,
+	}
+	for _, t := range c.Tables {
+		info.Size += len(t.Slots)
+		info.Buckets += len(t.Slots) / c.BucketSize
+	}
+	return info
+}
+
+// CFReserve creates an empty cuckoo filter at key. It fails with
+// ErrItemExists if the key is taken and with ErrCuckooTooLarge if the first
+// table would exceed cuckooMaxSlots.
+func (s *store) CFReserve(key string, opts CuckooOptions) error {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, ok := shard.lookup(key); ok {
+		return ErrItemExists
+	}
+	c := newCuckoo(opts)
+	if c == nil {
+		return ErrCuckooTooLarge
+	}
+	shard.put(key, &entry{Object: c})
+	s.notify(NotifyGeneric, "cf.reserve", key)
+	return nil
+}
+
+// CFAdd adds item to the cuckoo filter at key, creating it with the default
+// options if needed. With nx the item is left out if it may already be
+// there. It reports whether the item was added.
+func (s *store) CFAdd(key, item string, nx bool) (bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	c, err := lookupOrCreate(s, shard, key, func() *cuckooObject {
+		return newCuckoo(CuckooOptions{
+			Capacity:      cuckooDefaultCapacity,
+			BucketSize:    cuckooDefaultBucketSize,
+			MaxIterations: cuckooDefaultMaxIterations,
+			Expansion:     cuckooDefaultExpansion,
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+
+	fp, h := cuckooFingerprint(stableHash(item))
+	if nx && c.count(fp, h) > 0 {
+		return false, nil
+	}
+	if err := c.insert(fp, h); err != nil {
+		return false, err
+	}
+	s.notify(NotifyGeneric, "cf.add", key)
+	return true, nil
+}
+
+// CFExists reports which items may have been added to the cuckoo filter at
+// key. A missing key holds none of them.
+func (s *store) CFExists(key string, items []string) ([]bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	c, ok, err := lookupAs[*cuckooObject](shard, key)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]bool, len(items))
+	if ok {
+		for i, item := range items {
+			found[i] = c.count(cuckooFingerprint(stableHash(item))) > 0
+		}
+	}
+	return found, nil
+}
+
+// CFCount returns how many times item may have been added to the cuckoo
+// filter at key, and not deleted since.
+func (s *store) CFCount(key, item string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	c, ok, err := lookupAs[*cuckooObject](shard, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return c.count(cuckooFingerprint(stableHash(item))), nil
+}
+
+// CFDel deletes one copy of item from the cuckoo filter at key and reports
+// whether there was one. Deleting an item that was never added may remove
+// another item that shares its fingerprint.
+func (s *store) CFDel(key, item string) (bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	c, ok, err := lookupAs[*cuckooObject](shard, key)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, ErrFilterNotFound
+	}
+
+	if !c.remove(cuckooFingerprint(stableHash(item))) {
+		return false, nil
+	}
+	s.notify(NotifyGeneric, "cf.del", key)
+	return true, nil
+}
+
+func (s *store) CFInfo(key string) (CuckooInfo, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	c, ok, err := lookupAs[*cuckooObject](shard, key)
+	if err != nil {
+		return CuckooInfo{}, err
+	}
+	if !ok {
+		return CuckooInfo{}, ErrFilterNotFound
+	}
+	return c.info(), nil
+}
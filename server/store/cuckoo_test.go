@@ -0,0 +1,73 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// TestCuckooFilter fills a filter that may not grow until it refuses an
+// item, checking that the evictions tried on the way lose nothing, then
+// deletes every item again.
+func TestCuckooFilter(t *testing.T) {
+	c := newCuckoo(CuckooOptions{Capacity: 1000, BucketSize: 2, MaxIterations: 20})
+
+	var added []string
+	for i := 0; ; i++ {
+		item := fmt.Sprintf("item:%d", i)
+		if err := c.insert(cuckooFingerprint(stableHash(item))); err == ErrCuckooFull {
+			break
+		} else if err != nil {
+			t.Fatalf("insert %s: %v", item, err)
+		}
+		added = append(added, item)
+	}
+	if slots := len(c.Tables[0].Slots); len(added) < slots/2 {
+		t.Errorf("filter refused items at %d of %d slots", len(added), slots)
+	}
+
+	for _, item := range added {
+		if c.count(cuckooFingerprint(stableHash(item))) == 0 {
+			t.Fatalf("%s was added but is not found", item)
+		}
+	}
+
+	for _, item := range added {
+		if !c.remove(cuckooFingerprint(stableHash(item))) {
+			t.Fatalf("%s was added but could not be deleted", item)
+		}
+	}
+	for i, fp := range c.Tables[0].Slots {
+		if fp != 0 {
+			t.Fatalf("slot %d still holds %d after every item was deleted", i, fp)
+		}
+	}
+	if c.Items != 0 || c.Deleted != len(added) {
+		t.Errorf("items = %d, deleted = %d, want 0, %d", c.Items, c.Deleted, len(added))
+	}
+
+	// A growing filter takes the same items in more tables.
+	c = newCuckoo(CuckooOptions{Capacity: 64, BucketSize: 2, MaxIterations: 20, Expansion: 2})
+	for _, item := range added {
+		if err := c.insert(cuckooFingerprint(stableHash(item))); err != nil {
+			t.Fatalf("insert %s into a growing filter: %v", item, err)
+		}
+	}
+	if len(c.Tables) < 2 {
+		t.Errorf("filter kept %d table for %d items", len(c.Tables), len(added))
+	}
+	for _, item := range added {
+		if c.count(cuckooFingerprint(stableHash(item))) == 0 {
+			t.Fatalf("%s was added to a growing filter but is not found", item)
+		}
+	}
+
+	// Tables past cuckooMaxSlots are refused rather than allocated.
+	if c := newCuckoo(CuckooOptions{Capacity: math.MaxInt, BucketSize: 2, MaxIterations: 20}); c != nil {
+		t.Errorf("a filter of capacity MaxInt got %d tables", len(c.Tables))
+	}
+	c = newCuckoo(CuckooOptions{Capacity: 1 << 20, BucketSize: 2, MaxIterations: 20, Expansion: 32768})
+	if c.grow() {
+		t.Errorf("a table of %d slots was added", len(c.Tables[1].Slots))
+	}
+}
@@ -0,0 +1,189 @@
+package store
+
+import (
+	"errors"
+	"iter"
+	"math"
+	"math/rand/v2"
+)
+
+var ErrOOM = errors.New("OOM command not allowed when used memory > 'maxmemory'.")
+
+// EvictionPolicy decides which keys go when the store is over its memory
+// limit.
+type EvictionPolicy int
+
+const (
+	NoEviction EvictionPolicy = iota
+	AllKeysLRU
+	AllKeysLFU
+	AllKeysRandom
+	VolatileLRU
+	VolatileLFU
+	VolatileRandom
+	VolatileTTL
+)
+
+var evictionPolicyNames = []string{
+	NoEviction:     "noeviction",
+	AllKeysLRU:     "allkeys-lru",
+	AllKeysLFU:     "allkeys-lfu",
+	AllKeysRandom:  "allkeys-random",
+	VolatileLRU:    "volatile-lru",
+	VolatileLFU:    "volatile-lfu",
+	VolatileRandom: "volatile-random",
+	VolatileTTL:    "volatile-ttl",
+}
+
+func (p EvictionPolicy) String() string {
+	return evictionPolicyNames[p]
+}
+
+// ParseEvictionPolicy reads a maxmemory-policy name.
+func ParseEvictionPolicy(name string) (EvictionPolicy, bool) {
+	for p, n := range evictionPolicyNames {
+		if n == name {
+			return EvictionPolicy(p), true
+		}
+	}
+	return NoEviction, false
+}
+
+func (p EvictionPolicy) volatileOnly() bool {
+	return p >= VolatileLRU
+}
+
+// EvictionConfig caps the memory used by keys and their values.
+type EvictionConfig struct {
+	MaxMemory int64 // bytes, zero for no limit
+	Policy    EvictionPolicy
+	Samples   int // keys sampled per shard to pick each victim
+}
+
+var defaultEvictionConfig = EvictionConfig{Policy: NoEviction, Samples: 5}
+
+func (s *store) EvictionConfig() EvictionConfig {
+	s.evictionMu.RLock()
+	defer s.evictionMu.RUnlock()
+
+	return s.eviction
+}
+
+func (s *store) SetEvictionConfig(c EvictionConfig) {
+	s.evictionMu.Lock()
+	defer s.evictionMu.Unlock()
+
+	s.eviction = c
+}
+
+// UsedMemory returns the estimated bytes held by all keys and values and by
+// the bloom filters in front of them.
+func (s *store) UsedMemory() int64 {
+	var used int64
+	for i := range s.Shards {
+		shard := &s.Shards[i]
+
+		shard.mu.Lock()
+		shard.settle()
+		used += shard.used + shard.bloom.memoryUsage()
+		shard.mu.Unlock()
+	}
+	return used
+}
+
+// FreeMemory evicts keys until the store is back under its limit. It
+// returns ErrOOM when the limit is exceeded and the policy forbids
+// eviction or no key it allows is left; commands that would add data
+// should then be refused.
+func (s *store) FreeMemory() error {
+	cfg := s.EvictionConfig()
+	if cfg.MaxMemory <= 0 {
+		return nil
+	}
+
+	for s.UsedMemory() > cfg.MaxMemory {
+		if cfg.Policy == NoEviction || !s.evictOne(cfg) {
+			return ErrOOM
+		}
+	}
+	return nil
+}
+
+// evictOne samples keys from every shard and removes the best victim
+// among them, in the spirit of Redis' approximated LRU. It reports false
+// when there was nothing to evict. Shards are unlocked between sampling and
+// removal, so a victim that was deleted or replaced meanwhile is spared;
+// that still reports true, for the caller to sample again.
+func (s *store) evictOne(cfg EvictionConfig) bool {
+	bestShard, bestKey, bestScore := -1, "", math.Inf(-1)
+	var bestEntry *entry
+	now := nowMillis()
+
+	for i := range s.Shards {
+		shard := &s.Shards[i]
+
+		shard.mu.Lock()
+		for key := range shard.sample(cfg.Policy.volatileOnly(), cfg.Samples) {
+			e := shard.Data[key]
+			score := evictionScore(cfg.Policy, e, now)
+			if score > bestScore {
+				bestShard, bestKey, bestScore, bestEntry = i, key, score, e
+			}
+		}
+		shard.mu.Unlock()
+	}
+
+	if bestShard < 0 {
+		return false
+	}
+
+	shard := &s.Shards[bestShard]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if shard.Data[bestKey] != bestEntry {
+		return true
+	}
+	shard.remove(bestKey)
+	s.notify(NotifyEvicted, "evicted", bestKey)
+	return true
+}
+
+// sample yields up to n keys of the shard in map order, which Go starts at
+// a random point. With volatile only keys that have a deadline are
+// considered.
+func (sh *shard) sample(volatile bool, n int) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		seen := 0
+		if volatile {
+			for key := range sh.volatile {
+				if seen == n || !yield(key) {
+					return
+				}
+				seen++
+			}
+			return
+		}
+		for key := range sh.Data {
+			if seen == n || !yield(key) {
+				return
+			}
+			seen++
+		}
+	}
+}
+
+// evictionScore ranks e as a victim under policy; the highest score goes
+// first.
+func evictionScore(policy EvictionPolicy, e *entry, now int64) float64 {
+	switch policy {
+	case AllKeysLRU, VolatileLRU:
+		return float64(e.idle(now))
+	case AllKeysLFU, VolatileLFU:
+		return float64(255-e.frequency(now)) + float64(e.idle(now))/float64(math.MaxInt64)
+	case VolatileTTL:
+		return -float64(e.ExpiresAt)
+	default:
+		return rand.Float64()
+	}
+}
@@ -0,0 +1,139 @@
+package store
+
+import "time"
+
+const (
+	activeExpireInterval = 100 * time.Millisecond
+	activeExpireSamples  = 20
+)
+
+// ExpireCondition restricts when Expire is allowed to replace a key's
+// deadline, mirroring the NX/XX/GT/LT flags of the EXPIRE family. Flags
+// combine, each having to allow the change.
+type ExpireCondition int
+
+const (
+	ExpireNX     ExpireCondition = 1 << iota // only when the key has no deadline
+	ExpireXX                                 // only when the key already has a deadline
+	ExpireGT                                 // only when the new deadline is later
+	ExpireLT                                 // only when the new deadline is earlier
+	ExpireAlways ExpireCondition = 0
+)
+
+func nowMillis() int64 {
+	return time.Now().UnixMilli()
+}
+
+// allows treats a key without a deadline as one that never expires, as
+// Redis does for GT and LT.
+func (c ExpireCondition) allows(current, next int64) bool {
+	switch {
+	case c&ExpireNX != 0 && current != 0,
+		c&ExpireXX != 0 && current == 0,
+		c&ExpireGT != 0 && (current == 0 || next <= current),
+		c&ExpireLT != 0 && current != 0 && next >= current:
+		return false
+	}
+	return true
+}
+
+// Expire sets the deadline of key. A deadline that already passed deletes the
+// key straight away. It reports whether the key existed and cond allowed the
+// change.
+func (s *store) Expire(key string, deadline time.Time, cond ExpireCondition) bool {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e, ok := shard.lookup(key)
+	if !ok {
+		return false
+	}
+
+	expiresAt := deadline.UnixMilli()
+	if !cond.allows(e.ExpiresAt, expiresAt) {
+		return false
+	}
+
+	if expiresAt <= nowMillis() {
+		shard.remove(key)
+		s.notify(NotifyGeneric, "del", key)
+		return true
+	}
+
+	shard.setDeadline(key, e, expiresAt)
+	s.notify(NotifyGeneric, "expire", key)
+	return true
+}
+
+// Persist drops the deadline of key, reporting whether there was one.
+func (s *store) Persist(key string) bool {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e, ok := shard.lookup(key)
+	if !ok || e.ExpiresAt == 0 {
+		return false
+	}
+
+	shard.setDeadline(key, e, 0)
+	s.notify(NotifyGeneric, "persist", key)
+	return true
+}
+
+// PTTL returns the remaining time to live of key in milliseconds, -2 when the
+// key does not exist and -1 when it has no deadline.
+func (s *store) PTTL(key string) int64 {
+	shard := s.getShard(key)
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	e, ok := shard.Data[key]
+	now := nowMillis()
+	if !ok || e.expired(now) {
+		return -2
+	}
+	if e.ExpiresAt == 0 {
+		return -1
+	}
+
+	return e.ExpiresAt - now
+}
+
+// activeExpireCycle reclaims keys whose deadline passed but that nobody has
+// touched since, so they don't linger until the next lookup.
+func (s *store) activeExpireCycle() {
+	for i := range s.Shards {
+		s.Shards[i].expireSample()
+	}
+}
+
+// expireSample checks a random sample of the shard's volatile keys and keeps
+// going while more than a quarter of each sample turns out to be expired.
+func (sh *shard) expireSample() {
+	for {
+		sh.mu.Lock()
+		now := nowMillis()
+		sampled, expired := 0, 0
+		for key := range sh.volatile {
+			if sampled == activeExpireSamples {
+				break
+			}
+			sampled++
+			if sh.Data[key].expired(now) {
+				sh.remove(key)
+				sh.events.notify(NotifyExpired, "expired", key)
+				expired++
+			}
+		}
+		sh.mu.Unlock()
+
+		if sampled == 0 || expired*4 <= sampled {
+			return
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package store
+
+import "testing"
+
+// TestExpireConditionAllows checks the EXPIRE flags singly and combined,
+// with 0 standing for a key that has no deadline.
+func TestExpireConditionAllows(t *testing.T) {
+	cases := []struct {
+		cond          ExpireCondition
+		current, next int64
+		want          bool
+	}{
+		{ExpireAlways, 0, 5, true},
+		{ExpireNX, 0, 5, true},
+		{ExpireNX, 3, 5, false},
+		{ExpireXX, 0, 5, false},
+		{ExpireXX, 3, 5, true},
+		{ExpireGT, 0, 5, false},
+		{ExpireGT, 3, 5, true},
+		{ExpireGT, 5, 3, false},
+		{ExpireLT, 0, 5, true},
+		{ExpireLT, 5, 3, true},
+		{ExpireLT, 3, 5, false},
+		{ExpireXX | ExpireGT, 3, 5, true},
+		{ExpireXX | ExpireGT, 0, 5, false},
+		{ExpireXX | ExpireLT, 5, 3, true},
+		{ExpireXX | ExpireLT, 0, 5, false},
+	}
+	for _, c := range cases {
+		if got := c.cond.allows(c.current, c.next); got != c.want {
+			t.Errorf("condition %b with deadline %d -> %d: got %v, want %v", c.cond, c.current, c.next, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+var ErrGeoMemberMissing = errors.New("ERR could not decode requested zset member")
+
+// Geo sets are sorted sets scored by a 52-bit geohash: 26 bits each of
+// longitude and latitude, interleaved so that nearby points tend to have
+// nearby scores. Latitudes stop where Web Mercator does.
+const (
+	GeoLonMin, GeoLonMax = -180.0, 180.0
+	GeoLatMin, GeoLatMax = -85.05112878, 85.05112878
+
+	geoStepMax  = 26
+	earthRadius = 6372797.560856 // meters
+	mercatorMax = 20037726.37    // half the Mercator world width in meters
+)
+
+// GeoPoint is a position given as longitude and latitude in degrees.
+type GeoPoint struct {
+	Lon, Lat float64
+}
+
+// GeoShape selects the area of a GeoSearch.
+type GeoShape int
+
+const (
+	GeoByRadius GeoShape = iota
+	GeoByBox
+)
+
+// GeoSort orders the results of a GeoSearch by distance.
+type GeoSort int
+
+const (
+	GeoUnsorted GeoSort = iota
+	GeoAsc
+	GeoDesc
+)
+
+// GeoQuery describes a GEOSEARCH. Sizes are in meters.
+type GeoQuery struct {
+	FromMember    bool
+	Member        string   // the center, with FromMember
+	Center        GeoPoint // the center otherwise
+	Shape         GeoShape
+	Radius        float64
+	Width, Height float64
+	Sort          GeoSort
+	Count         int  // zero for no limit
+	Any           bool // stop at the first Count matches instead of the nearest
+}
+
+// GeoResult is one member found by GeoSearch, with its distance in meters
+// from the center.
+type GeoResult struct {
+	Member string
+	Dist   float64
+	Hash   uint64
+	Point  GeoPoint
+}
+
+// ValidGeoPoint reports whether p can be stored in a geo set.
+func ValidGeoPoint(p GeoPoint) bool {
+	return p.Lon >= GeoLonMin && p.Lon <= GeoLonMax && p.Lat >= GeoLatMin && p.Lat <= GeoLatMax
+}
+
+// geoCell returns the indexes of the cell holding p on a grid of 2^step
+// cells per side.
+func geoCell(p GeoPoint, step uint) (latIdx, lonIdx uint64) {
+	cells := float64(uint64(1) << step)
+	lat := min(max((p.Lat-GeoLatMin)/(GeoLatMax-GeoLatMin)*cells, 0), cells-1)
+	lon := min(max((p.Lon-GeoLonMin)/(GeoLonMax-GeoLonMin)*cells, 0), cells-1)
+	return uint64(lat), uint64(lon)
+}
+
+// interleave spreads the bits of lat over the even positions and those of
+// lon over the odd ones.
+func interleave(lat, lon uint64) uint64 {
+	return spread(lat) | spread(lon)<<1
+}
+
+func spread(x uint64) uint64 {
+	x &= 0xffffffff
+	x = (x | x<<16) & 0x0000ffff0000ffff
+	x = (x | x<<8) & 0x00ff00ff00ff00ff
+	x = (x | x<<4) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+	return x
+}
+
+func squash(x uint64) uint64 {
+	x &= 0x5555555555555555
+	x = (x | x>>1) & 0x3333333333333333
+	x = (x | x>>2) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x>>4) & 0x00ff00ff00ff00ff
+	x = (x | x>>8) & 0x0000ffff0000ffff
+	x = (x | x>>16) & 0x00000000ffffffff
+	return x
+}
+
+// GeoEncode returns the score p is stored under.
+func GeoEncode(p GeoPoint) uint64 {
+	return interleave(geoCell(p, geoStepMax))
+}
+
+// GeoDecode returns the center of the cell a score stands for.
+func GeoDecode(hash uint64) GeoPoint {
+	cells := float64(uint64(1) << geoStepMax)
+	latIdx, lonIdx := float64(squash(hash)), float64(squash(hash>>1))
+
+	latMin := GeoLatMin + latIdx/cells*(GeoLatMax-GeoLatMin)
+	latMax := GeoLatMin + (latIdx+1)/cells*(GeoLatMax-GeoLatMin)
+	lonMin := GeoLonMin + lonIdx/cells*(GeoLonMax-GeoLonMin)
+	lonMax := GeoLonMin + (lonIdx+1)/cells*(GeoLonMax-GeoLonMin)
+
+	return GeoPoint{
+		Lon: min(max((lonMin+lonMax)/2, GeoLonMin), GeoLonMax),
+		Lat: min(max((latMin+latMax)/2, GeoLatMin), GeoLatMax),
+	}
+}
+
+// GeohashString renders p as the usual 11-character base32 geohash, which
+// unlike scores spans latitudes from -90 to 90.
+func GeohashString(p GeoPoint) string {
+	const alphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+	cells := float64(uint64(1) << geoStepMax)
+	lat := uint64(min(max((p.Lat+90)/180*cells, 0), cells-1))
+	lon := uint64(min(max((p.Lon+180)/360*cells, 0), cells-1))
+	hash := interleave(lat, lon)
+
+	buf := make([]byte, 11)
+	for i := range buf {
+		idx := 0
+		// The last character would need bits past the 52 we have.
+		if i < 10 {
+			idx = int(hash>>(52-(i+1)*5)) & 0x1f
+		}
+		buf[i] = alphabet[idx]
+	}
+	return string(buf)
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
+
+// GeoDistance is the great-circle distance between a and b in meters.
+func GeoDistance(a, b GeoPoint) float64 {
+	u := math.Sin((radians(b.Lat) - radians(a.Lat)) / 2)
+	v := math.Sin((radians(b.Lon) - radians(a.Lon)) / 2)
+	h := u*u + math.Cos(radians(a.Lat))*math.Cos(radians(b.Lat))*v*v
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+// within reports whether p lies in the area of q around center, and how far
+// from center it is.
+func (q GeoQuery) within(center, p GeoPoint) (float64, bool) {
+	if q.Shape == GeoByRadius {
+		dist := GeoDistance(center, p)
+		return dist, dist <= q.Radius
+	}
+
+	if earthRadius*math.Abs(radians(p.Lat)-radians(center.Lat)) > q.Height/2 {
+		return 0, false
+	}
+	if GeoDistance(GeoPoint{Lon: center.Lon, Lat: p.Lat}, p) > q.Width/2 {
+		return 0, false
+	}
+	return GeoDistance(center, p), true
+}
+
+// boundingBox returns the longitudes and latitudes bounding the area of q
+// around center. Longitudes are not wrapped and may pass ±180.
+func (q GeoQuery) boundingBox(center GeoPoint) (minLon, minLat, maxLon, maxLat float64) {
+	halfWidth, halfHeight := q.Radius, q.Radius
+	if q.Shape == GeoByBox {
+		halfWidth, halfHeight = q.Width/2, q.Height/2
+	}
+
+	latDelta := degrees(halfHeight / earthRadius)
+	minLat, maxLat = center.Lat-latDelta, center.Lat+latDelta
+	if math.Abs(center.Lat)+latDelta >= 90 {
+		return center.Lon - 180, minLat, center.Lon + 180, maxLat
+	}
+
+	// The box is widest on the side nearer a pole.
+	widest := math.Max(math.Abs(minLat), math.Abs(maxLat))
+	lonDelta := degrees(halfWidth / earthRadius / math.Cos(radians(widest)))
+	return center.Lon - lonDelta, minLat, center.Lon + lonDelta, maxLat
+}
+
+// geoEstimateStep picks the finest grid whose cells are still about as large
+// as the search radius, using coarser ones near the poles.
+func geoEstimateStep(radius, lat float64) uint {
+	if radius == 0 {
+		return geoStepMax
+	}
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	step -= 2
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+	return uint(min(max(step, 1), geoStepMax))
+}
+
+// geoScoreRanges returns the score ranges of the grid cells to scan for q
+// around center: the cell of the center and its neighbours, on a grid coarse
+// enough for those nine cells to cover the whole bounding box.
+func (q GeoQuery) geoScoreRanges(center GeoPoint) []ScoreRange {
+	minLon, minLat, maxLon, maxLat := q.boundingBox(center)
+	radius := q.Radius
+	if q.Shape == GeoByBox {
+		radius = math.Hypot(q.Width/2, q.Height/2)
+	}
+
+	step := geoEstimateStep(radius, center.Lat)
+	var latIdx, lonIdx uint64
+	for ; ; step-- {
+		latIdx, lonIdx = geoCell(center, step)
+		if step == 1 {
+			break
+		}
+
+		cells := uint64(1) << step
+		latSpan := (GeoLatMax - GeoLatMin) / float64(cells)
+		lonSpan := (GeoLonMax - GeoLonMin) / float64(cells)
+		south := GeoLatMin + (float64(latIdx)-1)*latSpan
+		north := GeoLatMin + (float64(latIdx)+2)*latSpan
+		west := GeoLonMin + (float64(lonIdx)-1)*lonSpan
+		east := GeoLonMin + (float64(lonIdx)+2)*lonSpan
+
+		// Neighbours past the top or bottom row don't exist, and the grid
+		// edge bounds the points as well.
+		latCovered := (latIdx == 0 || minLat >= south) && (latIdx == cells-1 || maxLat <= north)
+		lonCovered := cells <= 3 || minLon >= west && maxLon <= east
+		if latCovered && lonCovered {
+			break
+		}
+	}
+
+	cells := int64(1) << step
+	shift := 2 * (geoStepMax - step)
+	seen := make(map[uint64]bool)
+	var ranges []ScoreRange
+	for dLat := int64(-1); dLat <= 1; dLat++ {
+		lat := int64(latIdx) + dLat
+		if lat < 0 || lat >= cells {
+			continue
+		}
+		for dLon := int64(-1); dLon <= 1; dLon++ {
+			lon := ((int64(lonIdx)+dLon)%cells + cells) % cells
+			hash := interleave(uint64(lat), uint64(lon))
+			if seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			ranges = append(ranges, ScoreRange{
+				Min:          float64(hash << shift),
+				Max:          float64((hash + 1) << shift),
+				MaxExclusive: true,
+			})
+		}
+	}
+	return ranges
+}
+
+// geoSearch runs q against z. It reports false when q.FromMember names a
+// member z does not have.
+func (z *zsetObject) geoSearch(q GeoQuery) ([]GeoResult, bool) {
+	center := q.Center
+	if q.FromMember {
+		score, ok := z.scores[q.Member]
+		if !ok {
+			return nil, false
+		}
+		center = GeoDecode(uint64(score))
+	}
+
+	results := []GeoResult{}
+scan:
+	for _, r := range q.geoScoreRanges(center) {
+		for x := z.sl.firstInScoreRange(r); x != nil && r.belowMax(x.score); x = x.levels[0].forward {
+			p := GeoDecode(uint64(x.score))
+			dist, ok := q.within(center, p)
+			if !ok {
+				continue
+			}
+			results = append(results, GeoResult{Member: x.member, Dist: dist, Hash: uint64(x.score), Point: p})
+			if q.Any && len(results) == q.Count {
+				break scan
+			}
+		}
+	}
+
+	switch q.Sort {
+	case GeoAsc:
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Dist < results[j].Dist })
+	case GeoDesc:
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Dist > results[j].Dist })
+	}
+	if q.Count > 0 && len(results) > q.Count {
+		results = results[:q.Count]
+	}
+	return results, true
+}
+
+// GeoSearch returns the members of the geo set at key inside the area q
+// describes.
+func (s *store) GeoSearch(key string, q GeoQuery) ([]GeoResult, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	z, ok, err := lookupAs[*zsetObject](shard, key)
+	if err != nil || !ok {
+		return []GeoResult{}, err
+	}
+
+	results, ok := z.geoSearch(q)
+	if !ok {
+		return nil, ErrGeoMemberMissing
+	}
+	return results, nil
+}
+
+// GeoSearchStore runs q against src and stores the results at dst as a geo
+// set, or with storeDist as a sorted set of distances in units of unit
+// meters. It returns how many members it stored; none deletes dst.
+func (s *store) GeoSearchStore(dst, src string, q GeoQuery, storeDist bool, unit float64) (int, error) {
+	unlock := s.lockKeys(dst, src)
+	defer unlock()
+
+	z, ok, err := lookupAs[*zsetObject](s.getShard(src), src)
+	if err != nil {
+		return 0, err
+	}
+
+	var results []GeoResult
+	if ok {
+		if results, ok = z.geoSearch(q); !ok {
+			return 0, ErrGeoMemberMissing
+		}
+	}
+
+	shard := s.getShard(dst)
+	if len(results) == 0 {
+		if _, ok := shard.lookup(dst); ok {
+			shard.remove(dst)
+			s.notify(NotifyGeneric, "del", dst)
+		}
+		return 0, nil
+	}
+
+	stored := newZSet()
+	for _, r := range results {
+		score := float64(r.Hash)
+		if storeDist {
+			score = r.Dist / unit
+		}
+		stored.set(r.Member, score)
+	}
+	shard.put(dst, &entry{Object: stored})
+	s.notify(NotifyZSet, "geosearchstore", dst)
+
+	return len(results), nil
+}
@@ -0,0 +1,147 @@
+package store
+
+import "strings"
+
+// Glob is a compiled key pattern in Redis' syntax: * matches any run of
+// bytes, ? any single byte, [abc], [^abc] and [a-z] a byte from a class,
+// and a backslash makes the next byte literal.
+//
+// The pattern's leading run of literal bytes is split off, so keys with
+// the wrong prefix are turned down without running the matcher, and
+// patterns that are all literal, or a literal followed by *, need no
+// matcher at all.
+type Glob struct {
+	literal string // the unescaped bytes before the first wildcard
+	rest    string // the pattern from the first wildcard on
+}
+
+func CompileGlob(pattern string) Glob {
+	var literal strings.Builder
+	p := 0
+loop:
+	for p < len(pattern) {
+		switch c := pattern[p]; c {
+		case '*', '?', '[':
+			break loop
+		case '\\':
+			if p+1 < len(pattern) {
+				p++
+			}
+			literal.WriteByte(pattern[p])
+		default:
+			literal.WriteByte(c)
+		}
+		p++
+	}
+	return Glob{literal: literal.String(), rest: pattern[p:]}
+}
+
+// MatchGlob reports whether s matches pattern.
+func MatchGlob(pattern, s string) bool {
+	return CompileGlob(pattern).Match(s)
+}
+
+// Literal returns the one string the pattern matches, if it has no
+// wildcards.
+func (g Glob) Literal() (string, bool) {
+	return g.literal, g.rest == ""
+}
+
+func (g Glob) Match(s string) bool {
+	switch {
+	case g.rest == "":
+		return s == g.literal
+	case !strings.HasPrefix(s, g.literal):
+		return false
+	case strings.Trim(g.rest, "*") == "":
+		return true
+	}
+	return globMatch(g.rest, s[len(g.literal):])
+}
+
+// globMatch matches s against pattern the way Redis' stringmatchlen does,
+// but without recursion: on a mismatch it retries from the last * with one
+// more byte consumed by it.
+func globMatch(pattern, s string) bool {
+	p, i := 0, 0
+	starP, starI := -1, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			switch c := pattern[p]; c {
+			case '*':
+				for p < len(pattern) && pattern[p] == '*' {
+					p++
+				}
+				if p == len(pattern) {
+					return true
+				}
+				starP, starI = p, i
+				continue
+			case '?':
+				p, i = p+1, i+1
+				continue
+			case '[':
+				if next, ok := matchClass(pattern, p, s[i]); ok {
+					p, i = next, i+1
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) {
+					c = pattern[p+1]
+					p++
+				}
+				fallthrough
+			default:
+				if c == s[i] {
+					p, i = p+1, i+1
+					continue
+				}
+			}
+		}
+
+		if starP < 0 {
+			return false
+		}
+		starI++
+		p, i = starP, starI
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass matches c against the class opening at pattern[p], returning
+// the position after it. As in Redis, an unterminated class runs to the end
+// of the pattern and a reversed range such as [z-a] is read as [a-z].
+func matchClass(pattern string, p int, c byte) (int, bool) {
+	p++
+	not := p < len(pattern) && pattern[p] == '^'
+	if not {
+		p++
+	}
+
+	match := false
+	for p < len(pattern) && pattern[p] != ']' {
+		switch {
+		case pattern[p] == '\\' && p+1 < len(pattern):
+			p++
+			match = match || pattern[p] == c
+		case p+2 < len(pattern) && pattern[p+1] == '-':
+			lo, hi := pattern[p], pattern[p+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			match = match || lo <= c && c <= hi
+			p += 2
+		default:
+			match = match || pattern[p] == c
+		}
+		p++
+	}
+	if p < len(pattern) {
+		p++
+	}
+	return p, match != not
+}
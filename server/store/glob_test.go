@@ -0,0 +1,58 @@
+package store
+
+import "testing"
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"hello", "hello", true},
+		{"hello", "hello!", false},
+		{"*", "", true},
+		{"*", "anything", true},
+		{"user:*", "user:1", true},
+		{"user:*", "user", false},
+		{"user:**", "user:", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h*llo", "hello world", false},
+		{"*llo*wor*", "hello world", true},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"h[b-a]llo", "hallo", true},
+		{"[\\]]", "]", true},
+		{"[]", "a", false},
+		{"h[ae", "ha", true},
+		{"a.b", "a.b", true},
+		{"a.b", "aXb", false},
+		{"a+(b)", "a+(b)", true},
+		{"\\*", "*", true},
+		{"\\*", "x", false},
+		{"a\\?c*", "a?cd", true},
+		{"a\\?c*", "abcd", false},
+		{"x\\", "x\\", true},
+	}
+
+	for _, tc := range tests {
+		if got := MatchGlob(tc.pattern, tc.s); got != tc.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tc.pattern, tc.s, got, tc.want)
+		}
+	}
+
+	if key, ok := CompileGlob("a\\*b").Literal(); !ok || key != "a*b" {
+		t.Errorf("Literal of a\\*b = %q, %v, want a*b, true", key, ok)
+	}
+	if _, ok := CompileGlob("a*b").Literal(); ok {
+		t.Errorf("a*b reported as literal")
+	}
+}
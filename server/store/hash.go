@@ -0,0 +1,322 @@
+package store
+
+import (
+	"errors"
+	"maps"
+	"math"
+	"simpleKV/resp"
+	"strconv"
+)
+
+var (
+	ErrHashNotInteger = errors.New("ERR hash value is not an integer")
+	ErrHashNotFloat   = errors.New("ERR hash value is not a float")
+	ErrOverflow       = errors.New("ERR increment or decrement would overflow")
+	ErrNaNOrInfinity  = errors.New("ERR increment would produce NaN or Infinity")
+)
+
+// hashObject is the field to value map behind a hash key.
+type hashObject struct {
+	Fields map[string]string
+
+	// table spreads the fields over buckets for HSCAN, the way the shards'
+	// tables do keys for SCAN. The first HSCAN builds it and set and del
+	// keep it in step from then on; snapshots leave it out.
+	table *keyTable
+}
+
+func (h *hashObject) typeName() string { return "hash" }
+
+func (h *hashObject) memoryUsage(samples int) int {
+	return mapHeader + estimateSize(len(h.Fields), samples, func(yield func(int) bool) {
+		for field, value := range h.Fields {
+			if !yield(2*stringHeader + mapSlot + len(field) + len(value)) {
+				return
+			}
+		}
+	})
+}
+
+func newHash() *hashObject { return &hashObject{Fields: map[string]string{}} }
+
+// set stores value at field and reports whether the field is new.
+func (h *hashObject) set(field, value string) bool {
+	_, exists := h.Fields[field]
+	h.Fields[field] = value
+	if !exists && h.table != nil {
+		h.table.add(field)
+	}
+	return !exists
+}
+
+// del removes field and reports whether it was there.
+func (h *hashObject) del(field string) bool {
+	if _, ok := h.Fields[field]; !ok {
+		return false
+	}
+	delete(h.Fields, field)
+	if h.table != nil {
+		h.table.remove(field)
+	}
+	return true
+}
+
+// HSet stores the field/value pairs in fieldValues and returns how many of the
+// fields are new.
+func (s *store) HSet(key string, fieldValues []string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	h, err := lookupOrCreate(s, shard, key, newHash)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for i := 0; i+1 < len(fieldValues); i += 2 {
+		if h.set(fieldValues[i], fieldValues[i+1]) {
+			added++
+		}
+	}
+	s.notify(NotifyHash, "hset", key)
+
+	return added, nil
+}
+
+func (s *store) HSetNX(key, field, value string) (bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	h, err := lookupOrCreate(s, shard, key, newHash)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := h.Fields[field]; ok {
+		return false, nil
+	}
+	h.set(field, value)
+	s.notify(NotifyHash, "hset", key)
+
+	return true, nil
+}
+
+func (s *store) HGet(key, field string) (string, bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	h, ok, err := lookupAs[*hashObject](shard, key)
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	value, ok := h.Fields[field]
+	return value, ok, nil
+}
+
+// HMGet returns one bulk string per field, or a null for fields that are
+// missing.
+func (s *store) HMGet(key string, fields []string) ([]resp.Value, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	h, ok, err := lookupAs[*hashObject](shard, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		h = newHash()
+	}
+
+	values := make([]resp.Value, len(fields))
+	for i, field := range fields {
+		if value, ok := h.Fields[field]; ok {
+			values[i] = resp.Value{Type: resp.BULK_STRING, BulkString: value}
+		} else {
+			values[i] = resp.Value{Type: resp.NULL}
+		}
+	}
+
+	return values, nil
+}
+
+// HDel removes fields from the hash and drops the key once it is empty.
+func (s *store) HDel(key string, fields []string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	h, ok, err := lookupAs[*hashObject](shard, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	removed := 0
+	for _, field := range fields {
+		if h.del(field) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		s.notify(NotifyHash, "hdel", key)
+	}
+	if len(h.Fields) == 0 {
+		shard.remove(key)
+		s.notify(NotifyGeneric, "del", key)
+	}
+
+	return removed, nil
+}
+
+// HGetAll returns a copy of the hash, so callers can range over it without
+// holding the shard lock.
+func (s *store) HGetAll(key string) (map[string]string, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	h, ok, err := lookupAs[*hashObject](shard, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return map[string]string{}, nil
+	}
+	return maps.Clone(h.Fields), nil
+}
+
+func (s *store) HExists(key, field string) (bool, error) {
+	_, ok, err := s.HGet(key, field)
+	return ok, err
+}
+
+func (s *store) HLen(key string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	h, ok, err := lookupAs[*hashObject](shard, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return len(h.Fields), nil
+}
+
+func (s *store) HIncrBy(key, field string, delta int64) (int64, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	h, err := lookupOrCreate(s, shard, key, newHash)
+	if err != nil {
+		return 0, err
+	}
+
+	var current int64
+	if value, ok := h.Fields[field]; ok {
+		current, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, ErrHashNotInteger
+		}
+	}
+
+	if delta > 0 && current > math.MaxInt64-delta || delta < 0 && current < math.MinInt64-delta {
+		return 0, ErrOverflow
+	}
+
+	current += delta
+	h.set(field, strconv.FormatInt(current, 10))
+	s.notify(NotifyHash, "hincrby", key)
+
+	return current, nil
+}
+
+func (s *store) HIncrByFloat(key, field string, delta float64) (float64, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	h, err := lookupOrCreate(s, shard, key, newHash)
+	if err != nil {
+		return 0, err
+	}
+
+	var current float64
+	if value, ok := h.Fields[field]; ok {
+		current, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, ErrHashNotFloat
+		}
+	}
+
+	current += delta
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		if len(h.Fields) == 0 {
+			shard.remove(key)
+		}
+		return 0, ErrNaNOrInfinity
+	}
+	h.set(field, strconv.FormatFloat(current, 'f', -1, 64))
+	s.notify(NotifyHash, "hincrbyfloat", key)
+
+	return current, nil
+}
+
+// HScan pages through the hash fields that match matchPattern, returning
+// them as field/value pairs along with the cursor of the next page, which is
+// 0 once the hash is exhausted. The cursor walks the hash's bucket table as
+// Scan walks a shard's, so a field present for the whole walk is returned
+// at least once however the hash changes in between.
+func (s *store) HScan(key string, cursor int, matchPattern string, count int) (int, []string, error) {
+	if matchPattern == "" {
+		matchPattern = "*"
+	}
+	glob := CompileGlob(matchPattern)
+
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	h, ok, err := lookupAs[*hashObject](shard, key)
+	if err != nil || !ok {
+		return 0, nil, err
+	}
+
+	if h.table == nil {
+		h.table = &keyTable{}
+		for field := range h.Fields {
+			h.table.add(field)
+		}
+	}
+
+	var fieldValues []string
+	next := uint64(cursor)
+	visited, iterations := 0, scanIterations(count)
+	for {
+		next = h.table.scan(next, func(field string) {
+			visited++
+			if glob.Match(field) {
+				fieldValues = append(fieldValues, field, h.Fields[field])
+			}
+		})
+		iterations--
+		if next == 0 || visited >= count || iterations == 0 {
+			break
+		}
+	}
+	return int(next), fieldValues, nil
+}
@@ -0,0 +1,279 @@
+package store
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	hllP         = 14
+	hllRegisters = 1 << hllP
+	hllBits      = 6
+	hllDenseSize = hllRegisters * hllBits / 8
+	hllQ         = 64 - hllP // hash bits left to count zeros in
+
+	// hllSparseMax is how many registers a sparse sketch holds before it
+	// switches to dense, where it would no longer be smaller.
+	hllSparseMax = hllDenseSize / 4
+)
+
+// hllObject is a HyperLogLog with 2^14 registers. Small sketches keep only
+// their non-zero registers in Sparse, sorted by index with the index in the
+// high bits and the value in the low byte. Past hllSparseMax registers the
+// sketch moves to Dense, packing every register in 6 bits.
+type hllObject struct {
+	Sparse []uint32
+	Dense  []byte
+}
+
+// HyperLogLogs are strings as far as Redis clients are concerned.
+func (h *hllObject) typeName() string { return "string" }
+
+func (h *hllObject) memoryUsage(int) int {
+	return hllObjectSize + 4*len(h.Sparse) + len(h.Dense)
+}
+
+func newHLL() *hllObject {
+	return &hllObject{}
+}
+
+func (h *hllObject) get(i int) uint8 {
+	if h.Dense == nil {
+		j := h.search(i)
+		if j < len(h.Sparse) && int(h.Sparse[j]>>8) == i {
+			return uint8(h.Sparse[j])
+		}
+		return 0
+	}
+
+	offset := i * hllBits
+	b, shift := offset/8, uint(offset%8)
+	v := h.Dense[b] >> shift
+	if shift > 8-hllBits {
+		v |= h.Dense[b+1] << (8 - shift)
+	}
+	return v & (1<<hllBits - 1)
+}
+
+func (h *hllObject) search(i int) int {
+	return sort.Search(len(h.Sparse), func(j int) bool { return int(h.Sparse[j]>>8) >= i })
+}
+
+// raise sets register i to v if that is larger than its value, and reports
+// whether it did.
+func (h *hllObject) raise(i int, v uint8) bool {
+	if v <= h.get(i) {
+		return false
+	}
+
+	if h.Dense == nil {
+		j := h.search(i)
+		packed := uint32(i)<<8 | uint32(v)
+		if j < len(h.Sparse) && int(h.Sparse[j]>>8) == i {
+			h.Sparse[j] = packed
+			return true
+		}
+		h.Sparse = append(h.Sparse, 0)
+		copy(h.Sparse[j+1:], h.Sparse[j:])
+		h.Sparse[j] = packed
+		if len(h.Sparse) > hllSparseMax {
+			h.toDense()
+		}
+		return true
+	}
+
+	offset := i * hllBits
+	b, shift := offset/8, uint(offset%8)
+	const mask = 1<<hllBits - 1
+	h.Dense[b] = h.Dense[b]&^(mask<<shift) | v<<shift
+	if shift > 8-hllBits {
+		h.Dense[b+1] = h.Dense[b+1]&^(mask>>(8-shift)) | v>>(8-shift)
+	}
+	return true
+}
+
+func (h *hllObject) toDense() {
+	sparse := h.Sparse
+	h.Sparse, h.Dense = nil, make([]byte, hllDenseSize)
+	for _, packed := range sparse {
+		h.raise(int(packed>>8), uint8(packed))
+	}
+}
+
+// mergeInto raises every register of regs to at least the one in h.
+func (h *hllObject) mergeInto(regs []uint8) {
+	if h.Dense == nil {
+		for _, packed := range h.Sparse {
+			i := packed >> 8
+			regs[i] = max(regs[i], uint8(packed))
+		}
+		return
+	}
+	for i := range regs {
+		regs[i] = max(regs[i], h.get(i))
+	}
+}
+
+// stableHash is the 64-bit hash of elem used by the sketches that are
+// persisted, so unlike maphash it comes out the same after a restart.
+func stableHash(elem string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(elem))
+	x := hash.Sum64()
+
+	// FNV alone spreads short keys poorly over the low bits, so finish with
+	// the MurmurHash3 mixer.
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// hllPosition hashes elem into a register index and the length of the run
+// of zeros that follows it, plus one.
+func hllPosition(elem string) (int, uint8) {
+	x := stableHash(elem)
+	index := int(x & (hllRegisters - 1))
+	rest := x>>hllP | 1<<hllQ // the sentinel bit bounds the run at hllQ
+	return index, uint8(bits.TrailingZeros64(rest) + 1)
+}
+
+// hllEstimate is Otmar Ertl's improved raw estimator, which needs no bias
+// tables or small and large range corrections.
+func hllEstimate(regs []uint8) uint64 {
+	var histogram [hllQ + 2]int
+	for _, r := range regs {
+		histogram[r]++
+	}
+
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+
+	return uint64(math.Round(0.5 / math.Ln2 * m * m / z))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if z == prev {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if z == prev {
+			return z / 3
+		}
+	}
+}
+
+// PFAdd adds elements to the HyperLogLog at key, creating it if needed. It
+// reports whether the sketch changed, which includes being created.
+func (s *store) PFAdd(key string, elements []string) (bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	h, existed, err := lookupAs[*hllObject](shard, key)
+	if err != nil {
+		return false, err
+	}
+	if !existed {
+		h, err = lookupOrCreate(s, shard, key, newHLL)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	changed := !existed
+	for _, elem := range elements {
+		if h.raise(hllPosition(elem)) {
+			changed = true
+		}
+	}
+	if changed {
+		s.notify(NotifyString, "pfadd", key)
+	}
+	return changed, nil
+}
+
+// PFCount estimates the number of distinct elements added to any of the
+// HyperLogLogs at keys, merging them on the fly. Missing keys count as empty.
+func (s *store) PFCount(keys []string) (uint64, error) {
+	unlock := s.lockKeys(keys...)
+	defer unlock()
+
+	regs := make([]uint8, hllRegisters)
+	for _, key := range keys {
+		h, ok, err := lookupAs[*hllObject](s.getShard(key), key)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			h.mergeInto(regs)
+		}
+	}
+	return hllEstimate(regs), nil
+}
+
+// PFMerge merges the HyperLogLogs at keys into the one at dst, creating it
+// if needed.
+func (s *store) PFMerge(dst string, keys []string) error {
+	unlock := s.lockKeys(append([]string{dst}, keys...)...)
+	defer unlock()
+
+	sources := make([]*hllObject, 0, len(keys))
+	for _, key := range keys {
+		h, ok, err := lookupAs[*hllObject](s.getShard(key), key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			sources = append(sources, h)
+		}
+	}
+
+	target, err := lookupOrCreate(s, s.getShard(dst), dst, newHLL)
+	if err != nil {
+		return err
+	}
+
+	regs := make([]uint8, hllRegisters)
+	for _, h := range sources {
+		h.mergeInto(regs)
+	}
+	for i, v := range regs {
+		if v > 0 {
+			target.raise(i, v)
+		}
+	}
+	s.notify(NotifyString, "pfadd", dst)
+	return nil
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"math/rand/v2"
+)
+
+var ErrSameKey = errors.New("ERR source and destination objects are the same")
+
+// Exists counts how many of keys are present. A key named twice is counted
+// twice.
+func (s *store) Exists(keys []string) int {
+	unlock := s.lockKeys(keys...)
+	defer unlock()
+
+	n := 0
+	for _, key := range keys {
+		if _, ok := s.getShard(key).lookup(key); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// Touch reports how many of keys are present, like Exists.
+func (s *store) Touch(keys []string) int {
+	return s.Exists(keys)
+}
+
+// Type returns the name of the type held at key, or "none" when it is
+// missing.
+func (s *store) Type(key string) string {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e, ok := shard.lookup(key)
+	if !ok {
+		return "none"
+	}
+	return e.typeName()
+}
+
+// Rename moves the value at src, deadline included, to dst. With nx it
+// leaves an existing dst alone and returns false.
+func (s *store) Rename(src, dst string, nx bool) (bool, error) {
+	unlock := s.lockKeys(src, dst)
+	defer unlock()
+
+	srcShard, dstShard := s.getShard(src), s.getShard(dst)
+	e, ok := srcShard.lookup(src)
+	if !ok {
+		return false, ErrNoSuchKey
+	}
+	if src == dst {
+		return !nx, nil
+	}
+	if _, exists := dstShard.lookup(dst); exists && nx {
+		return false, nil
+	}
+
+	srcShard.remove(src)
+	dstShard.put(dst, e)
+	s.notify(NotifyGeneric, "rename_from", src)
+	s.notify(NotifyGeneric, "rename_to", dst)
+	return true, nil
+}
+
+// Copy stores a deep copy of the value at src, deadline included, at dst.
+// It returns false when src is missing, or when dst exists and replace is
+// not set.
+func (s *store) Copy(src, dst string, replace bool) (bool, error) {
+	if src == dst {
+		return false, ErrSameKey
+	}
+
+	unlock := s.lockKeys(src, dst)
+	defer unlock()
+
+	srcShard, dstShard := s.getShard(src), s.getShard(dst)
+	e, ok := srcShard.lookup(src)
+	if !ok {
+		return false, nil
+	}
+	if _, exists := dstShard.lookup(dst); exists && !replace {
+		return false, nil
+	}
+
+	dup, err := cloneEntry(e)
+	if err != nil {
+		return false, err
+	}
+	dstShard.put(dst, dup)
+	s.notify(NotifyGeneric, "copy_to", dst)
+	return true, nil
+}
+
+// cloneEntry deep-copies e by sending it through gob, the same encoding
+// snapshots use, so every type that persists can also be copied.
+func cloneEntry(e *entry) (*entry, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		return nil, err
+	}
+
+	dup := &entry{}
+	if err := gob.NewDecoder(&buf).Decode(dup); err != nil {
+		return nil, err
+	}
+	return dup, nil
+}
+
+// RandomKey returns a live key picked at random, or false when the store is
+// empty. The shard is chosen uniformly and the key within it by map order.
+func (s *store) RandomKey() (string, bool) {
+	start := rand.IntN(len(s.Shards))
+	now := nowMillis()
+
+	for i := range s.Shards {
+		shard := &s.Shards[(start+i)%len(s.Shards)]
+
+		shard.mu.RLock()
+		for key, e := range shard.Data {
+			if !e.expired(now) {
+				shard.mu.RUnlock()
+				return key, true
+			}
+		}
+		shard.mu.RUnlock()
+	}
+	return "", false
+}
+
+// DBSize returns the number of keys held in all shards. Keys past their
+// deadline count until they are reclaimed.
+func (s *store) DBSize() int {
+	n := 0
+	for i := range s.Shards {
+		s.Shards[i].mu.RLock()
+		n += len(s.Shards[i].Data)
+		s.Shards[i].mu.RUnlock()
+	}
+	return n
+}
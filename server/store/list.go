@@ -0,0 +1,429 @@
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+)
+
+var (
+	ErrNoSuchKey       = errors.New("ERR no such key")
+	ErrIndexOutOfRange = errors.New("ERR index out of range")
+)
+
+// ListSide picks the end of a list an operation works on.
+type ListSide int
+
+const (
+	ListLeft ListSide = iota
+	ListRight
+)
+
+// pushEvent and popEvent name the keyspace events raised by pushing onto and
+// popping from side.
+func (side ListSide) pushEvent() string {
+	if side == ListLeft {
+		return "lpush"
+	}
+	return "rpush"
+}
+
+func (side ListSide) popEvent() string {
+	if side == ListLeft {
+		return "lpop"
+	}
+	return "rpop"
+}
+
+// listObject is a deque kept in a growable ring buffer, so pushes and pops at
+// either end are O(1) and indexing stays O(1) as well.
+type listObject struct {
+	items []string
+	head  int
+	size  int
+}
+
+func (l *listObject) typeName() string { return "list" }
+
+func (l *listObject) memoryUsage(samples int) int {
+	return listObjectSize + len(l.items)*stringHeader + estimateSize(l.size, samples, func(yield func(int) bool) {
+		for i := 0; i < l.size; i++ {
+			if !yield(len(l.at(i))) {
+				return
+			}
+		}
+	})
+}
+
+func newList() *listObject { return &listObject{} }
+
+func (l *listObject) grow() {
+	items := make([]string, max(8, 2*len(l.items)))
+	for i := 0; i < l.size; i++ {
+		items[i] = l.at(i)
+	}
+	l.items = items
+	l.head = 0
+}
+
+func (l *listObject) slot(i int) int {
+	return (l.head + i) % len(l.items)
+}
+
+func (l *listObject) at(i int) string {
+	return l.items[l.slot(i)]
+}
+
+func (l *listObject) push(side ListSide, value string) {
+	if l.size == len(l.items) {
+		l.grow()
+	}
+	if side == ListLeft {
+		l.head = (l.head - 1 + len(l.items)) % len(l.items)
+		l.items[l.head] = value
+	} else {
+		l.items[l.slot(l.size)] = value
+	}
+	l.size++
+}
+
+func (l *listObject) pop(side ListSide) string {
+	var i int
+	if side == ListLeft {
+		i = l.head
+		l.head = l.slot(1)
+	} else {
+		i = l.slot(l.size - 1)
+	}
+	value := l.items[i]
+	l.items[i] = ""
+	l.size--
+	return value
+}
+
+// values copies the elements in [start, end) out of the ring buffer.
+func (l *listObject) values(start, end int) []string {
+	values := make([]string, 0, max(end-start, 0))
+	for i := start; i < end; i++ {
+		values = append(values, l.at(i))
+	}
+	return values
+}
+
+// reset replaces the contents of the list with values.
+func (l *listObject) reset(values []string) {
+	l.items = values
+	l.head = 0
+	l.size = len(values)
+}
+
+// normalizeIndex turns a possibly negative index into an offset from the
+// head, reporting whether it falls inside the list.
+func (l *listObject) normalizeIndex(index int) (int, bool) {
+	if index < 0 {
+		index += l.size
+	}
+	return index, index >= 0 && index < l.size
+}
+
+// normalizeRange clamps an inclusive start/stop pair, as taken by LRANGE and
+// LTRIM, to a half-open range of offsets.
+func (l *listObject) normalizeRange(start, stop int) (int, int) {
+	if start < 0 {
+		start += l.size
+	}
+	if stop < 0 {
+		stop += l.size
+	}
+	start = max(start, 0)
+	stop = min(stop, l.size-1)
+	if start > stop {
+		return 0, 0
+	}
+	return start, stop + 1
+}
+
+func (l *listObject) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(l.values(0, l.size))
+	return buf.Bytes(), err
+}
+
+func (l *listObject) GobDecode(data []byte) error {
+	var values []string
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&values)
+	if err != nil {
+		return err
+	}
+	l.reset(values)
+	return nil
+}
+
+// dropIfEmptyList removes key once its list has no elements left, the same
+// way Redis never keeps empty aggregates around.
+func (sh *shard) dropIfEmptyList(key string, l *listObject) {
+	if l.size == 0 {
+		sh.remove(key)
+		sh.events.notify(NotifyGeneric, "del", key)
+	}
+}
+
+// ListPush adds values one by one to the given side of the list and returns
+// the new length. With onlyIfExists nothing is created for a missing key.
+func (s *store) ListPush(key string, side ListSide, values []string, onlyIfExists bool) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if onlyIfExists {
+		l, ok, err := lookupAs[*listObject](shard, key)
+		if err != nil || !ok {
+			return 0, err
+		}
+		for _, value := range values {
+			l.push(side, value)
+		}
+		s.notify(NotifyList, side.pushEvent(), key)
+		return l.size, nil
+	}
+
+	l, err := lookupOrCreate(s, shard, key, newList)
+	if err != nil {
+		return 0, err
+	}
+	for _, value := range values {
+		l.push(side, value)
+	}
+	s.notify(NotifyList, side.pushEvent(), key)
+
+	return l.size, nil
+}
+
+// ListPop removes up to count elements from the given side of the list. A
+// missing key yields a nil slice.
+func (s *store) ListPop(key string, side ListSide, count int) ([]string, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	l, ok, err := lookupAs[*listObject](shard, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	values := make([]string, 0, min(count, l.size))
+	for len(values) < count && l.size > 0 {
+		values = append(values, l.pop(side))
+	}
+	if len(values) > 0 {
+		s.notify(NotifyList, side.popEvent(), key)
+	}
+	shard.dropIfEmptyList(key, l)
+
+	return values, nil
+}
+
+func (s *store) LLen(key string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	l, ok, err := lookupAs[*listObject](shard, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return l.size, nil
+}
+
+// LRange returns the elements between start and stop inclusive. Negative
+// indexes count from the tail.
+func (s *store) LRange(key string, start, stop int) ([]string, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	l, ok, err := lookupAs[*listObject](shard, key)
+	if err != nil || !ok {
+		return []string{}, err
+	}
+
+	from, to := l.normalizeRange(start, stop)
+	return l.values(from, to), nil
+}
+
+func (s *store) LIndex(key string, index int) (string, bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	l, ok, err := lookupAs[*listObject](shard, key)
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	i, ok := l.normalizeIndex(index)
+	if !ok {
+		return "", false, nil
+	}
+	return l.at(i), true, nil
+}
+
+func (s *store) LSet(key string, index int, value string) error {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	l, ok, err := lookupAs[*listObject](shard, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNoSuchKey
+	}
+
+	i, ok := l.normalizeIndex(index)
+	if !ok {
+		return ErrIndexOutOfRange
+	}
+	l.items[l.slot(i)] = value
+	s.notify(NotifyList, "lset", key)
+
+	return nil
+}
+
+// LRem removes occurrences of value: the first count from the head when count
+// is positive, the last -count from the tail when negative, and all of them
+// when zero.
+func (s *store) LRem(key string, count int, value string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	l, ok, err := lookupAs[*listObject](shard, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	values := l.values(0, l.size)
+	keep := make([]bool, len(values))
+	removed := 0
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	for n := range values {
+		i := n
+		if count < 0 {
+			i = len(values) - 1 - n
+		}
+		if values[i] == value && (limit == 0 || removed < limit) {
+			removed++
+			continue
+		}
+		keep[i] = true
+	}
+
+	kept := make([]string, 0, len(values)-removed)
+	for i, v := range values {
+		if keep[i] {
+			kept = append(kept, v)
+		}
+	}
+	l.reset(kept)
+	if removed > 0 {
+		s.notify(NotifyList, "lrem", key)
+	}
+	shard.dropIfEmptyList(key, l)
+
+	return removed, nil
+}
+
+// LTrim keeps only the elements between start and stop inclusive.
+func (s *store) LTrim(key string, start, stop int) error {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	l, ok, err := lookupAs[*listObject](shard, key)
+	if err != nil || !ok {
+		return err
+	}
+
+	from, to := l.normalizeRange(start, stop)
+	l.reset(l.values(from, to))
+	s.notify(NotifyList, "ltrim", key)
+	shard.dropIfEmptyList(key, l)
+
+	return nil
+}
+
+// LInsert puts value right before or after the first occurrence of pivot and
+// returns the new length, -1 when pivot is not in the list, or 0 when the key
+// does not exist.
+func (s *store) LInsert(key string, before bool, pivot, value string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	l, ok, err := lookupAs[*listObject](shard, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	values := l.values(0, l.size)
+	for i, v := range values {
+		if v != pivot {
+			continue
+		}
+		if !before {
+			i++
+		}
+		values = append(values[:i], append([]string{value}, values[i:]...)...)
+		l.reset(values)
+		s.notify(NotifyList, "linsert", key)
+		return l.size, nil
+	}
+
+	return -1, nil
+}
+
+// LMove pops an element from one side of src and pushes it onto the given
+// side of dst, atomically with respect to both keys.
+func (s *store) LMove(src, dst string, from, to ListSide) (string, bool, error) {
+	srcShard, dstShard := s.getShard(src), s.getShard(dst)
+
+	unlock := s.lockKeys(src, dst)
+	defer unlock()
+
+	l, ok, err := lookupAs[*listObject](srcShard, src)
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	// Check dst before popping so a type error leaves src untouched.
+	if _, _, err := lookupAs[*listObject](dstShard, dst); err != nil {
+		return "", false, err
+	}
+
+	value := l.pop(from)
+	s.notify(NotifyList, from.popEvent(), src)
+	srcShard.dropIfEmptyList(src, l)
+
+	target, err := lookupOrCreate(s, dstShard, dst, newList)
+	if err != nil {
+		return "", false, err
+	}
+	target.push(to, value)
+	s.notify(NotifyList, to.pushEvent(), dst)
+
+	return value, true, nil
+}
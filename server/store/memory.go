@@ -0,0 +1,237 @@
+package store
+
+import (
+	"iter"
+	"math/rand/v2"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Sizes are estimates of what the Go runtime holds for a value, not exact
+// allocator numbers: string and slice headers, map slots and struct sizes
+// are counted along with the payload bytes.
+const (
+	memorySamples = 5 // elements measured per object when settling a shard
+
+	stringHeader  = int(unsafe.Sizeof(""))
+	sliceHeader   = int(unsafe.Sizeof([]byte(nil)))
+	pointerSize   = int(unsafe.Sizeof(uintptr(0)))
+	mapHeader     = 48
+	mapSlot       = 8 // per-slot overhead of a map beyond its keys and values
+	entryOverhead = int(unsafe.Sizeof(entry{})) + stringHeader + pointerSize + mapSlot
+
+	listObjectSize    = int(unsafe.Sizeof(listObject{}))
+	skipListNodeSize  = int(unsafe.Sizeof(skipListNode{}))
+	skipListLevelSize = int(unsafe.Sizeof(skipListLevel{}))
+	streamObjectSize  = int(unsafe.Sizeof(streamObject{}))
+	streamEntrySize   = int(unsafe.Sizeof(StreamEntry{}))
+	pendingSize       = int(unsafe.Sizeof(StreamID{})+unsafe.Sizeof(pendingInfo{})) + pointerSize + mapSlot
+	hllObjectSize     = int(unsafe.Sizeof(hllObject{}))
+	bloomObjectSize   = int(unsafe.Sizeof(bloomObject{}))
+	bloomLayerSize    = int(unsafe.Sizeof(bloomLayer{}))
+	cuckooObjectSize  = int(unsafe.Sizeof(cuckooObject{}))
+
+	cmsObjectSize         = int(unsafe.Sizeof(cmsObject{}))
+	topkObjectSize        = int(unsafe.Sizeof(topkObject{}))
+	topkItemSize          = int(unsafe.Sizeof(TopKItem{}))
+	heavyKeeperBucketSize = int(unsafe.Sizeof(heavyKeeperBucket{}))
+	tdigestObjectSize     = int(unsafe.Sizeof(tdigestObject{}))
+	centroidSize          = int(unsafe.Sizeof(centroid{}))
+)
+
+// keySize is the part of an entry's size that doesn't depend on its value.
+func keySize(key string) int64 {
+	return int64(entryOverhead + len(key))
+}
+
+// entrySize estimates the bytes key and e take up in a shard.
+func entrySize(key string, e *entry, samples int) int64 {
+	size := keySize(key)
+	if e.Object != nil {
+		size += int64(e.Object.memoryUsage(samples))
+	} else {
+		size += int64(len(e.Value.BulkString))
+	}
+	return size
+}
+
+// ShardMemory is the memory held by one shard, and the state of the bloom
+// filter in front of it.
+type ShardMemory struct {
+	Keys       int
+	KeyBytes   int64 // keys and the entries that hold them
+	ValueBytes int64 // the values stored under the keys
+
+	BloomBytes    int64
+	BloomCounters int
+	BloomFill     float64 // share of counters above zero
+	BloomFPR      float64 // estimated false positive rate
+	BloomRebuilds int64   // times the filter was resized or rebuilt
+}
+
+// MemoryStats breaks down the memory held by the store.
+type MemoryStats struct {
+	Shards []ShardMemory
+}
+
+func (m MemoryStats) Keys() (n int) {
+	for _, sh := range m.Shards {
+		n += sh.Keys
+	}
+	return n
+}
+
+// Dataset returns the bytes held by keys and values.
+func (m MemoryStats) Dataset() (n int64) {
+	for _, sh := range m.Shards {
+		n += sh.KeyBytes + sh.ValueBytes
+	}
+	return n
+}
+
+// BloomFilterBytes returns the bytes held by the shards' bloom filters.
+func (m MemoryStats) BloomFilterBytes() (n int64) {
+	for _, sh := range m.Shards {
+		n += sh.BloomBytes
+	}
+	return n
+}
+
+// BloomFillRatio returns the share of all bloom filter counters above zero.
+func (m MemoryStats) BloomFillRatio() float64 {
+	var set float64
+	var counters int
+	for _, sh := range m.Shards {
+		set += sh.BloomFill * float64(sh.BloomCounters)
+		counters += sh.BloomCounters
+	}
+	if counters == 0 {
+		return 0
+	}
+	return set / float64(counters)
+}
+
+// BloomFPR returns the estimated chance that a lookup of a missing key gets
+// past the bloom filters. Keys hash evenly over the shards, so this is the
+// mean of the shards' rates.
+func (m MemoryStats) BloomFPR() float64 {
+	if len(m.Shards) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, sh := range m.Shards {
+		sum += sh.BloomFPR
+	}
+	return sum / float64(len(m.Shards))
+}
+
+func (m MemoryStats) BloomRebuilds() (n int64) {
+	for _, sh := range m.Shards {
+		n += sh.BloomRebuilds
+	}
+	return n
+}
+
+// Total returns the bytes counted against maxmemory.
+func (m MemoryStats) Total() int64 {
+	return m.Dataset() + m.BloomFilterBytes()
+}
+
+// MemoryStats settles every shard and reports how its memory is spent.
+func (s *store) MemoryStats() MemoryStats {
+	stats := MemoryStats{
+		Shards: make([]ShardMemory, len(s.Shards)),
+	}
+	for i := range s.Shards {
+		shard := &s.Shards[i]
+
+		shard.mu.Lock()
+		shard.settle()
+		stats.Shards[i] = ShardMemory{
+			Keys:       len(shard.Data),
+			KeyBytes:   shard.keyBytes,
+			ValueBytes: shard.used - shard.keyBytes,
+
+			BloomBytes:    shard.bloom.memoryUsage(),
+			BloomCounters: len(shard.bloom.counters),
+			BloomFill:     shard.bloom.fillRatio(),
+			BloomFPR:      shard.bloom.estimatedFPR(),
+			BloomRebuilds: shard.bloomRebuilds,
+		}
+		shard.mu.Unlock()
+	}
+	return stats
+}
+
+// MemoryUsage estimates the bytes held by key and its value, sampling
+// samples elements of collections, or all of them when samples <= 0. It
+// doesn't count as an access to the key.
+func (s *store) MemoryUsage(key string, samples int) (int64, bool) {
+	shard := s.getShard(key)
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	e, ok := shard.Data[key]
+	if !ok || e.expired(nowMillis()) {
+		return 0, false
+	}
+	return entrySize(key, e, samples), true
+}
+
+// estimateSize extrapolates the total size of n elements from the sizes seq
+// yields for the first samples of them, or for all of them when
+// samples <= 0.
+func estimateSize(n, samples int, seq iter.Seq[int]) int {
+	total, seen := 0, 0
+	for size := range seq {
+		total += size
+		seen++
+		if samples > 0 && seen >= samples {
+			break
+		}
+	}
+	if seen == 0 {
+		return 0
+	}
+	return int(int64(total) * int64(n) / int64(seen))
+}
+
+// The LFU counter grows logarithmically with the number of accesses and
+// loses one point per idle minute, as in Redis with lfu-log-factor 10 and
+// lfu-decay-time 1.
+const (
+	lfuInitValue = 5
+	lfuLogFactor = 10
+	lfuDecayMs   = 60 * 1000
+)
+
+// touch records an access at now. It may run under a shard's read lock, so
+// the metadata is only read and written atomically.
+func (e *entry) touch(now int64) {
+	freq := e.frequency(now)
+	if freq < 255 {
+		base := max(int(freq)-lfuInitValue, 0)
+		if rand.Float64() < 1/float64(base*lfuLogFactor+1) {
+			freq++
+		}
+	}
+	atomic.StoreUint32(&e.freq, freq)
+	atomic.StoreInt64(&e.access, now)
+}
+
+// frequency returns the LFU counter decayed for the time since the last
+// access.
+func (e *entry) frequency(now int64) uint32 {
+	freq := atomic.LoadUint32(&e.freq)
+	decay := (now - atomic.LoadInt64(&e.access)) / lfuDecayMs
+	if decay >= int64(freq) {
+		return 0
+	}
+	return freq - uint32(decay)
+}
+
+// idle returns the milliseconds since the last access.
+func (e *entry) idle(now int64) int64 {
+	return now - atomic.LoadInt64(&e.access)
+}
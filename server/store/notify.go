@@ -0,0 +1,118 @@
+package store
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// NotifyFlags selects the keyspace events that are published, using the
+// letters of Redis' notify-keyspace-events setting.
+type NotifyFlags uint32
+
+const (
+	NotifyKeyspace NotifyFlags = 1 << iota // K: publish on __keyspace@<db>__:<key>
+	NotifyKeyevent                         // E: publish on __keyevent@<db>__:<event>
+	NotifyGeneric                          // g: DEL, EXPIRE, RENAME, ...
+	NotifyString                           // $
+	NotifyList                             // l
+	NotifySet                              // s
+	NotifyHash                             // h
+	NotifyZSet                             // z
+	NotifyExpired                          // x: a key reached its deadline
+	NotifyEvicted                          // e: a key was evicted for maxmemory
+	NotifyStream                           // t
+	NotifyNew                              // n: a key was created
+
+	// NotifyAll is the A alias. As in Redis it leaves out n.
+	NotifyAll = NotifyGeneric | NotifyString | NotifyList | NotifySet | NotifyHash |
+		NotifyZSet | NotifyExpired | NotifyEvicted | NotifyStream
+)
+
+var notifyFlagLetters = []struct {
+	flag   NotifyFlags
+	letter byte
+}{
+	{NotifyGeneric, 'g'}, {NotifyString, '$'}, {NotifyList, 'l'}, {NotifySet, 's'},
+	{NotifyHash, 'h'}, {NotifyZSet, 'z'}, {NotifyExpired, 'x'}, {NotifyEvicted, 'e'},
+	{NotifyStream, 't'}, {NotifyKeyspace, 'K'}, {NotifyKeyevent, 'E'}, {NotifyNew, 'n'},
+}
+
+// ParseNotifyFlags reads a flag string such as "KEA" or "Kx$".
+func ParseNotifyFlags(s string) (NotifyFlags, bool) {
+	var flags NotifyFlags
+	for i := 0; i < len(s); i++ {
+		if s[i] == 'A' {
+			flags |= NotifyAll
+			continue
+		}
+		found := false
+		for _, fl := range notifyFlagLetters {
+			if fl.letter == s[i] {
+				flags |= fl.flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return flags, true
+}
+
+// String renders flags the way CONFIG GET shows them, with A standing in
+// for the classes it covers.
+func (f NotifyFlags) String() string {
+	var sb strings.Builder
+	if f&NotifyAll == NotifyAll {
+		sb.WriteByte('A')
+		f &^= NotifyAll
+	}
+	for _, fl := range notifyFlagLetters {
+		if f&fl.flag != 0 {
+			sb.WriteByte(fl.letter)
+		}
+	}
+	return sb.String()
+}
+
+// Notifier receives the keyspace events the current flags select. It is
+// called with shard locks held, so it must not call back into the store.
+type Notifier func(event, key string)
+
+// notifier is shared by the store and its shards, so events can be raised
+// from wherever a key changes.
+type notifier struct {
+	flags atomic.Uint32
+	fn    atomic.Pointer[Notifier]
+}
+
+func (n *notifier) notify(class NotifyFlags, event, key string) {
+	flags := NotifyFlags(n.flags.Load())
+	if flags&class == 0 || flags&(NotifyKeyspace|NotifyKeyevent) == 0 {
+		return
+	}
+	if fn := n.fn.Load(); fn != nil {
+		(*fn)(event, key)
+	}
+}
+
+// notify records that key changed, for WATCH, and raises event for it. The
+// caller holds the lock of key's shard.
+func (s *store) notify(class NotifyFlags, event, key string) {
+	s.getShard(key).modified(key)
+	s.events.notify(class, event, key)
+}
+
+func (s *store) NotifyFlags() NotifyFlags {
+	return NotifyFlags(s.events.flags.Load())
+}
+
+func (s *store) SetNotifyFlags(flags NotifyFlags) {
+	s.events.flags.Store(uint32(flags))
+}
+
+// SetNotifier installs fn as the receiver of keyspace events.
+func (s *store) SetNotifier(fn Notifier) {
+	s.events.fn.Store(&fn)
+}
@@ -1,39 +1,167 @@
 package store
 
 import (
+	"bytes"
 	"encoding/gob"
 	"fmt"
 	"os"
+	"simpleKV/resp"
 )
 
+func init() {
+	gob.Register(&hashObject{})
+	gob.Register(&listObject{})
+	gob.Register(setObject{})
+	gob.Register(&zsetObject{})
+	gob.Register(&streamObject{})
+	gob.Register(&hllObject{})
+	gob.Register(&bloomObject{})
+	gob.Register(&cuckooObject{})
+	gob.Register(&cmsObject{})
+	gob.Register(&topkObject{})
+	gob.Register(&tdigestObject{})
+}
+
+// The snapshot is a gob stream holding the shard count followed by one
+// key/entry map per shard. Each shard is encoded under its own read lock, so
+// writers are only held up for the shard currently being written out.
+
 func (s *store) saveSnapshot() error {
-	file, err := os.Create(s.persistenceFile)
+	tmpFile := s.persistenceFile + ".tmp"
+	file, err := os.Create(tmpFile)
 	if err != nil {
 		return fmt.Errorf("could not create persistence file: %v", err)
 	}
+	defer os.Remove(tmpFile)
 	defer file.Close()
 
 	encoder := gob.NewEncoder(file)
-	err = encoder.Encode(s)
+	err = encoder.Encode(len(s.Shards))
 	if err != nil {
 		return fmt.Errorf("could not encode store data: %v", err)
 	}
 
+	for i := range s.Shards {
+		shard := &s.Shards[i]
+
+		shard.mu.RLock()
+		err = encoder.Encode(shard.Data)
+		shard.mu.RUnlock()
+		if err != nil {
+			return fmt.Errorf("could not encode store data: %v", err)
+		}
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("could not write persistence file: %v", err)
+	}
+
+	err = os.Rename(tmpFile, s.persistenceFile)
+	if err != nil {
+		return fmt.Errorf("could not replace persistence file: %v", err)
+	}
+
 	return nil
 }
 
 func (s *store) loadSnapshot() error {
-	file, err := os.Open(s.persistenceFile)
+	raw, err := os.ReadFile(s.persistenceFile)
 	if err != nil {
-		return fmt.Errorf("could not open persistence file: %v", err)
+		return fmt.Errorf("could not open persistence file: %w", err)
 	}
-	defer file.Close()
 
-	decoder := gob.NewDecoder(file)
-	err = decoder.Decode(s)
+	loaded, err := decodeSnapshot(raw)
 	if err != nil {
-		return fmt.Errorf("could not decode store data: %v", err)
+		var legacyErr error
+		loaded, legacyErr = decodeLegacySnapshot(raw)
+		if legacyErr != nil {
+			return err
+		}
+	}
+
+	// The snapshot may have been written with a different shard count, so
+	// every key is routed again instead of copying shard maps wholesale.
+	now := nowMillis()
+	routed := make([]map[string]*entry, len(s.Shards))
+	for i := range routed {
+		routed[i] = make(map[string]*entry)
+	}
+	for _, data := range loaded {
+		for key, e := range data {
+			if !e.expired(now) {
+				routed[s.shardIndex(key)][key] = e
+			}
+		}
+	}
+
+	// Shards are updated in place rather than wiped, so the reload can tell
+	// listeners which keys it dropped and which it loaded.
+	for i := range s.Shards {
+		shard := &s.Shards[i]
+
+		shard.mu.Lock()
+		for key := range shard.Data {
+			if _, ok := routed[i][key]; !ok {
+				shard.remove(key)
+				s.notify(NotifyGeneric, "del", key)
+			}
+		}
+		for key, e := range routed[i] {
+			shard.put(key, e)
+			s.notify(NotifyGeneric, "loaded", key)
+		}
+		shard.mu.Unlock()
 	}
 
 	return nil
 }
+
+func decodeSnapshot(data []byte) ([]map[string]*entry, error) {
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+
+	var numShards int
+	err := decoder.Decode(&numShards)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode store data: %v", err)
+	}
+
+	loaded := make([]map[string]*entry, numShards)
+	for i := range loaded {
+		err = decoder.Decode(&loaded[i])
+		if err != nil {
+			return nil, fmt.Errorf("could not decode store data: %v", err)
+		}
+	}
+
+	return loaded, nil
+}
+
+// legacySnapshot is the format written before keys carried types and
+// deadlines: the whole store encoded at once, with every value a plain
+// resp.Value. Fields it no longer has, like the bloom filter, are skipped.
+type legacySnapshot struct {
+	Shards []struct {
+		Data map[string]resp.Value
+	}
+}
+
+// decodeLegacySnapshot reads a snapshot in the legacy format, turning each
+// value into a string key that never expires.
+func decodeLegacySnapshot(data []byte) ([]map[string]*entry, error) {
+	var legacy legacySnapshot
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&legacy)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode legacy store data: %v", err)
+	}
+
+	loaded := make([]map[string]*entry, len(legacy.Shards))
+	for i, shard := range legacy.Shards {
+		loaded[i] = make(map[string]*entry, len(shard.Data))
+		for key, value := range shard.Data {
+			loaded[i][key] = &entry{Value: value}
+		}
+	}
+
+	return loaded, nil
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"simpleKV/resp"
+	"testing"
+)
+
+// TestLoadLegacySnapshot writes a snapshot the way stores did before keys
+// carried types and deadlines, and checks that its keys load as strings.
+func TestLoadLegacySnapshot(t *testing.T) {
+	type legacyShard struct {
+		Data map[string]resp.Value
+	}
+	type legacyBloomFilter struct {
+		M, K   uint32
+		Filter []uint32
+	}
+	legacy := struct {
+		Shards      []legacyShard
+		BloomFilter *legacyBloomFilter
+	}{
+		Shards: []legacyShard{
+			{Data: map[string]resp.Value{"a": {Type: resp.BULK_STRING, BulkString: "1"}}},
+			{Data: map[string]resp.Value{"b": {Type: resp.BULK_STRING, BulkString: "2"}}},
+		},
+		BloomFilter: &legacyBloomFilter{M: 64, K: 3, Filter: make([]uint32, 64)},
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(legacy); err != nil {
+		t.Fatal(err)
+	}
+	dump := filepath.Join(t.TempDir(), "dump.rdb")
+	if err := os.WriteFile(dump, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewStoreWithFile(4, 4096, dump)
+	if err != nil {
+		t.Fatalf("loading a legacy snapshot: %v", err)
+	}
+	defer s.Close()
+	for key, want := range map[string]string{"a": "1", "b": "2"} {
+		if v, ok, err := s.Get(key); err != nil || !ok || v.BulkString != want {
+			t.Errorf("Get(%q) = %v, %v, %v, want %q", key, v, ok, err, want)
+		}
+	}
+}
+
+// TestLoadUnreadableSnapshot checks that a snapshot which cannot be decoded
+// stops the store from starting and is left as it was.
+func TestLoadUnreadableSnapshot(t *testing.T) {
+	dump := filepath.Join(t.TempDir(), "dump.rdb")
+	garbage := []byte("not a snapshot")
+	if err := os.WriteFile(dump, garbage, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := NewStoreWithFile(4, 4096, dump); err == nil {
+		s.Close()
+		t.Fatal("loading an unreadable snapshot succeeded")
+	}
+	if data, err := os.ReadFile(dump); err != nil || !bytes.Equal(data, garbage) {
+		t.Errorf("snapshot changed to %q, %v", data, err)
+	}
+
+	s, err := NewStoreWithFile(4, 4096, filepath.Join(t.TempDir(), "missing.rdb"))
+	if err != nil {
+		t.Fatalf("loading a missing snapshot: %v", err)
+	}
+	s.Close()
+}
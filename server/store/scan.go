@@ -0,0 +1,181 @@
+package store
+
+import (
+	"hash/maphash"
+	"math"
+	"math/bits"
+)
+
+// keyTable spreads keys over a power-of-two number of buckets, giving SCAN
+// positions that stay meaningful while keys come and go. Every shard keeps
+// one in step with its Data through put and remove, and a hash one with
+// its fields once it has been scanned.
+type keyTable struct {
+	buckets [][]string
+	count   int
+}
+
+const minKeyTableSize = 4
+
+var keyTableSeed = maphash.MakeSeed()
+
+func (t *keyTable) bucket(key string) uint64 {
+	return maphash.String(keyTableSeed, key) & uint64(len(t.buckets)-1)
+}
+
+func (t *keyTable) add(key string) {
+	if t.buckets == nil {
+		t.buckets = make([][]string, minKeyTableSize)
+	}
+	t.count++
+	if t.count > len(t.buckets) {
+		t.resize(len(t.buckets) * 2)
+	}
+	i := t.bucket(key)
+	t.buckets[i] = append(t.buckets[i], key)
+}
+
+func (t *keyTable) remove(key string) {
+	if t.buckets == nil {
+		return
+	}
+	i := t.bucket(key)
+	b := t.buckets[i]
+	for j := range b {
+		if b[j] == key {
+			b[j] = b[len(b)-1]
+			t.buckets[i] = b[:len(b)-1]
+			t.count--
+			break
+		}
+	}
+	if len(t.buckets) > minKeyTableSize && t.count < len(t.buckets)/8 {
+		t.resize(len(t.buckets) / 2)
+	}
+}
+
+func (t *keyTable) resize(size int) {
+	old := t.buckets
+	t.buckets = make([][]string, size)
+	for _, b := range old {
+		for _, key := range b {
+			i := t.bucket(key)
+			t.buckets[i] = append(t.buckets[i], key)
+		}
+	}
+}
+
+// scan passes every key in the bucket at cursor to fn and returns the
+// cursor of the next bucket, 0 once the table has been walked.
+//
+// As in Redis, the cursor counts with its bits reversed. A bucket's keys
+// land, after the table doubles, in the two buckets that share its low
+// bits, and those come next in reversed order; after the table halves they
+// are merged into a bucket not yet visited. So a key present for the whole
+// walk is never skipped, though after a resize it may be seen twice.
+func (t *keyTable) scan(cursor uint64, fn func(key string)) uint64 {
+	if len(t.buckets) == 0 {
+		return 0
+	}
+	mask := uint64(len(t.buckets) - 1)
+	for _, key := range t.buckets[cursor&mask] {
+		fn(key)
+	}
+
+	cursor |= ^mask
+	cursor = bits.Reverse64(cursor)
+	cursor++
+	return bits.Reverse64(cursor)
+}
+
+// scanIterations is how many buckets a page of count keys may walk. Empty
+// buckets cost an iteration each, up to ten per key asked for.
+func scanIterations(count int) int {
+	if count > math.MaxInt/10 {
+		return math.MaxInt
+	}
+	return count * 10
+}
+
+// Scan returns a page of the keys matching matchPattern and, when keyType
+// is set, holding that type. The cursor keeps the shard in its low part,
+// modulo the shard count, and the shard's bucket cursor above it; 0 starts
+// and ends a walk. As in Redis, count buckets' worth of keys are examined
+// before the filters apply, so a page may come back short or even empty.
+func (s *store) Scan(cursor uint64, matchPattern, keyType string, count int) (uint64, []string) {
+	if matchPattern == "" {
+		matchPattern = "*"
+	}
+	glob := CompileGlob(matchPattern)
+
+	n := uint64(len(s.Shards))
+	shardIdx, bucket := cursor%n, cursor/n
+	if count < 1 {
+		count = 1
+	}
+
+	var keys []string
+	now := nowMillis()
+	visited, iterations := 0, scanIterations(count)
+	for shardIdx < n && visited < count && iterations > 0 {
+		sh := &s.Shards[shardIdx]
+		emit := func(key string) {
+			visited++
+			e := sh.Data[key]
+			if e.expired(now) || !glob.Match(key) ||
+				keyType != "" && e.typeName() != keyType {
+				return
+			}
+			keys = append(keys, key)
+		}
+
+		sh.mu.RLock()
+		for {
+			bucket = sh.table.scan(bucket, emit)
+			iterations--
+			if bucket == 0 || visited >= count || iterations == 0 {
+				break
+			}
+		}
+		sh.mu.RUnlock()
+
+		if bucket == 0 {
+			shardIdx++
+		}
+	}
+
+	if shardIdx == n {
+		return 0, keys
+	}
+	return bucket*n + shardIdx, keys
+}
+
+// Keys returns every live key matching pattern. A pattern without
+// wildcards is looked up directly rather than matched against every key.
+func (s *store) Keys(pattern string) []string {
+	glob := CompileGlob(pattern)
+	now := nowMillis()
+
+	if key, ok := glob.Literal(); ok {
+		sh := s.getShard(key)
+		sh.mu.RLock()
+		defer sh.mu.RUnlock()
+		if e, found := sh.Data[key]; found && !e.expired(now) {
+			return []string{key}
+		}
+		return nil
+	}
+
+	var keys []string
+	for i := range s.Shards {
+		sh := &s.Shards[i]
+		sh.mu.RLock()
+		for key, e := range sh.Data {
+			if !e.expired(now) && glob.Match(key) {
+				keys = append(keys, key)
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	return keys
+}
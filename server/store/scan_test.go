@@ -0,0 +1,58 @@
+package store
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+// TestKeyTableScan walks a key table one bucket at a time while keys are
+// added and removed, growing and shrinking it, and checks that every key
+// present for the whole walk is seen.
+func TestKeyTableScan(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		var table keyTable
+		stable := make(map[string]bool)
+		for i := 0; i < 200; i++ {
+			key := fmt.Sprintf("stable:%d", i)
+			table.add(key)
+			stable[key] = true
+		}
+
+		var churn []string
+		seen := make(map[string]bool)
+		cursor := uint64(0)
+		for steps := 0; ; steps++ {
+			cursor = table.scan(cursor, func(key string) { seen[key] = true })
+			if cursor == 0 {
+				break
+			}
+			if steps > 100000 {
+				t.Fatalf("scan did not terminate")
+			}
+
+			// Swing the table size up and down between steps.
+			if rand.IntN(2) == 0 {
+				for i := 0; i < rand.IntN(400); i++ {
+					key := fmt.Sprintf("churn:%d:%d", steps, i)
+					table.add(key)
+					churn = append(churn, key)
+				}
+			} else {
+				for len(churn) > 0 && rand.IntN(20) != 0 {
+					table.remove(churn[len(churn)-1])
+					churn = churn[:len(churn)-1]
+				}
+			}
+		}
+
+		for key := range stable {
+			if !seen[key] {
+				t.Fatalf("round %d: %s was never returned", round, key)
+			}
+		}
+		if table.count != len(stable)+len(churn) {
+			t.Fatalf("count = %d, want %d", table.count, len(stable)+len(churn))
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package store
+
+import (
+	"math/rand/v2"
+	"slices"
+	"sort"
+)
+
+// SetOp is the set algebra operation applied by SCombine.
+type SetOp int
+
+const (
+	SetInter SetOp = iota
+	SetUnion
+	SetDiff
+)
+
+// storeEvents names the keyspace event raised when SCombineStore writes the
+// result of each operation.
+var storeEvents = []string{
+	SetInter: "sinterstore",
+	SetUnion: "sunionstore",
+	SetDiff:  "sdiffstore",
+}
+
+// setObject is an unordered collection of unique members.
+type setObject map[string]struct{}
+
+func (st setObject) typeName() string { return "set" }
+
+func (st setObject) memoryUsage(samples int) int {
+	return mapHeader + estimateSize(len(st), samples, func(yield func(int) bool) {
+		for member := range st {
+			if !yield(stringHeader + mapSlot + len(member)) {
+				return
+			}
+		}
+	})
+}
+
+func newSet() setObject { return setObject{} }
+
+func (st setObject) members() []string {
+	members := make([]string, 0, len(st))
+	for member := range st {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return members
+}
+
+func (s *store) SAdd(key string, members []string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, err := lookupOrCreate(s, shard, key, newSet)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, member := range members {
+		if _, ok := st[member]; !ok {
+			st[member] = struct{}{}
+			added++
+		}
+	}
+	if added > 0 {
+		s.notify(NotifySet, "sadd", key)
+	}
+
+	return added, nil
+}
+
+// SRem removes members from the set and drops the key once it is empty.
+func (s *store) SRem(key string, members []string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, ok, err := lookupAs[setObject](shard, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	removed := 0
+	for _, member := range members {
+		if _, ok := st[member]; ok {
+			delete(st, member)
+			removed++
+		}
+	}
+	if removed > 0 {
+		s.notify(NotifySet, "srem", key)
+	}
+	if len(st) == 0 {
+		shard.remove(key)
+		s.notify(NotifyGeneric, "del", key)
+	}
+
+	return removed, nil
+}
+
+// SMIsMember reports for each of members whether it belongs to the set.
+func (s *store) SMIsMember(key string, members []string) ([]bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, _, err := lookupAs[setObject](shard, key)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]bool, len(members))
+	for i, member := range members {
+		_, found[i] = st[member]
+	}
+
+	return found, nil
+}
+
+// SMembers returns the members of the set in sorted order.
+func (s *store) SMembers(key string) ([]string, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, _, err := lookupAs[setObject](shard, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return st.members(), nil
+}
+
+func (s *store) SCard(key string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, _, err := lookupAs[setObject](shard, key)
+	return len(st), err
+}
+
+// SPop removes and returns up to count random members.
+func (s *store) SPop(key string, count int) ([]string, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, ok, err := lookupAs[setObject](shard, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	popped := make([]string, 0, min(count, len(st)))
+	for member := range st {
+		if len(popped) == count {
+			break
+		}
+		delete(st, member)
+		popped = append(popped, member)
+	}
+	if len(popped) > 0 {
+		s.notify(NotifySet, "spop", key)
+	}
+	if len(st) == 0 {
+		shard.remove(key)
+		s.notify(NotifyGeneric, "del", key)
+	}
+
+	return popped, nil
+}
+
+// SRandMember returns random members without removing them: up to count
+// distinct ones when count is positive, or exactly -count members that may
+// repeat when it is negative.
+func (s *store) SRandMember(key string, count int) ([]string, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, ok, err := lookupAs[setObject](shard, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	if count >= 0 {
+		picked := make([]string, 0, min(count, len(st)))
+		for member := range st {
+			if len(picked) == count {
+				break
+			}
+			picked = append(picked, member)
+		}
+		return picked, nil
+	}
+
+	members := st.members()
+	picked := make([]string, -count)
+	for i := range picked {
+		picked[i] = members[rand.IntN(len(members))]
+	}
+	return picked, nil
+}
+
+// SMove moves member from src to dst, atomically with respect to both keys.
+func (s *store) SMove(src, dst, member string) (bool, error) {
+	unlock := s.lockKeys(src, dst)
+	defer unlock()
+
+	srcShard, dstShard := s.getShard(src), s.getShard(dst)
+
+	from, ok, err := lookupAs[setObject](srcShard, src)
+	if err != nil || !ok {
+		return false, err
+	}
+	if _, _, err := lookupAs[setObject](dstShard, dst); err != nil {
+		return false, err
+	}
+	if _, ok := from[member]; !ok {
+		return false, nil
+	}
+
+	delete(from, member)
+	s.notify(NotifySet, "srem", src)
+	if len(from) == 0 {
+		srcShard.remove(src)
+		s.notify(NotifyGeneric, "del", src)
+	}
+
+	to, err := lookupOrCreate(s, dstShard, dst, newSet)
+	if err != nil {
+		return false, err
+	}
+	to[member] = struct{}{}
+	s.notify(NotifySet, "sadd", dst)
+
+	return true, nil
+}
+
+// SCombine applies op across the sets at keys, in order for SetDiff, and
+// returns the sorted result. Missing keys count as empty sets.
+func (s *store) SCombine(op SetOp, keys []string) ([]string, error) {
+	unlock := s.lockKeys(keys...)
+	defer unlock()
+
+	result, err := s.combineSets(op, keys)
+	if err != nil {
+		return nil, err
+	}
+	return result.members(), nil
+}
+
+// SCombineStore is SCombine writing the result to dst, which is replaced
+// whatever it held. It returns the size of the result.
+func (s *store) SCombineStore(op SetOp, dst string, keys []string) (int, error) {
+	unlock := s.lockKeys(append(slices.Clone(keys), dst)...)
+	defer unlock()
+
+	result, err := s.combineSets(op, keys)
+	if err != nil {
+		return 0, err
+	}
+
+	shard := s.getShard(dst)
+	if len(result) == 0 {
+		if _, ok := shard.lookup(dst); ok {
+			shard.remove(dst)
+			s.notify(NotifyGeneric, "del", dst)
+		}
+		return 0, nil
+	}
+	shard.put(dst, &entry{Object: result})
+	s.notify(NotifySet, storeEvents[op], dst)
+
+	return len(result), nil
+}
+
+// combineSets expects the shards of keys to be locked already. The result is
+// always a fresh set, never one of the inputs.
+func (s *store) combineSets(op SetOp, keys []string) (setObject, error) {
+	sets := make([]setObject, len(keys))
+	for i, key := range keys {
+		st, _, err := lookupAs[setObject](s.getShard(key), key)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = st
+	}
+
+	result := newSet()
+	switch op {
+	case SetUnion:
+		for _, st := range sets {
+			for member := range st {
+				result[member] = struct{}{}
+			}
+		}
+
+	case SetInter:
+		// Walk the smallest set and probe the others.
+		smallest := 0
+		for i, st := range sets {
+			if len(st) < len(sets[smallest]) {
+				smallest = i
+			}
+		}
+	members:
+		for member := range sets[smallest] {
+			for _, st := range sets {
+				if _, ok := st[member]; !ok {
+					continue members
+				}
+			}
+			result[member] = struct{}{}
+		}
+
+	case SetDiff:
+	diff:
+		for member := range sets[0] {
+			for _, st := range sets[1:] {
+				if _, ok := st[member]; ok {
+					continue diff
+				}
+			}
+			result[member] = struct{}{}
+		}
+	}
+
+	return result, nil
+}
@@ -2,29 +2,250 @@ package store
 
 import (
 	"hash/fnv"
-	"regexp"
 	"simpleKV/resp"
+	"slices"
 	"sync"
 )
 
+// entry is what a shard keeps for every key: the value itself plus the
+// bookkeeping that travels with it.
+type entry struct {
+	Value     resp.Value // payload of string keys
+	Object    object     // payload of every other type, nil for strings
+	ExpiresAt int64      // unix milliseconds, 0 when the key never expires
+
+	// Memory and eviction metadata, which is rebuilt rather than persisted.
+	size   int64  // bytes last accounted to the shard for this entry
+	access int64  // unix milliseconds of the last lookup, read atomically
+	freq   uint32 // logarithmic LFU counter, read atomically
+}
+
+// object is implemented by every non-string type a key can hold. Concrete
+// types are registered with gob in persistence.go.
+type object interface {
+	typeName() string
+	// memoryUsage estimates the bytes held by the object, extrapolating
+	// from samples elements, or measuring all of them when samples <= 0.
+	memoryUsage(samples int) int
+}
+
+// typeName is what TYPE reports for the entry.
+func (e *entry) typeName() string {
+	if e.Object != nil {
+		return e.Object.typeName()
+	}
+	return "string"
+}
+
+func (e *entry) expired(now int64) bool {
+	return e.ExpiresAt != 0 && e.ExpiresAt <= now
+}
+
 type shard struct {
 	mu   sync.RWMutex
-	Data map[string]resp.Value
+	Data map[string]*entry
+
+	// table holds the keys of Data again, in the buckets SCAN walks.
+	table keyTable
+
+	// bloom lets lookups of missing keys skip the map. It is resized, and
+	// rebuilt from Data, as the shard grows and shrinks; bloomMin is the
+	// capacity it started with and never shrinks below.
+	bloom         *CountingBloomFilter
+	bloomMin      int
+	bloomRebuilds int64
+
+	// volatile indexes the keys that carry a deadline so the active expire
+	// cycle can sample them without walking the whole shard.
+	volatile map[string]struct{}
+
+	// used is the sum of the size of every entry. Entries looked up since
+	// the last settle may have changed in place and are listed in touched.
+	used    int64
+	touched map[string]struct{}
+
+	// keyBytes is the part of used taken by keys and their entries, as
+	// opposed to the values they hold.
+	keyBytes int64
+
+	// events is the store's notifier, for the events a shard raises on its
+	// own: new keys and keys found past their deadline.
+	events *notifier
+
+	// watched holds a version counter for every key some connection
+	// WATCHes. Any change to the key bumps it.
+	watched map[string]*watchedKey
+}
+
+func newShard(events *notifier, bloomCapacity int) shard {
+	return shard{
+		events:   events,
+		bloom:    newCountingBloomFilter(bloomCapacity),
+		bloomMin: max(bloomCapacity, 1),
+		Data:     make(map[string]*entry),
+		volatile: make(map[string]struct{}),
+		touched:  make(map[string]struct{}),
+		watched:  make(map[string]*watchedKey),
+	}
 }
 
 func (s *store) getShard(key string) *shard {
+	return &s.Shards[s.shardIndex(key)]
+}
+
+func (s *store) shardIndex(key string) int {
 	hash := fnv.New32a()
 	hash.Write([]byte(key))
 
-	return &s.Shards[uint(hash.Sum32())%uint(len(s.Shards))]
+	return int(uint(hash.Sum32()) % uint(len(s.Shards)))
 }
 
-func (sh *shard) scanKeys(regex *regexp.Regexp) []string {
-	var keys []string
+// lockKeys write-locks every shard that one of keys lives in. Shards are
+// always taken in index order, and each only once, so operations spanning
+// several keys can't deadlock against each other. It returns the function
+// that releases them.
+func (s *store) lockKeys(keys ...string) func() {
+	indexes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		indexes = append(indexes, s.shardIndex(key))
+	}
+	slices.Sort(indexes)
+	indexes = slices.Compact(indexes)
+
+	for _, i := range indexes {
+		s.Shards[i].mu.Lock()
+	}
+
+	return func() {
+		for _, i := range indexes {
+			s.Shards[i].mu.Unlock()
+		}
+	}
+}
+
+// The helpers below expect the caller to hold sh.mu for writing.
+
+func (sh *shard) put(key string, e *entry) {
+	if old, ok := sh.Data[key]; !ok || old != e {
+		if ok {
+			sh.used -= old.size
+		} else {
+			sh.keyBytes += keySize(key)
+			sh.table.add(key)
+			sh.bloom.Insert(key)
+			sh.events.notify(NotifyNew, "new", key)
+		}
+		sh.used += e.size
+	}
+	if e.access == 0 {
+		e.access, e.freq = nowMillis(), lfuInitValue
+	}
+	sh.touched[key] = struct{}{}
+	sh.modified(key)
+
+	sh.Data[key] = e
+	sh.resizeBloom()
+	if e.ExpiresAt != 0 {
+		sh.volatile[key] = struct{}{}
+	} else {
+		delete(sh.volatile, key)
+	}
+}
+
+func (sh *shard) remove(key string) {
+	if e, ok := sh.Data[key]; ok {
+		sh.used -= e.size
+		sh.keyBytes -= keySize(key)
+		sh.table.remove(key)
+		sh.bloom.Remove(key)
+	}
+	delete(sh.Data, key)
+	sh.resizeBloom()
+	delete(sh.volatile, key)
+	delete(sh.touched, key)
+	sh.modified(key)
+}
+
+// resizeBloom rebuilds the bloom filter from the shard's keys when it has
+// outgrown its size or drifted from its target false positive rate.
+func (sh *shard) resizeBloom() {
+	capacity := sh.bloom.resizeTo(sh.bloomMin)
+	if capacity == 0 {
+		return
+	}
+	sh.bloom = newCountingBloomFilter(capacity)
 	for key := range sh.Data {
-		if regex != nil && regex.MatchString(key) || regex == nil {
-			keys = append(keys, key)
+		sh.bloom.Insert(key)
+	}
+	sh.bloomRebuilds++
+}
+
+func (sh *shard) setDeadline(key string, e *entry, expiresAt int64) {
+	e.ExpiresAt = expiresAt
+	if expiresAt != 0 {
+		sh.volatile[key] = struct{}{}
+	} else {
+		delete(sh.volatile, key)
+	}
+}
+
+// lookup returns the live entry for key, dropping it first if its deadline
+// has already passed. The entry counts as accessed, and since callers may
+// change it in place its size is settled again later.
+func (sh *shard) lookup(key string) (*entry, bool) {
+	e, ok := sh.Data[key]
+	if !ok {
+		return nil, false
+	}
+	now := nowMillis()
+	if e.expired(now) {
+		sh.remove(key)
+		sh.events.notify(NotifyExpired, "expired", key)
+		return nil, false
+	}
+	e.touch(now)
+	sh.touched[key] = struct{}{}
+	return e, true
+}
+
+// settle brings the size of every entry touched since the last call up to
+// date.
+func (sh *shard) settle() {
+	for key := range sh.touched {
+		if e, ok := sh.Data[key]; ok {
+			size := entrySize(key, e, memorySamples)
+			sh.used += size - e.size
+			e.size = size
 		}
 	}
-	return keys
+	clear(sh.touched)
+}
+
+// lookupAs returns the live object of type T stored at key. A missing key
+// yields the zero T and false; a key of another type yields ErrWrongType.
+func lookupAs[T object](sh *shard, key string) (T, bool, error) {
+	var zero T
+	e, ok := sh.lookup(key)
+	if !ok {
+		return zero, false, nil
+	}
+	obj, ok := e.Object.(T)
+	if !ok {
+		return zero, false, ErrWrongType
+	}
+	return obj, true, nil
+}
+
+// lookupOrCreate is lookupAs for writers: a missing key is filled with the
+// object returned by create before it is handed back.
+func lookupOrCreate[T object](s *store, sh *shard, key string, create func() T) (T, error) {
+	obj, ok, err := lookupAs[T](sh, key)
+	if err != nil || ok {
+		return obj, err
+	}
+
+	obj = create()
+	sh.put(key, &entry{Object: obj})
+
+	return obj, nil
 }
@@ -0,0 +1,251 @@
+package store
+
+import "math/rand/v2"
+
+const (
+	skipListMaxLevel = 32
+	skipListP        = 0.25
+)
+
+// skipList keeps sorted set members ordered by (score, member). Every forward
+// link also records how many nodes it skips, which turns rank lookups into a
+// single O(log n) descent.
+type skipList struct {
+	header *skipListNode
+	tail   *skipListNode
+	length int
+	level  int
+}
+
+type skipListNode struct {
+	member   string
+	score    float64
+	backward *skipListNode
+	levels   []skipListLevel
+}
+
+type skipListLevel struct {
+	forward *skipListNode
+	span    int
+}
+
+func newSkipList() *skipList {
+	return &skipList{
+		header: newSkipListNode(skipListMaxLevel, 0, ""),
+		level:  1,
+	}
+}
+
+func newSkipListNode(level int, score float64, member string) *skipListNode {
+	return &skipListNode{
+		member: member,
+		score:  score,
+		levels: make([]skipListLevel, level),
+	}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && rand.Float64() < skipListP {
+		level++
+	}
+	return level
+}
+
+// before reports whether n sorts before (score, member).
+func (n *skipListNode) before(score float64, member string) bool {
+	return n.score < score || n.score == score && n.member < member
+}
+
+// insert adds a node; the member must not be in the list already.
+func (sl *skipList) insert(score float64, member string) *skipListNode {
+	var update [skipListMaxLevel]*skipListNode
+	var rank [skipListMaxLevel]int
+
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = sl.header
+			update[i].levels[i].span = sl.length
+		}
+		sl.level = level
+	}
+
+	x = newSkipListNode(level, score, member)
+	for i := 0; i < level; i++ {
+		x.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = x
+
+		x.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < sl.level; i++ {
+		update[i].levels[i].span++
+	}
+
+	if update[0] != sl.header {
+		x.backward = update[0]
+	}
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x
+	} else {
+		sl.tail = x
+	}
+	sl.length++
+
+	return x
+}
+
+// delete removes the node holding (score, member), reporting whether it was
+// there.
+func (sl *skipList) delete(score float64, member string) bool {
+	var update [skipListMaxLevel]*skipListNode
+
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.levels[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+
+	for i := 0; i < sl.level; i++ {
+		if update[i].levels[i].forward == x {
+			update[i].levels[i].span += x.levels[i].span - 1
+			update[i].levels[i].forward = x.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x.backward
+	} else {
+		sl.tail = x.backward
+	}
+	for sl.level > 1 && sl.header.levels[sl.level-1].forward == nil {
+		sl.level--
+	}
+	sl.length--
+
+	return true
+}
+
+// first returns the lowest node, or nil when the list is empty.
+func (sl *skipList) first() *skipListNode {
+	return sl.header.levels[0].forward
+}
+
+// rank returns the 1-based position of (score, member), or 0 if it is not in
+// the list.
+func (sl *skipList) rank(score float64, member string) int {
+	rank := 0
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !(score < x.levels[i].forward.score ||
+			score == x.levels[i].forward.score && member < x.levels[i].forward.member) {
+			rank += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if x != sl.header && x.score == score && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank returns the node at the 1-based position rank, or nil.
+func (sl *skipList) byRank(rank int) *skipListNode {
+	traversed := 0
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= rank {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+// firstWhere returns the first node for which pastStart holds, assuming it
+// turns from false to true once along the list.
+func (sl *skipList) firstWhere(pastStart func(*skipListNode) bool) *skipListNode {
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !pastStart(x.levels[i].forward) {
+			x = x.levels[i].forward
+		}
+	}
+	return x.levels[0].forward
+}
+
+// lastWhere returns the last node for which beforeEnd holds, assuming it
+// turns from true to false once along the list.
+func (sl *skipList) lastWhere(beforeEnd func(*skipListNode) bool) *skipListNode {
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && beforeEnd(x.levels[i].forward) {
+			x = x.levels[i].forward
+		}
+	}
+	if x == sl.header {
+		return nil
+	}
+	return x
+}
+
+// firstInScoreRange returns the lowest node inside r, or nil.
+func (sl *skipList) firstInScoreRange(r ScoreRange) *skipListNode {
+	x := sl.firstWhere(func(n *skipListNode) bool { return r.aboveMin(n.score) })
+	if x == nil || !r.belowMax(x.score) {
+		return nil
+	}
+	return x
+}
+
+// lastInScoreRange returns the highest node inside r, or nil.
+func (sl *skipList) lastInScoreRange(r ScoreRange) *skipListNode {
+	x := sl.lastWhere(func(n *skipListNode) bool { return r.belowMax(n.score) })
+	if x == nil || !r.aboveMin(x.score) {
+		return nil
+	}
+	return x
+}
+
+// firstInLexRange returns the lowest node inside r, or nil.
+func (sl *skipList) firstInLexRange(r LexRange) *skipListNode {
+	x := sl.firstWhere(func(n *skipListNode) bool { return r.aboveMin(n.member) })
+	if x == nil || !r.belowMax(x.member) {
+		return nil
+	}
+	return x
+}
+
+// lastInLexRange returns the highest node inside r, or nil.
+func (sl *skipList) lastInLexRange(r LexRange) *skipListNode {
+	x := sl.lastWhere(func(n *skipListNode) bool { return r.belowMax(n.member) })
+	if x == nil || !r.aboveMin(x.member) {
+		return nil
+	}
+	return x
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"testing"
+)
+
+// TestSkipList checks the skip list against a sorted slice while members are
+// inserted and deleted at random.
+func TestSkipList(t *testing.T) {
+	sl := newSkipList()
+	var want []ScoredMember
+
+	less := func(a, b ScoredMember) bool {
+		return a.Score < b.Score || a.Score == b.Score && a.Member < b.Member
+	}
+
+	for i := 0; i < 2000; i++ {
+		if len(want) > 0 && rand.IntN(3) == 0 {
+			j := rand.IntN(len(want))
+			if !sl.delete(want[j].Score, want[j].Member) {
+				t.Fatalf("delete(%v) reported a missing node", want[j])
+			}
+			want = append(want[:j], want[j+1:]...)
+		} else {
+			m := ScoredMember{Member: fmt.Sprintf("m%d", i), Score: float64(rand.IntN(50))}
+			sl.insert(m.Score, m.Member)
+			want = append(want, m)
+			sort.Slice(want, func(a, b int) bool { return less(want[a], want[b]) })
+		}
+
+		if sl.length != len(want) {
+			t.Fatalf("length = %d, want %d", sl.length, len(want))
+		}
+	}
+
+	i := 0
+	for x := sl.first(); x != nil; x = x.levels[0].forward {
+		if x.member != want[i].Member || x.score != want[i].Score {
+			t.Fatalf("node %d = %s/%v, want %v", i, x.member, x.score, want[i])
+		}
+		if rank := sl.rank(x.score, x.member); rank != i+1 {
+			t.Fatalf("rank(%s) = %d, want %d", x.member, rank, i+1)
+		}
+		if n := sl.byRank(i + 1); n != x {
+			t.Fatalf("byRank(%d) returned the wrong node", i+1)
+		}
+		i++
+	}
+	if i != len(want) {
+		t.Fatalf("walked %d nodes, want %d", i, len(want))
+	}
+
+	r := ScoreRange{Min: 10, Max: 20, MinExclusive: true}
+	first, last := sl.firstInScoreRange(r), sl.lastInScoreRange(r)
+	for _, m := range want {
+		if r.aboveMin(m.Score) && r.belowMax(m.Score) {
+			if first.member != m.Member {
+				t.Fatalf("firstInScoreRange = %s, want %s", first.member, m.Member)
+			}
+			break
+		}
+	}
+	for j := len(want) - 1; j >= 0; j-- {
+		if r.aboveMin(want[j].Score) && r.belowMax(want[j].Score) {
+			if last.member != want[j].Member {
+				t.Fatalf("lastInScoreRange = %s, want %s", last.member, want[j].Member)
+			}
+			break
+		}
+	}
+}
@@ -1,50 +1,203 @@
 package store
 
 import (
+	"errors"
 	"fmt"
-	"regexp"
+	"io/fs"
 	"simpleKV/resp"
-	"strings"
 	"sync"
 	"time"
 )
 
+var (
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+)
+
 type IStore interface {
 	Set(key string, value resp.Value)
-	Get(key string) (resp.Value, bool)
+	SetWithOptions(key string, value resp.Value, opts SetOptions) (prev resp.Value, hadPrev bool, written bool, err error)
+	Get(key string) (resp.Value, bool, error)
 	Del(key string) bool
-	Scan(cursor int, matchPattern string, count int) resp.Value
+	MGet(keys []string) []resp.Value
+	MSet(keys []string, values []resp.Value)
+	MSetNX(keys []string, values []resp.Value) bool
+	Exists(keys []string) int
+	Touch(keys []string) int
+	Type(key string) string
+	Rename(src, dst string, nx bool) (bool, error)
+	Copy(src, dst string, replace bool) (bool, error)
+	RandomKey() (string, bool)
+	DBSize() int
+	IncrBy(key string, delta int64) (int64, error)
+	IncrByFloat(key string, delta float64) (float64, error)
+	Append(key, value string) (int, error)
+	StrLen(key string) (int, error)
+	GetRange(key string, start, end int) (string, error)
+	SetRange(key string, offset int, value string) (int, error)
+	GetDel(key string) (resp.Value, bool, error)
+	GetEx(key string, deadline time.Time, persist bool) (resp.Value, bool, error)
+	LCS(key1, key2 string) (string, []LCSMatch, error)
+	SetBit(key string, offset int64, bit int) (int, error)
+	GetBit(key string, offset int64) (int, error)
+	BitCount(key string, r BitRange) (int, error)
+	BitPos(key string, bit int, r BitRange) (int, error)
+	BitOp(op BitOp, dst string, keys []string) (int, error)
+	BitField(key string, ops []BitFieldOp) ([]*int64, error)
+	Expire(key string, deadline time.Time, cond ExpireCondition) bool
+	Persist(key string) bool
+	PTTL(key string) int64
+	Scan(cursor uint64, matchPattern, keyType string, count int) (next uint64, keys []string)
+	Keys(pattern string) []string
+	HSet(key string, fieldValues []string) (int, error)
+	HSetNX(key, field, value string) (bool, error)
+	HGet(key, field string) (string, bool, error)
+	HMGet(key string, fields []string) ([]resp.Value, error)
+	HDel(key string, fields []string) (int, error)
+	HGetAll(key string) (map[string]string, error)
+	HExists(key, field string) (bool, error)
+	HLen(key string) (int, error)
+	HIncrBy(key, field string, delta int64) (int64, error)
+	HIncrByFloat(key, field string, delta float64) (float64, error)
+	HScan(key string, cursor int, matchPattern string, count int) (next int, fieldValues []string, err error)
+	ListPush(key string, side ListSide, values []string, onlyIfExists bool) (int, error)
+	ListPop(key string, side ListSide, count int) ([]string, error)
+	LLen(key string) (int, error)
+	LRange(key string, start, stop int) ([]string, error)
+	LIndex(key string, index int) (string, bool, error)
+	LSet(key string, index int, value string) error
+	LRem(key string, count int, value string) (int, error)
+	LTrim(key string, start, stop int) error
+	LInsert(key string, before bool, pivot, value string) (int, error)
+	LMove(src, dst string, from, to ListSide) (string, bool, error)
+	SAdd(key string, members []string) (int, error)
+	SRem(key string, members []string) (int, error)
+	SMIsMember(key string, members []string) ([]bool, error)
+	SMembers(key string) ([]string, error)
+	SCard(key string) (int, error)
+	SPop(key string, count int) ([]string, error)
+	SRandMember(key string, count int) ([]string, error)
+	SMove(src, dst, member string) (bool, error)
+	SCombine(op SetOp, keys []string) ([]string, error)
+	SCombineStore(op SetOp, dst string, keys []string) (int, error)
+	ZAdd(key string, members []ScoredMember, opts ZAddOptions) (int, error)
+	ZIncrBy(key, member string, delta float64, opts ZAddOptions) (float64, bool, error)
+	ZRem(key string, members []string) (int, error)
+	ZMScore(key string, members []string) ([]*float64, error)
+	ZCard(key string) (int, error)
+	ZRank(key, member string, reverse bool) (int, bool, error)
+	ZCount(key string, r ScoreRange) (int, error)
+	ZLexCount(key string, r LexRange) (int, error)
+	ZRange(key string, spec ZRangeSpec) ([]ScoredMember, error)
+	ZPop(key string, count int, highest bool) ([]ScoredMember, error)
+	XAdd(key string, args XAddArgs, fields []string) (StreamID, bool, error)
+	XLen(key string) (int, error)
+	XRange(key string, start, end StreamID, count int, rev bool) ([]StreamEntry, error)
+	XDel(key string, ids []StreamID) (int, error)
+	XTrim(key string, args XTrimArgs) (int, error)
+	XLastID(key string) (StreamID, error)
+	XRead(reads []StreamRead, count int) ([][]StreamEntry, error)
+	XGroupCreate(key, group string, id StreamID, fromLast, mkStream bool) error
+	XGroupSetID(key, group string, id StreamID, fromLast bool) error
+	XGroupDestroy(key, group string) (bool, error)
+	XGroupCreateConsumer(key, group, consumer string) (bool, error)
+	XGroupDelConsumer(key, group, consumer string) (int, error)
+	XReadGroup(group, consumer string, reads []StreamRead, count int, noAck bool) ([][]StreamEntry, error)
+	XAck(key, group string, ids []StreamID) (int, error)
+	XPendingSummary(key, group string) (PendingSummary, error)
+	XPending(key, group string, start, end StreamID, count int, consumer string, minIdle time.Duration) ([]PendingEntry, error)
+	XClaim(key, group, consumer string, minIdle time.Duration, ids []StreamID, opts XClaimOptions) ([]StreamEntry, error)
+	PFAdd(key string, elements []string) (bool, error)
+	PFCount(keys []string) (uint64, error)
+	PFMerge(dst string, keys []string) error
+	BFReserve(key string, opts BloomOptions) error
+	BFAdd(key string, items []string) ([]bool, error)
+	BFExists(key string, items []string) ([]bool, error)
+	BFInfo(key string) (BloomInfo, error)
+	CFReserve(key string, opts CuckooOptions) error
+	CFAdd(key, item string, nx bool) (bool, error)
+	CFExists(key string, items []string) ([]bool, error)
+	CFCount(key, item string) (int, error)
+	CFDel(key, item string) (bool, error)
+	CFInfo(key string) (CuckooInfo, error)
+	CMSInitByDim(key string, width, depth int) error
+	CMSIncrBy(key string, items []string, increments []int64) ([]int64, error)
+	CMSQuery(key string, items []string) ([]int64, error)
+	CMSMerge(dst string, keys []string, weights []int64) error
+	TopKReserve(key string, opts TopKOptions) error
+	TopKAdd(key string, items []string) ([]*string, error)
+	TopKList(key string) ([]TopKItem, error)
+	TDigestCreate(key string, compression float64) error
+	TDigestAdd(key string, values []float64) error
+	TDigestQuantile(key string, qs []float64) ([]float64, error)
+	TDigestCDF(key string, values []float64) ([]float64, error)
+	TDigestMerge(dst string, keys []string, compression float64, override bool) error
+	GeoSearch(key string, q GeoQuery) ([]GeoResult, error)
+	GeoSearchStore(dst, src string, q GeoQuery, storeDist bool, unit float64) (int, error)
+	EvictionConfig() EvictionConfig
+	SetEvictionConfig(c EvictionConfig)
+	UsedMemory() int64
+	MemoryUsage(key string, samples int) (int64, bool)
+	MemoryStats() MemoryStats
+	FreeMemory() error
+	NotifyFlags() NotifyFlags
+	SetNotifyFlags(flags NotifyFlags)
+	SetNotifier(fn Notifier)
+	Watch(key string) uint64
+	Unwatch(key string)
+	Version(key string) uint64
 	SaveToDisk() error
 	LoadFromDisk() error
+	Close()
 }
 
 type store struct {
-	Shards      []shard
-	BloomFilter *CountingBloomFilter
+	Shards []shard
 
 	mu              sync.Mutex
 	persistenceFile string
+
+	evictionMu sync.RWMutex
+	eviction   EvictionConfig
+
+	events notifier
+
+	done      chan struct{} // closed to stop the background saves and expiry
+	closeOnce sync.Once
 }
 
-func NewStore(numShards int, bloomSize uint32) IStore {
-	shards := make([]shard, numShards)
-	for i := range shards {
-		shards[i] = shard{
-			Data: make(map[string]resp.Value),
-		}
-	}
+// NewStore returns a store loaded from dump.rdb, which it saves snapshots to
+// every minute. A missing file starts the store empty; one that cannot be
+// read is an error, so that it is never overwritten by the next snapshot.
+func NewStore(numShards int, bloomSize uint32) (IStore, error) {
+	return NewStoreWithFile(numShards, bloomSize, "dump.rdb")
+}
+
+// NewStoreWithFile is NewStore loading from, and saving snapshots to,
+// persistenceFile rather than dump.rdb.
+func NewStoreWithFile(numShards int, bloomSize uint32, persistenceFile string) (IStore, error) {
 	newStore := &store{
-		Shards:          shards,
-		BloomFilter:     NewCountingBloomFilter(bloomSize, 3),
+		Shards:          make([]shard, numShards),
 		mu:              sync.Mutex{},
-		persistenceFile: "dump.rdb",
+		persistenceFile: persistenceFile,
+		eviction:        defaultEvictionConfig,
+		done:            make(chan struct{}),
+	}
+	for i := range newStore.Shards {
+		newStore.Shards[i] = newShard(&newStore.events, int(float64(bloomSize)/bloomCountersPerKey))
 	}
 
-	newStore.LoadFromDisk()
+	err := newStore.LoadFromDisk()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
 
 	go func() {
 		for {
-			time.Sleep(60 * time.Second)
+			select {
+			case <-newStore.done:
+				return
+			case <-time.After(60 * time.Second):
+			}
 
 			err := newStore.SaveToDisk()
 			if err != nil {
@@ -53,97 +206,83 @@ func NewStore(numShards int, bloomSize uint32) IStore {
 		}
 	}()
 
-	return newStore
+	go func() {
+		for {
+			select {
+			case <-newStore.done:
+				return
+			case <-time.After(activeExpireInterval):
+			}
+
+			newStore.activeExpireCycle()
+		}
+	}()
+
+	return newStore, nil
 }
 
 func (s *store) Set(key string, value resp.Value) {
+	s.set(key, &entry{Value: value})
+}
+
+func (s *store) set(key string, e *entry) {
 	shard := s.getShard(key)
 
 	shard.mu.Lock()
 	defer shard.mu.Unlock()
 
-	shard.Data[key] = value
-
-	s.BloomFilter.Insert(key)
+	shard.put(key, e)
+	s.notify(NotifyString, "set", key)
 }
 
-func (s *store) Get(key string) (resp.Value, bool) {
-	if !s.BloomFilter.MightContain(key) {
-		return resp.Value{}, false
-	}
-
+func (s *store) Get(key string) (resp.Value, bool, error) {
 	shard := s.getShard(key)
 
 	shard.mu.RLock()
-	defer shard.mu.RUnlock()
-
-	val, ok := shard.Data[key]
+	if !shard.bloom.MightContain(key) {
+		shard.mu.RUnlock()
+		return resp.Value{}, false, nil
+	}
+	e, ok := shard.Data[key]
+	if now := nowMillis(); ok && !e.expired(now) {
+		e.touch(now)
+		val, obj := e.Value, e.Object
+		shard.mu.RUnlock()
+		if obj != nil {
+			return resp.Value{}, false, ErrWrongType
+		}
+		return asBulk(val), true, nil
+	}
+	shard.mu.RUnlock()
+
+	if ok {
+		// The key is past its deadline; retake the lock for writing so it can
+		// be dropped now rather than waiting for the active expire cycle.
+		shard.mu.Lock()
+		shard.lookup(key)
+		shard.mu.Unlock()
+	}
 
-	return val, ok
+	return resp.Value{}, false, nil
 }
 
 func (s *store) Del(key string) bool {
-	if !s.BloomFilter.MightContain(key) {
-		return false
-	}
-
 	shard := s.getShard(key)
 
 	shard.mu.Lock()
 	defer shard.mu.Unlock()
 
-	if _, ok := shard.Data[key]; ok {
-		delete(shard.Data, key)
-		return true
-	}
-
-	return false
-}
-
-func (s *store) Scan(startIdx int, matchPattern string, count int) resp.Value {
-	var allKeys []string
-
-	var regex *regexp.Regexp
-	var err error
-	if matchPattern != "" {
-		escapedPattern := strings.Replace(matchPattern, "*", ".*", -1)
-		escapedPattern = strings.Replace(escapedPattern, "?", ".", -1)
-		regex, err = regexp.Compile("^" + escapedPattern + "$")
-		if err != nil {
-			return resp.Value{
-				Type:   resp.SIMPLE_ERROR,
-				String: fmt.Sprintf("Invalid pattern: %v", err),
-			}
-		}
-	}
-
-	for i := range s.Shards {
-		shardKeys := s.Shards[i].scanKeys(regex)
-		allKeys = append(allKeys, shardKeys...)
+	if !shard.bloom.MightContain(key) {
+		return false
 	}
 
-	endIdx := min(startIdx + count, len(allKeys))
-
-	resultKeys := allKeys[startIdx:endIdx]
-
-	newCursor := "0"
-	if endIdx < len(allKeys) {
-		newCursor = fmt.Sprintf("%d", endIdx)
+	if _, ok := shard.lookup(key); ok {
+		shard.remove(key)
+		s.notify(NotifyGeneric, "del", key)
+		return true
 	}
 
-	return resp.Value{
-		Type: resp.ARRAY,
-		Array: []resp.Value{
-			{
-				Type:   resp.SIMPLE_STRING,
-				String: newCursor,
-			},
-			{
-				Type:  resp.ARRAY,
-				Array: createBulkStringArray(resultKeys),
-			},
-		},
-	}
+	return false
 }
 
 func (s *store) SaveToDisk() error {
@@ -160,13 +299,8 @@ func (s *store) LoadFromDisk() error {
 	return s.loadSnapshot()
 }
 
-func createBulkStringArray(keys []string) []resp.Value {
-	var arr []resp.Value
-	for _, key := range keys {
-		arr = append(arr, resp.Value{
-			Type:       resp.BULK_STRING,
-			BulkString: key,
-		})
-	}
-	return arr
+// Close stops the periodic snapshots and active expiry. The store can still
+// be used, with keys expiring lazily.
+func (s *store) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
 }
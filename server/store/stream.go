@@ -0,0 +1,857 @@
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrStreamIDTooSmall  = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	ErrStreamIDZero      = errors.New("ERR The ID specified in XADD must be greater than 0-0")
+	ErrStreamIDExhausted = errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+	ErrInvalidStreamID   = errors.New("ERR Invalid stream ID specified as stream command argument")
+	ErrBusyGroup         = errors.New("BUSYGROUP Consumer Group name already exists")
+	ErrGroupNeedsKey     = errors.New("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+)
+
+func errNoGroup(key, group string) error {
+	return fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+}
+
+// StreamID identifies a stream entry: the millisecond time it was added at
+// and a sequence number within that millisecond.
+type StreamID struct {
+	Ms, Seq uint64
+}
+
+var MaxStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+func (id StreamID) String() string {
+	return fmt.Sprintf("%d-%d", id.Ms, id.Seq)
+}
+
+func (id StreamID) Less(other StreamID) bool {
+	return id.Ms < other.Ms || id.Ms == other.Ms && id.Seq < other.Seq
+}
+
+// Next returns the smallest ID greater than id, and false if there is none.
+func (id StreamID) Next() (StreamID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return StreamID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return StreamID{Ms: id.Ms + 1}, true
+	default:
+		return id, false
+	}
+}
+
+// Prev returns the largest ID smaller than id, and false if there is none.
+func (id StreamID) Prev() (StreamID, bool) {
+	switch {
+	case id.Seq > 0:
+		return StreamID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	case id.Ms > 0:
+		return StreamID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	default:
+		return id, false
+	}
+}
+
+// ParseStreamID reads "ms-seq" or a bare "ms", in which case missingSeq is
+// used as the sequence number. "-" and "+" stand for the smallest and largest
+// possible IDs.
+func ParseStreamID(str string, missingSeq uint64) (StreamID, error) {
+	switch str {
+	case "-":
+		return StreamID{}, nil
+	case "+":
+		return MaxStreamID, nil
+	}
+
+	msPart, seqPart, hasSeq := strings.Cut(str, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, ErrInvalidStreamID
+	}
+	if !hasSeq {
+		return StreamID{Ms: ms, Seq: missingSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return StreamID{}, ErrInvalidStreamID
+	}
+	return StreamID{Ms: ms, Seq: seq}, nil
+}
+
+// StreamEntry is a single stream item. Fields holds field/value pairs; it is
+// nil for entries that were deleted while still pending in a group.
+type StreamEntry struct {
+	ID     StreamID
+	Fields []string
+}
+
+// XAddArgs controls how XAdd picks the ID of a new entry and trims the
+// stream afterwards.
+type XAddArgs struct {
+	ID         StreamID
+	AutoID     bool // "*": generate the whole ID
+	AutoSeq    bool // "ms-*": generate only the sequence number
+	NoMkStream bool
+	Trim       *XTrimArgs
+}
+
+// XTrimArgs keeps either the newest MaxLen entries or those with an ID of at
+// least MinID.
+type XTrimArgs struct {
+	ByMinID bool
+	MaxLen  int
+	MinID   StreamID
+}
+
+// StreamRead is one stream of an XREAD or XREADGROUP call: entries after
+// After are read, or with New the ones never delivered to the group.
+type StreamRead struct {
+	Key   string
+	After StreamID
+	New   bool
+}
+
+// PendingEntry describes an entry delivered to a consumer group and not yet
+// acknowledged.
+type PendingEntry struct {
+	ID         StreamID
+	Consumer   string
+	Idle       time.Duration
+	Deliveries int
+}
+
+// PendingSummary is the short form of XPENDING.
+type PendingSummary struct {
+	Count           int
+	Lowest, Highest StreamID
+	Consumers       map[string]int
+}
+
+// XClaimOptions carries the optional arguments of XCLAIM.
+type XClaimOptions struct {
+	Idle       *time.Duration // set the idle time instead of resetting it
+	RetryCount *int           // set the delivery count instead of bumping it
+	Force      bool           // claim IDs that are not in the PEL yet
+	JustID     bool           // leave the delivery count alone
+}
+
+type streamObject struct {
+	Entries []StreamEntry
+	LastID  StreamID
+	Groups  map[string]*consumerGroup
+}
+
+type consumerGroup struct {
+	LastDelivered StreamID
+	Pending       map[StreamID]*pendingInfo
+	Consumers     map[string]*streamConsumer
+}
+
+type pendingInfo struct {
+	Consumer      string
+	DeliveredAt   int64 // unix milliseconds
+	DeliveryCount int
+}
+
+type streamConsumer struct {
+	SeenAt int64 // unix milliseconds
+}
+
+func (st *streamObject) typeName() string { return "stream" }
+
+func (st *streamObject) memoryUsage(samples int) int {
+	size := streamObjectSize + cap(st.Entries)*streamEntrySize +
+		estimateSize(len(st.Entries), samples, func(yield func(int) bool) {
+			for _, e := range st.Entries {
+				fields := 0
+				for _, f := range e.Fields {
+					fields += stringHeader + len(f)
+				}
+				if !yield(fields) {
+					return
+				}
+			}
+		})
+
+	for name, g := range st.Groups {
+		size += stringHeader + mapSlot + len(name) + len(g.Pending)*pendingSize
+		for consumer := range g.Consumers {
+			size += stringHeader + mapSlot + len(consumer) + pointerSize
+		}
+	}
+	return size
+}
+
+func newStream() *streamObject {
+	return &streamObject{Groups: make(map[string]*consumerGroup)}
+}
+
+// streamState is the gob form of a stream; a separate type keeps the
+// methods below from recursing into themselves.
+type streamState streamObject
+
+func (st *streamObject) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode((*streamState)(st))
+	return buf.Bytes(), err
+}
+
+// GobDecode restores the maps gob leaves out when they were saved empty.
+func (st *streamObject) GobDecode(data []byte) error {
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode((*streamState)(st))
+	if err != nil {
+		return err
+	}
+
+	if st.Groups == nil {
+		st.Groups = make(map[string]*consumerGroup)
+	}
+	for _, g := range st.Groups {
+		if g.Pending == nil {
+			g.Pending = make(map[StreamID]*pendingInfo)
+		}
+		if g.Consumers == nil {
+			g.Consumers = make(map[string]*streamConsumer)
+		}
+	}
+	return nil
+}
+
+// search returns the index of the first entry whose ID is not below id.
+func (st *streamObject) search(id StreamID) int {
+	return sort.Search(len(st.Entries), func(i int) bool { return !st.Entries[i].ID.Less(id) })
+}
+
+func (st *streamObject) entry(id StreamID) (StreamEntry, bool) {
+	i := st.search(id)
+	if i < len(st.Entries) && st.Entries[i].ID == id {
+		return st.Entries[i], true
+	}
+	return StreamEntry{}, false
+}
+
+func (st *streamObject) nextID(args XAddArgs) (StreamID, error) {
+	if args.AutoID {
+		ms := uint64(nowMillis())
+		if ms > st.LastID.Ms {
+			return StreamID{Ms: ms}, nil
+		}
+		id, ok := st.LastID.Next()
+		if !ok {
+			return StreamID{}, ErrStreamIDExhausted
+		}
+		return id, nil
+	}
+
+	id := args.ID
+	if args.AutoSeq {
+		switch {
+		case id.Ms > st.LastID.Ms:
+			id.Seq = 0
+		case id.Ms == st.LastID.Ms:
+			if st.LastID.Seq == math.MaxUint64 {
+				return StreamID{}, ErrStreamIDTooSmall
+			}
+			id.Seq = st.LastID.Seq + 1
+		default:
+			return StreamID{}, ErrStreamIDTooSmall
+		}
+	}
+
+	if id == (StreamID{}) {
+		return StreamID{}, ErrStreamIDZero
+	}
+	if !st.LastID.Less(id) {
+		return StreamID{}, ErrStreamIDTooSmall
+	}
+	return id, nil
+}
+
+func (st *streamObject) trim(args XTrimArgs) int {
+	var cut int
+	if args.ByMinID {
+		cut = st.search(args.MinID)
+	} else {
+		cut = max(len(st.Entries)-args.MaxLen, 0)
+	}
+
+	if cut == 0 {
+		return 0
+	}
+
+	// Dropping the prefix keeps an XADD with MAXLEN from copying the whole
+	// stream; append moves the rest to a new array once the old one fills.
+	// A cut larger than what is left copies the rest straight away, so the
+	// array doesn't stay pinned by a few entries.
+	rest := st.Entries[cut:]
+	if cut < len(rest) {
+		clear(st.Entries[:cut])
+		st.Entries = rest
+	} else {
+		st.Entries = slices.Clone(rest)
+	}
+	return cut
+}
+
+func (st *streamObject) rangeOf(start, end StreamID, count int, rev bool) []StreamEntry {
+	from := st.search(start)
+	to := st.search(end)
+	if to < len(st.Entries) && st.Entries[to].ID == end {
+		to++
+	}
+
+	entries := []StreamEntry{}
+	if from >= to {
+		return entries
+	}
+	if rev {
+		for i := to - 1; i >= from && (count < 0 || len(entries) < count); i-- {
+			entries = append(entries, st.Entries[i])
+		}
+		return entries
+	}
+	for i := from; i < to && (count < 0 || len(entries) < count); i++ {
+		entries = append(entries, st.Entries[i])
+	}
+	return entries
+}
+
+// after returns up to count entries with an ID greater than id.
+func (st *streamObject) after(id StreamID, count int) []StreamEntry {
+	next, ok := id.Next()
+	if !ok {
+		return []StreamEntry{}
+	}
+	return st.rangeOf(next, MaxStreamID, count, false)
+}
+
+func (g *consumerGroup) consumer(name string, now int64) *streamConsumer {
+	c, ok := g.Consumers[name]
+	if !ok {
+		c = &streamConsumer{}
+		g.Consumers[name] = c
+	}
+	c.SeenAt = now
+	return c
+}
+
+// XAdd appends an entry and returns its ID. It reports false, adding nothing,
+// when the stream is missing and args.NoMkStream is set.
+func (s *store) XAdd(key string, args XAddArgs, fields []string) (StreamID, bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, ok, err := lookupAs[*streamObject](shard, key)
+	if err != nil {
+		return StreamID{}, false, err
+	}
+	if !ok {
+		if args.NoMkStream {
+			return StreamID{}, false, nil
+		}
+		st = newStream()
+	}
+
+	id, err := st.nextID(args)
+	if err != nil {
+		return StreamID{}, false, err
+	}
+
+	if !ok {
+		shard.put(key, &entry{Object: st})
+	}
+
+	st.Entries = append(st.Entries, StreamEntry{ID: id, Fields: fields})
+	st.LastID = id
+	s.notify(NotifyStream, "xadd", key)
+	if args.Trim != nil && st.trim(*args.Trim) > 0 {
+		s.notify(NotifyStream, "xtrim", key)
+	}
+
+	return id, true, nil
+}
+
+func (s *store) XLen(key string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, ok, err := lookupAs[*streamObject](shard, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return len(st.Entries), nil
+}
+
+// XRange returns up to count entries between start and end inclusive, newest
+// first when rev is set. A negative count returns them all.
+func (s *store) XRange(key string, start, end StreamID, count int, rev bool) ([]StreamEntry, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, ok, err := lookupAs[*streamObject](shard, key)
+	if err != nil || !ok {
+		return []StreamEntry{}, err
+	}
+	return st.rangeOf(start, end, count, rev), nil
+}
+
+func (s *store) XDel(key string, ids []StreamID) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, ok, err := lookupAs[*streamObject](shard, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, id := range ids {
+		i := st.search(id)
+		if i < len(st.Entries) && st.Entries[i].ID == id {
+			st.Entries = append(st.Entries[:i], st.Entries[i+1:]...)
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		s.notify(NotifyStream, "xdel", key)
+	}
+
+	return deleted, nil
+}
+
+func (s *store) XTrim(key string, args XTrimArgs) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, ok, err := lookupAs[*streamObject](shard, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	trimmed := st.trim(args)
+	if trimmed > 0 {
+		s.notify(NotifyStream, "xtrim", key)
+	}
+	return trimmed, nil
+}
+
+// XLastID returns the ID of the last entry ever added to the stream, which is
+// what "$" stands for in XREAD and XGROUP.
+func (s *store) XLastID(key string) (StreamID, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, _, err := lookupAs[*streamObject](shard, key)
+	if err != nil || st == nil {
+		return StreamID{}, err
+	}
+	return st.LastID, nil
+}
+
+// XRead returns, for each of reads, up to count entries added after its ID.
+// A negative count returns them all.
+func (s *store) XRead(reads []StreamRead, count int) ([][]StreamEntry, error) {
+	keys := make([]string, len(reads))
+	for i, r := range reads {
+		keys[i] = r.Key
+	}
+	unlock := s.lockKeys(keys...)
+	defer unlock()
+
+	results := make([][]StreamEntry, len(reads))
+	for i, r := range reads {
+		st, ok, err := lookupAs[*streamObject](s.getShard(r.Key), r.Key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			results[i] = st.after(r.After, count)
+		}
+	}
+
+	return results, nil
+}
+
+func (s *store) lookupGroup(key, group string) (*streamObject, *consumerGroup, error) {
+	st, ok, err := lookupAs[*streamObject](s.getShard(key), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok || st.Groups[group] == nil {
+		return nil, nil, errNoGroup(key, group)
+	}
+	return st, st.Groups[group], nil
+}
+
+// XGroupCreate adds a consumer group that will deliver the entries after id,
+// or only new ones when fromLast is set.
+func (s *store) XGroupCreate(key, group string, id StreamID, fromLast, mkStream bool) error {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, ok, err := lookupAs[*streamObject](shard, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if !mkStream {
+			return ErrGroupNeedsKey
+		}
+		st, err = lookupOrCreate(s, shard, key, newStream)
+		if err != nil {
+			return err
+		}
+	}
+	if _, exists := st.Groups[group]; exists {
+		return ErrBusyGroup
+	}
+
+	if fromLast {
+		id = st.LastID
+	}
+	st.Groups[group] = &consumerGroup{
+		LastDelivered: id,
+		Pending:       make(map[StreamID]*pendingInfo),
+		Consumers:     make(map[string]*streamConsumer),
+	}
+	s.notify(NotifyStream, "xgroup-create", key)
+
+	return nil
+}
+
+// XGroupSetID moves the last delivered ID of a group.
+func (s *store) XGroupSetID(key, group string, id StreamID, fromLast bool) error {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, g, err := s.lookupGroup(key, group)
+	if err != nil {
+		return err
+	}
+	if fromLast {
+		id = st.LastID
+	}
+	g.LastDelivered = id
+	s.notify(NotifyStream, "xgroup-setid", key)
+
+	return nil
+}
+
+func (s *store) XGroupDestroy(key, group string) (bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, ok, err := lookupAs[*streamObject](shard, key)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, ErrGroupNeedsKey
+	}
+	if _, exists := st.Groups[group]; !exists {
+		return false, nil
+	}
+	delete(st.Groups, group)
+	s.notify(NotifyStream, "xgroup-destroy", key)
+
+	return true, nil
+}
+
+func (s *store) XGroupCreateConsumer(key, group, consumer string) (bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	_, g, err := s.lookupGroup(key, group)
+	if err != nil {
+		return false, err
+	}
+	if _, exists := g.Consumers[consumer]; exists {
+		return false, nil
+	}
+	g.consumer(consumer, nowMillis())
+	s.notify(NotifyStream, "xgroup-createconsumer", key)
+
+	return true, nil
+}
+
+// XGroupDelConsumer removes a consumer along with its pending entries and
+// returns how many were pending.
+func (s *store) XGroupDelConsumer(key, group, consumer string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	_, g, err := s.lookupGroup(key, group)
+	if err != nil {
+		return 0, err
+	}
+	if _, exists := g.Consumers[consumer]; !exists {
+		return 0, nil
+	}
+
+	pending := 0
+	for id, p := range g.Pending {
+		if p.Consumer == consumer {
+			delete(g.Pending, id)
+			pending++
+		}
+	}
+	delete(g.Consumers, consumer)
+	s.notify(NotifyStream, "xgroup-delconsumer", key)
+
+	return pending, nil
+}
+
+// XReadGroup reads on behalf of consumer. A StreamRead with New delivers
+// entries the group has not handed out yet and records them as pending
+// (unless noAck); any other ID replays the consumer's own pending entries
+// after it.
+func (s *store) XReadGroup(group, consumer string, reads []StreamRead, count int, noAck bool) ([][]StreamEntry, error) {
+	keys := make([]string, len(reads))
+	for i, r := range reads {
+		keys[i] = r.Key
+	}
+	unlock := s.lockKeys(keys...)
+	defer unlock()
+
+	// Resolve every group first so a missing one fails the call as a whole.
+	streams := make([]*streamObject, len(reads))
+	groups := make([]*consumerGroup, len(reads))
+	for i, r := range reads {
+		st, g, err := s.lookupGroup(r.Key, group)
+		if err != nil {
+			return nil, err
+		}
+		streams[i], groups[i] = st, g
+	}
+
+	now := nowMillis()
+	results := make([][]StreamEntry, len(reads))
+	for i, r := range reads {
+		st, g := streams[i], groups[i]
+		g.consumer(consumer, now)
+
+		if !r.New {
+			results[i] = g.history(st, consumer, r.After, count)
+			continue
+		}
+
+		entries := st.after(g.LastDelivered, count)
+		for _, e := range entries {
+			g.LastDelivered = e.ID
+			if !noAck {
+				g.Pending[e.ID] = &pendingInfo{Consumer: consumer, DeliveredAt: now, DeliveryCount: 1}
+			}
+		}
+		results[i] = entries
+		if len(entries) > 0 {
+			s.getShard(r.Key).modified(r.Key)
+		}
+	}
+
+	return results, nil
+}
+
+// history returns the entries pending for consumer with an ID above after.
+func (g *consumerGroup) history(st *streamObject, consumer string, after StreamID, count int) []StreamEntry {
+	ids := g.pendingIDs()
+	entries := []StreamEntry{}
+	for _, id := range ids {
+		if count >= 0 && len(entries) == count {
+			break
+		}
+		if !after.Less(id) || g.Pending[id].Consumer != consumer {
+			continue
+		}
+		e, ok := st.entry(id)
+		if !ok {
+			e = StreamEntry{ID: id}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func (g *consumerGroup) pendingIDs() []StreamID {
+	ids := make([]StreamID, 0, len(g.Pending))
+	for id := range g.Pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Less(ids[j]) })
+	return ids
+}
+
+// XAck acknowledges ids, removing them from the group's pending entries.
+func (s *store) XAck(key, group string, ids []StreamID) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	_, g, err := s.lookupGroup(key, group)
+	if err != nil {
+		if err == ErrWrongType {
+			return 0, err
+		}
+		return 0, nil
+	}
+
+	acked := 0
+	for _, id := range ids {
+		if _, ok := g.Pending[id]; ok {
+			delete(g.Pending, id)
+			acked++
+		}
+	}
+	if acked > 0 {
+		shard.modified(key)
+	}
+
+	return acked, nil
+}
+
+// XPendingSummary returns the short form of XPENDING for a group.
+func (s *store) XPendingSummary(key, group string) (PendingSummary, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	_, g, err := s.lookupGroup(key, group)
+	if err != nil {
+		return PendingSummary{}, err
+	}
+
+	summary := PendingSummary{Consumers: make(map[string]int)}
+	ids := g.pendingIDs()
+	if len(ids) == 0 {
+		return summary, nil
+	}
+
+	summary.Count = len(ids)
+	summary.Lowest, summary.Highest = ids[0], ids[len(ids)-1]
+	for _, p := range g.Pending {
+		summary.Consumers[p.Consumer]++
+	}
+
+	return summary, nil
+}
+
+// XPending lists up to count pending entries between start and end that have
+// been idle for at least minIdle, optionally only those of one consumer.
+func (s *store) XPending(key, group string, start, end StreamID, count int, consumer string, minIdle time.Duration) ([]PendingEntry, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	_, g, err := s.lookupGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	now := nowMillis()
+	pending := []PendingEntry{}
+	for _, id := range g.pendingIDs() {
+		if len(pending) == count {
+			break
+		}
+		p := g.Pending[id]
+		idle := time.Duration(now-p.DeliveredAt) * time.Millisecond
+		if id.Less(start) || end.Less(id) || consumer != "" && p.Consumer != consumer || idle < minIdle {
+			continue
+		}
+		pending = append(pending, PendingEntry{ID: id, Consumer: p.Consumer, Idle: idle, Deliveries: p.DeliveryCount})
+	}
+
+	return pending, nil
+}
+
+// XClaim hands the pending entries among ids that have been idle for at
+// least minIdle over to consumer and returns them. Pending entries whose
+// stream entry was deleted are dropped from the PEL instead.
+func (s *store) XClaim(key, group, consumer string, minIdle time.Duration, ids []StreamID, opts XClaimOptions) ([]StreamEntry, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	st, g, err := s.lookupGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	now := nowMillis()
+	g.consumer(consumer, now)
+
+	claimed := []StreamEntry{}
+	for _, id := range ids {
+		e, exists := st.entry(id)
+		p, pending := g.Pending[id]
+		if !pending {
+			if !opts.Force || !exists {
+				continue
+			}
+			p = &pendingInfo{}
+			g.Pending[id] = p
+		}
+		if !exists {
+			delete(g.Pending, id)
+			continue
+		}
+		if minIdle > 0 && time.Duration(now-p.DeliveredAt)*time.Millisecond < minIdle {
+			continue
+		}
+
+		p.Consumer = consumer
+		p.DeliveredAt = now
+		if opts.Idle != nil {
+			p.DeliveredAt = now - opts.Idle.Milliseconds()
+		}
+		switch {
+		case opts.RetryCount != nil:
+			p.DeliveryCount = *opts.RetryCount
+		case !opts.JustID:
+			p.DeliveryCount++
+		}
+
+		claimed = append(claimed, e)
+	}
+	shard.modified(key)
+
+	return claimed, nil
+}
@@ -0,0 +1,34 @@
+package store
+
+import "testing"
+
+// TestStreamTrim adds entries one at a time with a MAXLEN, as XADD does,
+// and checks that the newest ones always remain, then trims by a larger cut
+// and by a minimum ID.
+func TestStreamTrim(t *testing.T) {
+	st := &streamObject{}
+	const maxLen = 10
+	for ms := uint64(1); ms <= 1000; ms++ {
+		st.Entries = append(st.Entries, StreamEntry{ID: StreamID{Ms: ms}, Fields: []string{"n", "v"}})
+		want := 0
+		if ms > maxLen {
+			want = 1
+		}
+		if cut := st.trim(XTrimArgs{MaxLen: maxLen}); cut != want {
+			t.Fatalf("entry %d: cut %d, want %d", ms, cut, want)
+		}
+		if len(st.Entries) != min(int(ms), maxLen) || st.Entries[len(st.Entries)-1].ID.Ms != ms {
+			t.Fatalf("entry %d: %d entries, newest %v", ms, len(st.Entries), st.Entries[len(st.Entries)-1].ID)
+		}
+	}
+
+	if cut := st.trim(XTrimArgs{MaxLen: maxLen}); cut != 0 {
+		t.Errorf("trimming a stream at its MAXLEN cut %d", cut)
+	}
+	if cut := st.trim(XTrimArgs{MaxLen: 2}); cut != 8 || st.Entries[0].ID.Ms != 999 {
+		t.Errorf("MAXLEN 2: cut %d, first entry %v", cut, st.Entries[0].ID)
+	}
+	if cut := st.trim(XTrimArgs{ByMinID: true, MinID: StreamID{Ms: 1000}}); cut != 1 || len(st.Entries) != 1 {
+		t.Errorf("MINID 1000: cut %d, %d entries left", cut, len(st.Entries))
+	}
+}
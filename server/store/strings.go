@@ -0,0 +1,500 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"simpleKV/resp"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNotInteger       = errors.New("ERR value is not an integer or out of range")
+	ErrNotFloat         = errors.New("ERR value is not a valid float")
+	ErrStringTooLong    = errors.New("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+	ErrOffsetOutOfRange = errors.New("ERR offset is out of range")
+	ErrLCSTooLarge      = errors.New("ERR Insufficient memory, transient memory for LCS exceeds the limit")
+)
+
+// maxStringSize caps how large APPEND and SETRANGE may grow a value.
+const maxStringSize = 512 << 20
+
+// lcsMaxCells caps the dynamic programming table of LCS, 256MB worth.
+const lcsMaxCells = 1 << 26
+
+// SetCondition restricts SetWithOptions to missing or existing keys.
+type SetCondition int
+
+const (
+	SetAlways SetCondition = iota
+	SetNX                  // only when the key does not exist
+	SetXX                  // only when the key already exists
+)
+
+// SetOptions carries the optional parts of a SET.
+type SetOptions struct {
+	Condition SetCondition
+	Deadline  time.Time // zero leaves the key without a deadline
+	KeepTTL   bool      // keep the current deadline instead of clearing it
+	Get       bool      // the caller wants the previous value back
+}
+
+// SetWithOptions checks opts.Condition and writes value under a single shard
+// lock, so concurrent writers can't slip in between the two. It returns the
+// value key held before the call, whether there was one, and whether value was
+// written. With opts.Get a key holding another type is left alone and
+// ErrWrongType is returned, since its previous value can't be reported.
+func (s *store) SetWithOptions(key string, value resp.Value, opts SetOptions) (resp.Value, bool, bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	var prev resp.Value
+	old, hadPrev := shard.lookup(key)
+	if hadPrev {
+		if old.Object != nil && opts.Get {
+			return prev, false, false, ErrWrongType
+		}
+		prev = asBulk(old.Value)
+	}
+
+	if opts.Condition == SetNX && hadPrev || opts.Condition == SetXX && !hadPrev {
+		return prev, hadPrev, false, nil
+	}
+
+	e := &entry{Value: value}
+	if !opts.Deadline.IsZero() {
+		e.ExpiresAt = opts.Deadline.UnixMilli()
+	} else if opts.KeepTTL && hadPrev {
+		e.ExpiresAt = old.ExpiresAt
+	}
+
+	if e.expired(nowMillis()) {
+		// A deadline in the past still overwrites, the key just doesn't survive.
+		shard.remove(key)
+		if hadPrev {
+			s.notify(NotifyGeneric, "del", key)
+		}
+		return prev, hadPrev, true, nil
+	}
+
+	shard.put(key, e)
+	s.notify(NotifyString, "set", key)
+	if !opts.Deadline.IsZero() {
+		s.notify(NotifyGeneric, "expire", key)
+	}
+
+	return prev, hadPrev, true, nil
+}
+
+// MGet returns the value of each key, with a NULL for keys that are missing
+// or don't hold a string. All keys are read under one set of shard locks,
+// so the reply is a consistent snapshot.
+func (s *store) MGet(keys []string) []resp.Value {
+	unlock := s.lockKeys(keys...)
+	defer unlock()
+
+	values := make([]resp.Value, len(keys))
+	for i, key := range keys {
+		e, ok := s.getShard(key).lookup(key)
+		if !ok || e.Object != nil {
+			values[i] = resp.Value{Type: resp.NULL}
+			continue
+		}
+		values[i] = asBulk(e.Value)
+	}
+	return values
+}
+
+// MSet sets each key to the value at the same index, dropping any
+// deadlines, as one atomic write.
+func (s *store) MSet(keys []string, values []resp.Value) {
+	unlock := s.lockKeys(keys...)
+	defer unlock()
+
+	s.mset(keys, values)
+}
+
+// MSetNX is MSet that writes nothing, and returns false, if any of keys
+// already exists.
+func (s *store) MSetNX(keys []string, values []resp.Value) bool {
+	unlock := s.lockKeys(keys...)
+	defer unlock()
+
+	for _, key := range keys {
+		if _, ok := s.getShard(key).lookup(key); ok {
+			return false
+		}
+	}
+
+	s.mset(keys, values)
+	return true
+}
+
+// mset expects the caller to hold the locks of every shard in keys.
+func (s *store) mset(keys []string, values []resp.Value) {
+	for i, key := range keys {
+		s.getShard(key).put(key, &entry{Value: values[i]})
+		s.notify(NotifyString, "set", key)
+	}
+}
+
+// asBulk renders a string value the way clients see it. Counters are stored
+// as resp.INTEGER but read back as bulk strings.
+func asBulk(v resp.Value) resp.Value {
+	if v.Type == resp.INTEGER {
+		return resp.Value{Type: resp.BULK_STRING, BulkString: strconv.FormatInt(v.Integer, 10)}
+	}
+	return v
+}
+
+// lookupString returns the string entry at key, or nil when there is none.
+func lookupString(sh *shard, key string) (*entry, error) {
+	e, ok := sh.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	if e.Object != nil {
+		return nil, ErrWrongType
+	}
+	return e, nil
+}
+
+// IncrBy adds delta to the integer at key, starting from 0 when the key is
+// missing, and keeps the key's deadline. The result is stored as
+// resp.INTEGER so the next increment doesn't have to parse it.
+func (s *store) IncrBy(key string, delta int64) (int64, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e, err := lookupString(shard, key)
+	if err != nil {
+		return 0, err
+	}
+
+	var current int64
+	if e != nil {
+		switch e.Value.Type {
+		case resp.INTEGER:
+			current = e.Value.Integer
+		default:
+			current, err = strconv.ParseInt(e.Value.BulkString, 10, 64)
+			if err != nil {
+				return 0, ErrNotInteger
+			}
+		}
+	}
+
+	if delta > 0 && current > math.MaxInt64-delta || delta < 0 && current < math.MinInt64-delta {
+		return 0, ErrOverflow
+	}
+	current += delta
+
+	if e == nil {
+		shard.put(key, &entry{Value: resp.NewIntegerValue(current)})
+	} else {
+		e.Value = resp.NewIntegerValue(current)
+	}
+	s.notify(NotifyString, "incrby", key)
+
+	return current, nil
+}
+
+// IncrByFloat adds delta to the number at key the way IncrBy does, but
+// stores the result as a string since it need not be an integer.
+func (s *store) IncrByFloat(key string, delta float64) (float64, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e, err := lookupString(shard, key)
+	if err != nil {
+		return 0, err
+	}
+
+	var current float64
+	if e != nil {
+		current, err = strconv.ParseFloat(asBulk(e.Value).BulkString, 64)
+		if err != nil || math.IsNaN(current) || math.IsInf(current, 0) {
+			return 0, ErrNotFloat
+		}
+	}
+
+	current += delta
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return 0, ErrNaNOrInfinity
+	}
+
+	value := resp.Value{Type: resp.BULK_STRING, BulkString: strconv.FormatFloat(current, 'f', -1, 64)}
+	if e == nil {
+		shard.put(key, &entry{Value: value})
+	} else {
+		e.Value = value
+	}
+	s.notify(NotifyString, "incrbyfloat", key)
+
+	return current, nil
+}
+
+// putString stores str at key, keeping the deadline of an existing entry e.
+func (s *store) putString(sh *shard, key string, e *entry, str string) {
+	value := resp.Value{Type: resp.BULK_STRING, BulkString: str}
+	if e == nil {
+		sh.put(key, &entry{Value: value})
+		return
+	}
+	e.Value = value
+}
+
+// Append adds value to the end of the string at key, creating it if needed,
+// and returns the new length.
+func (s *store) Append(key, value string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e, err := lookupString(shard, key)
+	if err != nil {
+		return 0, err
+	}
+
+	current := ""
+	if e != nil {
+		current = asBulk(e.Value).BulkString
+	}
+	if len(current)+len(value) > maxStringSize {
+		return 0, ErrStringTooLong
+	}
+
+	s.putString(shard, key, e, current+value)
+	s.notify(NotifyString, "append", key)
+	return len(current) + len(value), nil
+}
+
+func (s *store) StrLen(key string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e, err := lookupString(shard, key)
+	if err != nil || e == nil {
+		return 0, err
+	}
+	return len(asBulk(e.Value).BulkString), nil
+}
+
+// GetRange returns the bytes of the string at key between start and end
+// inclusive. Negative offsets count from the end.
+func (s *store) GetRange(key string, start, end int) (string, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e, err := lookupString(shard, key)
+	if err != nil || e == nil {
+		return "", err
+	}
+
+	str := asBulk(e.Value).BulkString
+	if start < 0 && end < 0 && start > end {
+		return "", nil
+	}
+	if start < 0 {
+		start = max(len(str)+start, 0)
+	}
+	if end < 0 {
+		end = max(len(str)+end, 0)
+	}
+	end = min(end, len(str)-1)
+	if start > end || len(str) == 0 {
+		return "", nil
+	}
+
+	return str[start : end+1], nil
+}
+
+// SetRange overwrites the string at key from offset on with value, padding
+// it with zero bytes if it is shorter, and returns the new length. A missing
+// key is only created when value is not empty.
+func (s *store) SetRange(key string, offset int, value string) (int, error) {
+	if offset < 0 {
+		return 0, ErrOffsetOutOfRange
+	}
+
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e, err := lookupString(shard, key)
+	if err != nil {
+		return 0, err
+	}
+
+	current := ""
+	if e != nil {
+		current = asBulk(e.Value).BulkString
+	}
+	if value == "" {
+		return len(current), nil
+	}
+	if offset > maxStringSize-len(value) {
+		return 0, ErrStringTooLong
+	}
+
+	buf := []byte(current)
+	if need := offset + len(value); need > len(buf) {
+		buf = append(buf, make([]byte, need-len(buf))...)
+	}
+	copy(buf[offset:], value)
+
+	s.putString(shard, key, e, string(buf))
+	s.notify(NotifyString, "setrange", key)
+	return len(buf), nil
+}
+
+// GetDel returns the string at key and deletes it.
+func (s *store) GetDel(key string) (resp.Value, bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e, err := lookupString(shard, key)
+	if err != nil || e == nil {
+		return resp.Value{}, false, err
+	}
+
+	shard.remove(key)
+	s.notify(NotifyGeneric, "del", key)
+	return asBulk(e.Value), true, nil
+}
+
+// GetEx returns the string at key and gives it a new deadline, or removes its
+// deadline when persist is set. A zero deadline without persist leaves the key
+// as it is.
+func (s *store) GetEx(key string, deadline time.Time, persist bool) (resp.Value, bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e, err := lookupString(shard, key)
+	if err != nil || e == nil {
+		return resp.Value{}, false, err
+	}
+
+	switch {
+	case persist:
+		shard.setDeadline(key, e, 0)
+		s.notify(NotifyGeneric, "persist", key)
+	case !deadline.IsZero():
+		shard.setDeadline(key, e, deadline.UnixMilli())
+		if e.expired(nowMillis()) {
+			shard.remove(key)
+			s.notify(NotifyGeneric, "del", key)
+		} else {
+			s.notify(NotifyGeneric, "expire", key)
+		}
+	}
+
+	return asBulk(e.Value), true, nil
+}
+
+// LCSMatch is one contiguous run shared by the two strings of an LCS, given
+// as inclusive byte ranges in each.
+type LCSMatch struct {
+	A, B [2]int
+}
+
+func (m LCSMatch) Len() int {
+	return m.A[1] - m.A[0] + 1
+}
+
+// LCS returns the longest common subsequence of the strings at key1 and key2
+// along with its matching runs, last run first. Missing keys count as empty
+// strings. Strings whose table would exceed lcsMaxCells are refused with
+// ErrLCSTooLarge.
+func (s *store) LCS(key1, key2 string) (string, []LCSMatch, error) {
+	unlock := s.lockKeys(key1, key2)
+	var strs [2]string
+	for i, key := range []string{key1, key2} {
+		e, err := lookupString(s.getShard(key), key)
+		if err != nil {
+			unlock()
+			return "", nil, err
+		}
+		if e != nil {
+			strs[i] = asBulk(e.Value).BulkString
+		}
+	}
+	// Strings are immutable, so the table is filled without the locks.
+	unlock()
+
+	if len(strs[0])+1 > lcsMaxCells/(len(strs[1])+1) {
+		return "", nil, ErrLCSTooLarge
+	}
+	seq, matches := longestCommonSubsequence(strs[0], strs[1])
+	return seq, matches, nil
+}
+
+// longestCommonSubsequence fills the classic dynamic programming table and
+// walks it back from the end, collecting runs that are contiguous in both
+// strings.
+func longestCommonSubsequence(a, b string) (string, []LCSMatch) {
+	width := len(b) + 1
+	table := make([]uint32, (len(a)+1)*width)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				table[i*width+j] = table[(i-1)*width+j-1] + 1
+			} else {
+				table[i*width+j] = max(table[(i-1)*width+j], table[i*width+j-1])
+			}
+		}
+	}
+
+	seq := make([]byte, table[len(a)*width+len(b)])
+	matches := []LCSMatch{}
+	var run *LCSMatch
+	i, j, k := len(a), len(b), len(seq)
+	for i > 0 && j > 0 {
+		if a[i-1] == b[j-1] {
+			k--
+			seq[k] = a[i-1]
+			if run != nil && run.A[0] == i && run.B[0] == j {
+				run.A[0], run.B[0] = i-1, j-1
+			} else {
+				if run != nil {
+					matches = append(matches, *run)
+				}
+				run = &LCSMatch{A: [2]int{i - 1, i - 1}, B: [2]int{j - 1, j - 1}}
+			}
+			i--
+			j--
+			continue
+		}
+
+		if table[(i-1)*width+j] > table[i*width+j-1] {
+			i--
+		} else {
+			j--
+		}
+		if run != nil {
+			matches = append(matches, *run)
+			run = nil
+		}
+	}
+	if run != nil {
+		matches = append(matches, *run)
+	}
+
+	return string(seq), matches
+}
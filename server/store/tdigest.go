@@ -0,0 +1,320 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"slices"
+)
+
+const tdigestDefaultCompression = 100
+
+var (
+	ErrTDigestExists   = errors.New("ERR T-Digest: key already exists")
+	ErrTDigestNotFound = errors.New("ERR T-Digest: key does not exist")
+)
+
+type centroid struct {
+	Mean, Weight float64
+}
+
+// tdigestObject is a merging t-digest. Values pile up in Unmerged until
+// there are enough of them to be worth folding into Centroids, which stay
+// sorted by mean. The k1 scale function bounds how much weight a centroid
+// may hold: little near the tails, where quantiles need to be sharp, and
+// more in the middle. Compression is the δ of the scale function, roughly
+// the number of centroids kept.
+type tdigestObject struct {
+	Compression float64
+	Centroids   []centroid
+	Unmerged    []centroid
+	Min, Max    float64
+}
+
+// Like RedisBloom's, the type name is that of the module type.
+func (t *tdigestObject) typeName() string { return "TDIS-TYPE" }
+
+func (t *tdigestObject) memoryUsage(int) int {
+	return tdigestObjectSize + centroidSize*(cap(t.Centroids)+cap(t.Unmerged))
+}
+
+func newTDigest(compression float64) *tdigestObject {
+	return &tdigestObject{Compression: compression, Min: math.Inf(1), Max: math.Inf(-1)}
+}
+
+// bufferSize is how many unmerged centroids are let in before a merge.
+func (t *tdigestObject) bufferSize() int {
+	return int(6*t.Compression) + 10
+}
+
+func (t *tdigestObject) add(c centroid, lo, hi float64) {
+	t.Unmerged = append(t.Unmerged, c)
+	t.Min, t.Max = min(t.Min, lo), max(t.Max, hi)
+	if len(t.Unmerged) >= t.bufferSize() {
+		t.compress()
+	}
+}
+
+// scale is the k1 scale function, k(q) = δ/2π · asin(2q - 1), and
+// scaleInverse its inverse.
+func (t *tdigestObject) scale(q float64) float64 {
+	return t.Compression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+func (t *tdigestObject) scaleInverse(k float64) float64 {
+	k = min(k, t.Compression/4)
+	return (math.Sin(k*2*math.Pi/t.Compression) + 1) / 2
+}
+
+// compress folds Unmerged into Centroids: all of them are sorted by mean and
+// merged left to right for as long as the merged centroid spans less than
+// one unit of the scale function.
+func (t *tdigestObject) compress() {
+	if len(t.Unmerged) == 0 {
+		return
+	}
+	all := append(t.Centroids, t.Unmerged...)
+	slices.SortFunc(all, func(a, b centroid) int { return cmp.Compare(a.Mean, b.Mean) })
+	total := 0.0
+	for _, c := range all {
+		total += c.Weight
+	}
+
+	merged := make([]centroid, 0, len(t.Centroids)+1)
+	cur := all[0]
+	before := 0.0 // weight of the centroids emitted so far
+	limit := total * t.scaleInverse(t.scale(0)+1)
+	for _, c := range all[1:] {
+		if before+cur.Weight+c.Weight <= limit {
+			cur.Weight += c.Weight
+			cur.Mean += (c.Mean - cur.Mean) * c.Weight / cur.Weight
+			continue
+		}
+		before += cur.Weight
+		merged = append(merged, cur)
+		limit = total * t.scaleInverse(t.scale(before/total)+1)
+		cur = c
+	}
+	t.Centroids = append(merged, cur)
+	t.Unmerged = t.Unmerged[:0]
+}
+
+func (t *tdigestObject) weight() float64 {
+	w := 0.0
+	for _, c := range t.Centroids {
+		w += c.Weight
+	}
+	return w
+}
+
+// quantile estimates the value below which a share q of the weight lies.
+// Each centroid's weight is taken to be spread evenly around its mean, so
+// the estimate is interpolated between neighboring means, and between the
+// outer means and Min and Max.
+func (t *tdigestObject) quantile(q float64) float64 {
+	t.compress()
+	n := len(t.Centroids)
+	switch {
+	case n == 0:
+		return math.NaN()
+	case q <= 0:
+		return t.Min
+	case q >= 1:
+		return t.Max
+	case n == 1:
+		c := t.Centroids[0]
+		if q < 0.5 {
+			return t.Min + (c.Mean-t.Min)*q*2
+		}
+		return c.Mean + (t.Max-c.Mean)*(q-0.5)*2
+	}
+
+	index := q * t.weight()
+	first := t.Centroids[0]
+	if index < first.Weight/2 {
+		return t.Min + (first.Mean-t.Min)*index/(first.Weight/2)
+	}
+
+	seen := first.Weight / 2 // weight left of the current centroid's mean
+	for i := 0; i+1 < n; i++ {
+		a, b := t.Centroids[i], t.Centroids[i+1]
+		span := (a.Weight + b.Weight) / 2
+		if index < seen+span {
+			return a.Mean + (b.Mean-a.Mean)*(index-seen)/span
+		}
+		seen += span
+	}
+
+	last := t.Centroids[n-1]
+	return last.Mean + (t.Max-last.Mean)*min((index-seen)/(last.Weight/2), 1)
+}
+
+// cdf estimates the share of the weight below x, counting half of any
+// weight at exactly x, the same way quantile interpolates.
+func (t *tdigestObject) cdf(x float64) float64 {
+	t.compress()
+	n := len(t.Centroids)
+	switch {
+	case n == 0:
+		return math.NaN()
+	case x < t.Min:
+		return 0
+	case x > t.Max:
+		return 1
+	case t.Min == t.Max:
+		return 0.5
+	}
+
+	total := t.weight()
+	first := t.Centroids[0]
+	if x < first.Mean {
+		return (x - t.Min) / (first.Mean - t.Min) * first.Weight / 2 / total
+	}
+
+	seen := first.Weight / 2
+	for i := 0; i+1 < n; i++ {
+		a, b := t.Centroids[i], t.Centroids[i+1]
+		span := (a.Weight + b.Weight) / 2
+		if x < b.Mean {
+			return (seen + span*(x-a.Mean)/(b.Mean-a.Mean)) / total
+		}
+		seen += span
+	}
+
+	last := t.Centroids[n-1]
+	if last.Mean == t.Max {
+		return seen / total
+	}
+	return (seen + last.Weight/2*(x-last.Mean)/(t.Max-last.Mean)) / total
+}
+
+// TDigestCreate creates an empty t-digest at key, with the default
+// compression when compression is 0. It fails with ErrTDigestExists if the
+// key is taken.
+func (s *store) TDigestCreate(key string, compression float64) error {
+	if compression == 0 {
+		compression = tdigestDefaultCompression
+	}
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, ok := shard.lookup(key); ok {
+		return ErrTDigestExists
+	}
+	shard.put(key, &entry{Object: newTDigest(compression)})
+	s.notify(NotifyGeneric, "tdigest.create", key)
+	return nil
+}
+
+func (s *store) TDigestAdd(key string, values []float64) error {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	t, ok, err := lookupAs[*tdigestObject](shard, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTDigestNotFound
+	}
+
+	for _, v := range values {
+		t.add(centroid{Mean: v, Weight: 1}, v, v)
+	}
+	s.notify(NotifyGeneric, "tdigest.add", key)
+	return nil
+}
+
+// TDigestQuantile estimates the value at every quantile in qs, NaN for an
+// empty digest.
+func (s *store) TDigestQuantile(key string, qs []float64) ([]float64, error) {
+	return s.tdigestRead(key, qs, (*tdigestObject).quantile)
+}
+
+// TDigestCDF estimates the share of values below every one of values, NaN
+// for an empty digest.
+func (s *store) TDigestCDF(key string, values []float64) ([]float64, error) {
+	return s.tdigestRead(key, values, (*tdigestObject).cdf)
+}
+
+// tdigestRead applies fn to every one of args. Reads fold in unmerged
+// values, so they take the shard lock for writing.
+func (s *store) tdigestRead(key string, args []float64, fn func(*tdigestObject, float64) float64) ([]float64, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	t, ok, err := lookupAs[*tdigestObject](shard, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTDigestNotFound
+	}
+
+	results := make([]float64, len(args))
+	for i, arg := range args {
+		results[i] = fn(t, arg)
+	}
+	return results, nil
+}
+
+// TDigestMerge stores at dst a digest of every value added to the digests
+// at keys, along with dst's own unless override is set or dst is missing.
+// A compression of 0 takes the largest of the merged digests'. Merging
+// adds up centroids, so the result is as accurate as a digest that saw
+// every value, up to its own compression.
+func (s *store) TDigestMerge(dst string, keys []string, compression float64, override bool) error {
+	unlock := s.lockKeys(append([]string{dst}, keys...)...)
+	defer unlock()
+
+	dstShard := s.getShard(dst)
+	target, ok, err := lookupAs[*tdigestObject](dstShard, dst)
+	if err != nil {
+		return err
+	}
+
+	var sources []*tdigestObject
+	if ok && !override {
+		sources = append(sources, target)
+	}
+	for _, key := range keys {
+		t, ok, err := lookupAs[*tdigestObject](s.getShard(key), key)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrTDigestNotFound
+		}
+		sources = append(sources, t)
+	}
+
+	if compression == 0 {
+		for _, t := range sources {
+			compression = max(compression, t.Compression)
+		}
+	}
+	merged := newTDigest(compression)
+	for _, t := range sources {
+		for _, c := range slices.Concat(t.Centroids, t.Unmerged) {
+			merged.add(c, t.Min, t.Max)
+		}
+	}
+	merged.compress()
+
+	// An existing dst keeps its deadline, as it would have had the digests
+	// been merged into it in place.
+	e := &entry{}
+	if ok {
+		e, _ = dstShard.lookup(dst)
+	}
+	e.Object = merged
+	dstShard.put(dst, e)
+	s.notify(NotifyGeneric, "tdigest.merge", dst)
+	return nil
+}
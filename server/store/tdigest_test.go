@@ -0,0 +1,70 @@
+package store
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// TestTDigest checks quantile and CDF estimates against the exact values of
+// a skewed sample, for one digest fed everything and for one merged from
+// digests that each saw a part.
+func TestTDigest(t *testing.T) {
+	values := make([]float64, 100000)
+	for i := range values {
+		values[i] = rand.ExpFloat64() * 100
+	}
+
+	whole := newTDigest(tdigestDefaultCompression)
+	parts := make([]*tdigestObject, 4)
+	for i := range parts {
+		parts[i] = newTDigest(tdigestDefaultCompression)
+	}
+	for i, v := range values {
+		whole.add(centroid{Mean: v, Weight: 1}, v, v)
+		parts[i%len(parts)].add(centroid{Mean: v, Weight: 1}, v, v)
+	}
+	merged := newTDigest(tdigestDefaultCompression)
+	for _, p := range parts {
+		for _, c := range slices.Concat(p.Centroids, p.Unmerged) {
+			merged.add(c, p.Min, p.Max)
+		}
+	}
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	for name, d := range map[string]*tdigestObject{"whole": whole, "merged": merged} {
+		if got := d.weight() + float64(len(d.Unmerged)); got != float64(len(values)) {
+			t.Errorf("%s: weight %v, want %d", name, got, len(values))
+		}
+		if len(d.Centroids) > 2*tdigestDefaultCompression {
+			t.Errorf("%s: %d centroids for compression %d", name, len(d.Centroids), tdigestDefaultCompression)
+		}
+
+		for _, q := range []float64{0.001, 0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+			want := sorted[int(q*float64(len(sorted)))]
+			got := d.quantile(q)
+			// Compare in rank rather than value, where the digest's error
+			// bound is stated, and tighter toward the tails.
+			rank, _ := slices.BinarySearch(sorted, got)
+			if err := math.Abs(float64(rank)/float64(len(sorted)) - q); err > 0.001+0.01*math.Sqrt(q*(1-q)) {
+				t.Errorf("%s: quantile(%v) = %v at rank %v, want %v", name, q, got, float64(rank)/float64(len(sorted)), want)
+			}
+			if cdf := d.cdf(want); math.Abs(cdf-q) > 0.01 {
+				t.Errorf("%s: cdf(%v) = %v, want %v", name, want, cdf, q)
+			}
+		}
+
+		if d.quantile(0) != sorted[0] || d.quantile(1) != sorted[len(sorted)-1] {
+			t.Errorf("%s: quantiles 0 and 1 are %v and %v, want the min and max", name, d.quantile(0), d.quantile(1))
+		}
+		if d.cdf(sorted[0]-1) != 0 || d.cdf(sorted[len(sorted)-1]+1) != 1 {
+			t.Errorf("%s: cdf outside the range is %v and %v", name, d.cdf(sorted[0]-1), d.cdf(sorted[len(sorted)-1]+1))
+		}
+	}
+
+	if empty := newTDigest(tdigestDefaultCompression); !math.IsNaN(empty.quantile(0.5)) || !math.IsNaN(empty.cdf(1)) {
+		t.Errorf("an empty digest gave numbers")
+	}
+}
@@ -0,0 +1,204 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// topkMaxK and topkMaxBuckets bound the list and the table of a Top-K; the
+// table may then take 512MB.
+const (
+	topkMaxK       = 100000
+	topkMaxBuckets = 1 << 26
+)
+
+var (
+	ErrTopKTooLarge = errors.New("ERR TopK: k or width * depth is too large")
+	ErrTopKExists   = errors.New("ERR TopK: key already exists")
+	ErrTopKNotFound = errors.New("ERR TopK: key does not exist")
+)
+
+// TopKOptions describe a Top-K created by TOPK.RESERVE.
+type TopKOptions struct {
+	K            int
+	Width, Depth int
+	Decay        float64 // chance, raised to a bucket's count, of decaying it
+}
+
+// TopKItem is an item of a Top-K's list with its estimated count.
+type TopKItem struct {
+	Item  string
+	Count int64
+}
+
+// heavyKeeperBucket counts the item whose fingerprint it holds.
+type heavyKeeperBucket struct {
+	Fingerprint uint32
+	Count       uint32
+}
+
+// topkObject tracks the K most frequent items with HeavyKeeper: Depth rows
+// of Width buckets, where an item that lands on a bucket held by another
+// decays that bucket's count with probability Decay^count and takes the
+// bucket over once it reaches zero. Small counts give way quickly while
+// heavy hitters hold on. The items themselves are kept in Heap, the K
+// slots with the highest counts seen.
+type topkObject struct {
+	K            int
+	Width, Depth int
+	Decay        float64
+	Buckets      []heavyKeeperBucket // row after row
+	Heap         []TopKItem          // K slots, unordered; Count 0 marks a free one
+}
+
+// Like RedisBloom's, the type name is that of the module type.
+func (t *topkObject) typeName() string { return "TopK-TYPE" }
+
+func (t *topkObject) memoryUsage(int) int {
+	size := topkObjectSize + heavyKeeperBucketSize*len(t.Buckets)
+	for _, it := range t.Heap {
+		size += topkItemSize + len(it.Item)
+	}
+	return size
+}
+
+func newTopK(opts TopKOptions) *topkObject {
+	return &topkObject{
+		K:       opts.K,
+		Width:   opts.Width,
+		Depth:   opts.Depth,
+		Decay:   opts.Decay,
+		Buckets: make([]heavyKeeperBucket, opts.Width*opts.Depth),
+		Heap:    make([]TopKItem, opts.K),
+	}
+}
+
+// add counts item and returns the item it pushed out of the list, if any.
+func (t *topkObject) add(item string) (string, bool) {
+	h := stableHash(item)
+	fp := uint32(h >> 32)
+	h1, h2 := h&math.MaxUint32, h>>32|1
+
+	var count uint32
+	for row := 0; row < t.Depth; row++ {
+		b := &t.Buckets[row*t.Width+int((h1+uint64(row)*h2)%uint64(t.Width))]
+		switch {
+		case b.Count == 0:
+			b.Fingerprint, b.Count = fp, 1
+		case b.Fingerprint == fp:
+			if b.Count < math.MaxUint32 {
+				b.Count++
+			}
+		case rand.Float64() < math.Pow(t.Decay, float64(b.Count)):
+			b.Count--
+			if b.Count == 0 {
+				b.Fingerprint, b.Count = fp, 1
+			}
+		}
+		if b.Fingerprint == fp {
+			count = max(count, b.Count)
+		}
+	}
+
+	// The list is small, so it is searched rather than kept as a heap.
+	least := 0
+	for i := range t.Heap {
+		if t.Heap[i].Count > 0 && t.Heap[i].Item == item {
+			t.Heap[i].Count = int64(count)
+			return "", false
+		}
+		if t.Heap[i].Count < t.Heap[least].Count {
+			least = i
+		}
+	}
+	if int64(count) <= t.Heap[least].Count {
+		return "", false
+	}
+	expelled := t.Heap[least]
+	t.Heap[least] = TopKItem{Item: item, Count: int64(count)}
+	return expelled.Item, expelled.Count > 0
+}
+
+// list returns the items of the list, the most frequent first.
+func (t *topkObject) list() []TopKItem {
+	var items []TopKItem
+	for _, it := range t.Heap {
+		if it.Count > 0 {
+			items = append(items, it)
+		}
+	}
+	slices.SortFunc(items, func(a, b TopKItem) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Item, b.Item)
+	})
+	return items
+}
+
+// TopKReserve creates an empty Top-K at key. It fails with ErrTopKTooLarge
+// past topkMaxK or topkMaxBuckets and with ErrTopKExists if the key is
+// taken.
+func (s *store) TopKReserve(key string, opts TopKOptions) error {
+	if opts.K < 1 || opts.K > topkMaxK || opts.Width < 1 || opts.Depth < 1 || opts.Width > topkMaxBuckets/opts.Depth {
+		return ErrTopKTooLarge
+	}
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, ok := shard.lookup(key); ok {
+		return ErrTopKExists
+	}
+	shard.put(key, &entry{Object: newTopK(opts)})
+	s.notify(NotifyGeneric, "topk.reserve", key)
+	return nil
+}
+
+// TopKAdd counts items in the Top-K at key. For every item it returns the
+// one it pushed out of the list, or nil.
+func (s *store) TopKAdd(key string, items []string) ([]*string, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	t, ok, err := lookupAs[*topkObject](shard, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTopKNotFound
+	}
+
+	expelled := make([]*string, len(items))
+	for i, item := range items {
+		if out, ok := t.add(item); ok {
+			expelled[i] = &out
+		}
+	}
+	s.notify(NotifyGeneric, "topk.add", key)
+	return expelled, nil
+}
+
+// TopKList returns the items the Top-K at key currently holds, the most
+// frequent first.
+func (s *store) TopKList(key string) ([]TopKItem, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	t, ok, err := lookupAs[*topkObject](shard, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTopKNotFound
+	}
+	return t.list(), nil
+}
@@ -0,0 +1,66 @@
+package store
+
+// watchedKey is the optimistic lock behind WATCH. Connections remember the
+// version they saw and EXEC compares it with the current one.
+type watchedKey struct {
+	version  uint64
+	watchers int
+}
+
+// modified bumps the version of key if anyone watches it. The caller holds
+// sh.mu for writing.
+func (sh *shard) modified(key string) {
+	if w, ok := sh.watched[key]; ok {
+		w.version++
+	}
+}
+
+// Watch starts tracking changes to key and returns its current version.
+// Every call must be paired with an Unwatch.
+func (s *store) Watch(key string) uint64 {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	// A key already past its deadline is dropped first, so its expiry isn't
+	// mistaken for a change made after the WATCH.
+	shard.lookup(key)
+
+	w, ok := shard.watched[key]
+	if !ok {
+		w = &watchedKey{}
+		shard.watched[key] = w
+	}
+	w.watchers++
+	return w.version
+}
+
+func (s *store) Unwatch(key string) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if w, ok := shard.watched[key]; ok {
+		w.watchers--
+		if w.watchers <= 0 {
+			delete(shard.watched, key)
+		}
+	}
+}
+
+// Version returns the current version of a watched key. A key that reached
+// its deadline since it was watched is expired now and counts as changed.
+func (s *store) Version(key string) uint64 {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.lookup(key)
+	if w, ok := shard.watched[key]; ok {
+		return w.version
+	}
+	return 0
+}
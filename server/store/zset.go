@@ -0,0 +1,521 @@
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"math"
+)
+
+var ErrScoreNaN = errors.New("ERR resulting score is not a number (NaN)")
+
+// ScoredMember is one element of a sorted set.
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// ScoreRange is an interval of scores. Min and Max may be infinite.
+type ScoreRange struct {
+	Min, Max                   float64
+	MinExclusive, MaxExclusive bool
+}
+
+func (r ScoreRange) aboveMin(score float64) bool {
+	if r.MinExclusive {
+		return score > r.Min
+	}
+	return score >= r.Min
+}
+
+func (r ScoreRange) belowMax(score float64) bool {
+	if r.MaxExclusive {
+		return score < r.Max
+	}
+	return score <= r.Max
+}
+
+// LexRange is an interval of members, only meaningful when every member of
+// the set has the same score. An unbounded side stands for "-" or "+".
+type LexRange struct {
+	Min, Max                   string
+	MinExclusive, MaxExclusive bool
+	MinUnbounded, MaxUnbounded bool
+}
+
+func (r LexRange) aboveMin(member string) bool {
+	switch {
+	case r.MinUnbounded:
+		return true
+	case r.MinExclusive:
+		return member > r.Min
+	default:
+		return member >= r.Min
+	}
+}
+
+func (r LexRange) belowMax(member string) bool {
+	switch {
+	case r.MaxUnbounded:
+		return true
+	case r.MaxExclusive:
+		return member < r.Max
+	default:
+		return member <= r.Max
+	}
+}
+
+// ZRangeBy selects how ZRangeSpec bounds are interpreted.
+type ZRangeBy int
+
+const (
+	ZRangeByRank ZRangeBy = iota
+	ZRangeByScore
+	ZRangeByLex
+)
+
+// ZRangeSpec describes a ZRANGE query. Only the bounds matching By are used.
+type ZRangeSpec struct {
+	By          ZRangeBy
+	Start, Stop int // inclusive ranks, negative ones count from the end
+	Score       ScoreRange
+	Lex         LexRange
+	Rev         bool // walk from the highest element down
+	Offset      int  // elements to skip, for ZRangeByScore and ZRangeByLex
+	Count       int  // elements to return after Offset, negative for all
+}
+
+// ZAddOptions carries the flags of ZADD.
+type ZAddOptions struct {
+	Condition SetCondition // SetNX adds only new members, SetXX updates only existing ones
+	GT, LT    bool         // only update existing members when the score goes up (GT) or down (LT)
+	CH        bool         // count changed members along with added ones
+}
+
+func (o ZAddOptions) allows(current, next float64) bool {
+	return !(o.GT && next <= current || o.LT && next >= current)
+}
+
+// zsetObject pairs a skip list, which keeps members ordered, with a map that
+// answers score lookups in O(1).
+type zsetObject struct {
+	scores map[string]float64
+	sl     *skipList
+}
+
+func (z *zsetObject) typeName() string { return "zset" }
+
+// memoryUsage counts each member once; the map key and the skip list node
+// share its bytes.
+func (z *zsetObject) memoryUsage(samples int) int {
+	return mapHeader + skipListNodeSize + skipListMaxLevel*skipListLevelSize +
+		estimateSize(z.sl.length, samples, func(yield func(int) bool) {
+			for x := z.sl.first(); x != nil; x = x.levels[0].forward {
+				node := skipListNodeSize + sliceHeader + len(x.levels)*skipListLevelSize
+				if !yield(stringHeader + 8 + mapSlot + len(x.member) + node) {
+					return
+				}
+			}
+		})
+}
+
+func newZSet() *zsetObject {
+	return &zsetObject{
+		scores: make(map[string]float64),
+		sl:     newSkipList(),
+	}
+}
+
+func (z *zsetObject) set(member string, score float64) {
+	if old, ok := z.scores[member]; ok {
+		if old == score {
+			return
+		}
+		z.sl.delete(old, member)
+	}
+	z.sl.insert(score, member)
+	z.scores[member] = score
+}
+
+func (z *zsetObject) remove(member string) bool {
+	score, ok := z.scores[member]
+	if !ok {
+		return false
+	}
+	z.sl.delete(score, member)
+	delete(z.scores, member)
+	return true
+}
+
+func (z *zsetObject) GobEncode() ([]byte, error) {
+	members := make([]ScoredMember, 0, z.sl.length)
+	for x := z.sl.first(); x != nil; x = x.levels[0].forward {
+		members = append(members, ScoredMember{Member: x.member, Score: x.score})
+	}
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(members)
+	return buf.Bytes(), err
+}
+
+func (z *zsetObject) GobDecode(data []byte) error {
+	var members []ScoredMember
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&members)
+	if err != nil {
+		return err
+	}
+
+	*z = *newZSet()
+	for _, m := range members {
+		z.set(m.Member, m.Score)
+	}
+	return nil
+}
+
+// dropIfEmptyZSet removes key once its sorted set has no members left.
+func (sh *shard) dropIfEmptyZSet(key string, z *zsetObject) {
+	if len(z.scores) == 0 {
+		sh.remove(key)
+		sh.events.notify(NotifyGeneric, "del", key)
+	}
+}
+
+// ZAdd adds or updates members following opts and returns the number of
+// members added, plus the number updated when opts.CH is set.
+func (s *store) ZAdd(key string, members []ScoredMember, opts ZAddOptions) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	z, ok, err := lookupAs[*zsetObject](shard, key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		if opts.Condition == SetXX {
+			return 0, nil
+		}
+		z, err = lookupOrCreate(s, shard, key, newZSet)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	added, changed := 0, 0
+	for _, m := range members {
+		current, exists := z.scores[m.Member]
+		switch {
+		case !exists && opts.Condition != SetXX:
+			z.set(m.Member, m.Score)
+			added++
+		case exists && opts.Condition != SetNX && current != m.Score && opts.allows(current, m.Score):
+			z.set(m.Member, m.Score)
+			changed++
+		}
+	}
+	if added+changed > 0 {
+		s.notify(NotifyZSet, "zadd", key)
+	}
+	shard.dropIfEmptyZSet(key, z)
+
+	if opts.CH {
+		return added + changed, nil
+	}
+	return added, nil
+}
+
+// ZIncrBy adds delta to the score of member, creating it with delta as score
+// if needed. It reports false when opts kept the member from changing.
+func (s *store) ZIncrBy(key, member string, delta float64, opts ZAddOptions) (float64, bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	z, ok, err := lookupAs[*zsetObject](shard, key)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok && opts.Condition == SetXX {
+		return 0, false, nil
+	}
+
+	var current float64
+	exists := false
+	if ok {
+		current, exists = z.scores[member]
+	}
+	if exists && opts.Condition == SetNX || !exists && opts.Condition == SetXX {
+		return 0, false, nil
+	}
+
+	score := current + delta
+	if math.IsNaN(score) {
+		return 0, false, ErrScoreNaN
+	}
+	if exists && !opts.allows(current, score) {
+		return 0, false, nil
+	}
+
+	if !ok {
+		z, err = lookupOrCreate(s, shard, key, newZSet)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+	z.set(member, score)
+	s.notify(NotifyZSet, "zincr", key)
+
+	return score, true, nil
+}
+
+// ZRem removes members from the sorted set and drops the key once it is
+// empty.
+func (s *store) ZRem(key string, members []string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	z, ok, err := lookupAs[*zsetObject](shard, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	removed := 0
+	for _, member := range members {
+		if z.remove(member) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		s.notify(NotifyZSet, "zrem", key)
+	}
+	shard.dropIfEmptyZSet(key, z)
+
+	return removed, nil
+}
+
+// ZMScore returns the score of each of members, with nil for missing ones.
+func (s *store) ZMScore(key string, members []string) ([]*float64, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	z, ok, err := lookupAs[*zsetObject](shard, key)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make([]*float64, len(members))
+	if !ok {
+		return scores, nil
+	}
+	for i, member := range members {
+		if score, ok := z.scores[member]; ok {
+			scores[i] = &score
+		}
+	}
+
+	return scores, nil
+}
+
+func (s *store) ZCard(key string) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	z, ok, err := lookupAs[*zsetObject](shard, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return len(z.scores), nil
+}
+
+// ZRank returns the 0-based rank of member, counted from the highest score
+// when reverse is set.
+func (s *store) ZRank(key, member string, reverse bool) (int, bool, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	z, ok, err := lookupAs[*zsetObject](shard, key)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+
+	score, ok := z.scores[member]
+	if !ok {
+		return 0, false, nil
+	}
+
+	rank := z.sl.rank(score, member)
+	if reverse {
+		return z.sl.length - rank, true, nil
+	}
+	return rank - 1, true, nil
+}
+
+// ZCount returns how many members have a score inside r.
+func (s *store) ZCount(key string, r ScoreRange) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	z, ok, err := lookupAs[*zsetObject](shard, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	first := z.sl.firstInScoreRange(r)
+	if first == nil {
+		return 0, nil
+	}
+	last := z.sl.lastInScoreRange(r)
+
+	return z.sl.rank(last.score, last.member) - z.sl.rank(first.score, first.member) + 1, nil
+}
+
+// ZLexCount returns how many members fall inside r.
+func (s *store) ZLexCount(key string, r LexRange) (int, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	z, ok, err := lookupAs[*zsetObject](shard, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	first := z.sl.firstInLexRange(r)
+	if first == nil {
+		return 0, nil
+	}
+	last := z.sl.lastInLexRange(r)
+
+	return z.sl.rank(last.score, last.member) - z.sl.rank(first.score, first.member) + 1, nil
+}
+
+// ZRange returns the members selected by spec, in the order they are walked.
+func (s *store) ZRange(key string, spec ZRangeSpec) ([]ScoredMember, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	z, ok, err := lookupAs[*zsetObject](shard, key)
+	if err != nil || !ok {
+		return []ScoredMember{}, err
+	}
+
+	return z.rangeOf(spec), nil
+}
+
+func (z *zsetObject) rangeOf(spec ZRangeSpec) []ScoredMember {
+	sl := z.sl
+
+	var x *skipListNode
+	var inRange func(*skipListNode) bool
+	limit, skip := spec.Count, spec.Offset
+
+	switch spec.By {
+	case ZRangeByRank:
+		start, stop := spec.Start, spec.Stop
+		if start < 0 {
+			start += sl.length
+		}
+		if stop < 0 {
+			stop += sl.length
+		}
+		start = max(start, 0)
+		stop = min(stop, sl.length-1)
+		if start > stop {
+			return []ScoredMember{}
+		}
+
+		rank := start + 1
+		if spec.Rev {
+			rank = sl.length - start
+		}
+		x = sl.byRank(rank)
+		limit, skip = stop-start+1, 0
+		inRange = func(*skipListNode) bool { return true }
+
+	case ZRangeByScore:
+		if spec.Rev {
+			x = sl.lastInScoreRange(spec.Score)
+			inRange = func(n *skipListNode) bool { return spec.Score.aboveMin(n.score) }
+		} else {
+			x = sl.firstInScoreRange(spec.Score)
+			inRange = func(n *skipListNode) bool { return spec.Score.belowMax(n.score) }
+		}
+
+	case ZRangeByLex:
+		if spec.Rev {
+			x = sl.lastInLexRange(spec.Lex)
+			inRange = func(n *skipListNode) bool { return spec.Lex.aboveMin(n.member) }
+		} else {
+			x = sl.firstInLexRange(spec.Lex)
+			inRange = func(n *skipListNode) bool { return spec.Lex.belowMax(n.member) }
+		}
+	}
+
+	next := func(n *skipListNode) *skipListNode {
+		if spec.Rev {
+			return n.backward
+		}
+		return n.levels[0].forward
+	}
+
+	for ; x != nil && skip > 0 && inRange(x); skip-- {
+		x = next(x)
+	}
+
+	members := []ScoredMember{}
+	for ; x != nil && limit != 0 && inRange(x); x = next(x) {
+		members = append(members, ScoredMember{Member: x.member, Score: x.score})
+		limit--
+	}
+
+	return members
+}
+
+// ZPop removes and returns up to count members with the lowest scores, or the
+// highest ones when highest is set.
+func (s *store) ZPop(key string, count int, highest bool) ([]ScoredMember, error) {
+	shard := s.getShard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	z, ok, err := lookupAs[*zsetObject](shard, key)
+	if err != nil || !ok {
+		return []ScoredMember{}, err
+	}
+
+	popped := make([]ScoredMember, 0, min(count, z.sl.length))
+	for len(popped) < count && z.sl.length > 0 {
+		x := z.sl.first()
+		if highest {
+			x = z.sl.tail
+		}
+		popped = append(popped, ScoredMember{Member: x.member, Score: x.score})
+		z.remove(x.member)
+	}
+	if len(popped) > 0 {
+		event := "zpopmin"
+		if highest {
+			event = "zpopmax"
+		}
+		s.notify(NotifyZSet, event, key)
+	}
+	shard.dropIfEmptyZSet(key, z)
+
+	return popped, nil
+}
@@ -0,0 +1,696 @@
+package server
+
+import (
+	"math"
+	"simpleKV/resp"
+	"simpleKV/server/store"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xadd handles
+//
+//	XADD key [NOMKSTREAM] [MAXLEN | MINID [= | ~] threshold [LIMIT count]] * | id field value [field value ...]
+func (s *server) xadd(args []resp.Value) resp.Value {
+	key := args[0].BulkString
+
+	var xargs store.XAddArgs
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i].BulkString) {
+		case "NOMKSTREAM":
+			xargs.NoMkStream = true
+		case "MAXLEN", "MINID":
+			trim, next, errReply, ok := parseTrim(args, i)
+			if !ok {
+				return errReply
+			}
+			xargs.Trim = &trim
+			i = next - 1
+		default:
+			break options
+		}
+	}
+
+	if i >= len(args) {
+		return resp.NewErrorValue("ERR syntax error")
+	}
+	fields := args[i+1:]
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return wrongNumberOfArgs(resp.CMD_XADD)
+	}
+
+	id := args[i].BulkString
+	switch {
+	case id == "*":
+		xargs.AutoID = true
+	case strings.HasSuffix(id, "-*"):
+		ms, err := strconv.ParseUint(strings.TrimSuffix(id, "-*"), 10, 64)
+		if err != nil {
+			return resp.NewErrorValue(store.ErrInvalidStreamID.Error())
+		}
+		xargs.ID, xargs.AutoSeq = store.StreamID{Ms: ms}, true
+	default:
+		parsed, err := store.ParseStreamID(id, 0)
+		if err != nil || id == "-" || id == "+" {
+			return resp.NewErrorValue(store.ErrInvalidStreamID.Error())
+		}
+		xargs.ID = parsed
+	}
+
+	added, ok, err := s.store.XAdd(key, xargs, bulkStrings(fields))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if !ok {
+		return resp.Value{Type: resp.NULL}
+	}
+
+	s.blocking.signal(key)
+	return resp.Value{Type: resp.BULK_STRING, BulkString: added.String()}
+}
+
+// parseTrim reads a MAXLEN or MINID clause starting at args[i] and returns
+// the index of the first argument after it. Approximate trimming ("~") trims
+// exactly, which is always allowed; LIMIT is accepted only along with it.
+func parseTrim(args []resp.Value, i int) (store.XTrimArgs, int, resp.Value, bool) {
+	var trim store.XTrimArgs
+	trim.ByMinID = strings.ToUpper(args[i].BulkString) == "MINID"
+	i++
+
+	approx := false
+	if i < len(args) && (args[i].BulkString == "=" || args[i].BulkString == "~") {
+		approx = args[i].BulkString == "~"
+		i++
+	}
+	if i >= len(args) {
+		return trim, 0, resp.NewErrorValue("ERR syntax error"), false
+	}
+
+	if trim.ByMinID {
+		id, err := store.ParseStreamID(args[i].BulkString, 0)
+		if err != nil {
+			return trim, 0, resp.NewErrorValue(store.ErrInvalidStreamID.Error()), false
+		}
+		trim.MinID = id
+	} else {
+		n, err := strconv.Atoi(args[i].BulkString)
+		if err != nil || n < 0 {
+			return trim, 0, resp.NewErrorValue("ERR The MAXLEN argument must be >= 0."), false
+		}
+		trim.MaxLen = n
+	}
+	i++
+
+	if i+1 < len(args) && strings.ToUpper(args[i].BulkString) == "LIMIT" {
+		if !approx {
+			return trim, 0, resp.NewErrorValue("ERR syntax error, LIMIT cannot be used without the special ~ option"), false
+		}
+		if _, err := strconv.Atoi(args[i+1].BulkString); err != nil {
+			return trim, 0, resp.NewErrorValue("ERR value is not an integer or out of range"), false
+		}
+		i += 2
+	}
+
+	return trim, i, resp.Value{}, true
+}
+
+func (s *server) xlen(args []resp.Value) resp.Value {
+	n, err := s.store.XLen(args[0].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+// xrange handles
+//
+//	XRANGE key start end [COUNT count]
+//	XREVRANGE key end start [COUNT count]
+//
+// A bound prefixed with "(" is exclusive.
+func (s *server) xrange(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	if len(args) != 3 && len(args) != 5 {
+		return wrongNumberOfArgs(cmd)
+	}
+	rev := cmd == resp.CMD_XREVRANGE
+	lo, hi := args[1].BulkString, args[2].BulkString
+	if rev {
+		lo, hi = hi, lo
+	}
+
+	start, ok1 := parseRangeID(lo, false)
+	end, ok2 := parseRangeID(hi, true)
+	if !ok1 || !ok2 {
+		return resp.NewErrorValue(store.ErrInvalidStreamID.Error())
+	}
+
+	count := -1
+	if len(args) == 5 {
+		if strings.ToUpper(args[3].BulkString) != "COUNT" {
+			return resp.NewErrorValue("ERR syntax error")
+		}
+		n, err := strconv.Atoi(args[4].BulkString)
+		if err != nil {
+			return resp.NewErrorValue("ERR value is not an integer or out of range")
+		}
+		count = max(n, 0)
+	}
+
+	entries, err := s.store.XRange(args[0].BulkString, start, end, count, rev)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return streamEntriesReply(entries)
+}
+
+// parseRangeID reads an XRANGE bound. A bare millisecond time covers the whole
+// millisecond, so its sequence number depends on which end it bounds.
+func parseRangeID(str string, isEnd bool) (store.StreamID, bool) {
+	exclusive := strings.HasPrefix(str, "(")
+	str = strings.TrimPrefix(str, "(")
+
+	var missingSeq uint64
+	if isEnd {
+		missingSeq = math.MaxUint64
+	}
+	id, err := store.ParseStreamID(str, missingSeq)
+	if err != nil {
+		return id, false
+	}
+	if !exclusive {
+		return id, true
+	}
+	if isEnd {
+		return id.Prev()
+	}
+	return id.Next()
+}
+
+func (s *server) xdel(args []resp.Value) resp.Value {
+	ids, ok := parseStreamIDs(args[1:])
+	if !ok {
+		return resp.NewErrorValue(store.ErrInvalidStreamID.Error())
+	}
+
+	n, err := s.store.XDel(args[0].BulkString, ids)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+// xtrim handles
+//
+//	XTRIM key MAXLEN | MINID [= | ~] threshold [LIMIT count]
+func (s *server) xtrim(args []resp.Value) resp.Value {
+	switch strings.ToUpper(args[1].BulkString) {
+	case "MAXLEN", "MINID":
+	default:
+		return resp.NewErrorValue("ERR syntax error")
+	}
+
+	trim, next, errReply, ok := parseTrim(args, 1)
+	if !ok {
+		return errReply
+	}
+	if next != len(args) {
+		return resp.NewErrorValue("ERR syntax error")
+	}
+
+	n, err := s.store.XTrim(args[0].BulkString, trim)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+// xread handles
+//
+//	XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
+func (s *server) xread(sess *session, args []resp.Value) resp.Value {
+	count, block, streams, errReply, ok := parseReadOptions(args, false)
+	if !ok {
+		return errReply
+	}
+
+	reads, errReply, ok := s.parseStreamReads(streams, false)
+	if !ok {
+		return errReply
+	}
+
+	return s.readStreams(sess, reads, block, func() ([][]store.StreamEntry, error) {
+		return s.store.XRead(reads, count)
+	})
+}
+
+// xreadgroup handles
+//
+//	XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]
+func (s *server) xreadgroup(sess *session, args []resp.Value) resp.Value {
+	if strings.ToUpper(args[0].BulkString) != "GROUP" {
+		return resp.NewErrorValue("ERR syntax error")
+	}
+	group, consumer := args[1].BulkString, args[2].BulkString
+
+	count, block, streams, errReply, ok := parseReadOptions(args[3:], true)
+	if !ok {
+		return errReply
+	}
+	noAck := slices.ContainsFunc(args[3:len(args)-len(streams)], func(arg resp.Value) bool {
+		return strings.ToUpper(arg.BulkString) == "NOACK"
+	})
+
+	reads, errReply, ok := s.parseStreamReads(streams, true)
+	if !ok {
+		return errReply
+	}
+
+	// Only reads of new entries can wait for more; replaying history answers
+	// right away.
+	if !slices.ContainsFunc(reads, func(r store.StreamRead) bool { return r.New }) {
+		block = nil
+	}
+
+	return s.readStreams(sess, reads, block, func() ([][]store.StreamEntry, error) {
+		return s.store.XReadGroup(group, consumer, reads, count, noAck)
+	})
+}
+
+// parseReadOptions reads the options of XREAD and XREADGROUP and returns the
+// arguments after STREAMS. A nil block means the call must not block.
+func parseReadOptions(args []resp.Value, group bool) (int, *time.Duration, []resp.Value, resp.Value, bool) {
+	count := -1
+	var block *time.Duration
+	for i := 0; i < len(args); i++ {
+		opt := strings.ToUpper(args[i].BulkString)
+		switch {
+		case opt == "STREAMS":
+			streams := args[i+1:]
+			if len(streams) == 0 || len(streams)%2 != 0 {
+				return 0, nil, nil, resp.NewErrorValue("ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified."), false
+			}
+			return count, block, streams, resp.Value{}, true
+		case opt == "NOACK" && group:
+		case opt == "COUNT" && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1].BulkString)
+			if err != nil {
+				return 0, nil, nil, resp.NewErrorValue("ERR value is not an integer or out of range"), false
+			}
+			if n > 0 {
+				count = n
+			}
+			i++
+		case opt == "BLOCK" && i+1 < len(args):
+			ms, err := strconv.ParseInt(args[i+1].BulkString, 10, 64)
+			if err != nil {
+				return 0, nil, nil, resp.NewErrorValue("ERR timeout is not an integer or out of range"), false
+			}
+			if ms < 0 {
+				return 0, nil, nil, resp.NewErrorValue("ERR timeout is negative"), false
+			}
+			timeout := time.Duration(min(ms, math.MaxInt64/int64(time.Millisecond))) * time.Millisecond
+			block = &timeout
+			i++
+		default:
+			return 0, nil, nil, resp.NewErrorValue("ERR syntax error"), false
+		}
+	}
+	return 0, nil, nil, resp.NewErrorValue("ERR syntax error"), false
+}
+
+// parseStreamReads pairs the keys after STREAMS with their IDs. "$" means
+// the last ID in the stream right now, so that only newer entries are read;
+// ">" asks a group for entries never delivered to it.
+func (s *server) parseStreamReads(streams []resp.Value, group bool) ([]store.StreamRead, resp.Value, bool) {
+	n := len(streams) / 2
+	reads := make([]store.StreamRead, n)
+	for i := range n {
+		key, id := streams[i].BulkString, streams[n+i].BulkString
+		reads[i].Key = key
+
+		switch {
+		case id == ">" && group:
+			reads[i].New = true
+		case id == "$" && !group:
+			last, err := s.store.XLastID(key)
+			if err != nil {
+				return nil, resp.NewErrorValue(err.Error()), false
+			}
+			reads[i].After = last
+		default:
+			parsed, err := store.ParseStreamID(id, 0)
+			if err != nil {
+				return nil, resp.NewErrorValue(store.ErrInvalidStreamID.Error()), false
+			}
+			reads[i].After = parsed
+		}
+	}
+	return reads, resp.Value{}, true
+}
+
+// readStreams answers an XREAD or XREADGROUP with the streams read returns
+// entries for. When there are none and block is set, the client waits for an
+// XADD to one of the keys.
+func (s *server) readStreams(sess *session, reads []store.StreamRead, block *time.Duration, read func() ([][]store.StreamEntry, error)) resp.Value {
+	serve := func(string) (resp.Value, string, bool) {
+		results, err := read()
+		if err != nil {
+			return resp.NewErrorValue(err.Error()), "", true
+		}
+
+		pairs := []resp.Value{}
+		for i, entries := range results {
+			if len(entries) > 0 {
+				key := resp.Value{Type: resp.BULK_STRING, BulkString: reads[i].Key}
+				pairs = append(pairs, key, streamEntriesReply(entries))
+			}
+		}
+		if len(pairs) == 0 {
+			return resp.Value{}, "", false
+		}
+		return sess.streamsReply(pairs), "", true
+	}
+
+	if reply, _, ok := serve(""); ok || block == nil {
+		if !ok {
+			return resp.Value{Type: resp.NULL}
+		}
+		return reply
+	}
+
+	keys := make([]string, len(reads))
+	for i, r := range reads {
+		keys[i] = r.Key
+	}
+	reply, ok := s.blocking.waitStream(keys, sess.blockTimeout(*block), sess.done, serve)
+	if !ok {
+		return resp.Value{Type: resp.NULL}
+	}
+	return reply
+}
+
+// streamsReply maps stream names to their entries: a RESP3 map, or under
+// RESP2 an array of [key, entries] pairs.
+func (sess *session) streamsReply(pairs []resp.Value) resp.Value {
+	if sess.protocol == 3 {
+		return resp.Value{Type: resp.MAP, Array: pairs}
+	}
+
+	streams := make([]resp.Value, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		streams = append(streams, resp.Value{Type: resp.ARRAY, Array: pairs[i : i+2]})
+	}
+	return resp.Value{Type: resp.ARRAY, Array: streams}
+}
+
+// streamEntriesReply lists entries as [id, [field, value, ...]] pairs. The
+// fields of an entry deleted while pending are NULL.
+func streamEntriesReply(entries []store.StreamEntry) resp.Value {
+	reply := make([]resp.Value, len(entries))
+	for i, e := range entries {
+		fields := resp.Value{Type: resp.NULL}
+		if e.Fields != nil {
+			fields = resp.Value{Type: resp.ARRAY, Array: createBulkStringArray(e.Fields)}
+		}
+		reply[i] = resp.Value{Type: resp.ARRAY, Array: []resp.Value{
+			{Type: resp.BULK_STRING, BulkString: e.ID.String()},
+			fields,
+		}}
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
+
+func parseStreamIDs(args []resp.Value) ([]store.StreamID, bool) {
+	ids := make([]store.StreamID, len(args))
+	for i, arg := range args {
+		id, err := store.ParseStreamID(arg.BulkString, 0)
+		if err != nil {
+			return nil, false
+		}
+		ids[i] = id
+	}
+	return ids, true
+}
+
+// xgroup handles
+//
+//	XGROUP CREATE key group id | $ [MKSTREAM] [ENTRIESREAD entries-read]
+//	XGROUP SETID key group id | $ [ENTRIESREAD entries-read]
+//	XGROUP DESTROY key group
+//	XGROUP CREATECONSUMER key group consumer
+//	XGROUP DELCONSUMER key group consumer
+//
+// ENTRIESREAD is accepted for compatibility and otherwise ignored.
+func (s *server) xgroup(args []resp.Value) resp.Value {
+	sub := strings.ToUpper(args[0].BulkString)
+	args = args[1:]
+
+	arity := map[string]int{"CREATE": 3, "SETID": 3, "DESTROY": 2, "CREATECONSUMER": 3, "DELCONSUMER": 3}
+	want, known := arity[sub]
+	if !known {
+		return resp.NewErrorValue("ERR unknown subcommand '" + sub + "'. Try XGROUP HELP.")
+	}
+	if len(args) < want || (sub != "CREATE" && sub != "SETID" && len(args) != want) {
+		return resp.NewErrorValue("ERR wrong number of arguments for 'xgroup|" + strings.ToLower(sub) + "' command")
+	}
+	key, group := args[0].BulkString, args[1].BulkString
+
+	switch sub {
+	case "CREATE", "SETID":
+		mkStream := false
+		for i := 3; i < len(args); i++ {
+			switch opt := strings.ToUpper(args[i].BulkString); {
+			case opt == "MKSTREAM" && sub == "CREATE":
+				mkStream = true
+			case opt == "ENTRIESREAD" && i+1 < len(args):
+				i++
+			default:
+				return resp.NewErrorValue("ERR syntax error")
+			}
+		}
+
+		fromLast := args[2].BulkString == "$"
+		var id store.StreamID
+		if !fromLast {
+			parsed, err := store.ParseStreamID(args[2].BulkString, 0)
+			if err != nil {
+				return resp.NewErrorValue(store.ErrInvalidStreamID.Error())
+			}
+			id = parsed
+		}
+
+		var err error
+		if sub == "CREATE" {
+			err = s.store.XGroupCreate(key, group, id, fromLast, mkStream)
+		} else {
+			err = s.store.XGroupSetID(key, group, id, fromLast)
+		}
+		if err != nil {
+			return resp.NewErrorValue(err.Error())
+		}
+		return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+
+	case "DESTROY":
+		destroyed, err := s.store.XGroupDestroy(key, group)
+		if err != nil {
+			return resp.NewErrorValue(err.Error())
+		}
+		return boolInteger(destroyed)
+
+	case "CREATECONSUMER":
+		created, err := s.store.XGroupCreateConsumer(key, group, args[2].BulkString)
+		if err != nil {
+			return resp.NewErrorValue(err.Error())
+		}
+		return boolInteger(created)
+
+	default:
+		pending, err := s.store.XGroupDelConsumer(key, group, args[2].BulkString)
+		if err != nil {
+			return resp.NewErrorValue(err.Error())
+		}
+		return resp.NewIntegerValue(int64(pending))
+	}
+}
+
+func (s *server) xack(args []resp.Value) resp.Value {
+	ids, ok := parseStreamIDs(args[2:])
+	if !ok {
+		return resp.NewErrorValue(store.ErrInvalidStreamID.Error())
+	}
+
+	n, err := s.store.XAck(args[0].BulkString, args[1].BulkString, ids)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+// xpending handles
+//
+//	XPENDING key group [[IDLE min-idle-time] start end count [consumer]]
+func (s *server) xpending(args []resp.Value) resp.Value {
+	if len(args) != 2 && len(args) < 5 {
+		return wrongNumberOfArgs(resp.CMD_XPENDING)
+	}
+	key, group := args[0].BulkString, args[1].BulkString
+	if len(args) == 2 {
+		return s.xpendingSummary(key, group)
+	}
+
+	rest := args[2:]
+	var minIdle time.Duration
+	if strings.ToUpper(rest[0].BulkString) == "IDLE" {
+		ms, err := strconv.ParseInt(rest[1].BulkString, 10, 64)
+		if err != nil {
+			return resp.NewErrorValue("ERR value is not an integer or out of range")
+		}
+		minIdle = time.Duration(ms) * time.Millisecond
+		rest = rest[2:]
+	}
+	if len(rest) != 3 && len(rest) != 4 {
+		return resp.NewErrorValue("ERR syntax error")
+	}
+
+	start, ok1 := parseRangeID(rest[0].BulkString, false)
+	end, ok2 := parseRangeID(rest[1].BulkString, true)
+	if !ok1 || !ok2 {
+		return resp.NewErrorValue(store.ErrInvalidStreamID.Error())
+	}
+	count, err := strconv.Atoi(rest[2].BulkString)
+	if err != nil {
+		return resp.NewErrorValue("ERR value is not an integer or out of range")
+	}
+	consumer := ""
+	if len(rest) == 4 {
+		consumer = rest[3].BulkString
+	}
+
+	pending, err := s.store.XPending(key, group, start, end, max(count, 0), consumer, minIdle)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	reply := make([]resp.Value, len(pending))
+	for i, p := range pending {
+		reply[i] = resp.Value{Type: resp.ARRAY, Array: []resp.Value{
+			{Type: resp.BULK_STRING, BulkString: p.ID.String()},
+			{Type: resp.BULK_STRING, BulkString: p.Consumer},
+			resp.NewIntegerValue(p.Idle.Milliseconds()),
+			resp.NewIntegerValue(int64(p.Deliveries)),
+		}}
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
+
+// xpendingSummary answers with the number of pending entries, the lowest and
+// highest of their IDs and how many each consumer holds.
+func (s *server) xpendingSummary(key, group string) resp.Value {
+	summary, err := s.store.XPendingSummary(key, group)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	null := resp.Value{Type: resp.NULL}
+	if summary.Count == 0 {
+		return resp.Value{Type: resp.ARRAY, Array: []resp.Value{resp.NewIntegerValue(0), null, null, null}}
+	}
+
+	names := make([]string, 0, len(summary.Consumers))
+	for name := range summary.Consumers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	consumers := make([]resp.Value, len(names))
+	for i, name := range names {
+		consumers[i] = resp.Value{Type: resp.ARRAY, Array: createBulkStringArray([]string{
+			name, strconv.Itoa(summary.Consumers[name]),
+		})}
+	}
+
+	return resp.Value{Type: resp.ARRAY, Array: []resp.Value{
+		resp.NewIntegerValue(int64(summary.Count)),
+		{Type: resp.BULK_STRING, BulkString: summary.Lowest.String()},
+		{Type: resp.BULK_STRING, BulkString: summary.Highest.String()},
+		{Type: resp.ARRAY, Array: consumers},
+	}}
+}
+
+// xclaim handles
+//
+//	XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-milliseconds]
+//	  [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID lastid]
+//
+// LASTID is accepted for compatibility and otherwise ignored.
+func (s *server) xclaim(args []resp.Value) resp.Value {
+	key, group, consumer := args[0].BulkString, args[1].BulkString, args[2].BulkString
+
+	minIdleMs, err := strconv.ParseInt(args[3].BulkString, 10, 64)
+	if err != nil {
+		return resp.NewErrorValue("ERR Invalid min-idle-time argument for XCLAIM")
+	}
+
+	var ids []store.StreamID
+	i := 4
+	for ; i < len(args); i++ {
+		id, err := store.ParseStreamID(args[i].BulkString, 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return resp.NewErrorValue(store.ErrInvalidStreamID.Error())
+	}
+
+	var opts store.XClaimOptions
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(args[i].BulkString)
+		switch {
+		case opt == "FORCE":
+			opts.Force = true
+		case opt == "JUSTID":
+			opts.JustID = true
+		case (opt == "IDLE" || opt == "TIME" || opt == "RETRYCOUNT") && i+1 < len(args):
+			n, err := strconv.ParseInt(args[i+1].BulkString, 10, 64)
+			if err != nil {
+				return resp.NewErrorValue("ERR Invalid " + opt + " option argument for XCLAIM")
+			}
+			switch opt {
+			case "IDLE":
+				idle := time.Duration(n) * time.Millisecond
+				opts.Idle = &idle
+			case "TIME":
+				idle := time.Since(time.UnixMilli(n))
+				opts.Idle = &idle
+			default:
+				count := int(n)
+				opts.RetryCount = &count
+			}
+			i++
+		case opt == "LASTID" && i+1 < len(args):
+			i++
+		default:
+			return resp.NewErrorValue("ERR Unrecognized XCLAIM option '" + args[i].BulkString + "'")
+		}
+	}
+
+	claimed, err := s.store.XClaim(key, group, consumer, time.Duration(minIdleMs)*time.Millisecond, ids, opts)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	if opts.JustID {
+		reply := make([]resp.Value, len(claimed))
+		for i, e := range claimed {
+			reply[i] = resp.Value{Type: resp.BULK_STRING, BulkString: e.ID.String()}
+		}
+		return resp.Value{Type: resp.ARRAY, Array: reply}
+	}
+	return streamEntriesReply(claimed)
+}
@@ -0,0 +1,324 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"simpleKV/resp"
+	"simpleKV/server/store"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (s *server) get(args []resp.Value) resp.Value {
+	value, found, err := s.store.Get(args[0].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if !found {
+		return resp.Value{Type: resp.NULL}
+	}
+	return value
+}
+
+// set handles
+//
+//	SET key value [NX | XX] [GET] [EX seconds | PX milliseconds |
+//	  EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+func (s *server) set(args []resp.Value) resp.Value {
+	key := args[0].BulkString
+	value := args[1]
+
+	var opts store.SetOptions
+	hasExpiry := false
+	for i := 2; i < len(args); i++ {
+		opt := strings.ToUpper(args[i].BulkString)
+		switch opt {
+		case "NX", "XX":
+			if opts.Condition != store.SetAlways {
+				return resp.NewErrorValue("ERR syntax error")
+			}
+			opts.Condition = store.SetNX
+			if opt == "XX" {
+				opts.Condition = store.SetXX
+			}
+
+		case "GET":
+			opts.Get = true
+
+		case "KEEPTTL":
+			if hasExpiry {
+				return resp.NewErrorValue("ERR syntax error")
+			}
+			opts.KeepTTL = true
+			hasExpiry = true
+
+		case "EX", "PX", "EXAT", "PXAT":
+			if hasExpiry || i+1 >= len(args) {
+				return resp.NewErrorValue("ERR syntax error")
+			}
+			hasExpiry = true
+			i++
+			deadline, errReply, ok := parseDeadline(resp.CMD_SET, opt, args[i])
+			if !ok {
+				return errReply
+			}
+			opts.Deadline = deadline
+
+		default:
+			return resp.NewErrorValue("ERR syntax error")
+		}
+	}
+
+	prev, hadPrev, written, err := s.store.SetWithOptions(key, value, opts)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if opts.Get {
+		if !hadPrev {
+			return resp.Value{Type: resp.NULL}
+		}
+		return prev
+	}
+	if !written {
+		return resp.Value{Type: resp.NULL}
+	}
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+}
+
+// parseDeadline reads the argument of an EX, PX, EXAT or PXAT option into an
+// absolute deadline.
+func parseDeadline(cmd resp.RESPCommand, opt string, arg resp.Value) (time.Time, resp.Value, bool) {
+	n, err := strconv.ParseInt(arg.BulkString, 10, 64)
+	if err != nil {
+		return time.Time{}, resp.NewErrorValue("ERR value is not an integer or out of range"), false
+	}
+
+	unit := time.Second
+	if opt == "PX" || opt == "PXAT" {
+		unit = time.Millisecond
+	}
+	d, err := expireDuration(n, unit)
+	if err != nil || d <= 0 {
+		return time.Time{}, resp.NewErrorValue(fmt.Sprintf("ERR invalid expire time in '%s' command", cmd)), false
+	}
+
+	if opt == "EXAT" || opt == "PXAT" {
+		return time.UnixMilli(d.Milliseconds()), resp.Value{}, true
+	}
+	return time.Now().Add(d), resp.Value{}, true
+}
+
+// incr serves INCR, DECR, INCRBY and DECRBY.
+func (s *server) incr(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	delta := int64(1)
+	if len(args) == 2 {
+		n, err := strconv.ParseInt(args[1].BulkString, 10, 64)
+		if err != nil {
+			return resp.NewErrorValue("ERR value is not an integer or out of range")
+		}
+		delta = n
+	}
+
+	if cmd == resp.CMD_DECR || cmd == resp.CMD_DECRBY {
+		if delta == math.MinInt64 {
+			return resp.NewErrorValue("ERR decrement would overflow")
+		}
+		delta = -delta
+	}
+
+	n, err := s.store.IncrBy(args[0].BulkString, delta)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(n)
+}
+
+func (s *server) incrbyfloat(args []resp.Value) resp.Value {
+	delta, err := strconv.ParseFloat(args[1].BulkString, 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return resp.NewErrorValue("ERR value is not a valid float")
+	}
+
+	n, err := s.store.IncrByFloat(args[0].BulkString, delta)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.BULK_STRING, BulkString: strconv.FormatFloat(n, 'f', -1, 64)}
+}
+
+func (s *server) appendString(args []resp.Value) resp.Value {
+	n, err := s.store.Append(args[0].BulkString, args[1].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+func (s *server) strlen(args []resp.Value) resp.Value {
+	n, err := s.store.StrLen(args[0].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+func (s *server) getrange(args []resp.Value) resp.Value {
+	start, err1 := strconv.Atoi(args[1].BulkString)
+	end, err2 := strconv.Atoi(args[2].BulkString)
+	if err1 != nil || err2 != nil {
+		return resp.NewErrorValue("ERR value is not an integer or out of range")
+	}
+
+	str, err := s.store.GetRange(args[0].BulkString, start, end)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.Value{Type: resp.BULK_STRING, BulkString: str}
+}
+
+func (s *server) setrange(args []resp.Value) resp.Value {
+	offset, err := strconv.Atoi(args[1].BulkString)
+	if err != nil {
+		return resp.NewErrorValue("ERR value is not an integer or out of range")
+	}
+
+	n, err := s.store.SetRange(args[0].BulkString, offset, args[2].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+func (s *server) getdel(args []resp.Value) resp.Value {
+	value, ok, err := s.store.GetDel(args[0].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if !ok {
+		return resp.Value{Type: resp.NULL}
+	}
+	return value
+}
+
+func (s *server) mget(args []resp.Value) resp.Value {
+	return resp.Value{Type: resp.ARRAY, Array: s.store.MGet(bulkStrings(args))}
+}
+
+// mset serves MSET and MSETNX, both of which take key value pairs.
+func (s *server) mset(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	if len(args)%2 != 0 {
+		return wrongNumberOfArgs(cmd)
+	}
+	keys := make([]string, 0, len(args)/2)
+	values := make([]resp.Value, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		keys = append(keys, args[i].BulkString)
+		values = append(values, args[i+1])
+	}
+
+	if cmd == resp.CMD_MSETNX {
+		return boolInteger(s.store.MSetNX(keys, values))
+	}
+	s.store.MSet(keys, values)
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+}
+
+// getex handles
+//
+//	GETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds |
+//	  PXAT unix-time-milliseconds | PERSIST]
+func (s *server) getex(args []resp.Value) resp.Value {
+	var deadline time.Time
+	persist := false
+	for i := 1; i < len(args); i++ {
+		opt := strings.ToUpper(args[i].BulkString)
+		hasExpiry := persist || !deadline.IsZero()
+		switch {
+		case opt == "PERSIST" && !hasExpiry:
+			persist = true
+		case (opt == "EX" || opt == "PX" || opt == "EXAT" || opt == "PXAT") && !hasExpiry && i+1 < len(args):
+			i++
+			d, errReply, ok := parseDeadline(resp.CMD_GETEX, opt, args[i])
+			if !ok {
+				return errReply
+			}
+			deadline = d
+		default:
+			return resp.NewErrorValue("ERR syntax error")
+		}
+	}
+
+	value, ok, err := s.store.GetEx(args[0].BulkString, deadline, persist)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if !ok {
+		return resp.Value{Type: resp.NULL}
+	}
+	return value
+}
+
+// lcs handles
+//
+//	LCS key1 key2 [LEN] [IDX] [MINMATCHLEN min-match-len] [WITHMATCHLEN]
+func (s *server) lcs(sess *session, args []resp.Value) resp.Value {
+	getLen, getIdx, withMatchLen := false, false, false
+	minMatchLen := 0
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].BulkString); {
+		case opt == "LEN":
+			getLen = true
+		case opt == "IDX":
+			getIdx = true
+		case opt == "WITHMATCHLEN":
+			withMatchLen = true
+		case opt == "MINMATCHLEN" && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1].BulkString)
+			if err != nil {
+				return resp.NewErrorValue("ERR value is not an integer or out of range")
+			}
+			minMatchLen = max(n, 0)
+			i++
+		default:
+			return resp.NewErrorValue("ERR syntax error")
+		}
+	}
+	if getLen && getIdx {
+		return resp.NewErrorValue("ERR If you want both the length and indexes, please just use IDX.")
+	}
+
+	seq, matches, err := s.store.LCS(args[0].BulkString, args[1].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	switch {
+	case getLen:
+		return resp.NewIntegerValue(int64(len(seq)))
+	case !getIdx:
+		return resp.Value{Type: resp.BULK_STRING, BulkString: seq}
+	}
+
+	ranges := []resp.Value{}
+	for _, m := range matches {
+		if m.Len() < minMatchLen {
+			continue
+		}
+		match := []resp.Value{
+			{Type: resp.ARRAY, Array: []resp.Value{resp.NewIntegerValue(int64(m.A[0])), resp.NewIntegerValue(int64(m.A[1]))}},
+			{Type: resp.ARRAY, Array: []resp.Value{resp.NewIntegerValue(int64(m.B[0])), resp.NewIntegerValue(int64(m.B[1]))}},
+		}
+		if withMatchLen {
+			match = append(match, resp.NewIntegerValue(int64(m.Len())))
+		}
+		ranges = append(ranges, resp.Value{Type: resp.ARRAY, Array: match})
+	}
+
+	return sess.mapReply([]resp.Value{
+		{Type: resp.BULK_STRING, BulkString: "matches"},
+		{Type: resp.ARRAY, Array: ranges},
+		{Type: resp.BULK_STRING, BulkString: "len"},
+		resp.NewIntegerValue(int64(len(seq))),
+	})
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"simpleKV/resp"
+	"time"
+)
+
+// txState is a connection's transaction: the commands queued since MULTI,
+// and the keys it WATCHes with the version each had at the time.
+type txState struct {
+	active    bool
+	queued    []queuedCommand
+	aborted   bool // a command was refused while queuing, so EXEC will fail
+	executing bool // EXEC is running the queued commands
+	watched   map[string]uint64
+}
+
+// refuse answers a command that can't be queued. Inside MULTI it also dooms
+// the transaction, as Redis does.
+func (tx *txState) refuse(reply resp.Value) resp.Value {
+	if tx.active {
+		tx.aborted = true
+	}
+	return reply
+}
+
+// transactionControl are the commands that act right away between MULTI
+// and EXEC instead of being queued. They take txMu themselves.
+var transactionControl = commandSet(resp.CMD_MULTI, resp.CMD_EXEC, resp.CMD_DISCARD, resp.CMD_WATCH)
+
+// queuedCommand is a command held back until EXEC.
+type queuedCommand struct {
+	spec *commandSpec
+	args []resp.Value
+}
+
+// queue holds a command back until EXEC. Its name and arity were checked
+// already, as Redis does before queuing.
+func (s *server) queue(sess *session, spec *commandSpec, args []resp.Value) resp.Value {
+	if spec.flags&flagNoMulti != 0 {
+		return sess.tx.refuse(resp.NewErrorValue("ERR Command not allowed inside a transaction"))
+	}
+
+	sess.tx.queued = append(sess.tx.queued, queuedCommand{spec, args})
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "QUEUED"}
+}
+
+// transaction handles MULTI, EXEC, DISCARD and WATCH, which run right away
+// even between MULTI and EXEC.
+func (s *server) transaction(sess *session, cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	switch cmd {
+	case resp.CMD_MULTI:
+		if sess.tx.active {
+			return resp.NewErrorValue("ERR MULTI calls can not be nested")
+		}
+		sess.tx.active = true
+
+	case resp.CMD_EXEC:
+		if !sess.tx.active {
+			return resp.NewErrorValue("ERR EXEC without MULTI")
+		}
+		return s.exec(sess)
+
+	case resp.CMD_DISCARD:
+		if !sess.tx.active {
+			return resp.NewErrorValue("ERR DISCARD without MULTI")
+		}
+		s.discard(sess)
+
+	case resp.CMD_WATCH:
+		if sess.tx.active {
+			return resp.NewErrorValue("ERR WATCH inside MULTI is not allowed")
+		}
+		s.watch(sess, bulkStrings(args))
+	}
+
+	return resp.Value{Type: resp.SIMPLE_STRING, String: "OK"}
+}
+
+// exec runs the queued commands with txMu held for writing, so no other
+// connection's command can interleave with them. It answers NULL, running
+// nothing, when a watched key changed since WATCH.
+func (s *server) exec(sess *session) resp.Value {
+	queued, aborted := sess.tx.queued, sess.tx.aborted
+	sess.tx.active, sess.tx.queued, sess.tx.aborted = false, nil, false
+	defer s.unwatch(sess)
+
+	if aborted {
+		return resp.NewErrorValue("EXECABORT Transaction discarded because of previous errors.")
+	}
+
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	for key, version := range sess.tx.watched {
+		if s.store.Version(key) != version {
+			return resp.Value{Type: resp.NULL}
+		}
+	}
+
+	sess.tx.executing = true
+	defer func() { sess.tx.executing = false }()
+
+	replies := make([]resp.Value, len(queued))
+	for i, q := range queued {
+		replies[i] = s.execute(sess, q.spec, q.args)
+	}
+	return resp.Value{Type: resp.ARRAY, Array: replies}
+}
+
+// discard drops the queued commands and the watched keys.
+func (s *server) discard(sess *session) {
+	sess.tx.active, sess.tx.queued, sess.tx.aborted = false, nil, false
+	s.unwatch(sess)
+}
+
+func (s *server) watch(sess *session, keys []string) {
+	if sess.tx.watched == nil {
+		sess.tx.watched = make(map[string]uint64)
+	}
+	for _, key := range keys {
+		if _, ok := sess.tx.watched[key]; !ok {
+			sess.tx.watched[key] = s.store.Watch(key)
+		}
+	}
+}
+
+func (s *server) unwatch(sess *session) {
+	for key := range sess.tx.watched {
+		s.store.Unwatch(key)
+	}
+	clear(sess.tx.watched)
+}
+
+// blockTimeout is the timeout a blocking command waits for: noWait while
+// EXEC runs it, timeout otherwise.
+func (sess *session) blockTimeout(timeout time.Duration) time.Duration {
+	if sess.tx.executing {
+		return noWait
+	}
+	return timeout
+}
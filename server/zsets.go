@@ -0,0 +1,407 @@
+package server
+
+import (
+	"math"
+	"simpleKV/resp"
+	"simpleKV/server/store"
+	"strconv"
+	"strings"
+)
+
+// zadd handles
+//
+//	ZADD key [NX | XX] [GT | LT] [CH] [INCR] score member [score member ...]
+func (s *server) zadd(sess *session, args []resp.Value) resp.Value {
+	key := args[0].BulkString
+
+	var opts store.ZAddOptions
+	incr, nx, xx := false, false, false
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i].BulkString) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			opts.GT = true
+		case "LT":
+			opts.LT = true
+		case "CH":
+			opts.CH = true
+		case "INCR":
+			incr = true
+		default:
+			break options
+		}
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return resp.NewErrorValue("ERR syntax error")
+	}
+	if nx && xx {
+		return resp.NewErrorValue("ERR XX and NX options at the same time are not compatible")
+	}
+	if nx {
+		opts.Condition = store.SetNX
+	} else if xx {
+		opts.Condition = store.SetXX
+	}
+	if opts.GT && opts.LT || (opts.GT || opts.LT) && nx {
+		return resp.NewErrorValue("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if incr && len(pairs) != 2 {
+		return resp.NewErrorValue("ERR INCR option supports a single increment-element pair")
+	}
+
+	members := make([]store.ScoredMember, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, ok := parseScore(pairs[j].BulkString)
+		if !ok {
+			return resp.NewErrorValue("ERR value is not a valid float")
+		}
+		members = append(members, store.ScoredMember{Member: pairs[j+1].BulkString, Score: score})
+	}
+
+	if incr {
+		score, ok, err := s.store.ZIncrBy(key, members[0].Member, members[0].Score, opts)
+		if err != nil {
+			return resp.NewErrorValue(err.Error())
+		}
+		if !ok {
+			return resp.Value{Type: resp.NULL}
+		}
+		return sess.scoreReply(score)
+	}
+
+	n, err := s.store.ZAdd(key, members, opts)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+func (s *server) zincrby(sess *session, args []resp.Value) resp.Value {
+	delta, ok := parseScore(args[1].BulkString)
+	if !ok {
+		return resp.NewErrorValue("ERR value is not a valid float")
+	}
+
+	score, _, err := s.store.ZIncrBy(args[0].BulkString, args[2].BulkString, delta, store.ZAddOptions{})
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return sess.scoreReply(score)
+}
+
+func (s *server) zrem(args []resp.Value) resp.Value {
+	n, err := s.store.ZRem(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+// zmscore serves ZSCORE, which answers with a single score, and ZMSCORE,
+// which answers with one per member.
+func (s *server) zmscore(sess *session, cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	scores, err := s.store.ZMScore(args[0].BulkString, bulkStrings(args[1:]))
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	reply := make([]resp.Value, len(scores))
+	for i, score := range scores {
+		if score == nil {
+			reply[i] = resp.Value{Type: resp.NULL}
+		} else {
+			reply[i] = sess.scoreReply(*score)
+		}
+	}
+	if cmd == resp.CMD_ZSCORE {
+		return reply[0]
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
+
+func (s *server) zcard(args []resp.Value) resp.Value {
+	n, err := s.store.ZCard(args[0].BulkString)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+// zrank serves ZRANK and ZREVRANK.
+func (s *server) zrank(cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	rank, ok, err := s.store.ZRank(args[0].BulkString, args[1].BulkString, cmd == resp.CMD_ZREVRANK)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	if !ok {
+		return resp.Value{Type: resp.NULL}
+	}
+	return resp.NewIntegerValue(int64(rank))
+}
+
+func (s *server) zcount(args []resp.Value) resp.Value {
+	r, ok := parseScoreRange(args[1].BulkString, args[2].BulkString)
+	if !ok {
+		return resp.NewErrorValue("ERR min or max is not a float")
+	}
+
+	n, err := s.store.ZCount(args[0].BulkString, r)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+func (s *server) zlexcount(args []resp.Value) resp.Value {
+	r, empty, ok := parseLexRange(args[1].BulkString, args[2].BulkString)
+	if !ok {
+		return resp.NewErrorValue("ERR min or max not valid string range item")
+	}
+	if empty {
+		return resp.NewIntegerValue(0)
+	}
+
+	n, err := s.store.ZLexCount(args[0].BulkString, r)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return resp.NewIntegerValue(int64(n))
+}
+
+// zrange serves ZRANGE and its older single-purpose forms:
+//
+//	ZRANGE key start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+//	ZREVRANGE key start stop [WITHSCORES]
+//	ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+//	ZREVRANGEBYSCORE key max min [WITHSCORES] [LIMIT offset count]
+//	ZRANGEBYLEX key min max [LIMIT offset count]
+//	ZREVRANGEBYLEX key max min [LIMIT offset count]
+func (s *server) zrange(sess *session, cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	spec := store.ZRangeSpec{Count: -1}
+	switch cmd {
+	case resp.CMD_ZREVRANGE:
+		spec.Rev = true
+	case resp.CMD_ZRANGEBYSCORE:
+		spec.By = store.ZRangeByScore
+	case resp.CMD_ZREVRANGEBYSCORE:
+		spec.By, spec.Rev = store.ZRangeByScore, true
+	case resp.CMD_ZRANGEBYLEX:
+		spec.By = store.ZRangeByLex
+	case resp.CMD_ZREVRANGEBYLEX:
+		spec.By, spec.Rev = store.ZRangeByLex, true
+	}
+
+	withScores, hasLimit := false, false
+	for i := 3; i < len(args); i++ {
+		opt := strings.ToUpper(args[i].BulkString)
+		switch {
+		case opt == "WITHSCORES" && cmd != resp.CMD_ZRANGEBYLEX && cmd != resp.CMD_ZREVRANGEBYLEX:
+			withScores = true
+		case opt == "LIMIT" && cmd != resp.CMD_ZREVRANGE:
+			if i+2 >= len(args) {
+				return resp.NewErrorValue("ERR syntax error")
+			}
+			offset, err1 := strconv.Atoi(args[i+1].BulkString)
+			count, err2 := strconv.Atoi(args[i+2].BulkString)
+			if err1 != nil || err2 != nil {
+				return resp.NewErrorValue("ERR value is not an integer or out of range")
+			}
+			spec.Offset, spec.Count = offset, count
+			hasLimit = true
+			i += 2
+		case opt == "BYSCORE" && cmd == resp.CMD_ZRANGE:
+			spec.By = store.ZRangeByScore
+		case opt == "BYLEX" && cmd == resp.CMD_ZRANGE:
+			spec.By = store.ZRangeByLex
+		case opt == "REV" && cmd == resp.CMD_ZRANGE:
+			spec.Rev = true
+		default:
+			return resp.NewErrorValue("ERR syntax error")
+		}
+	}
+
+	if hasLimit && spec.By == store.ZRangeByRank {
+		return resp.NewErrorValue("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if withScores && spec.By == store.ZRangeByLex {
+		return resp.NewErrorValue("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+	if spec.Offset < 0 {
+		return sess.scoredMembersReply(nil, withScores)
+	}
+
+	// Reverse queries name the upper bound first.
+	lo, hi := args[1].BulkString, args[2].BulkString
+	if spec.Rev && spec.By != store.ZRangeByRank {
+		lo, hi = hi, lo
+	}
+
+	switch spec.By {
+	case store.ZRangeByRank:
+		start, err1 := strconv.Atoi(lo)
+		stop, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil {
+			return resp.NewErrorValue("ERR value is not an integer or out of range")
+		}
+		spec.Start, spec.Stop = start, stop
+
+	case store.ZRangeByScore:
+		r, ok := parseScoreRange(lo, hi)
+		if !ok {
+			return resp.NewErrorValue("ERR min or max is not a float")
+		}
+		spec.Score = r
+
+	case store.ZRangeByLex:
+		r, empty, ok := parseLexRange(lo, hi)
+		if !ok {
+			return resp.NewErrorValue("ERR min or max not valid string range item")
+		}
+		if empty {
+			return sess.scoredMembersReply(nil, withScores)
+		}
+		spec.Lex = r
+	}
+
+	members, err := s.store.ZRange(args[0].BulkString, spec)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+	return sess.scoredMembersReply(members, withScores)
+}
+
+// zpop serves ZPOPMIN and ZPOPMAX.
+//
+//	ZPOPMIN key [count]
+func (s *server) zpop(sess *session, cmd resp.RESPCommand, args []resp.Value) resp.Value {
+	if len(args) > 2 {
+		return wrongNumberOfArgs(cmd)
+	}
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1].BulkString)
+		if err != nil || n < 0 {
+			return resp.NewErrorValue("ERR value is out of range, must be positive")
+		}
+		count = n
+	}
+
+	members, err := s.store.ZPop(args[0].BulkString, count, cmd == resp.CMD_ZPOPMAX)
+	if err != nil {
+		return resp.NewErrorValue(err.Error())
+	}
+
+	if len(args) == 1 {
+		// Without a count RESP3 clients get a flat member/score pair.
+		reply := []resp.Value{}
+		for _, m := range members {
+			reply = append(reply, resp.Value{Type: resp.BULK_STRING, BulkString: m.Member}, sess.scoreReply(m.Score))
+		}
+		return resp.Value{Type: resp.ARRAY, Array: reply}
+	}
+	return sess.scoredMembersReply(members, true)
+}
+
+// parseScore reads a score, accepting "inf", "+inf" and "-inf" but not NaN.
+func parseScore(str string) (float64, bool) {
+	score, err := strconv.ParseFloat(str, 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, false
+	}
+	return score, true
+}
+
+// parseScoreRange reads ZRANGEBYSCORE style bounds, where a leading "("
+// makes a bound exclusive.
+func parseScoreRange(min, max string) (store.ScoreRange, bool) {
+	var r store.ScoreRange
+	var ok1, ok2 bool
+	r.Min, r.MinExclusive, ok1 = parseScoreBound(min)
+	r.Max, r.MaxExclusive, ok2 = parseScoreBound(max)
+	return r, ok1 && ok2
+}
+
+func parseScoreBound(bound string) (float64, bool, bool) {
+	exclusive := strings.HasPrefix(bound, "(")
+	if exclusive {
+		bound = bound[1:]
+	}
+	score, ok := parseScore(bound)
+	return score, exclusive, ok
+}
+
+// parseLexRange reads ZRANGEBYLEX style bounds: "[" and "(" prefix inclusive
+// and exclusive members, while "-" and "+" stand for the lowest and highest
+// possible member. It also reports bounds that can never match anything.
+func parseLexRange(min, max string) (r store.LexRange, empty bool, ok bool) {
+	switch {
+	case min == "-":
+		r.MinUnbounded = true
+	case min == "+":
+		empty = true
+	case strings.HasPrefix(min, "["), strings.HasPrefix(min, "("):
+		r.Min, r.MinExclusive = min[1:], min[0] == '('
+	default:
+		return r, false, false
+	}
+
+	switch {
+	case max == "+":
+		r.MaxUnbounded = true
+	case max == "-":
+		empty = true
+	case strings.HasPrefix(max, "["), strings.HasPrefix(max, "("):
+		r.Max, r.MaxExclusive = max[1:], max[0] == '('
+	default:
+		return r, false, false
+	}
+
+	return r, empty, true
+}
+
+// formatScore renders a score the way Redis does in RESP2 replies.
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	case math.IsNaN(score):
+		return "nan"
+	default:
+		return strconv.FormatFloat(score, 'g', -1, 64)
+	}
+}
+
+// scoreReply answers with a RESP3 double, or a bulk string under RESP2.
+func (sess *session) scoreReply(score float64) resp.Value {
+	if sess.protocol == 3 {
+		return resp.Value{Type: resp.DOUBLE, Double: score}
+	}
+	return resp.Value{Type: resp.BULK_STRING, BulkString: formatScore(score)}
+}
+
+// scoredMembersReply lists members, optionally with their scores: flat
+// member/score pairs under RESP2, one [member, score] array each under RESP3.
+func (sess *session) scoredMembersReply(members []store.ScoredMember, withScores bool) resp.Value {
+	reply := []resp.Value{}
+	for _, m := range members {
+		member := resp.Value{Type: resp.BULK_STRING, BulkString: m.Member}
+		switch {
+		case !withScores:
+			reply = append(reply, member)
+		case sess.protocol == 3:
+			reply = append(reply, resp.Value{Type: resp.ARRAY, Array: []resp.Value{member, sess.scoreReply(m.Score)}})
+		default:
+			reply = append(reply, member, sess.scoreReply(m.Score))
+		}
+	}
+	return resp.Value{Type: resp.ARRAY, Array: reply}
+}
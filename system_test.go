@@ -3,21 +3,48 @@ package simplekv_test
 import (
 	"fmt"
 	"log"
-	"os"
+	"math"
+	"path/filepath"
 	"regexp"
 	"simpleKV/client"
 	"simpleKV/server"
 	"simpleKV/server/store"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
-func TestSystem(t *testing.T) {
-	// Start the server
-	go func() {
-		store := store.NewStore(4, 4096)
-		server := server.NewServer(":6379", store)
+// testServer is a server started by startServer. It embeds the store the
+// server runs on and knows the file that store saves snapshots to.
+type testServer struct {
+	store.IStore
+	dump string
+}
+
+// startServer runs a fresh server on addr. It saves snapshots to a
+// directory of the test's own and is shut down when the test ends.
+func startServer(t *testing.T, addr string) *testServer {
+	t.Helper()
+	return startServerFrom(t, addr, filepath.Join(t.TempDir(), "dump.rdb"))
+}
+
+// startServerFrom is startServer with the store loaded from, and saving
+// to, dump.
+func startServerFrom(t *testing.T, addr, dump string) *testServer {
+	t.Helper()
+	store, err := store.NewStoreWithFile(4, 4096, dump)
+	if err != nil {
+		t.Fatalf("Failed to load %s: %v", dump, err)
+	}
+	server := server.NewServer(addr, store)
+	t.Cleanup(func() {
+		server.Close()
+		store.Close()
+	})
 
+	go func() {
 		err := server.Run()
 		if err != nil {
 			log.Fatalf("Failed to start server: %v", err)
@@ -25,6 +52,28 @@ func TestSystem(t *testing.T) {
 	}()
 	time.Sleep(time.Second * 3) // Give the server a moment to start
 
+	return &testServer{IStore: store, dump: dump}
+}
+
+// reload saves a snapshot and returns a store loaded from it, to check what
+// survives a restart.
+func (ts *testServer) reload(t *testing.T) store.IStore {
+	t.Helper()
+	if err := ts.SaveToDisk(); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+	restored, err := store.NewStoreWithFile(4, 4096, ts.dump)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	t.Cleanup(restored.Close)
+	return restored
+}
+
+func TestSystem(t *testing.T) {
+	// Start the server
+	s := startServer(t, ":6379")
+
 	// Connect to the server
 	c, err := client.NewClient("localhost:6379")
 	if err != nil {
@@ -85,26 +134,22 @@ func TestSystem(t *testing.T) {
 	if err := c.Set("persistentKey", "persistentValue"); err != nil {
 		t.Errorf("Failed to set persistent key: %v", err)
 	}
+	if _, err := c.Expire("persistentKey", time.Hour); err != nil {
+		t.Errorf("Failed to expire persistent key: %v", err)
+	}
+	if _, err := c.HSet("persistentHash", map[string]any{"field": "value"}); err != nil {
+		t.Errorf("Failed to set persistent hash: %v", err)
+	}
 	c.Close()
 
-	// Restart the server to test persistence
-	if err := os.Remove("dump.rdb"); err != nil && !os.IsNotExist(err) {
-		t.Fatalf("Failed to remove dump.rdb: %v", err)
+	// Snapshot and start a second server from the dump to test persistence
+	if err := s.SaveToDisk(); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
 	}
-
-	go func() {
-		store := store.NewStore(4, 4096)
-		server := server.NewServer(":6379", store)
-
-		err := server.Run()
-		if err != nil {
-			log.Fatalf("Failed to start server: %v", err)
-		}
-	}()
-	time.Sleep(time.Second * 3)
+	startServerFrom(t, ":6380", s.dump)
 
 	// Reconnect
-	c, err = client.NewClient("localhost:6379")
+	c, err = client.NewClient("localhost:6380")
 	if err != nil {
 		t.Fatalf("Failed to reconnect to server: %v", err)
 	}
@@ -115,6 +160,1841 @@ func TestSystem(t *testing.T) {
 	if err != nil || val != "persistentValue" {
 		t.Errorf("Persistence failed: got %v, expected 'persistentValue'", val)
 	}
+	if ttl, err := c.TTL("persistentKey"); err != nil || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("Persistence lost the deadline: got %v, %v", ttl, err)
+	}
+	if val, err := c.HGet("persistentHash", "field"); err != nil || val != "value" {
+		t.Errorf("Hash persistence failed: got %v, %v, expected 'value'", val, err)
+	}
 
 	fmt.Println("System test completed successfully.")
 }
+
+func TestExpiry(t *testing.T) {
+	startServer(t, ":6381")
+
+	c, err := client.NewClient("localhost:6381")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	if ttl, err := c.TTL("missing"); err != nil || ttl != -2 {
+		t.Errorf("TTL on missing key: got %v, %v, expected -2", ttl, err)
+	}
+
+	if err := c.Set("session", "abc"); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+	if ttl, err := c.TTL("session"); err != nil || ttl != -1 {
+		t.Errorf("TTL without deadline: got %v, %v, expected -1", ttl, err)
+	}
+
+	if ok, err := c.Expire("session", time.Minute); err != nil || !ok {
+		t.Errorf("EXPIRE failed: %v, %v", ok, err)
+	}
+	if ok, err := c.Persist("session"); err != nil || !ok {
+		t.Errorf("PERSIST failed: %v, %v", ok, err)
+	}
+	if ttl, _ := c.TTL("session"); ttl != -1 {
+		t.Errorf("TTL after PERSIST: got %v, expected -1", ttl)
+	}
+
+	if _, err := c.Expire("session", 100*time.Millisecond); err != nil {
+		t.Fatalf("EXPIRE failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if val, err := c.Get("session"); err != nil || val != nil {
+		t.Errorf("GET after expiry: got %v, %v, expected nil", val, err)
+	}
+	if ok, _ := c.Expire("session", time.Minute); ok {
+		t.Errorf("EXPIRE on expired key should report false")
+	}
+}
+
+func TestSetOptions(t *testing.T) {
+	startServer(t, ":6382")
+
+	c, err := client.NewClient("localhost:6382")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	if res, err := c.SetArgs("lock", "a", client.SetArgs{Mode: "XX"}); err != nil || res != nil {
+		t.Errorf("SET XX on missing key: got %v, %v, expected nil", res, err)
+	}
+	if res, err := c.SetArgs("lock", "a", client.SetArgs{Mode: "NX", TTL: time.Minute}); err != nil || res != "OK" {
+		t.Errorf("SET NX PX: got %v, %v, expected OK", res, err)
+	}
+	if res, err := c.SetArgs("lock", "b", client.SetArgs{Mode: "NX"}); err != nil || res != nil {
+		t.Errorf("SET NX on existing key: got %v, %v, expected nil", res, err)
+	}
+
+	res, err := c.SetArgs("lock", "c", client.SetArgs{Get: true, KeepTTL: true})
+	if err != nil || res != "a" {
+		t.Errorf("SET GET KEEPTTL: got %v, %v, expected 'a'", res, err)
+	}
+	if ttl, _ := c.TTL("lock"); ttl <= 0 {
+		t.Errorf("KEEPTTL dropped the deadline: got %v", ttl)
+	}
+
+	if _, err := c.SetArgs("lock", "d", client.SetArgs{}); err != nil {
+		t.Errorf("SET failed: %v", err)
+	}
+	if ttl, _ := c.TTL("lock"); ttl != -1 {
+		t.Errorf("plain SET should clear the deadline: got %v", ttl)
+	}
+}
+
+func TestHashes(t *testing.T) {
+	startServer(t, ":6383")
+
+	c, err := client.NewClient("localhost:6383")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	if n, err := c.HSet("user:1", map[string]any{"name": "John", "age": 30}); err != nil || n != 2 {
+		t.Errorf("HSET: got %v, %v, expected 2", n, err)
+	}
+	if n, err := c.HSet("user:1", map[string]any{"name": "Jane"}); err != nil || n != 0 {
+		t.Errorf("HSET overwrite: got %v, %v, expected 0", n, err)
+	}
+	if val, err := c.HGet("user:1", "name"); err != nil || val != "Jane" {
+		t.Errorf("HGET: got %v, %v, expected 'Jane'", val, err)
+	}
+	if vals, err := c.HMGet("user:1", "age", "missing"); err != nil || len(vals) != 2 || vals[0] != "30" || vals[1] != nil {
+		t.Errorf("HMGET: got %v, %v", vals, err)
+	}
+	if n, err := c.HIncrBy("user:1", "age", 5); err != nil || n != 35 {
+		t.Errorf("HINCRBY: got %v, %v, expected 35", n, err)
+	}
+	if _, err := c.HIncrBy("user:1", "name", 1); err == nil {
+		t.Errorf("HINCRBY on a non-integer field should fail")
+	}
+	if all, err := c.HGetAll("user:1"); err != nil || len(all) != 2 || all["age"] != "35" {
+		t.Errorf("HGETALL: got %v, %v", all, err)
+	}
+
+	fields, cursor, err := c.HScan("user:1", 0, "n*", 10)
+	if err != nil || cursor != 0 || len(fields) != 1 || fields["name"] != "Jane" {
+		t.Errorf("HSCAN: got %v, %d, %v", fields, cursor, err)
+	}
+
+	// Fields added and removed between pages must not hide the ones that
+	// stay put.
+	stable := make(map[string]any)
+	for i := 0; i < 300; i++ {
+		stable[fmt.Sprintf("f%d", i)] = i
+	}
+	if _, err := c.HSet("big", stable); err != nil {
+		t.Fatalf("HSET failed: %v", err)
+	}
+	seen := make(map[string]bool)
+	for page := 0; ; page++ {
+		fields, next, err := c.HScan("big", cursor, "", 10)
+		if err != nil {
+			t.Fatalf("HSCAN failed: %v", err)
+		}
+		for field := range fields {
+			seen[field] = true
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+		churn := make(map[string]any)
+		for i := 0; i < 50; i++ {
+			churn[fmt.Sprintf("churn:%d:%d", page, i)] = i
+		}
+		if _, err := c.HSet("big", churn); err != nil {
+			t.Fatalf("HSET failed: %v", err)
+		}
+		if page%2 == 1 {
+			for field := range churn {
+				if _, err := c.HDel("big", field); err != nil {
+					t.Fatalf("HDEL failed: %v", err)
+				}
+			}
+		}
+	}
+	for field := range stable {
+		if !seen[field] {
+			t.Errorf("HSCAN never returned %s", field)
+		}
+	}
+
+	if _, err := c.Get("user:1"); err == nil || !strings.HasPrefix(err.Error(), "WRONGTYPE") {
+		t.Errorf("GET on a hash should fail with WRONGTYPE, got %v", err)
+	}
+	if err := c.Set("plain", "value"); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+	if _, err := c.HGet("plain", "field"); err == nil || !strings.HasPrefix(err.Error(), "WRONGTYPE") {
+		t.Errorf("HGET on a string should fail with WRONGTYPE, got %v", err)
+	}
+
+	if n, err := c.HDel("user:1", "name", "age", "missing"); err != nil || n != 2 {
+		t.Errorf("HDEL: got %v, %v, expected 2", n, err)
+	}
+	if n, err := c.HLen("user:1"); err != nil || n != 0 {
+		t.Errorf("HLEN after deleting every field: got %v, %v, expected 0", n, err)
+	}
+}
+
+func TestLists(t *testing.T) {
+	startServer(t, ":6384")
+
+	c, err := client.NewClient("localhost:6384")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	if n, err := c.RPush("queue", "a", "b", "c"); err != nil || n != 3 {
+		t.Errorf("RPUSH: got %v, %v, expected 3", n, err)
+	}
+	if n, err := c.LPush("queue", "z"); err != nil || n != 4 {
+		t.Errorf("LPUSH: got %v, %v, expected 4", n, err)
+	}
+	if vals, err := c.LRange("queue", 0, -1); err != nil || strings.Join(vals, ",") != "z,a,b,c" {
+		t.Errorf("LRANGE: got %v, %v, expected z,a,b,c", vals, err)
+	}
+	if val, err := c.LPop("queue"); err != nil || val != "z" {
+		t.Errorf("LPOP: got %v, %v, expected 'z'", val, err)
+	}
+	if val, err := c.LMove("queue", "done", "RIGHT", "LEFT"); err != nil || val != "c" {
+		t.Errorf("LMOVE: got %v, %v, expected 'c'", val, err)
+	}
+	if n, err := c.LLen("queue"); err != nil || n != 2 {
+		t.Errorf("LLEN: got %v, %v, expected 2", n, err)
+	}
+
+	if vals, err := c.BLPop(100*time.Millisecond, "empty"); err != nil || vals != nil {
+		t.Errorf("BLPOP on an empty list should time out, got %v, %v", vals, err)
+	}
+	if err := c.Set("str", "v"); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+	if vals, err := c.BLPop(0, "str"); err == nil {
+		t.Errorf("BLPOP on a string should fail right away, got %v", vals)
+	}
+
+	// Park two clients on the same key and check they are served in order.
+	results := make(chan string, 2)
+	for i := range 2 {
+		blocked, err := client.NewClient("localhost:6384")
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		defer blocked.Close()
+
+		go func() {
+			vals, err := blocked.BLPop(5*time.Second, "jobs")
+			if err != nil || len(vals) != 2 {
+				results <- fmt.Sprintf("error: %v %v", vals, err)
+				return
+			}
+			results <- fmt.Sprintf("%d:%s", i, vals[1])
+		}()
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if _, err := c.RPush("jobs", "first", "second"); err != nil {
+		t.Fatalf("RPUSH failed: %v", err)
+	}
+	got := []string{<-results, <-results}
+	slices.Sort(got)
+	if strings.Join(got, ",") != "0:first,1:second" {
+		t.Errorf("blocked clients were not served in FIFO order: %v", got)
+	}
+
+	// A client that hangs up while blocked must not take the next push.
+	gone, err := client.NewClient("localhost:6384")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	go gone.BLPop(0, "orphaned")
+	time.Sleep(100 * time.Millisecond)
+	gone.Close()
+	time.Sleep(100 * time.Millisecond)
+	if _, err := c.RPush("orphaned", "kept"); err != nil {
+		t.Fatalf("RPUSH failed: %v", err)
+	}
+	if n, err := c.LLen("orphaned"); err != nil || n != 1 {
+		t.Errorf("push to a key a disconnected client blocked on: LLEN = %d, %v", n, err)
+	}
+}
+
+func TestSets(t *testing.T) {
+	startServer(t, ":6385")
+
+	c, err := client.NewClient("localhost:6385")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	if n, err := c.SAdd("a", 1, 2, 3, 3); err != nil || n != 3 {
+		t.Errorf("SADD: got %v, %v, expected 3", n, err)
+	}
+	if _, err := c.SAdd("b", 2, 3, 4); err != nil {
+		t.Fatalf("SADD failed: %v", err)
+	}
+	if ok, err := c.SIsMember("a", 2); err != nil || !ok {
+		t.Errorf("SISMEMBER: got %v, %v, expected true", ok, err)
+	}
+	if members, err := c.SInter("a", "b"); err != nil || strings.Join(members, ",") != "2,3" {
+		t.Errorf("SINTER: got %v, %v, expected 2,3", members, err)
+	}
+	if members, err := c.SUnion("a", "b", "missing"); err != nil || strings.Join(members, ",") != "1,2,3,4" {
+		t.Errorf("SUNION: got %v, %v, expected 1,2,3,4", members, err)
+	}
+	if members, err := c.SDiff("a", "b"); err != nil || strings.Join(members, ",") != "1" {
+		t.Errorf("SDIFF: got %v, %v, expected 1", members, err)
+	}
+
+	// Under RESP3 the same replies come back as sets.
+	if info, err := c.Hello(3); err != nil || info["proto"] != "3" {
+		t.Fatalf("HELLO 3: got %v, %v", info, err)
+	}
+	if members, err := c.SMembers("a"); err != nil || strings.Join(members, ",") != "1,2,3" {
+		t.Errorf("SMEMBERS over RESP3: got %v, %v, expected 1,2,3", members, err)
+	}
+
+	if n, err := c.SRem("a", 1, 2, 3); err != nil || n != 3 {
+		t.Errorf("SREM: got %v, %v, expected 3", n, err)
+	}
+	if n, err := c.SCard("a"); err != nil || n != 0 {
+		t.Errorf("SCARD after removing every member: got %v, %v, expected 0", n, err)
+	}
+}
+
+func TestSortedSets(t *testing.T) {
+	startServer(t, ":6386")
+
+	c, err := client.NewClient("localhost:6386")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	n, err := c.ZAdd("board",
+		client.ScoredMember{Member: "alice", Score: 30},
+		client.ScoredMember{Member: "bob", Score: 10},
+		client.ScoredMember{Member: "carol", Score: 20})
+	if err != nil || n != 3 {
+		t.Errorf("ZADD: got %v, %v, expected 3", n, err)
+	}
+	if members, err := c.ZRange("board", 0, -1); err != nil || strings.Join(members, ",") != "bob,carol,alice" {
+		t.Errorf("ZRANGE: got %v, %v, expected bob,carol,alice", members, err)
+	}
+	if score, err := c.ZIncrBy("board", 25, "bob"); err != nil || score != 35 {
+		t.Errorf("ZINCRBY: got %v, %v, expected 35", score, err)
+	}
+	if rank, ok, err := c.ZRank("board", "bob"); err != nil || !ok || rank != 2 {
+		t.Errorf("ZRANK: got %v, %v, %v, expected 2", rank, ok, err)
+	}
+	if members, err := c.ZRangeByScore("board", "(20", "+inf"); err != nil || strings.Join(members, ",") != "alice,bob" {
+		t.Errorf("ZRANGEBYSCORE: got %v, %v, expected alice,bob", members, err)
+	}
+
+	// Scores come back as doubles under RESP3.
+	if _, err := c.Hello(3); err != nil {
+		t.Fatalf("HELLO 3 failed: %v", err)
+	}
+	if score, ok, err := c.ZScore("board", "carol"); err != nil || !ok || score != 20 {
+		t.Errorf("ZSCORE over RESP3: got %v, %v, %v, expected 20", score, ok, err)
+	}
+	withScores, err := c.ZRangeWithScores("board", 0, 0)
+	if err != nil || len(withScores) != 1 || withScores[0] != (client.ScoredMember{Member: "carol", Score: 20}) {
+		t.Errorf("ZRANGE WITHSCORES over RESP3: got %v, %v", withScores, err)
+	}
+
+	popped, err := c.ZPopMin("board", 2)
+	if err != nil || len(popped) != 2 || popped[0].Member != "carol" || popped[1].Member != "alice" {
+		t.Errorf("ZPOPMIN: got %v, %v, expected carol and alice", popped, err)
+	}
+}
+
+func TestStreams(t *testing.T) {
+	s := startServer(t, ":6387")
+
+	c, err := client.NewClient("localhost:6387")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	for i, id := range []string{"1-1", "1-2", "2-0"} {
+		if got, err := c.XAdd("events", id, "n", i); err != nil || got != id {
+			t.Errorf("XADD %s: got %v, %v", id, got, err)
+		}
+	}
+	if _, err := c.XAdd("events", "1-5", "n", 9); err == nil {
+		t.Error("XADD with an ID below the top item should fail")
+	}
+	if id, err := c.XAdd("events", "*", "n", 3); err != nil || len(id) <= len("2-0") {
+		t.Errorf("XADD *: got %v, %v, expected a time-based ID", id, err)
+	}
+
+	entries, err := c.XRange("events", "1", "1")
+	if err != nil || len(entries) != 2 || entries[1].ID != "1-2" || entries[1].Values["n"] != "1" {
+		t.Errorf("XRANGE 1 1: got %v, %v, expected 1-1 and 1-2", entries, err)
+	}
+	if n, err := c.XTrimMaxLen("events", 3); err != nil || n != 1 {
+		t.Errorf("XTRIM: got %v, %v, expected 1", n, err)
+	}
+	if n, err := c.XLen("events"); err != nil || n != 3 {
+		t.Errorf("XLEN: got %v, %v, expected 3", n, err)
+	}
+
+	// A blocked XREAD is woken up by the next XADD.
+	reader, err := client.NewClient("localhost:6387")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer reader.Close()
+
+	read := make(chan map[string][]client.StreamEntry, 1)
+	go func() {
+		streams, err := reader.XRead(client.XReadArgs{Streams: []string{"feed", "$"}, Block: 5 * time.Second})
+		if err != nil {
+			t.Errorf("XREAD BLOCK failed: %v", err)
+		}
+		read <- streams
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := c.XAdd("feed", "5-0", "msg", "hi"); err != nil {
+		t.Fatalf("XADD failed: %v", err)
+	}
+	select {
+	case streams := <-read:
+		if len(streams["feed"]) != 1 || streams["feed"][0].Values["msg"] != "hi" {
+			t.Errorf("XREAD BLOCK: got %v, expected the new entry", streams)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("XREAD BLOCK was not woken up by XADD")
+	}
+
+	// Consumer groups track what each consumer has yet to acknowledge.
+	if err := c.XGroupCreate("events", "workers", "0", false); err != nil {
+		t.Fatalf("XGROUP CREATE failed: %v", err)
+	}
+	streams, err := c.XReadGroup("workers", "alice", client.XReadArgs{Streams: []string{"events", ">"}, Count: 2})
+	if err != nil || len(streams["events"]) != 2 {
+		t.Fatalf("XREADGROUP: got %v, %v, expected two entries", streams, err)
+	}
+	if n, err := c.XPending("events", "workers"); err != nil || n != 2 {
+		t.Errorf("XPENDING: got %v, %v, expected 2", n, err)
+	}
+	if n, err := c.XAck("events", "workers", streams["events"][0].ID); err != nil || n != 1 {
+		t.Errorf("XACK: got %v, %v, expected 1", n, err)
+	}
+	claimed, err := c.XClaim("events", "workers", "bob", 0, streams["events"][1].ID)
+	if err != nil || len(claimed) != 1 || claimed[0].ID != streams["events"][1].ID {
+		t.Errorf("XCLAIM: got %v, %v", claimed, err)
+	}
+
+	// The stream, its group and the pending entry survive a snapshot.
+	restored := s.reload(t)
+	if n, err := restored.XLen("events"); err != nil || n != 3 {
+		t.Errorf("XLEN after reload: got %v, %v, expected 3", n, err)
+	}
+	summary, err := restored.XPendingSummary("events", "workers")
+	if err != nil || summary.Count != 1 || summary.Consumers["bob"] != 1 {
+		t.Errorf("XPENDING after reload: got %+v, %v, expected one entry held by bob", summary, err)
+	}
+}
+
+func TestCounters(t *testing.T) {
+	startServer(t, ":6388")
+
+	c, err := client.NewClient("localhost:6388")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	if n, err := c.Incr("hits"); err != nil || n != 1 {
+		t.Errorf("INCR on a missing key: got %v, %v, expected 1", n, err)
+	}
+	if n, err := c.IncrBy("hits", 41); err != nil || n != 42 {
+		t.Errorf("INCRBY: got %v, %v, expected 42", n, err)
+	}
+	if n, err := c.DecrBy("hits", 2); err != nil || n != 40 {
+		t.Errorf("DECRBY: got %v, %v, expected 40", n, err)
+	}
+	if val, err := c.Get("hits"); err != nil || val != "40" {
+		t.Errorf("GET on a counter: got %v, %v, expected '40'", val, err)
+	}
+
+	if err := c.Set("name", "bob"); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+	if _, err := c.Incr("name"); err == nil || err.Error() != "ERR value is not an integer or out of range" {
+		t.Errorf("INCR on a non-integer: got %v, expected a not-an-integer error", err)
+	}
+	if _, err := c.IncrBy("hits", math.MaxInt64); err == nil || err.Error() != "ERR increment or decrement would overflow" {
+		t.Errorf("INCRBY past MaxInt64: got %v, expected an overflow error", err)
+	}
+
+	if f, err := c.IncrByFloat("price", 10.5); err != nil || f != 10.5 {
+		t.Errorf("INCRBYFLOAT: got %v, %v, expected 10.5", f, err)
+	}
+	if f, err := c.IncrByFloat("hits", 0.25); err != nil || f != 40.25 {
+		t.Errorf("INCRBYFLOAT on a counter: got %v, %v, expected 40.25", f, err)
+	}
+
+	// Increments from several connections must not lose updates.
+	var wg sync.WaitGroup
+	for range 4 {
+		worker, err := client.NewClient("localhost:6388")
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		defer worker.Close()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				if _, err := worker.Incr("shared"); err != nil {
+					t.Errorf("INCR failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if val, err := c.Get("shared"); err != nil || val != "400" {
+		t.Errorf("concurrent INCR: got %v, %v, expected '400'", val, err)
+	}
+}
+
+func TestStringEdits(t *testing.T) {
+	startServer(t, ":6389")
+
+	c, err := client.NewClient("localhost:6389")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	if n, err := c.Append("greeting", "Hello"); err != nil || n != 5 {
+		t.Errorf("APPEND on a missing key: got %v, %v, expected 5", n, err)
+	}
+	if n, err := c.Append("greeting", " World"); err != nil || n != 11 {
+		t.Errorf("APPEND: got %v, %v, expected 11", n, err)
+	}
+	if str, err := c.GetRange("greeting", -5, -1); err != nil || str != "World" {
+		t.Errorf("GETRANGE: got %q, %v, expected 'World'", str, err)
+	}
+	if n, err := c.SetRange("greeting", 6, "Redis"); err != nil || n != 11 {
+		t.Errorf("SETRANGE: got %v, %v, expected 11", n, err)
+	}
+	if n, err := c.SetRange("padded", 3, "x"); err != nil || n != 4 {
+		t.Errorf("SETRANGE past the end: got %v, %v, expected 4", n, err)
+	}
+	if val, err := c.Get("padded"); err != nil || val != "\x00\x00\x00x" {
+		t.Errorf("SETRANGE should pad with zero bytes, got %q, %v", val, err)
+	}
+	if _, err := c.SetRange("padded", math.MaxInt, "x"); err == nil {
+		t.Errorf("SETRANGE at the largest offset: expected an error")
+	}
+	if n, err := c.StrLen("greeting"); err != nil || n != 11 {
+		t.Errorf("STRLEN: got %v, %v, expected 11", n, err)
+	}
+
+	if val, err := c.GetEx("greeting", time.Minute); err != nil || val != "Hello Redis" {
+		t.Errorf("GETEX: got %v, %v, expected 'Hello Redis'", val, err)
+	}
+	if ttl, _ := c.TTL("greeting"); ttl <= 0 {
+		t.Errorf("GETEX did not set a deadline: got %v", ttl)
+	}
+	if val, err := c.GetDel("greeting"); err != nil || val != "Hello Redis" {
+		t.Errorf("GETDEL: got %v, %v, expected 'Hello Redis'", val, err)
+	}
+	if val, err := c.Get("greeting"); err != nil || val != nil {
+		t.Errorf("GETDEL left the key behind: got %v, %v", val, err)
+	}
+
+	c.Set("key1", "ohmytext")
+	c.Set("key2", "mynewtext")
+	if seq, err := c.LCS("key1", "key2"); err != nil || seq != "mytext" {
+		t.Errorf("LCS: got %q, %v, expected 'mytext'", seq, err)
+	}
+	c.Set("long1", strings.Repeat("a", 10000))
+	c.Set("long2", strings.Repeat("b", 10000))
+	if _, err := c.LCS("long1", "long2"); err == nil {
+		t.Errorf("LCS of strings past the table limit: expected an error")
+	}
+}
+
+func TestBitmaps(t *testing.T) {
+	startServer(t, ":6390")
+
+	c, err := client.NewClient("localhost:6390")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	// Users 0, 3 and 100 were active on Monday, 3 and 7 on Tuesday.
+	for _, user := range []int64{0, 3, 100} {
+		if prev, err := c.SetBit("active:mon", user, 1); err != nil || prev != 0 {
+			t.Errorf("SETBIT %d: got %v, %v, expected 0", user, prev, err)
+		}
+	}
+	for _, user := range []int64{3, 7} {
+		c.SetBit("active:tue", user, 1)
+	}
+
+	if bit, err := c.GetBit("active:mon", 100); err != nil || bit != 1 {
+		t.Errorf("GETBIT: got %v, %v, expected 1", bit, err)
+	}
+	if n, err := c.StrLen("active:mon"); err != nil || n != 13 {
+		t.Errorf("SETBIT should grow the value to 13 bytes, got %v, %v", n, err)
+	}
+	if n, err := c.BitCount("active:mon"); err != nil || n != 3 {
+		t.Errorf("BITCOUNT: got %v, %v, expected 3", n, err)
+	}
+	if pos, err := c.BitPos("active:tue", 1); err != nil || pos != 3 {
+		t.Errorf("BITPOS: got %v, %v, expected 3", pos, err)
+	}
+
+	if n, err := c.BitOp("AND", "active:both", "active:mon", "active:tue"); err != nil || n != 13 {
+		t.Errorf("BITOP AND: got %v, %v, expected 13", n, err)
+	}
+	if n, err := c.BitCount("active:both"); err != nil || n != 1 {
+		t.Errorf("BITCOUNT after BITOP AND: got %v, %v, expected 1", n, err)
+	}
+
+	results, err := c.BitField("counters", "INCRBY", "u2", 0, 3, "OVERFLOW", "SAT", "INCRBY", "u2", 2, 5,
+		"OVERFLOW", "FAIL", "INCRBY", "u2", 0, 1, "GET", "i4", 0)
+	if err != nil || len(results) != 4 {
+		t.Fatalf("BITFIELD: got %v, %v", results, err)
+	}
+	if *results[0] != 3 || *results[1] != 3 || results[2] != nil || *results[3] != -1 {
+		t.Errorf("BITFIELD: got %v %v %v %v, expected 3 3 nil -1", *results[0], *results[1], results[2], *results[3])
+	}
+	if _, err := c.BitField("counters", "GET", "u8", math.MaxInt64); err == nil {
+		t.Errorf("BITFIELD at the largest offset: expected an error")
+	}
+}
+
+func TestHyperLogLog(t *testing.T) {
+	s := startServer(t, ":6391")
+
+	c, err := client.NewClient("localhost:6391")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	if changed, err := c.PFAdd("small", "a", "b", "c"); err != nil || !changed {
+		t.Errorf("PFADD: got %v, %v, expected true", changed, err)
+	}
+	if changed, err := c.PFAdd("small", "a"); err != nil || changed {
+		t.Errorf("PFADD of a known element: got %v, %v, expected false", changed, err)
+	}
+	if n, err := c.PFCount("small"); err != nil || n != 3 {
+		t.Errorf("PFCOUNT: got %v, %v, expected 3", n, err)
+	}
+
+	// Enough visitors to move both sketches to the dense encoding.
+	addVisitors := func(key string, from, to int) {
+		for start := from; start < to; start += 500 {
+			batch := make([]any, 0, 500)
+			for i := start; i < min(start+500, to); i++ {
+				batch = append(batch, fmt.Sprintf("user:%d", i))
+			}
+			if _, err := c.PFAdd(key, batch...); err != nil {
+				t.Fatalf("PFADD failed: %v", err)
+			}
+		}
+	}
+	addVisitors("page:a", 0, 10000)
+	addVisitors("page:b", 5000, 15000)
+
+	within := func(got int64, want float64) bool {
+		return math.Abs(float64(got)-want) <= want*0.02
+	}
+	if n, err := c.PFCount("page:a"); err != nil || !within(n, 10000) {
+		t.Errorf("PFCOUNT page:a: got %v, %v, expected about 10000", n, err)
+	}
+	if n, err := c.PFCount("page:a", "page:b"); err != nil || !within(n, 15000) {
+		t.Errorf("PFCOUNT over two keys: got %v, %v, expected about 15000", n, err)
+	}
+	if err := c.PFMerge("site", "page:a", "page:b", "small"); err != nil {
+		t.Fatalf("PFMERGE failed: %v", err)
+	}
+	if n, err := c.PFCount("site"); err != nil || !within(n, 15003) {
+		t.Errorf("PFCOUNT after PFMERGE: got %v, %v, expected about 15003", n, err)
+	}
+
+	restored := s.reload(t)
+	for _, key := range []string{"small", "site"} {
+		want, _ := c.PFCount(key)
+		if n, err := restored.PFCount([]string{key}); err != nil || int64(n) != want {
+			t.Errorf("PFCOUNT %s after reload: got %v, %v, expected %v", key, n, err, want)
+		}
+	}
+}
+
+func TestGeo(t *testing.T) {
+	startServer(t, ":6392")
+
+	c, err := client.NewClient("localhost:6392")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	added, err := c.GeoAdd("drivers",
+		client.GeoLocation{Name: "palermo", Longitude: 13.361389, Latitude: 38.115556},
+		client.GeoLocation{Name: "catania", Longitude: 15.087269, Latitude: 37.502669},
+		client.GeoLocation{Name: "rome", Longitude: 12.496366, Latitude: 41.902782},
+	)
+	if err != nil || added != 3 {
+		t.Fatalf("GEOADD: got %v, %v, expected 3", added, err)
+	}
+
+	if dist, ok, err := c.GeoDist("drivers", "palermo", "catania", "km"); err != nil || !ok || math.Abs(dist-166.2742) > 1e-4 {
+		t.Errorf("GEODIST: got %v, %v, %v, expected 166.2742", dist, ok, err)
+	}
+	if _, ok, err := c.GeoDist("drivers", "palermo", "milan", ""); err != nil || ok {
+		t.Errorf("GEODIST with a missing member: got %v, %v, expected no distance", ok, err)
+	}
+
+	positions, err := c.GeoPos("drivers", "catania", "milan")
+	if err != nil || len(positions) != 2 || positions[0] == nil || positions[1] != nil {
+		t.Fatalf("GEOPOS: got %v, %v", positions, err)
+	}
+	if math.Abs(positions[0].Longitude-15.087269) > 1e-5 || math.Abs(positions[0].Latitude-37.502669) > 1e-5 {
+		t.Errorf("GEOPOS catania: got %v, %v", positions[0].Longitude, positions[0].Latitude)
+	}
+
+	if hashes, err := c.GeoHash("drivers", "palermo", "catania"); err != nil || !slices.Equal(hashes, []string{"sqc8b49rny0", "sqdtr74hyu0"}) {
+		t.Errorf("GEOHASH: got %v, %v", hashes, err)
+	}
+
+	near := client.GeoSearchQuery{Longitude: 15, Latitude: 37, Radius: 200, Unit: "km", Sort: "ASC"}
+	found, err := c.GeoSearch("drivers", near)
+	if err != nil || len(found) != 2 || found[0].Name != "catania" || found[1].Name != "palermo" {
+		t.Fatalf("GEOSEARCH BYRADIUS: got %v, %v, expected catania then palermo", found, err)
+	}
+	if math.Abs(found[0].Dist-56.4413) > 1e-4 {
+		t.Errorf("GEOSEARCH distance: got %v, expected 56.4413", found[0].Dist)
+	}
+
+	box := client.GeoSearchQuery{Member: "rome", Width: 100, Height: 100, Unit: "km"}
+	if found, err := c.GeoSearch("drivers", box); err != nil || len(found) != 1 || found[0].Name != "rome" {
+		t.Errorf("GEOSEARCH BYBOX: got %v, %v, expected rome", found, err)
+	}
+
+	farthest := client.GeoSearchQuery{Member: "catania", Radius: 1000, Unit: "km", Sort: "DESC", Count: 1}
+	if found, err := c.GeoSearch("drivers", farthest); err != nil || len(found) != 1 || found[0].Name != "rome" {
+		t.Errorf("GEOSEARCH DESC COUNT 1: got %v, %v, expected rome", found, err)
+	}
+
+	if n, err := c.GeoSearchStore("nearby", "drivers", near, false); err != nil || n != 2 {
+		t.Errorf("GEOSEARCHSTORE: got %v, %v, expected 2", n, err)
+	}
+	if dist, _, err := c.GeoDist("nearby", "palermo", "catania", "km"); err != nil || math.Abs(dist-166.2742) > 1e-4 {
+		t.Errorf("GEODIST on a stored result: got %v, %v", dist, err)
+	}
+	if n, err := c.GeoSearchStore("distances", "drivers", near, true); err != nil || n != 2 {
+		t.Errorf("GEOSEARCHSTORE STOREDIST: got %v, %v, expected 2", n, err)
+	}
+	if score, ok, err := c.ZScore("distances", "catania"); err != nil || !ok || math.Abs(score-56.4413) > 1e-3 {
+		t.Errorf("STOREDIST score: got %v, %v, %v, expected about 56.4413", score, ok, err)
+	}
+
+	if _, err := c.GeoAdd("drivers", client.GeoLocation{Name: "pole", Longitude: 0, Latitude: 89}); err == nil {
+		t.Error("GEOADD accepted a latitude outside the valid range")
+	}
+}
+
+func TestMultiKey(t *testing.T) {
+	startServer(t, ":6393")
+
+	c, err := client.NewClient("localhost:6393")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.MSet(map[string]any{"a": 1, "b": "two", "c": 3.5}); err != nil {
+		t.Fatalf("MSET failed: %v", err)
+	}
+	if _, err := c.LPush("list", "x"); err != nil {
+		t.Fatalf("LPUSH failed: %v", err)
+	}
+	vals, err := c.MGet("a", "b", "missing", "list", "c")
+	if err != nil || !slices.Equal(vals, []any{"1", "two", nil, nil, "3.5"}) {
+		t.Errorf("MGET: got %v, %v", vals, err)
+	}
+
+	if ok, err := c.MSetNX(map[string]any{"d": 4, "a": 5}); err != nil || ok {
+		t.Errorf("MSETNX with an existing key: got %v, %v, expected false", ok, err)
+	}
+	if val, _ := c.Get("d"); val != nil {
+		t.Errorf("MSETNX wrote part of a failed batch: d = %v", val)
+	}
+	if ok, err := c.MSetNX(map[string]any{"d": 4, "e": 5}); err != nil || !ok {
+		t.Errorf("MSETNX with new keys: got %v, %v, expected true", ok, err)
+	}
+
+	// Racing MSETNX calls over overlapping keys spread across shards: exactly
+	// one may win, and its whole batch must be visible.
+	var wg sync.WaitGroup
+	wins := make(chan int, 8)
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rc, err := client.NewClient("localhost:6393")
+			if err != nil {
+				t.Errorf("Failed to connect to server: %v", err)
+				return
+			}
+			defer rc.Close()
+
+			batch := map[string]any{}
+			for k := range 16 {
+				batch[fmt.Sprintf("race:%d", k)] = i
+			}
+			if ok, err := rc.MSetNX(batch); err != nil {
+				t.Errorf("MSETNX failed: %v", err)
+			} else if ok {
+				wins <- i
+			}
+		}()
+	}
+	wg.Wait()
+	close(wins)
+
+	if len(wins) != 1 {
+		t.Fatalf("racing MSETNX: %d winners, expected 1", len(wins))
+	}
+	winner := fmt.Sprint(<-wins)
+	keys := make([]string, 16)
+	for k := range keys {
+		keys[k] = fmt.Sprintf("race:%d", k)
+	}
+	vals, err = c.MGet(keys...)
+	if err != nil {
+		t.Fatalf("MGET failed: %v", err)
+	}
+	for k, v := range vals {
+		if v != winner {
+			t.Errorf("race:%d = %v, expected the winner's value %s", k, v, winner)
+		}
+	}
+}
+
+func TestKeyspace(t *testing.T) {
+	startServer(t, ":6394")
+
+	c, err := client.NewClient("localhost:6394")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	if key, err := c.RandomKey(); err != nil || key != nil {
+		t.Errorf("RANDOMKEY on an empty store: got %v, %v, expected nil", key, err)
+	}
+
+	if err := c.Set("greeting", "hello"); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+	if _, err := c.Expire("greeting", time.Hour); err != nil {
+		t.Fatalf("EXPIRE failed: %v", err)
+	}
+	if _, err := c.RPush("queue", "a", "b"); err != nil {
+		t.Fatalf("RPUSH failed: %v", err)
+	}
+	if _, err := c.SAdd("tags", "x", "y"); err != nil {
+		t.Fatalf("SADD failed: %v", err)
+	}
+
+	if n, err := c.Exists("greeting", "queue", "missing", "greeting"); err != nil || n != 3 {
+		t.Errorf("EXISTS: got %v, %v, expected 3", n, err)
+	}
+	for key, want := range map[string]string{"greeting": "string", "queue": "list", "tags": "set", "missing": "none"} {
+		if typ, err := c.Type(key); err != nil || typ != want {
+			t.Errorf("TYPE %s: got %v, %v, expected %s", key, typ, err, want)
+		}
+	}
+	if n, err := c.DBSize(); err != nil || n != 3 {
+		t.Errorf("DBSIZE: got %v, %v, expected 3", n, err)
+	}
+	if key, err := c.RandomKey(); err != nil || !slices.Contains([]any{"greeting", "queue", "tags"}, key) {
+		t.Errorf("RANDOMKEY: got %v, %v", key, err)
+	}
+
+	// Enough renames that source and destination land in different shards.
+	for i := range 8 {
+		src, dst := fmt.Sprintf("greeting%d", i-1), fmt.Sprintf("greeting%d", i)
+		if i == 0 {
+			src = "greeting"
+		}
+		if err := c.Rename(src, dst); err != nil {
+			t.Fatalf("RENAME %s %s failed: %v", src, dst, err)
+		}
+	}
+	if val, err := c.Get("greeting7"); err != nil || val != "hello" {
+		t.Errorf("GET after RENAME: got %v, %v, expected 'hello'", val, err)
+	}
+	if ttl, err := c.TTL("greeting7"); err != nil || ttl <= 0 {
+		t.Errorf("RENAME dropped the deadline: got %v, %v", ttl, err)
+	}
+	if val, err := c.Get("greeting"); err != nil || val != nil {
+		t.Errorf("GET of the old name: got %v, %v, expected nil", val, err)
+	}
+	if err := c.Rename("missing", "other"); err == nil {
+		t.Error("RENAME of a missing key should fail")
+	}
+	if ok, err := c.RenameNX("queue", "tags"); err != nil || ok {
+		t.Errorf("RENAMENX onto an existing key: got %v, %v, expected false", ok, err)
+	}
+
+	if ok, err := c.Copy("tags", "tags2", false); err != nil || !ok {
+		t.Errorf("COPY: got %v, %v, expected true", ok, err)
+	}
+	if _, err := c.SAdd("tags2", "z"); err != nil {
+		t.Fatalf("SADD failed: %v", err)
+	}
+	if n, err := c.SCard("tags"); err != nil || n != 2 {
+		t.Errorf("COPY shares data with its source: SCARD got %v, %v, expected 2", n, err)
+	}
+	if ok, err := c.Copy("queue", "tags2", false); err != nil || ok {
+		t.Errorf("COPY onto an existing key: got %v, %v, expected false", ok, err)
+	}
+	if ok, err := c.Copy("queue", "tags2", true); err != nil || !ok {
+		t.Errorf("COPY REPLACE: got %v, %v, expected true", ok, err)
+	}
+	if typ, _ := c.Type("tags2"); typ != "list" {
+		t.Errorf("TYPE after COPY REPLACE: got %v, expected list", typ)
+	}
+	if n, err := c.Touch("queue", "tags2", "missing"); err != nil || n != 2 {
+		t.Errorf("TOUCH: got %v, %v, expected 2", n, err)
+	}
+}
+
+func TestMaxMemory(t *testing.T) {
+	s := startServer(t, ":6395")
+
+	c, err := client.NewClient("localhost:6395")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	if cfg, err := c.ConfigGet("maxmemory*"); err != nil || cfg["maxmemory"] != "0" || cfg["maxmemory-policy"] != "noeviction" {
+		t.Errorf("CONFIG GET defaults: got %v, %v", cfg, err)
+	}
+
+	const limit = 64 << 10
+	value := strings.Repeat("v", 100)
+	if err := c.ConfigSet("maxmemory", "64kb"); err != nil {
+		t.Fatalf("CONFIG SET maxmemory failed: %v", err)
+	}
+	if err := c.ConfigSet("maxmemory-policy", "allkeys-lru"); err != nil {
+		t.Fatalf("CONFIG SET maxmemory-policy failed: %v", err)
+	}
+	if err := c.Set("hot", value); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+	for i := range 2000 {
+		if err := c.Set(fmt.Sprintf("cold:%d", i), value); err != nil {
+			t.Fatalf("SET under allkeys-lru failed: %v", err)
+		}
+		if _, err := c.Get("hot"); err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+	}
+
+	if used := s.UsedMemory(); used > limit+1024 {
+		t.Errorf("used memory %d is well over the %d limit", used, limit)
+	}
+	if n, err := c.DBSize(); err != nil || n == 0 || n >= 2001 {
+		t.Errorf("DBSIZE after eviction: got %v, %v", n, err)
+	}
+	if val, err := c.Get("hot"); err != nil || val != value {
+		t.Errorf("the recently used key was evicted: got %v, %v", val, err)
+	}
+	if val, err := c.Get("cold:0"); err != nil || val != nil {
+		t.Errorf("the least recently used key survived: got %v, %v", val, err)
+	}
+
+	if err := c.ConfigSet("maxmemory-policy", "noeviction"); err != nil {
+		t.Fatalf("CONFIG SET maxmemory-policy failed: %v", err)
+	}
+	if err := c.ConfigSet("maxmemory", "1kb"); err != nil {
+		t.Fatalf("CONFIG SET maxmemory failed: %v", err)
+	}
+	if err := c.Set("one-more", value); err == nil || !strings.HasPrefix(err.Error(), "OOM") {
+		t.Errorf("SET over maxmemory with noeviction: got %v, expected an OOM error", err)
+	}
+	if val, err := c.Get("hot"); err != nil || val != value {
+		t.Errorf("GET over maxmemory: got %v, %v", val, err)
+	}
+	if err := c.Del("hot"); err != nil {
+		t.Errorf("DEL over maxmemory: %v", err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	startServer(t, ":6396")
+
+	c, err := client.NewClient("localhost:6396")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	if _, ok, err := c.MemoryUsage("missing"); err != nil || ok {
+		t.Errorf("MEMORY USAGE of a missing key: got %v, %v, expected none", ok, err)
+	}
+
+	small := strings.Repeat("x", 10)
+	big := strings.Repeat("x", 10000)
+	if err := c.MSet(map[string]any{"small": small, "big": big}); err != nil {
+		t.Fatalf("MSET failed: %v", err)
+	}
+	smallSize, _, err := c.MemoryUsage("small")
+	if err != nil {
+		t.Fatalf("MEMORY USAGE failed: %v", err)
+	}
+	bigSize, _, err := c.MemoryUsage("big")
+	if err != nil {
+		t.Fatalf("MEMORY USAGE failed: %v", err)
+	}
+	if want := int64(len("big"+big) - len("small"+small)); bigSize-smallSize != want {
+		t.Errorf("MEMORY USAGE: small=%d big=%d, expected them to differ by %d", smallSize, bigSize, want)
+	}
+
+	members := make([]any, 1000)
+	for i := range members {
+		members[i] = fmt.Sprintf("member:%04d", i)
+	}
+	if _, err := c.SAdd("members", members...); err != nil {
+		t.Fatalf("SADD failed: %v", err)
+	}
+	if size, _, err := c.MemoryUsage("members"); err != nil || size < 1000*int64(len("member:0000")) {
+		t.Errorf("MEMORY USAGE of a set: got %v, %v, expected at least its payload", size, err)
+	}
+
+	stats, err := c.MemoryStats()
+	if err != nil {
+		t.Fatalf("MEMORY STATS failed: %v", err)
+	}
+	if stats["keys.count"] != 3 {
+		t.Errorf("MEMORY STATS keys.count: got %d, expected 3", stats["keys.count"])
+	}
+	if stats["dataset.bytes"] < bigSize+smallSize {
+		t.Errorf("MEMORY STATS dataset.bytes: got %d, expected at least %d", stats["dataset.bytes"], bigSize+smallSize)
+	}
+	if stats["total.allocated"] != stats["dataset.bytes"]+stats["bloomfilter.bytes"] {
+		t.Errorf("MEMORY STATS total doesn't add up: %v", stats)
+	}
+	var shardKeys int64
+	for i := range 4 {
+		shardKeys += stats[fmt.Sprintf("shard.%d.keys", i)]
+	}
+	if shardKeys != 3 {
+		t.Errorf("MEMORY STATS per-shard keys add up to %d, expected 3", shardKeys)
+	}
+
+	if err := c.Del("big"); err != nil {
+		t.Fatalf("DEL failed: %v", err)
+	}
+	if after, _ := c.MemoryStats(); stats["dataset.bytes"]-after["dataset.bytes"] != bigSize {
+		t.Errorf("DEL freed %d bytes, expected %d", stats["dataset.bytes"]-after["dataset.bytes"], bigSize)
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	startServer(t, ":6397")
+
+	connect := func() client.IClient {
+		c, err := client.NewClient("localhost:6397")
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+	publisher, subscriber, watcher := connect(), connect(), connect()
+
+	if err := subscriber.Subscribe("news", "sports"); err != nil {
+		t.Fatalf("SUBSCRIBE failed: %v", err)
+	}
+	if _, err := watcher.Hello(3); err != nil {
+		t.Fatalf("HELLO 3 failed: %v", err)
+	}
+	if err := watcher.PSubscribe("news.*"); err != nil {
+		t.Fatalf("PSUBSCRIBE failed: %v", err)
+	}
+
+	if channels, err := publisher.PubSubChannels(""); err != nil || !slices.Equal(channels, []string{"news", "sports"}) {
+		t.Errorf("PUBSUB CHANNELS: got %v, %v", channels, err)
+	}
+	if counts, err := publisher.PubSubNumSub("news", "weather"); err != nil || counts["news"] != 1 || counts["weather"] != 0 {
+		t.Errorf("PUBSUB NUMSUB: got %v, %v", counts, err)
+	}
+
+	if n, err := publisher.Publish("news", "hello"); err != nil || n != 1 {
+		t.Errorf("PUBLISH news: got %v, %v, expected 1", n, err)
+	}
+	if n, err := publisher.Publish("news.local", "fire"); err != nil || n != 1 {
+		t.Errorf("PUBLISH news.local: got %v, %v, expected 1", n, err)
+	}
+
+	want := client.Message{Kind: "message", Channel: "news", Payload: "hello"}
+	if msg, err := subscriber.ReceiveMessage(time.Second); err != nil || msg != want {
+		t.Errorf("RESP2 subscriber: got %+v, %v, expected %+v", msg, err, want)
+	}
+	want = client.Message{Kind: "pmessage", Pattern: "news.*", Channel: "news.local", Payload: "fire"}
+	if msg, err := watcher.ReceiveMessage(time.Second); err != nil || msg != want {
+		t.Errorf("RESP3 pattern subscriber: got %+v, %v, expected %+v", msg, err, want)
+	}
+
+	if _, err := subscriber.Get("key"); err == nil || !strings.Contains(err.Error(), "only (P|S)SUBSCRIBE") {
+		t.Errorf("GET in RESP2 subscriber mode: got %v, expected an error", err)
+	}
+	if _, err := watcher.Get("key"); err != nil {
+		t.Errorf("GET in RESP3 subscriber mode: %v", err)
+	}
+
+	if err := subscriber.SSubscribe("orders"); err != nil {
+		t.Fatalf("SSUBSCRIBE failed: %v", err)
+	}
+	if n, err := publisher.Publish("orders", "ignored"); err != nil || n != 0 {
+		t.Errorf("PUBLISH to a shard channel: got %v, %v, expected 0", n, err)
+	}
+	if n, err := publisher.SPublish("orders", "42"); err != nil || n != 1 {
+		t.Errorf("SPUBLISH: got %v, %v, expected 1", n, err)
+	}
+	want = client.Message{Kind: "smessage", Channel: "orders", Payload: "42"}
+	if msg, err := subscriber.ReceiveMessage(time.Second); err != nil || msg != want {
+		t.Errorf("shard subscriber: got %+v, %v, expected %+v", msg, err, want)
+	}
+
+	if err := subscriber.Unsubscribe(); err != nil {
+		t.Fatalf("UNSUBSCRIBE failed: %v", err)
+	}
+	if err := subscriber.SUnsubscribe(); err != nil {
+		t.Fatalf("SUNSUBSCRIBE failed: %v", err)
+	}
+	if _, err := subscriber.Get("key"); err != nil {
+		t.Errorf("GET after leaving subscriber mode: %v", err)
+	}
+
+	watcher.Close()
+	deadline := time.Now().Add(time.Second)
+	for {
+		n, err := publisher.Publish("news.local", "anyone?")
+		if err != nil {
+			t.Fatalf("PUBLISH failed: %v", err)
+		}
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("a closed connection still receives messages")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A subscriber that stops reading is disconnected once it falls behind,
+	// without holding up the publisher.
+	stalled := connect()
+	if err := stalled.Subscribe("firehose"); err != nil {
+		t.Fatalf("SUBSCRIBE failed: %v", err)
+	}
+	payload := strings.Repeat("x", 16<<10)
+	start := time.Now()
+	for i := 0; ; i++ {
+		n, err := publisher.Publish("firehose", payload)
+		if err != nil {
+			t.Fatalf("PUBLISH failed: %v", err)
+		}
+		if n == 0 {
+			break
+		}
+		if i == 10000 || time.Since(start) > 10*time.Second {
+			t.Fatalf("a subscriber that stopped reading is still subscribed after %d messages", i)
+		}
+	}
+}
+
+func TestKeyspaceNotifications(t *testing.T) {
+	s := startServer(t, ":6398")
+
+	connect := func() client.IClient {
+		c, err := client.NewClient("localhost:6398")
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+	c, watcher := connect(), connect()
+
+	if err := c.ConfigSet("notify-keyspace-events", "Kq"); err == nil {
+		t.Errorf("CONFIG SET notify-keyspace-events Kq: expected an error")
+	}
+	if err := c.ConfigSet("notify-keyspace-events", "KEg$x"); err != nil {
+		t.Fatalf("CONFIG SET notify-keyspace-events failed: %v", err)
+	}
+	if got, err := c.ConfigGet("notify-keyspace-events"); err != nil || got["notify-keyspace-events"] != "g$xKE" {
+		t.Errorf("CONFIG GET notify-keyspace-events: got %v, %v", got, err)
+	}
+
+	if err := watcher.Subscribe("__keyspace@0__:user"); err != nil {
+		t.Fatalf("SUBSCRIBE failed: %v", err)
+	}
+	if err := watcher.PSubscribe("__keyevent@0__:*"); err != nil {
+		t.Fatalf("PSUBSCRIBE failed: %v", err)
+	}
+
+	keyspace := func(key, event string) client.Message {
+		return client.Message{Kind: "message", Channel: "__keyspace@0__:" + key, Payload: event}
+	}
+	keyevent := func(event, key string) client.Message {
+		return client.Message{Kind: "pmessage", Pattern: "__keyevent@0__:*", Channel: "__keyevent@0__:" + event, Payload: key}
+	}
+	receive := func(n int) []client.Message {
+		t.Helper()
+		var msgs []client.Message
+		for range n {
+			msg, err := watcher.ReceiveMessage(time.Second)
+			if err != nil {
+				t.Fatalf("Failed to receive a notification: %v", err)
+			}
+			msgs = append(msgs, msg)
+		}
+		return msgs
+	}
+	expect := func(what string, want ...client.Message) {
+		t.Helper()
+		got := receive(len(want))
+		for _, w := range want {
+			if !slices.Contains(got, w) {
+				t.Errorf("%s: got %+v, expected %+v", what, got, want)
+				return
+			}
+		}
+	}
+
+	if err := c.Set("user", "alice"); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+	expect("SET", keyspace("user", "set"), keyevent("set", "user"))
+
+	// Lists are not among the selected classes, so LPUSH stays quiet and the
+	// next notification is the one for EXPIRE.
+	if _, err := c.LPush("queue", "job"); err != nil {
+		t.Fatalf("LPUSH failed: %v", err)
+	}
+	if _, err := c.Expire("user", 50*time.Millisecond); err != nil {
+		t.Fatalf("EXPIRE failed: %v", err)
+	}
+	expect("EXPIRE", keyspace("user", "expire"), keyevent("expire", "user"))
+	expect("expiry", keyspace("user", "expired"), keyevent("expired", "user"))
+
+	if err := c.Set("user", "bob"); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+	if err := c.Del("queue"); err != nil {
+		t.Fatalf("DEL failed: %v", err)
+	}
+	expect("SET and DEL", keyspace("user", "set"), keyevent("set", "user"), keyevent("del", "queue"))
+
+	if err := s.SaveToDisk(); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+	if err := c.Del("user"); err != nil {
+		t.Fatalf("DEL failed: %v", err)
+	}
+	if err := c.Set("temp", "x"); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+	expect("DEL and SET", keyspace("user", "del"), keyevent("del", "user"), keyevent("set", "temp"))
+
+	// Reloading drops the key written after the snapshot and brings back
+	// the one deleted since.
+	if err := s.LoadFromDisk(); err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	expect("reload", keyevent("del", "temp"), keyspace("user", "loaded"), keyevent("loaded", "user"))
+}
+
+func TestTransactions(t *testing.T) {
+	startServer(t, ":6400")
+
+	connect := func() client.IClient {
+		c, err := client.NewClient("localhost:6400")
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+	c, other := connect(), connect()
+
+	// A client parked in BLPOP must not hold up transactions.
+	popped := make(chan []string, 1)
+	go func() {
+		values, err := other.BLPop(0, "jobs")
+		if err != nil {
+			t.Errorf("BLPOP failed: %v", err)
+		}
+		popped <- values
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	if err := c.Set("stock", 10); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+	if err := c.Watch("stock"); err != nil {
+		t.Fatalf("WATCH failed: %v", err)
+	}
+	if err := c.Multi(); err != nil {
+		t.Fatalf("MULTI failed: %v", err)
+	}
+	if err := c.Multi(); err == nil || !strings.Contains(err.Error(), "nested") {
+		t.Errorf("nested MULTI: got %v, expected an error", err)
+	}
+	for _, cmd := range [][]any{{"DECRBY", "stock", 3}, {"GET", "stock"}, {"RPUSH", "jobs", "restock"}} {
+		if err := c.Queue(cmd...); err != nil {
+			t.Fatalf("Failed to queue %v: %v", cmd, err)
+		}
+	}
+	replies, ok, err := c.Exec()
+	if err != nil || !ok || !slices.Equal(replies, []any{int64(7), "7", int64(1)}) {
+		t.Errorf("EXEC: got %v, %v, %v", replies, ok, err)
+	}
+	select {
+	case values := <-popped:
+		if !slices.Equal(values, []string{"jobs", "restock"}) {
+			t.Errorf("BLPOP: got %v", values)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("BLPOP was not served by the transaction's push")
+	}
+
+	// A watched key changed by another client aborts EXEC.
+	if err := c.Watch("stock"); err != nil {
+		t.Fatalf("WATCH failed: %v", err)
+	}
+	if _, err := other.IncrBy("stock", 5); err != nil {
+		t.Fatalf("INCRBY failed: %v", err)
+	}
+	c.Multi()
+	c.Queue("DECRBY", "stock", 3)
+	if replies, ok, err := c.Exec(); err != nil || ok {
+		t.Errorf("EXEC after a watched key changed: got %v, %v, %v, expected an abort", replies, ok, err)
+	}
+	if got, _ := c.Get("stock"); got != "12" {
+		t.Errorf("stock after the aborted EXEC: got %v, expected 12", got)
+	}
+
+	// UNWATCH forgets the change.
+	c.Watch("stock")
+	other.IncrBy("stock", 1)
+	if err := c.Unwatch(); err != nil {
+		t.Fatalf("UNWATCH failed: %v", err)
+	}
+	c.Multi()
+	if replies, ok, err := c.Exec(); err != nil || !ok || len(replies) != 0 {
+		t.Errorf("EXEC after UNWATCH: got %v, %v, %v", replies, ok, err)
+	}
+
+	// Errors while queuing discard the whole transaction.
+	c.Multi()
+	if err := c.Queue("SET", "flag"); err == nil {
+		t.Errorf("queuing SET with a missing argument: expected an error")
+	}
+	if err := c.Queue("NOSUCHCOMMAND"); err == nil {
+		t.Errorf("queuing an unknown command: expected an error")
+	}
+	c.Queue("SET", "flag", "on")
+	if _, _, err := c.Exec(); err == nil || !strings.HasPrefix(err.Error(), "EXECABORT") {
+		t.Errorf("EXEC after queuing errors: got %v, expected EXECABORT", err)
+	}
+	if got, _ := c.Get("flag"); got != nil {
+		t.Errorf("flag after EXECABORT: got %v, expected nil", got)
+	}
+
+	// Errors while running are reported in place and don't stop the rest.
+	c.Multi()
+	c.Queue("SET", "name", "abc")
+	c.Queue("INCR", "name")
+	c.Queue("BLPOP", "nothing", 0)
+	c.Queue("SET", "flag", "on")
+	replies, ok, err = c.Exec()
+	if err != nil || !ok || len(replies) != 4 {
+		t.Fatalf("EXEC: got %v, %v, %v", replies, ok, err)
+	}
+	if _, isErr := replies[1].(error); !isErr || replies[0] != "OK" || replies[2] != nil || replies[3] != "OK" {
+		t.Errorf("EXEC with a failing command: got %v", replies)
+	}
+
+	c.Multi()
+	c.Queue("DEL", "flag")
+	if err := c.Discard(); err != nil {
+		t.Fatalf("DISCARD failed: %v", err)
+	}
+	if _, _, err := c.Exec(); err == nil || !strings.Contains(err.Error(), "without MULTI") {
+		t.Errorf("EXEC after DISCARD: got %v, expected an error", err)
+	}
+	if got, _ := c.Get("flag"); got != "on" {
+		t.Errorf("flag after DISCARD: got %v, expected on", got)
+	}
+}
+
+func TestCommandTable(t *testing.T) {
+	startServer(t, ":6401")
+
+	c, err := client.NewClient("localhost:6401")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	count, err := c.CommandCount()
+	if err != nil || count < 100 {
+		t.Errorf("COMMAND COUNT: got %v, %v", count, err)
+	}
+
+	infos, err := c.CommandInfo("get", "mset", "blpop", "xread", "nosuchcommand")
+	if err != nil || len(infos) != 5 {
+		t.Fatalf("COMMAND INFO: got %v, %v", infos, err)
+	}
+	get, mset, blpop, xread := infos[0], infos[1], infos[2], infos[3]
+	if get.Name != "get" || get.Arity != 2 || !slices.Contains(get.Flags, "readonly") ||
+		get.FirstKey != 1 || get.LastKey != 1 || get.Step != 1 || !slices.Contains(get.ACLCategories, "@string") {
+		t.Errorf("COMMAND INFO get: got %+v", get)
+	}
+	if mset.Arity != -3 || !slices.Contains(mset.Flags, "denyoom") || mset.LastKey != -1 || mset.Step != 2 {
+		t.Errorf("COMMAND INFO mset: got %+v", mset)
+	}
+	if !slices.Contains(blpop.Flags, "blocking") || blpop.LastKey != -2 {
+		t.Errorf("COMMAND INFO blpop: got %+v", blpop)
+	}
+	if !slices.Contains(xread.Flags, "movablekeys") || xread.FirstKey != 0 {
+		t.Errorf("COMMAND INFO xread: got %+v", xread)
+	}
+	if infos[4] != nil {
+		t.Errorf("COMMAND INFO of an unknown command: got %+v, expected nil", infos[4])
+	}
+
+	all, err := c.CommandInfo()
+	if err != nil || int64(len(all)) != count {
+		t.Errorf("COMMAND INFO without names: got %d entries, %v, expected %d", len(all), err, count)
+	}
+
+	docs, err := c.CommandDocs("set", "nosuchcommand")
+	if err != nil || len(docs) != 1 || docs["set"].Group != "string" || docs["set"].Since != "1.0.0" || docs["set"].Summary == "" {
+		t.Errorf("COMMAND DOCS: got %v, %v", docs, err)
+	}
+
+	for _, tc := range []struct {
+		args []any
+		keys []string
+	}{
+		{[]any{"SET", "a", "1"}, []string{"a"}},
+		{[]any{"MSET", "a", "1", "b", "2"}, []string{"a", "b"}},
+		{[]any{"BLPOP", "q1", "q2", 0}, []string{"q1", "q2"}},
+		{[]any{"SINTERCARD", 2, "s1", "s2", "LIMIT", 5}, []string{"s1", "s2"}},
+		{[]any{"XREAD", "COUNT", 1, "STREAMS", "x", "y", "0", "0"}, []string{"x", "y"}},
+		{[]any{"MEMORY", "USAGE", "big"}, []string{"big"}},
+	} {
+		if keys, err := c.CommandGetKeys(tc.args...); err != nil || !slices.Equal(keys, tc.keys) {
+			t.Errorf("COMMAND GETKEYS %v: got %v, %v, expected %v", tc.args, keys, err, tc.keys)
+		}
+	}
+	if _, err := c.CommandGetKeys("PING"); err == nil {
+		t.Errorf("COMMAND GETKEYS PING: expected an error")
+	}
+	if _, err := c.CommandGetKeys("GET"); err == nil {
+		t.Errorf("COMMAND GETKEYS with too few arguments: expected an error")
+	}
+	for _, args := range [][]any{{"SINTERCARD", math.MaxInt64, "a"}, {"CMS.MERGE", "c", math.MaxInt64, "a"}} {
+		if _, err := c.CommandGetKeys(args...); err == nil {
+			t.Errorf("COMMAND GETKEYS %v: expected an error for a numkeys past the arguments", args)
+		}
+	}
+
+	// Arity is checked before any handler runs.
+	for _, args := range [][]any{{"GET"}, {"GET", "a", "b"}, {"LPOP", "a", 1, 2}, {"MSET", "a", "1", "b"}, {"HSET", "h", "f"}} {
+		if err := c.Queue(args...); err == nil || !strings.Contains(err.Error(), "wrong number of arguments") {
+			t.Errorf("%v: got %v, expected an arity error", args, err)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	startServer(t, ":6402")
+
+	connect := func() client.IClient {
+		c, err := client.NewClient("localhost:6402")
+		if err != nil {
+			t.Fatalf("Failed to connect to server: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+	c, writer := connect(), connect()
+
+	for i := 0; i < 500; i++ {
+		if err := c.Set(fmt.Sprintf("scan:%d", i), i); err != nil {
+			t.Fatalf("SET failed: %v", err)
+		}
+	}
+	if _, err := c.HSet("scan:hash", map[string]any{"f": "v"}); err != nil {
+		t.Fatalf("HSET failed: %v", err)
+	}
+
+	// Keys added and removed during the walk must not hide the ones that
+	// stay put.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2000; i++ {
+			writer.Set(fmt.Sprintf("churn:%d", i), i)
+			if i%3 == 0 {
+				writer.Del(fmt.Sprintf("churn:%d", i/2))
+			}
+		}
+	}()
+
+	seen := make(map[string]bool)
+	cursor := 0
+	for {
+		keys, next, err := c.Scan(cursor, regexp.MustCompile("scan:*"), 20)
+		if err != nil {
+			t.Fatalf("SCAN failed: %v", err)
+		}
+		for _, key := range keys {
+			seen[key] = true
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	<-done
+	for i := 0; i < 500; i++ {
+		if !seen[fmt.Sprintf("scan:%d", i)] {
+			t.Errorf("SCAN never returned scan:%d", i)
+		}
+	}
+
+	var hashes []string
+	cursor = 0
+	for {
+		keys, next, err := c.ScanType(cursor, "", "hash", 100)
+		if err != nil {
+			t.Fatalf("SCAN TYPE failed: %v", err)
+		}
+		hashes = append(hashes, keys...)
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	if !slices.Equal(hashes, []string{"scan:hash"}) {
+		t.Errorf("SCAN TYPE hash: got %v", hashes)
+	}
+
+	if _, _, err := c.ScanType(0, "", "nosuchtype", 10); err == nil {
+		t.Errorf("SCAN with an unknown TYPE: expected an error")
+	}
+	if _, _, err := c.Scan(-1, nil, 10); err == nil {
+		t.Errorf("SCAN with a negative cursor: expected an error")
+	}
+	if keys, next, err := c.Scan(0, regexp.MustCompile("scan:*"), math.MaxInt); err != nil || next != 0 || len(keys) != 501 {
+		t.Errorf("SCAN with a huge COUNT: got %d keys, cursor %v, %v, expected all 501 in one page", len(keys), next, err)
+	}
+}
+
+func TestKeysPatterns(t *testing.T) {
+	startServer(t, ":6403")
+
+	c, err := client.NewClient("localhost:6403")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	for _, key := range []string{"hello", "hallo", "hxllo", "h.llo", "a+(b)", "star*", "user:1", "user:22"} {
+		if err := c.Set(key, 1); err != nil {
+			t.Fatalf("SET failed: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		pattern string
+		want    []string
+	}{
+		{"h?llo", []string{"h.llo", "hallo", "hello", "hxllo"}},
+		{"h[ae]llo", []string{"hallo", "hello"}},
+		{"h[^e]llo", []string{"h.llo", "hallo", "hxllo"}},
+		{"h[a-f]llo", []string{"hallo", "hello"}},
+		{"h.llo", []string{"h.llo"}},
+		{"a+(b)", []string{"a+(b)"}},
+		{"star\\*", []string{"star*"}},
+		{"user:?", []string{"user:1"}},
+		{"user:*", []string{"user:1", "user:22"}},
+		{"missing", nil},
+	} {
+		keys, err := c.Keys(tc.pattern)
+		if err != nil {
+			t.Fatalf("KEYS %s failed: %v", tc.pattern, err)
+		}
+		slices.Sort(keys)
+		if !slices.Equal(keys, tc.want) {
+			t.Errorf("KEYS %s: got %v, expected %v", tc.pattern, keys, tc.want)
+		}
+	}
+
+	keys, _, err := c.Scan(0, regexp.MustCompile(`h\.llo`), 100)
+	if err != nil || !slices.Equal(keys, []string{"h.llo"}) {
+		t.Errorf("SCAN MATCH h\\.llo: got %v, %v", keys, err)
+	}
+}
+
+func TestProbabilisticFilters(t *testing.T) {
+	s := startServer(t, ":6404")
+
+	c, err := client.NewClient("localhost:6404")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	// Bloom filters stack layers as they fill and never forget an item.
+	if err := c.BFReserve("seen", 0.01, 100); err != nil {
+		t.Fatalf("BF.RESERVE failed: %v", err)
+	}
+	if err := c.BFReserve("seen", 0.01, 100); err == nil || err.Error() != "ERR item exists" {
+		t.Errorf("BF.RESERVE on an existing key: got %v, expected ERR item exists", err)
+	}
+	if err := c.BFReserve("huge", 0.01, math.MaxInt64); err == nil {
+		t.Errorf("BF.RESERVE with a capacity past the limit: expected an error")
+	}
+	items := make([]any, 1000)
+	for i := range items {
+		items[i] = fmt.Sprintf("event:%d", i)
+	}
+	if _, err := c.BFMAdd("seen", items...); err != nil {
+		t.Fatalf("BF.MADD failed: %v", err)
+	}
+	if added, err := c.BFAdd("seen", "event:0"); err != nil || added {
+		t.Errorf("BF.ADD of a known item: got %v, %v, expected false", added, err)
+	}
+	found, err := c.BFMExists("seen", items...)
+	if err != nil || slices.Contains(found, false) {
+		t.Fatalf("BF.MEXISTS: an added item was reported missing (%v)", err)
+	}
+	falsePositives := 0
+	for i := 0; i < 1000; i++ {
+		if ok, _ := c.BFExists("seen", fmt.Sprintf("other:%d", i)); ok {
+			falsePositives++
+		}
+	}
+	if falsePositives > 20 {
+		t.Errorf("BF.EXISTS: %d false positives in 1000, expected about 10", falsePositives)
+	}
+	info, err := c.BFInfo("seen")
+	if err != nil || info["Number of filters"] < 2 || info["Expansion rate"] != 2 || info["Capacity"] < 1000 {
+		t.Errorf("BF.INFO: got %v, %v", info, err)
+	}
+	if ok, err := c.BFExists("nowhere", "x"); err != nil || ok {
+		t.Errorf("BF.EXISTS on a missing key: got %v, %v", ok, err)
+	}
+	if typ, _ := c.Type("seen"); typ != "MBbloom--" {
+		t.Errorf("TYPE of a bloom filter: got %q", typ)
+	}
+
+	// Cuckoo filters count and delete items.
+	if err := c.CFReserve("carts", 1000); err != nil {
+		t.Fatalf("CF.RESERVE failed: %v", err)
+	}
+	if err := c.CFReserve("huge", math.MaxInt64); err == nil {
+		t.Errorf("CF.RESERVE with a capacity past the limit: expected an error")
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.CFAdd("carts", "apple"); err != nil {
+			t.Fatalf("CF.ADD failed: %v", err)
+		}
+	}
+	if added, err := c.CFAddNX("carts", "apple"); err != nil || added {
+		t.Errorf("CF.ADDNX of a known item: got %v, %v, expected false", added, err)
+	}
+	if n, err := c.CFCount("carts", "apple"); err != nil || n != 3 {
+		t.Errorf("CF.COUNT: got %v, %v, expected 3", n, err)
+	}
+	if deleted, err := c.CFDel("carts", "apple"); err != nil || !deleted {
+		t.Errorf("CF.DEL: got %v, %v, expected true", deleted, err)
+	}
+	if n, _ := c.CFCount("carts", "apple"); n != 2 {
+		t.Errorf("CF.COUNT after CF.DEL: got %v, expected 2", n)
+	}
+	if deleted, err := c.CFDel("carts", "pear"); err != nil || deleted {
+		t.Errorf("CF.DEL of a missing item: got %v, %v, expected false", deleted, err)
+	}
+	if _, err := c.CFDel("nowhere", "pear"); err == nil {
+		t.Errorf("CF.DEL on a missing key: expected an error")
+	}
+	info, err = c.CFInfo("carts")
+	if err != nil || info["Number of items inserted"] != 2 || info["Number of items deleted"] != 1 || info["Bucket size"] != 2 {
+		t.Errorf("CF.INFO: got %v, %v", info, err)
+	}
+
+	// Both survive a snapshot.
+	restored := s.reload(t)
+	found, err = restored.BFExists("seen", []string{"event:0", "event:999"})
+	if err != nil || !found[0] || !found[1] {
+		t.Errorf("BF.EXISTS after reload: got %v, %v", found, err)
+	}
+	if n, err := restored.CFCount("carts", "apple"); err != nil || n != 2 {
+		t.Errorf("CF.COUNT after reload: got %v, %v, expected 2", n, err)
+	}
+}
+
+func TestSketches(t *testing.T) {
+	s := startServer(t, ":6405")
+
+	c, err := client.NewClient("localhost:6405")
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer c.Close()
+
+	// Count-Min Sketches never undercount, and merge by adding counters.
+	for _, key := range []string{"clicks:a", "clicks:b", "clicks:all"} {
+		if err := c.CMSInitByDim(key, 2000, 5); err != nil {
+			t.Fatalf("CMS.INITBYDIM %s failed: %v", key, err)
+		}
+	}
+	if err := c.CMSInitByDim("clicks:a", 2000, 5); err == nil {
+		t.Errorf("CMS.INITBYDIM on an existing key: expected an error")
+	}
+	for i := 0; i < 500; i++ {
+		if _, err := c.CMSIncrBy("clicks:a", fmt.Sprintf("page:%d", i), int64(i%10+1)); err != nil {
+			t.Fatalf("CMS.INCRBY failed: %v", err)
+		}
+	}
+	if n, err := c.CMSIncrBy("clicks:b", "page:7", 100); err != nil || n != 100 {
+		t.Errorf("CMS.INCRBY: got %v, %v, expected 100", n, err)
+	}
+	if err := c.CMSMerge("clicks:all", []string{"clicks:a", "clicks:b"}, []int64{1, 2}); err != nil {
+		t.Fatalf("CMS.MERGE failed: %v", err)
+	}
+	counts, err := c.CMSQuery("clicks:all", "page:7", "page:3")
+	if err != nil || len(counts) != 2 || counts[0] < 208 || counts[0] > 215 || counts[1] < 4 || counts[1] > 10 {
+		t.Errorf("CMS.QUERY after CMS.MERGE: got %v, %v, expected about [208 4]", counts, err)
+	}
+	if err := c.CMSInitByDim("narrow", 10, 5); err != nil {
+		t.Fatalf("CMS.INITBYDIM failed: %v", err)
+	}
+	if err := c.CMSMerge("clicks:all", []string{"clicks:a", "narrow"}, nil); err == nil {
+		t.Errorf("CMS.MERGE of sketches of different sizes: expected an error")
+	}
+	if err := c.CMSInitByDim("huge", 1<<32, 1<<32); err == nil {
+		t.Errorf("CMS.INITBYDIM with width*depth past the limit: expected an error")
+	}
+	for i := 0; i < 2; i++ {
+		if n, err := c.CMSIncrBy("narrow", "x", math.MaxInt64); err != nil || n != math.MaxUint32 {
+			t.Errorf("CMS.INCRBY past the counter limit: got %v, %v, expected it to saturate", n, err)
+		}
+	}
+
+	// Top-K keeps the heavy hitters.
+	if err := c.TopKReserve("top", 3); err != nil {
+		t.Fatalf("TOPK.RESERVE failed: %v", err)
+	}
+	if err := c.TopKReserve("top:huge", 1<<40); err == nil {
+		t.Errorf("TOPK.RESERVE with k past the limit: expected an error")
+	}
+	for i := 0; i < 2000; i++ {
+		item := fmt.Sprintf("noise:%d", i)
+		switch {
+		case i%4 == 0:
+			item = "heavy:1"
+		case i%6 == 1:
+			item = "heavy:2"
+		case i%10 == 3:
+			item = "heavy:3"
+		}
+		if _, err := c.TopKAdd("top", item); err != nil {
+			t.Fatalf("TOPK.ADD failed: %v", err)
+		}
+	}
+	top, err := c.TopKList("top")
+	if err != nil || !slices.Equal(top, []string{"heavy:1", "heavy:2", "heavy:3"}) {
+		t.Errorf("TOPK.LIST: got %v, %v", top, err)
+	}
+
+	// t-digests estimate quantiles, and merge across keys.
+	for _, key := range []string{"latency:a", "latency:b"} {
+		if err := c.TDigestCreate(key, 0); err != nil {
+			t.Fatalf("TDIGEST.CREATE %s failed: %v", key, err)
+		}
+	}
+	var a, b []float64
+	for i := 1; i <= 1000; i++ {
+		if i%2 == 0 {
+			a = append(a, float64(i))
+		} else {
+			b = append(b, float64(i))
+		}
+	}
+	if err := c.TDigestAdd("latency:a", a...); err != nil {
+		t.Fatalf("TDIGEST.ADD failed: %v", err)
+	}
+	if err := c.TDigestAdd("latency:b", b...); err != nil {
+		t.Fatalf("TDIGEST.ADD failed: %v", err)
+	}
+	if err := c.TDigestMerge("latency", "latency:a", "latency:b"); err != nil {
+		t.Fatalf("TDIGEST.MERGE failed: %v", err)
+	}
+	quantiles, err := c.TDigestQuantile("latency", 0, 0.5, 0.99, 1)
+	if err != nil || len(quantiles) != 4 || quantiles[0] != 1 || math.Abs(quantiles[1]-500) > 10 ||
+		math.Abs(quantiles[2]-990) > 5 || quantiles[3] != 1000 {
+		t.Errorf("TDIGEST.QUANTILE: got %v, %v", quantiles, err)
+	}
+	shares, err := c.TDigestCDF("latency", 0, 250, 2000)
+	if err != nil || len(shares) != 3 || shares[0] != 0 || math.Abs(shares[1]-0.25) > 0.01 || shares[2] != 1 {
+		t.Errorf("TDIGEST.CDF: got %v, %v", shares, err)
+	}
+	if err := c.TDigestCreate("empty", 0); err != nil {
+		t.Fatalf("TDIGEST.CREATE failed: %v", err)
+	}
+	if quantiles, err := c.TDigestQuantile("empty", 0.5); err != nil || !math.IsNaN(quantiles[0]) {
+		t.Errorf("TDIGEST.QUANTILE of an empty digest: got %v, %v, expected nan", quantiles, err)
+	}
+	if _, err := c.TDigestQuantile("latency", 1.5); err == nil {
+		t.Errorf("TDIGEST.QUANTILE out of range: expected an error")
+	}
+
+	// All three survive a snapshot.
+	restored := s.reload(t)
+	if got, err := restored.CMSQuery("clicks:all", []string{"page:7"}); err != nil || got[0] != counts[0] {
+		t.Errorf("CMS.QUERY after reload: got %v, %v, expected %v", got, err, counts[0])
+	}
+	if items, err := restored.TopKList("top"); err != nil || len(items) != 3 || items[0].Item != "heavy:1" {
+		t.Errorf("TOPK.LIST after reload: got %v, %v", items, err)
+	}
+	if got, err := restored.TDigestQuantile("latency", []float64{0.99}); err != nil || got[0] != quantiles[2] {
+		t.Errorf("TDIGEST.QUANTILE after reload: got %v, %v, expected %v", got, err, quantiles[2])
+	}
+}